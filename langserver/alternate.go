@@ -0,0 +1,92 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleAlternate handles `bingo/alternate` requests. On an interface, or on a
+// method of an interface, it returns the implementation if there is exactly
+// one. Elsewhere it returns the file paired with the document: foo_test.go
+// for foo.go, and foo.go for foo_test.go. The result is null if there is
+// none.
+func (h *LangHandler) handleAlternate(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*lsp.Location, error) {
+	if err := checkFileURI(params.TextDocument.URI); err != nil {
+		return nil, err
+	}
+
+	// The file pair does not need the type information, the documents of an
+	// ill typed package still have one.
+	if pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position); err == nil {
+		pathNodes, _ := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+		pathNodes, action := findInterestingNode(pkg, pathNodes)
+		var obj types.Object
+		if id, ok := pathNodes[0].(*ast.Ident); ok {
+			obj = pkg.GetTypesInfo().ObjectOf(id)
+		}
+		if isInterfaceObject(obj) {
+			locs, err := implements(ctx, h.project, h.overlay.columns, pkg, pathNodes, action, h.config.ImplementationStdlib, false)
+			if err != nil {
+				return nil, err
+			}
+			// The package is also loaded with its tests, the same
+			// implementation may be found several times, and the
+			// interface itself as it is not identical to its copy.
+			found := make(map[lsp.Location]bool)
+			for _, loc := range filterImplementations(locs, implementationTo) {
+				found[loc.Location] = true
+			}
+			delete(found, h.overlay.columns.goRangeToLSPLocation(pkg.GetFileSet(), obj.Pos(), obj.Name()))
+			if len(found) != 1 {
+				return nil, nil
+			}
+			for loc := range found {
+				return &loc, nil
+			}
+		}
+	}
+
+	filename := alternateFile(util.UriToRealPath(params.TextDocument.URI))
+	if filename == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return nil, nil
+	}
+	return &lsp.Location{URI: util.PathToURI(filename)}, nil
+}
+
+// isInterfaceObject reports whether obj is an interface type or a method of
+// an interface.
+func isInterfaceObject(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		return isInterface(obj.Type())
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		return recv != nil && isInterface(recv.Type())
+	}
+	return false
+}
+
+// alternateFile returns the name of the test file of the Go file filename, or
+// the name of the file tested by the test file filename. It returns "" if
+// filename is not a Go file.
+func alternateFile(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, "_test.go"):
+		return strings.TrimSuffix(filename, "_test.go") + ".go"
+	case filepath.Ext(filename) == ".go":
+		return strings.TrimSuffix(filename, ".go") + "_test.go"
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleAPISurface handles `bingo/apiSurface` requests. It returns the
+// exported declarations of the package of the requested document, one per
+// line in a stable order.
+func (h *LangHandler) handleAPISurface(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params APISurfaceParams) (string, error) {
+	if err := checkFileURI(params.TextDocument.URI); err != nil {
+		return "", err
+	}
+
+	pkg, _, err := h.project.TypeCheck(ctx, params.TextDocument.URI)
+	if err != nil {
+		return "", err
+	}
+
+	if pkg.IsIllTyped() {
+		return "", fmt.Errorf("package for %s is ill typed", params.TextDocument.URI)
+	}
+
+	lines := source.APISurface(pkg)
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
@@ -12,12 +12,12 @@ import (
 	"github.com/sourcegraph/go-lsp"
 )
 
-func rangeForNode(fset *token.FileSet, node ast.Node) lsp.Range {
+func (m *columnMapper) rangeForNode(fset *token.FileSet, node ast.Node) lsp.Range {
 	start := fset.Position(node.Pos())
 	end := fset.Position(node.End()) // node.End is exclusive, and so is the LSP spec
 	return lsp.Range{
-		Start: lsp.Position{Line: start.Line - 1, Character: start.Column - 1},
-		End:   lsp.Position{Line: end.Line - 1, Character: end.Column - 1},
+		Start: m.position(start),
+		End:   m.position(end),
 	}
 }
 
@@ -26,9 +26,11 @@ type fakeNode struct{ p, e token.Pos }
 func (n fakeNode) Pos() token.Pos { return n.p }
 func (n fakeNode) End() token.Pos { return n.e }
 
-// goRangeToLSPLocation converts a token.Pos range into a lsp.Location. end is
-// exclusive.
-func goRangeToLSPLocation(fSet *token.FileSet, pos token.Pos, name string) lsp.Location {
+// goRangeToLSPLocation returns the location of the identifier name at pos. Its
+// end is pos plus the length of name in bytes, which the column mapper
+// converts to the code units of the position encoding like the start, so that
+// it is exact for the non-ASCII names too.
+func (m *columnMapper) goRangeToLSPLocation(fSet *token.FileSet, pos token.Pos, name string) lsp.Location {
 	filename := fSet.Position(pos).Filename
 	if filename == "" {
 		// for builtin symbol
@@ -38,19 +40,19 @@ func goRangeToLSPLocation(fSet *token.FileSet, pos token.Pos, name string) lsp.L
 
 	return lsp.Location{
 		URI:   lsp.DocumentURI(source.ToURI(filename)),
-		Range: objToRange(fSet, pos, name),
+		Range: m.objToRange(fSet, pos, name),
 	}
 }
 
-func createLocationFromRange(fSet *token.FileSet, pos token.Pos, end token.Pos) lsp.Location {
+func (m *columnMapper) createLocationFromRange(fSet *token.FileSet, pos token.Pos, end token.Pos) lsp.Location {
 	return lsp.Location{
 		URI:   lsp.DocumentURI(source.ToURI(fSet.Position(pos).Filename)),
-		Range: rangeForNode(fSet, fakeNode{p: pos, e: end}),
+		Range: m.rangeForNode(fSet, fakeNode{p: pos, e: end}),
 	}
 }
 
 // objToRange please reference https://go-review.googlesource.com/c/tools/+/150044
-func objToRange(fSet *token.FileSet, p token.Pos, name string) lsp.Range {
+func (m *columnMapper) objToRange(fSet *token.FileSet, p token.Pos, name string) lsp.Range {
 	f := fSet.File(p)
 	pos := f.Position(p)
 	if pos.Column == 1 {
@@ -71,7 +73,7 @@ func objToRange(fSet *token.FileSet, p token.Pos, name string) lsp.Range {
 		}
 	}
 
-	return rangeForNode(fSet, fakeNode{p: p, e: p + token.Pos(len([]byte(name)))})
+	return m.rangeForNode(fSet, fakeNode{p: p, e: p + token.Pos(len([]byte(name)))})
 }
 
 type action int
@@ -257,6 +259,11 @@ func findInterestingNode(pkg source.Package, path []ast.Node) ([]ast.Node, actio
 				// Interface.Methods         -- field
 				// FuncType.{Params.Results} -- actionExpr
 				// FuncDecl.Recv             -- actionExpr
+				//
+				// The names of the fields only lack an object in
+				// malformed code, eg. "func (", and the field would
+				// descend to its sole name again.
+				return path, actionUnknown
 
 			case *ast.File:
 				// 'package foo'
@@ -0,0 +1,307 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// CallHierarchyItem is a function or method of the call hierarchy.
+type CallHierarchyItem struct {
+	Name string         `json:"name"`
+	Kind lsp.SymbolKind `json:"kind"`
+
+	// Detail is the import path of the package of the function.
+	Detail string          `json:"detail,omitempty"`
+	URI    lsp.DocumentURI `json:"uri"`
+
+	// Range encloses the declaration of the function, SelectionRange its
+	// name.
+	Range          lsp.Range `json:"range"`
+	SelectionRange lsp.Range `json:"selectionRange"`
+}
+
+// CallHierarchyCallsParams are the parameters of the callHierarchy/incomingCalls
+// and callHierarchy/outgoingCalls requests.
+type CallHierarchyCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a function calling the requested item at
+// FromRanges.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []lsp.Range       `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCall is a function called by the requested item at
+// FromRanges.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []lsp.Range       `json:"fromRanges"`
+}
+
+// handlePrepareCallHierarchy returns the function or method at the position,
+// either its declaration or a call to it.
+func (h *LangHandler) handlePrepareCallHierarchy(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]CallHierarchyItem, error) {
+	pkg, fn, err := h.lookupFunc(ctx, params.TextDocument.URI, params.Position)
+	if err != nil {
+		if isNoResult(err) {
+			return []CallHierarchyItem{}, nil
+		}
+		return nil, err
+	}
+	if fn == nil {
+		return []CallHierarchyItem{}, nil
+	}
+
+	return []CallHierarchyItem{newCallHierarchyItem(pkg.GetFileSet(), h.overlay.columns, fn, funcDecl(pkg, fn))}, nil
+}
+
+// handleIncomingCalls returns the functions calling the item, with the
+// positions of the calls. Calls outside of a function, eg. in the
+// initializer of a package level variable, are not reported.
+func (h *LangHandler) handleIncomingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params CallHierarchyCallsParams) ([]CallHierarchyIncomingCall, error) {
+	pkg, fn, err := h.lookupFunc(ctx, params.Item.URI, params.Item.SelectionRange.Start)
+	if err != nil {
+		return nil, err
+	}
+	if fn == nil {
+		return []CallHierarchyIncomingCall{}, nil
+	}
+
+	var mu sync.Mutex
+	calls := []CallHierarchyIncomingCall{}
+	index := make(map[lsp.Location]int)
+	columns := h.overlay.columns.cached()
+	// collect is called concurrently by the workers of the search.
+	collect := func(refPkg source.Package, refs []*ast.Ident) {
+		mu.Lock()
+		defer mu.Unlock()
+		fset := refPkg.GetFileSet()
+		for _, ref := range refs {
+			decl := enclosingCallerDecl(refPkg, ref)
+			if decl == nil {
+				continue
+			}
+			caller, ok := refPkg.GetTypesInfo().Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+
+			item := newCallHierarchyItem(fset, columns, caller, decl)
+			key := lsp.Location{URI: item.URI, Range: item.SelectionRange}
+			i, ok := index[key]
+			if !ok {
+				i = len(calls)
+				index[key] = i
+				calls = append(calls, CallHierarchyIncomingCall{From: item})
+			}
+			calls[i].FromRanges = appendRange(calls[i].FromRanges, columns.goRangeToLSPLocation(fset, ref.Pos(), ref.Name).Range)
+		}
+	}
+
+	if _, err := h.findReferences(ctx, pkg, fn, false, false, collect); err != nil {
+		return nil, err
+	}
+
+	// The references are found in no particular order.
+	for _, call := range calls {
+		sortRanges(call.FromRanges)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i].From, calls[j].From
+		return lessLocation(lsp.Location{URI: a.URI, Range: a.SelectionRange}, lsp.Location{URI: b.URI, Range: b.SelectionRange})
+	})
+	return calls, nil
+}
+
+// handleOutgoingCalls returns the functions and methods called in the body of
+// the item, with the positions of the calls.
+func (h *LangHandler) handleOutgoingCalls(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params CallHierarchyCallsParams) ([]CallHierarchyOutgoingCall, error) {
+	pkg, fn, err := h.lookupFunc(ctx, params.Item.URI, params.Item.SelectionRange.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	calls := []CallHierarchyOutgoingCall{}
+	if fn == nil {
+		return calls, nil
+	}
+	decl := funcDecl(pkg, fn)
+	if decl == nil || decl.Body == nil {
+		return calls, nil
+	}
+
+	fset := pkg.GetFileSet()
+	index := make(map[*types.Func]int)
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var ident *ast.Ident
+		switch fun := astutil.Unparen(call.Fun).(type) {
+		case *ast.Ident:
+			ident = fun
+		case *ast.SelectorExpr:
+			ident = fun.Sel
+		default:
+			return true
+		}
+		// Conversions, builtins and calls of function values are skipped.
+		callee, ok := pkg.GetTypesInfo().Uses[ident].(*types.Func)
+		if !ok || callee.Pkg() == nil {
+			return true
+		}
+
+		i, ok := index[callee]
+		if !ok {
+			i = len(calls)
+			index[callee] = i
+			calls = append(calls, CallHierarchyOutgoingCall{To: newCallHierarchyItem(fset, h.overlay.columns, callee, funcDecl(pkg, callee))})
+		}
+		calls[i].FromRanges = appendRange(calls[i].FromRanges, h.overlay.columns.goRangeToLSPLocation(fset, ident.Pos(), ident.Name).Range)
+		return true
+	})
+	return calls, nil
+}
+
+// lookupFunc returns the function or method at the position of the document,
+// it returns a nil function if there is another object.
+func (h *LangHandler) lookupFunc(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) (source.Package, *types.Func, error) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node := pathNodes[0]
+	if decl, ok := node.(*ast.FuncDecl); ok {
+		node = decl.Name
+	}
+	ident := definitionIdent(node)
+	if ident == nil {
+		return nil, nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	}
+
+	fn, ok := source.FindIdentObject(pkg, ident).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return pkg, nil, nil
+	}
+	return pkg, fn, nil
+}
+
+// funcDecl returns the declaration of fn in pkg or in the imported package
+// declaring it, or nil if it is not found, eg. for an interface method.
+func funcDecl(pkg source.Package, fn *types.Func) *ast.FuncDecl {
+	declPkg := pkg
+	if fn.Pkg().Path() != pkg.GetPkgPath() {
+		declPkg = pkg.GetImport(fn.Pkg().Path())
+		if declPkg == nil {
+			return nil
+		}
+	}
+
+	for _, file := range declPkg.GetSyntax() {
+		if fn.Pos() < file.Pos() || fn.Pos() > file.End() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Pos() == fn.Pos() {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingCallerDecl returns the declaration of the function enclosing ref,
+// if ref is the called function of a call expression.
+func enclosingCallerDecl(pkg source.Package, ref *ast.Ident) *ast.FuncDecl {
+	for _, file := range pkg.GetSyntax() {
+		if ref.Pos() < file.Pos() || ref.Pos() > file.End() {
+			continue
+		}
+
+		path, _ := astutil.PathEnclosingInterval(file, ref.Pos(), ref.End())
+		if len(path) < 2 {
+			return nil
+		}
+		i := 1
+		if sel, ok := path[i].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+			i++
+		}
+		for i < len(path) {
+			if _, ok := path[i].(*ast.ParenExpr); !ok {
+				break
+			}
+			i++
+		}
+		if i >= len(path) {
+			return nil
+		}
+		call, ok := path[i].(*ast.CallExpr)
+		if !ok || call.Fun != path[i-1] {
+			return nil
+		}
+
+		for _, node := range path[i:] {
+			if decl, ok := node.(*ast.FuncDecl); ok {
+				return decl
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func newCallHierarchyItem(fset *token.FileSet, columns *columnMapper, fn *types.Func, decl *ast.FuncDecl) CallHierarchyItem {
+	kind := lsp.SKFunction
+	if fn.Type().(*types.Signature).Recv() != nil {
+		kind = lsp.SKMethod
+	}
+
+	loc := columns.goRangeToLSPLocation(fset, fn.Pos(), fn.Name())
+	item := CallHierarchyItem{
+		Name:           fn.Name(),
+		Kind:           kind,
+		Detail:         fn.Pkg().Path(),
+		URI:            loc.URI,
+		Range:          loc.Range,
+		SelectionRange: loc.Range,
+	}
+	if decl != nil {
+		item.Range = columns.rangeForNode(fset, decl)
+	}
+	return item
+}
+
+func sortRanges(ranges []lsp.Range) {
+	sort.Slice(ranges, func(i, j int) bool {
+		return lessLocation(lsp.Location{Range: ranges[i]}, lsp.Location{Range: ranges[j]})
+	})
+}
+
+// appendRange appends r to ranges unless it is already there, the references
+// of the xtest packages may be found twice.
+func appendRange(ranges []lsp.Range, r lsp.Range) []lsp.Range {
+	for _, other := range ranges {
+		if other == r {
+			return ranges
+		}
+	}
+	return append(ranges, r)
+}
@@ -52,3 +52,25 @@ func (c *cancel) Cancel(id jsonrpc2.ID) {
 		cancel()
 	}
 }
+
+// cancelCheckInterval is the number of iterations of a hot loop between two
+// checks of the cancellation of a request.
+const cancelCheckInterval = 256
+
+// cancelChecker checks the cancellation of a request in a hot loop, eg. over
+// the uses of a large package, so that a cancelled request stops promptly
+// without paying for ctx.Err at each iteration.
+type cancelChecker struct {
+	ctx context.Context
+	n   int
+}
+
+// err returns the error of ctx every cancelCheckInterval calls, nil
+// otherwise.
+func (c *cancelChecker) err() error {
+	c.n++
+	if c.n%cancelCheckInterval != 0 {
+		return nil
+	}
+	return c.ctx.Err()
+}
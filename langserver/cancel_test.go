@@ -44,3 +44,20 @@ func TestCancel(t *testing.T) {
 	// should just be a noop.
 	c.Cancel(id3)
 }
+
+func TestCancelChecker(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	checker := cancelChecker{ctx: ctx}
+	for i := 0; i < 2*cancelCheckInterval; i++ {
+		require.NoError(checker.err())
+	}
+
+	cancel()
+	for i := 1; i < cancelCheckInterval; i++ {
+		require.NoError(checker.err(), "the cancellation is checked every %d calls", cancelCheckInterval)
+	}
+	require.Equal(context.Canceled, checker.err())
+}
@@ -3,6 +3,7 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"go/token"
 
 	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -23,44 +24,137 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 		return []protocol.CodeAction{}, nil
 	}
 
-	edits, err := organizeImports(ctx, h.View(), fileURI)
+	edits, err := organizeImports(ctx, h.View(), h.overlay.columns, fileURI, h.config.ImportsLocalPrefix)
 	if err != nil {
 		return nil, err
 	}
-	return []protocol.CodeAction{
-		{
-			Title: "Organize Imports",
-			Kind:  protocol.SourceOrganizeImports,
-			Edit: lsp.WorkspaceEdit{
-				Changes: map[string][]lsp.TextEdit{
-					string(params.TextDocument.URI): edits,
-				},
+	actions := []protocol.CodeAction{
+		editAction("Organize Imports", protocol.SourceOrganizeImports, fileURI, edits),
+	}
+
+	// The unused imports are removed by organizing the imports.
+	var unused []lsp.Diagnostic
+	for _, diag := range params.Context.Diagnostics {
+		if isUnusedImportError(diag.Message) {
+			unused = append(unused, diag)
+		}
+	}
+	if len(unused) > 0 && len(edits) > 0 {
+		action := editAction("Remove unused imports", protocol.QuickFix, fileURI, edits)
+		action.Diagnostics = unused
+		actions = append(actions, action)
+	}
+
+	edits, err = fillStruct(ctx, h.View(), h.overlay.columns, fileURI, params.Range)
+	if err == nil && len(edits) > 0 {
+		actions = append(actions, editAction("Fill struct", protocol.RefactorRewrite, fileURI, edits))
+	}
+
+	edits, tok, err := convertVarConst(ctx, h.View(), h.overlay.columns, fileURI, params.Range)
+	if err == nil && len(edits) > 0 {
+		actions = append(actions, editAction(fmt.Sprintf("Convert to %s", tok), protocol.RefactorRewrite, fileURI, edits))
+	}
+
+	edits, toPointer, broken, err := convertReceiver(ctx, h.View(), h.overlay.columns, fileURI, params.Range)
+	if err == nil && len(edits) > 0 {
+		title := "Convert to value receiver"
+		if toPointer {
+			title = "Convert to pointer receiver"
+		}
+		if broken > 0 {
+			title += fmt.Sprintf(" (breaks %d uses)", broken)
+		}
+		actions = append(actions, editAction(title, protocol.RefactorRewrite, fileURI, edits))
+	}
+
+	return actions, nil
+}
+
+// editAction returns the code action of kind applying the edits to the file
+// of uri.
+func editAction(title string, kind protocol.CodeActionKind, uri lsp.DocumentURI, edits []lsp.TextEdit) protocol.CodeAction {
+	return protocol.CodeAction{
+		Title: title,
+		Kind:  kind,
+		Edit: lsp.WorkspaceEdit{
+			Changes: map[string][]lsp.TextEdit{
+				string(uri): edits,
 			},
 		},
-	}, nil
+	}
 }
 
-func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([]lsp.TextEdit, error) {
+// codeActionFile returns the file of uri in the view v and its token file.
+func codeActionFile(ctx context.Context, v source.View, uri lsp.DocumentURI) (source.File, *token.File, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	f, err := v.GetFile(ctx, sourceURI)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	tok := f.GetToken(ctx)
 	if tok == nil {
-		return nil, fmt.Errorf("token file does not exist for file %s", uri)
+		return nil, nil, fmt.Errorf("token file does not exist for file %s", uri)
+	}
+	return f, tok, nil
+}
+
+func organizeImports(ctx context.Context, v source.View, columns *columnMapper, uri lsp.DocumentURI, localPrefix string) ([]lsp.TextEdit, error) {
+	f, tok, err := codeActionFile(ctx, v, uri)
+	if err != nil {
+		return nil, err
 	}
 
 	r := span.Range{
 		Start: tok.Pos(0),
 		End:   tok.Pos(tok.Size()),
 	}
-	edits, err := source.Imports(ctx, f, r)
+	edits, err := source.Imports(ctx, f, r, localPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(ctx, columns, f, edits), nil
+}
+
+func fillStruct(ctx context.Context, v source.View, columns *columnMapper, uri lsp.DocumentURI, rng lsp.Range) ([]lsp.TextEdit, error) {
+	f, tok, err := codeActionFile(ctx, v, uri)
 	if err != nil {
 		return nil, err
 	}
-	return toProtocolEdits(ctx, f, edits), nil
+
+	edits, err := source.FillStruct(ctx, f, columns.fromProtocolRange(tok, rng))
+	if err != nil {
+		return nil, err
+	}
+	return toProtocolEdits(ctx, columns, f, edits), nil
+}
+
+func convertVarConst(ctx context.Context, v source.View, columns *columnMapper, uri lsp.DocumentURI, rng lsp.Range) ([]lsp.TextEdit, token.Token, error) {
+	f, tok, err := codeActionFile(ctx, v, uri)
+	if err != nil {
+		return nil, token.ILLEGAL, err
+	}
+
+	edits, newTok, err := source.ConvertVarConst(ctx, f, columns.fromProtocolRange(tok, rng))
+	if err != nil {
+		return nil, token.ILLEGAL, err
+	}
+	return toProtocolEdits(ctx, columns, f, edits), newTok, nil
+}
+
+// convertReceiver returns the edits of source.ConvertReceiver, whether the
+// receiver becomes a pointer and the number of uses it breaks.
+func convertReceiver(ctx context.Context, v source.View, columns *columnMapper, uri lsp.DocumentURI, rng lsp.Range) ([]lsp.TextEdit, bool, int, error) {
+	f, tok, err := codeActionFile(ctx, v, uri)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	edits, toPointer, broken, err := source.ConvertReceiver(ctx, f, columns.fromProtocolRange(tok, rng))
+	if err != nil {
+		return nil, false, 0, err
+	}
+	return toProtocolEdits(ctx, columns, f, edits), toPointer, len(broken), nil
 }
@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"go/types"
+	"log"
 	"sort"
 	"strings"
 
@@ -17,7 +19,43 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*lsp.CompletionList, error) {
+// completionList is a lsp.CompletionList whose items may import a package.
+type completionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []completionItem `json:"items"`
+}
+
+// completionItem is a lsp.CompletionItem with the additionalTextEdits the
+// version of go-lsp used does not support.
+type completionItem struct {
+	lsp.CompletionItem
+
+	// AdditionalTextEdits are applied with the completion, eg. to import the
+	// package of the item.
+	AdditionalTextEdits []lsp.TextEdit `json:"additionalTextEdits,omitempty"`
+
+	// Data identifies the object of the item for completionItem/resolve.
+	Data *completionData `json:"data,omitempty"`
+}
+
+// completionData identifies the object of a completion item by the position
+// of its declaration, so that completionItem/resolve can find it again
+// without keeping the candidates of the completion.
+type completionData struct {
+	// URI is the document completed.
+	URI lsp.DocumentURI `json:"uri"`
+
+	// PkgPath is the path of the package of the object.
+	PkgPath string `json:"pkgPath"`
+
+	// Name, Filename and Offset are the name of the object and the
+	// position of its declaration.
+	Name     string `json:"name"`
+	Filename string `json:"filename"`
+	Offset   int    `json:"offset"`
+}
+
+func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*completionList, error) {
 	fileURI := params.TextDocument.URI
 	if err := checkFileURI(fileURI); err != nil {
 		return nil, nil
@@ -32,8 +70,9 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", fileURI))
 	}
 
-	pos := fromProtocolPosition(tok, params.Position)
-	items, prefix, err := source.Completion(ctx, f, pos, h.project.Cache())
+	pos := h.overlay.columns.fromProtocolPosition(tok, params.Position)
+	// The detail of the items is resolved later if the client can.
+	items, prefix, err := source.Completion(ctx, f, pos, h.project.Cache(), !h.clientResolvesDetail())
 	if err != nil {
 		return nil, err
 	}
@@ -43,17 +82,152 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 	}
 
 	useSnippets := h.clientSupportsSnippets() && !h.config.DisableFuncSnippet
-	result := &lsp.CompletionList{
+	result := &completionList{
 		IsIncomplete: false,
-		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false),
+		Items:        toProtocolCompletionItems(items, prefix, params.Position, h.overlay.columns.encoding, useSnippets, false, h.importEdits(ctx, f), completionDataFunc(fileURI, f.GetPackage(ctx))),
 	}
 	return result, nil
 }
 
+// completionDataFunc returns a function returning the data resolving the
+// object of a candidate completed in the document uri of pkg, nil if it has
+// no declaration, eg. a builtin, or if it is a package name, whose detail is
+// its path.
+func completionDataFunc(uri lsp.DocumentURI, pkg source.Package) func(source.CompletionItem) *completionData {
+	return func(candidate source.CompletionItem) *completionData {
+		obj := candidate.Object
+		if obj == nil || obj.Pkg() == nil || !obj.Pos().IsValid() {
+			return nil
+		}
+		if _, ok := obj.(*types.PkgName); ok {
+			return nil
+		}
+		pos := pkg.GetFileSet().Position(obj.Pos())
+		if pos.Filename == "" {
+			return nil
+		}
+		return &completionData{URI: uri, PkgPath: obj.Pkg().Path(), Name: obj.Name(), Filename: pos.Filename, Offset: pos.Offset}
+	}
+}
+
+// handleCompletionResolve handles completionItem/resolve requests. The
+// completion returns the items without their documentation, and without
+// their detail if the client resolves it, which are costly when there are
+// hundreds of candidates after a ".", the item resolved gets the declaration
+// of its object as detail and its doc comment as documentation.
+func (h *LangHandler) handleCompletionResolve(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params completionItem) (*completionItem, error) {
+	data := params.Data
+	if data == nil {
+		return &params, nil
+	}
+	if err := checkFileURI(data.URI); err != nil {
+		return nil, err
+	}
+
+	pkg, _, err := h.project.TypeCheck(ctx, data.URI)
+	if err != nil {
+		return nil, err
+	}
+	declPkg := pkg
+	if data.PkgPath != pkg.GetPkgPath() {
+		// The object may be declared in a package the document does not
+		// import directly, eg. a promoted field, or at all, eg. a member of an
+		// unimported package.
+		if declPkg = pkg.GetImport(data.PkgPath); declPkg == nil {
+			if declPkg = h.project.GetFromPkgPath(data.PkgPath); declPkg == nil {
+				declPkg = h.project.GetStdlibPackage(data.PkgPath)
+			}
+		}
+	}
+	obj := lookupDeclaration(declPkg, data)
+	if obj == nil {
+		return &params, nil
+	}
+
+	qf := func(p *types.Package) string {
+		if p.Path() == pkg.GetPkgPath() {
+			return ""
+		}
+		return p.Name()
+	}
+	if h.clientResolvesDetail() {
+		// The detail may already name the package to import.
+		params.Detail = strings.TrimSpace(types.ObjectString(obj, qf) + " " + params.Detail)
+	}
+
+	comments, err := source.FindComments(declPkg, declPkg.GetFileSet(), obj, obj.Name())
+	if err != nil || comments == "" {
+		comments = h.findStdlibComments(obj, obj.Name())
+	}
+	params.Documentation = comments
+	return &params, nil
+}
+
+// lookupDeclaration returns the object of pkg declared at the position of
+// data, nil if it is not found, eg. if the file changed since the
+// completion.
+func lookupDeclaration(pkg source.Package, data *completionData) types.Object {
+	if pkg == nil || pkg.GetTypesInfo() == nil {
+		return nil
+	}
+	for id, obj := range pkg.GetTypesInfo().Defs {
+		if obj == nil || id.Name != data.Name {
+			continue
+		}
+		if pos := pkg.GetFileSet().Position(id.Pos()); pos.Filename == data.Filename && pos.Offset == data.Offset {
+			return obj
+		}
+	}
+	return nil
+}
+
+// importEdits returns a function returning the edits importing the package
+// of a candidate in the file f, if it is found in a package f does not import.
+// The edits are computed once per package.
+func (h *LangHandler) importEdits(ctx context.Context, f source.File) func(source.CompletionItem) []lsp.TextEdit {
+	edits := make(map[string][]lsp.TextEdit)
+	return func(candidate source.CompletionItem) []lsp.TextEdit {
+		if candidate.ImportPath == "" {
+			return nil
+		}
+		if e, ok := edits[candidate.ImportPath]; ok {
+			return e
+		}
+		e, err := source.AddImport(ctx, f, candidate.ImportPath)
+		if err != nil {
+			log.Printf("completion: cannot import %s: %s", candidate.ImportPath, err)
+		}
+		if len(e) > 0 {
+			edits[candidate.ImportPath] = toProtocolEdits(ctx, h.overlay.columns, f, e)
+		} else {
+			edits[candidate.ImportPath] = nil
+		}
+		return edits[candidate.ImportPath]
+	}
+}
+
 func (h *LangHandler) clientSupportsSnippets() bool {
 	return h.init != nil && h.init.Capabilities.TextDocument.Completion.CompletionItem.SnippetSupport
 }
 
+// clientResolvesDetail reports whether the client resolves the detail of the
+// completion items with completionItem/resolve.
+func (h *LangHandler) clientResolvesDetail() bool {
+	if h.init == nil {
+		return false
+	}
+	resolve := h.init.Capabilities.TextDocument.Completion.CompletionItem.ResolveSupport
+	if resolve == nil {
+		return false
+	}
+	for _, property := range resolve.Properties {
+		if property == "detail" {
+			return true
+		}
+	}
+	return false
+}
+
 func getLspRange(pos lsp.Position, rangeLen int) lsp.Range {
 	return lsp.Range{
 		Start: lsp.Position{Line: pos.Line, Character: pos.Character - rangeLen},
@@ -61,7 +235,7 @@ func getLspRange(pos lsp.Position, rangeLen int) lsp.Range {
 	}
 }
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool) []lsp.CompletionItem {
+func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, encoding string, snippetsSupported, signatureHelpEnabled bool, importEdits func(source.CompletionItem) []lsp.TextEdit, resolveData func(source.CompletionItem) *completionData) []completionItem {
 	insertTextFormat := lsp.ITFPlainText
 	if snippetsSupported {
 		insertTextFormat = lsp.ITFSnippet
@@ -69,7 +243,7 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 	sort.SliceStable(candidates, func(i, j int) bool {
 		return candidates[i].Score > candidates[j].Score
 	})
-	items := []lsp.CompletionItem{}
+	items := []completionItem{}
 	for i, candidate := range candidates {
 		// Matching against the label.
 		if !strings.HasPrefix(candidate.Label, prefix) {
@@ -79,6 +253,13 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 		// TODO(rstambler): Remove this logic when we are confident that we no
 		// longer need to support it.
 		insertText, _ := labelToProtocolSnippets(candidate.Label, candidate.Kind, insertTextFormat, signatureHelpEnabled)
+		if candidate.InsertText != "" {
+			insertText = candidate.InsertText
+		}
+		if candidate.Qualifier != "" {
+			// The package is not imported, the name is qualified by it.
+			insertText = candidate.Qualifier + "." + insertText
+		}
 		//if strings.HasPrefix(insertText, prefix) {
 		//	insertText = insertText[len(prefix):]
 		//}
@@ -88,7 +269,7 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 			Kind:             toProtocolCompletionItemKind(candidate.Kind),
 			TextEdit: &lsp.TextEdit{
 				NewText: insertText,
-				Range:   getLspRange(pos, len(prefix)),
+				Range:   getLspRange(pos, codeUnits([]byte(prefix), encoding)),
 			},
 			
 			InsertTextFormat: insertTextFormat,
@@ -98,15 +279,20 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 			// according to their score. This can be removed upon the resolution of
 			// https://github.com/Microsoft/language-server-protocol/issues/348.
 			SortText:   fmt.Sprintf("%05d", i),
-			Documentation: candidate.Documentation,
 		}
+		data := resolveData(candidate)
 		// If we are completing a function, we should trigger signature help if possible.
 		//if triggerSignatureHelp && signatureHelpEnabled {
 		//	item.Command = &lsp.Command{
 		//		Command: "editor.action.triggerParameterHints",
 		//	}
 		//}
-		items = append(items, item)
+		edits := importEdits(candidate)
+		if len(edits) > 0 && candidate.Kind != source.PackageCompletionItem {
+			// The package is not imported yet, name it.
+			item.Detail = strings.TrimSpace(fmt.Sprintf("%s (from %q)", item.Detail, candidate.ImportPath))
+		}
+		items = append(items, completionItem{CompletionItem: item, AdditionalTextEdits: edits, Data: data})
 	}
 	return items
 }
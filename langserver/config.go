@@ -1,7 +1,13 @@
 package langserver
 
 import (
+	"log"
+	"os"
 	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/source"
 )
 
 // Config adjusts the behaviour of go-langserver. Please keep in sync with
@@ -15,6 +21,9 @@ type Config struct {
 	DisableFuncSnippet bool
 
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
+	// With "lazy", the package of a file is loaded by the first request on
+	// it, and all the packages by the first request searching the workspace,
+	// instead of at initialization.
 	//
 	// Defaults to "always" if not specified
 	GlobalCacheStyle string
@@ -24,6 +33,27 @@ type Config struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle string
 
+	// DiagnosticsAnalyses publishes the diagnostics of the vet analyzers,
+	// eg. printf, along with the compiler errors of a package when it has
+	// none. The files with one of NoAnalysisDirectives are skipped.
+	//
+	// Defaults to false.
+	DiagnosticsAnalyses bool
+
+	// NoAnalysisDirectives are the directives which suppress the analysis
+	// diagnostics of a file when one of them starts a comment above its
+	// package clause, eg. in generated code.
+	//
+	// Defaults to //lint:file-ignore and //bingo:noanalysis.
+	NoAnalysisDirectives []string
+
+	// CtxCheck publishes the diagnostics of the ctxcheck analyzer, the misuses
+	// of context.Context, along with the ones of DiagnosticsAnalyses, which
+	// it turns on.
+	//
+	// Defaults to false.
+	CtxCheck bool
+
 	// FormatStyle format style
 	//
 	// Defaults to "gofmt" if not secified
@@ -34,6 +64,14 @@ type Config struct {
 	// Defaults to empty string if not specified.
 	GoimportsLocalPrefix string
 
+	// ImportsLocalPrefix groups the imports into standard library,
+	// third-party and local blocks when organizing imports or formatting with
+	// goimports, the local imports being those starting with one of its
+	// comma-separated prefixes, usually the module path.
+	//
+	// Defaults to empty, which keeps the goimports grouping.
+	ImportsLocalPrefix string
+
 	// MaxParallelism controls the maximum number of goroutines that should be used
 	// to fulfill requests. This is useful in editor environments where users do
 	// not want results ASAP, but rather just semi quickly without eating all of
@@ -51,6 +89,210 @@ type Config struct {
 	//
 	// Defaults to empty
 	BuildTags []string
+
+	// BuildFlags are the other build flags passed to the go command when
+	// loading packages, eg. -mod=vendor. GOFLAGS in the environment still
+	// applies.
+	//
+	// Defaults to empty
+	BuildFlags []string
+
+	// WarmupOnInitialize loads the packages of the workspace into the global
+	// cache while handling the initialize request, so that the first
+	// requests are fast. If false, they are loaded in the background after
+	// the initialize response.
+	//
+	// Defaults to the GOLSP_WARMUP_ON_INITIALIZE environment variable if it
+	// is set, true otherwise.
+	WarmupOnInitialize bool
+
+	// GOOS and GOARCH set the target platform the packages are loaded for,
+	// so that files excluded by build constraints on the host platform can
+	// be analysed.
+	//
+	// Defaults to the host platform
+	GOOS   string
+	GOARCH string
+
+	// SortReferencesByProximity sorts the references by their distance to
+	// the requested document: the same file first, then the same package,
+	// then the same module, then the rest.
+	//
+	// Defaults to false, which sorts the references by position.
+	SortReferencesByProximity bool
+
+	// HoverBitFlags shows the other constants of a `1 << iota` bit flag
+	// group, with their values in hex and binary, when hovering one of them.
+	//
+	// Defaults to false
+	HoverBitFlags bool
+
+	// HoverSymbolFooter appends the fully qualified symbol path, eg.
+	// "github.com/foo/bar/-/T/M", and the kind of the symbol to every hover.
+	//
+	// Defaults to false
+	HoverSymbolFooter bool
+
+	// HoverMethodSet lists the method set of the receiver type when hovering
+	// a method, the methods promoted from embedded fields are marked.
+	//
+	// Defaults to false
+	HoverMethodSet bool
+
+	// HoverSkipUnexported returns no hover for the unexported objects, eg.
+	// the unexported functions and the local variables.
+	//
+	// Defaults to false
+	HoverSkipUnexported bool
+
+	// HoverSkipExternal returns no hover for the objects defined in GOROOT
+	// or in the module cache, eg. the standard library.
+	//
+	// Defaults to false
+	HoverSkipExternal bool
+
+	// HoverASTNode returns the kind and the source of the innermost AST node
+	// at the position when there is no other hover, eg. on a keyword or an
+	// operator. It is meant for debugging.
+	//
+	// Defaults to false
+	HoverASTNode bool
+
+	// PrintfFuncs are the full names of the printf-like functions, in
+	// addition to the ones of the standard library, eg. fmt.Printf, whose
+	// format directives are resolved to their arguments by hover and
+	// definition, eg. github.com/foo/log.Infof or
+	// (*github.com/foo/log.Logger).Infof. Their format is the parameter
+	// before the variadic one.
+	//
+	// Defaults to empty
+	PrintfFuncs []string
+
+	// MaxCachedPackages limits the number of packages retained in the global
+	// cache. The least recently used packages outside of the main modules are
+	// evicted with their importers when the limit is exceeded, and reloaded
+	// on demand.
+	//
+	// Defaults to 0, which means no limit.
+	MaxCachedPackages int
+
+	// LoadBatchSize loads the packages of each module, or of the GOPATH
+	// workspace, and their dependencies, in batches of at most this many
+	// packages, the imported packages first, instead of all at once, so that
+	// the peak memory stays bounded on huge repositories. A package already
+	// cached is not loaded again. The loads run with at most MaxParallelism
+	// at once.
+	//
+	// Defaults to 0, which loads them all at once.
+	LoadBatchSize int
+
+	// ExcludeInternalPackages skips the packages under an internal directory
+	// when searching workspace symbols and references, so that the results
+	// are scoped to the public API.
+	//
+	// Defaults to false, which includes the internal packages.
+	ExcludeInternalPackages bool
+
+	// ExcludeDirs are the names of the directories skipped, in addition to
+	// .git, vendor, node_modules and the like, when the workspace is walked
+	// to find the go.mod files, eg. testdata.
+	//
+	// Defaults to empty
+	ExcludeDirs []string
+
+	// MaxWalkDepth is the maximum depth of the directories walked to find the
+	// go.mod files of the workspace, the deeper directories are skipped.
+	//
+	// Defaults to 8
+	MaxWalkDepth int
+
+	// ImplementationDirection filters the results of textDocument/implementation:
+	// "to" returns only the types implementing the interface, "from" returns
+	// only the interfaces satisfied by the type, "both" returns all of them.
+	//
+	// Defaults to "both" if not specified.
+	ImplementationDirection string
+
+	// ImplementationStdlib includes the types of the standard library
+	// packages imported by the project in textDocument/implementation, eg.
+	// the standard library types implementing an interface of the project.
+	//
+	// Defaults to false, scanning the standard library is expensive.
+	ImplementationStdlib bool
+
+	// ImplementationMethods makes textDocument/implementation return, for
+	// an interface type, the methods of each implementation satisfying the
+	// interface instead of the declaration of its type.
+	//
+	// Defaults to false
+	ImplementationMethods bool
+
+	// TypeDefinitionFullRange makes the locations of textDocument/typeDefinition
+	// cover the whole type declaration, eg. type T struct{...}, instead of
+	// the name of the type.
+	//
+	// Defaults to false
+	TypeDefinitionFullRange bool
+
+	// ReferencesTests controls the references in _test.go files: "include"
+	// keeps them, "exclude" drops them and "only" drops the other ones.
+	//
+	// Defaults to "include" if not specified.
+	ReferencesTests string
+
+	// ReferencesFollowAliases makes textDocument/references unify a type
+	// and its aliases, eg. type B = A: the references to A or to B are the
+	// references to both.
+	//
+	// Defaults to false
+	ReferencesFollowAliases bool
+
+	// GodocURL is the base URL the import paths are linked to by
+	// textDocument/documentLink, eg. "https://pkg.go.dev", the import path
+	// is appended to it.
+	//
+	// Defaults to empty, which links the directories of the packages.
+	GodocURL string
+
+	// SymbolEmbeddedFields includes the embedded struct fields in the
+	// document and workspace symbols, they are named after their type, eg.
+	// Reader for an embedded io.Reader.
+	//
+	// Defaults to false
+	SymbolEmbeddedFields bool
+
+	// SymbolMaxResults caps the number of results of workspace/symbol, even
+	// if a request asks for more, to bound the memory used by a response.
+	//
+	// Defaults to 1000. 0 means no limit.
+	SymbolMaxResults int
+
+	// SymbolWeights are the weights workspace/symbol ranks the results with.
+	//
+	// Defaults to defaultSymbolWeights.
+	SymbolWeights SymbolWeights
+
+	// SymbolMainModulesOnly restricts workspace/symbol to the packages of
+	// the main modules, without their dependencies and the standard library.
+	// A query can override it with is:all, or set it with is:main.
+	//
+	// Defaults to false
+	SymbolMainModulesOnly bool
+
+	// RequestTimeout is the maximum duration of a request, except initialize.
+	// When it is exceeded, textDocument/references and workspace/symbol
+	// return the results found so far, the other requests fail.
+	//
+	// Defaults to 0, no timeout.
+	RequestTimeout time.Duration
+
+	// LogLevel is the verbosity of the messages logged to the client with
+	// window/logMessage: "error", "info", "debug" which also logs the
+	// rebuilds of the cache and the durations of the searches, or "trace"
+	// which also logs the node each request resolves its position to.
+	//
+	// Defaults to "info" if not specified.
+	LogLevel string
 }
 
 // Apply sets the corresponding field in c for each non-nil field in o.
@@ -66,6 +308,18 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.DiagnosticsStyle = *o.DiagnosticsStyle
 	}
 
+	if o.DiagnosticsAnalyses != nil {
+		c.DiagnosticsAnalyses = *o.DiagnosticsAnalyses
+	}
+
+	if o.NoAnalysisDirectives != nil {
+		c.NoAnalysisDirectives = o.NoAnalysisDirectives
+	}
+
+	if o.CtxCheck != nil {
+		c.CtxCheck = *o.CtxCheck
+	}
+
 	if o.GlobalCacheStyle != nil {
 		c.GlobalCacheStyle = *o.GlobalCacheStyle
 	}
@@ -82,6 +336,10 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.GoimportsLocalPrefix = *o.GoimportsLocalPrefix
 	}
 
+	if o.ImportsLocalPrefix != nil {
+		c.ImportsLocalPrefix = *o.ImportsLocalPrefix
+	}
+
 	if o.MaxParallelism != nil {
 		c.MaxParallelism = *o.MaxParallelism
 	}
@@ -90,9 +348,178 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.BuildTags = o.BuildTags
 	}
 
+	if o.BuildFlags != nil {
+		c.BuildFlags = o.BuildFlags
+	}
+
+	if o.WarmupOnInitialize != nil {
+		c.WarmupOnInitialize = *o.WarmupOnInitialize
+	}
+
+	if o.GOOS != nil {
+		c.GOOS = *o.GOOS
+	}
+
+	if o.GOARCH != nil {
+		c.GOARCH = *o.GOARCH
+	}
+
+	if o.SortReferencesByProximity != nil {
+		c.SortReferencesByProximity = *o.SortReferencesByProximity
+	}
+
+	if o.HoverBitFlags != nil {
+		c.HoverBitFlags = *o.HoverBitFlags
+	}
+
+	if o.HoverSymbolFooter != nil {
+		c.HoverSymbolFooter = *o.HoverSymbolFooter
+	}
+
+	if o.HoverMethodSet != nil {
+		c.HoverMethodSet = *o.HoverMethodSet
+	}
+
+	if o.HoverSkipUnexported != nil {
+		c.HoverSkipUnexported = *o.HoverSkipUnexported
+	}
+
+	if o.HoverSkipExternal != nil {
+		c.HoverSkipExternal = *o.HoverSkipExternal
+	}
+
+	if o.HoverASTNode != nil {
+		c.HoverASTNode = *o.HoverASTNode
+	}
+
+	if o.PrintfFuncs != nil {
+		c.PrintfFuncs = o.PrintfFuncs
+	}
+
+	if o.MaxCachedPackages != nil {
+		c.MaxCachedPackages = *o.MaxCachedPackages
+	}
+
+	if o.LoadBatchSize != nil {
+		c.LoadBatchSize = *o.LoadBatchSize
+	}
+
+	if o.ExcludeInternalPackages != nil {
+		c.ExcludeInternalPackages = *o.ExcludeInternalPackages
+	}
+
+	if o.ExcludeDirs != nil {
+		c.ExcludeDirs = o.ExcludeDirs
+	}
+
+	if o.MaxWalkDepth != nil {
+		c.MaxWalkDepth = *o.MaxWalkDepth
+	}
+
+	if o.RequestTimeout != nil {
+		if timeout, err := time.ParseDuration(*o.RequestTimeout); err != nil {
+			log.Printf("invalid requestTimeout %q: %s", *o.RequestTimeout, err)
+		} else {
+			c.RequestTimeout = timeout
+		}
+	}
+
+	if o.ImplementationDirection != nil {
+		c.ImplementationDirection = *o.ImplementationDirection
+	}
+
+	if o.ImplementationStdlib != nil {
+		c.ImplementationStdlib = *o.ImplementationStdlib
+	}
+
+	if o.ImplementationMethods != nil {
+		c.ImplementationMethods = *o.ImplementationMethods
+	}
+
+	if o.TypeDefinitionFullRange != nil {
+		c.TypeDefinitionFullRange = *o.TypeDefinitionFullRange
+	}
+
+	if o.ReferencesTests != nil {
+		c.ReferencesTests = *o.ReferencesTests
+	}
+
+	if o.ReferencesFollowAliases != nil {
+		c.ReferencesFollowAliases = *o.ReferencesFollowAliases
+	}
+
+	if o.GodocURL != nil {
+		c.GodocURL = *o.GodocURL
+	}
+
+	if o.SymbolEmbeddedFields != nil {
+		c.SymbolEmbeddedFields = *o.SymbolEmbeddedFields
+	}
+
+	if o.SymbolMaxResults != nil {
+		c.SymbolMaxResults = *o.SymbolMaxResults
+	}
+
+	if o.SymbolMainModulesOnly != nil {
+		c.SymbolMainModulesOnly = *o.SymbolMainModulesOnly
+	}
+
+	if o.SymbolWeights != nil {
+		c.SymbolWeights = c.SymbolWeights.apply(o.SymbolWeights)
+	}
+
+	if o.LogLevel != nil {
+		c.LogLevel = *o.LogLevel
+	}
+
 	return c
 }
 
+// apply sets the corresponding weight in w for each non-nil field in o.
+func (w SymbolWeights) apply(o *SymbolWeightsOptions) SymbolWeights {
+	if o.ContainerPrefix != nil {
+		w.ContainerPrefix = *o.ContainerPrefix
+	}
+
+	if o.NamePrefix != nil {
+		w.NamePrefix = *o.NamePrefix
+	}
+
+	if o.ExactContainer != nil {
+		w.ExactContainer = *o.ExactContainer
+	}
+
+	if o.ExactName != nil {
+		w.ExactName = *o.ExactName
+	}
+
+	if o.ExactNameNotLast != nil {
+		w.ExactNameNotLast = *o.ExactNameNotLast
+	}
+
+	if o.FilePath != nil {
+		w.FilePath = *o.FilePath
+	}
+
+	if o.FileBasePrefix != nil {
+		w.FileBasePrefix = *o.FileBasePrefix
+	}
+
+	if o.NonVendor != nil {
+		w.NonVendor = *o.NonVendor
+	}
+
+	if o.Exported != nil {
+		w.Exported = *o.Exported
+	}
+
+	if o.QualifiedName != nil {
+		w.QualifiedName = *o.QualifiedName
+	}
+
+	return w
+}
+
 // NewDefaultConfig returns the default config. See the field comments for the
 // defaults.
 func NewDefaultConfig() Config {
@@ -102,8 +529,19 @@ func NewDefaultConfig() Config {
 		maxparallelism = 1
 	}
 
+	warmup := true
+	if v, err := strconv.ParseBool(os.Getenv("GOLSP_WARMUP_ON_INITIALIZE")); err == nil {
+		warmup = v
+	}
+
 	return Config{
 		DisableFuncSnippet: false,
 		MaxParallelism:     maxparallelism,
+		WarmupOnInitialize: warmup,
+		MaxWalkDepth:       8,
+		SymbolMaxResults:   1000,
+		SymbolWeights:      defaultSymbolWeights,
+
+		NoAnalysisDirectives: source.DefaultNoAnalysisDirectives,
 	}
 }
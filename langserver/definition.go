@@ -2,7 +2,6 @@ package langserver
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"go/ast"
 	"go/types"
@@ -18,6 +17,12 @@ func (h *LangHandler) handleDefinition(ctx context.Context, conn jsonrpc2.JSONRP
 	if err != nil {
 		return nil, err
 	}
+	if len(res) == 0 {
+		// A format directive, eg. %d, is defined by its argument.
+		if loc, ok := h.formatArgumentLocation(ctx, params.TextDocument.URI, params.Position); ok {
+			return []lsp.Location{loc}, nil
+		}
+	}
 	locs := make([]lsp.Location, 0, len(res))
 	for _, li := range res {
 		locs = append(locs, li.Location)
@@ -25,79 +30,178 @@ func (h *LangHandler) handleDefinition(ctx context.Context, conn jsonrpc2.JSONRP
 	return locs, nil
 }
 
+// handleTypeDefinition returns the declaration of the type of the identifier
+// at the position. For the function of a call, whose type is not a named
+// type, it returns the declarations of the result types of the call.
 func (h *LangHandler) handleTypeDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
-	res, err := h.handleXDefinition(ctx, conn, req, params)
+	pkg, pathNodes, ident, err := h.identAt(ctx, params.TextDocument.URI, params.Position, definitionIdent)
+	if err != nil {
+		if isNoResult(err) {
+			return []lsp.Location{}, nil
+		}
+		return nil, err
+	}
+	res, err := h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, ident)
 	if err != nil {
 		return nil, err
 	}
 	locs := make([]lsp.Location, 0, len(res))
+	seen := make(map[lsp.Location]bool)
 	for _, li := range res {
 		// not everything we find a definition for also has a type definition
-		if li.TypeLocation.URI != "" {
+		if li.TypeLocation.URI != "" && !seen[li.TypeLocation] {
+			seen[li.TypeLocation] = true
 			locs = append(locs, li.TypeLocation)
 		}
 	}
+	if len(locs) > 0 {
+		return locs, nil
+	}
+
+	if call := calledBy(pathNodes, ident); call != nil {
+		if sig, ok := pkg.GetTypesInfo().TypeOf(call.Fun).(*types.Signature); ok {
+			for i := 0; i < sig.Results().Len(); i++ {
+				typ := source.TypeLookup(sig.Results().At(i).Type())
+				if typ == nil || !typ.Pos().IsValid() {
+					continue
+				}
+				loc := h.typeLocation(pkg, typ)
+				if loc.URI != "" && !seen[loc] {
+					seen[loc] = true
+					locs = append(locs, loc)
+				}
+			}
+		}
+	}
 	return locs, nil
 }
 
-var testOSToVFSPath func(osPath string) string
+// typeLocation returns the location of the name of the named type typ, or of
+// its whole declaration if Config.TypeDefinitionFullRange is set, eg. from
+// type to the closing brace of type T struct{...}. The name is used if the
+// declaration is not found.
+func (h *LangHandler) typeLocation(pkg source.Package, typ *types.TypeName) lsp.Location {
+	fset := pkg.GetFileSet()
+	if h.config.TypeDefinitionFullRange {
+		if nodes, _, err := source.GetObjectPathNode(pkg, fset, typ); err == nil {
+			if decl := typeDecl(nodes); decl != nil {
+				return h.overlay.columns.createLocationFromRange(fset, decl.Pos(), decl.End())
+			}
+		}
+	}
+	return h.overlay.columns.goRangeToLSPLocation(fset, typ.Pos(), typ.Name())
+}
 
-type foundNode struct {
-	ident *ast.Ident      // the lookup in Uses[] or Defs[]
-	typ   *types.TypeName // the object for a named type, if present
+// typeDecl returns the type declaration of the path to the name of a type:
+// the declaration with its type keyword if it declares a single type, or the
+// type spec in a parenthesized declaration.
+func typeDecl(pathNodes []ast.Node) ast.Node {
+	for i, node := range pathNodes {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		if i+1 < len(pathNodes) {
+			if decl, ok := pathNodes[i+1].(*ast.GenDecl); ok && !decl.Lparen.IsValid() {
+				return decl
+			}
+		}
+		return spec
+	}
+	return nil
 }
 
-func (h *LangHandler) handleXDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
-	symbols, err := h.doHandleXDefinition(ctx, conn, req, params)
+// calledBy returns the innermost call of pathNodes if ident is its function,
+// eg. f in f() or x.f(), and nil otherwise.
+func calledBy(pathNodes []ast.Node, ident *ast.Ident) *ast.CallExpr {
+	for _, node := range pathNodes {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		fun := call.Fun
+		for {
+			paren, ok := fun.(*ast.ParenExpr)
+			if !ok {
+				break
+			}
+			fun = paren.X
+		}
+		if sel, ok := fun.(*ast.SelectorExpr); ok {
+			fun = sel.Sel
+		}
+		if fun == ident {
+			return call
+		}
+		return nil
+	}
+	return nil
+}
+
+// handleDeclaration returns where the identifier at the position is declared.
+// For a method called through an interface this is the method of the
+// interface, the concrete methods are returned by textDocument/implementation.
+// Unlike handleDefinition, it does not offer the type of an embedded field.
+func (h *LangHandler) handleDeclaration(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]lsp.Location, error) {
+	res, err := h.handleXDefinition(ctx, conn, req, params)
 	if err != nil {
-		params.Position.Character--
-		symbols, err = h.doHandleXDefinition(ctx, conn, req, params)
+		return nil, err
 	}
+	if len(res) == 0 {
+		return []lsp.Location{}, nil
+	}
+	// The declaration of the identifier itself always comes first.
+	return []lsp.Location{res[0].Location}, nil
+}
+
+var testOSToVFSPath func(osPath string) string
 
-	return symbols, err
+type foundNode struct {
+	ident *ast.Ident      // the lookup in Uses[] or Defs[]
+	typ   *types.TypeName // the object for a named type, if present
 }
 
-func (h *LangHandler) doHandleXDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
-	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+func (h *LangHandler) handleXDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]symbolLocationInformation, error) {
+	pkg, pathNodes, ident, err := h.identAt(ctx, params.TextDocument.URI, params.Position, definitionIdent)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no locations.
-		if _, ok := err.(*source.InvalidNodeError); ok {
+		if isNoResult(err) {
 			return []symbolLocationInformation{}, nil
 		}
 		return nil, err
 	}
-
-	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
-	if err != nil {
-		return nil, err
+	if ident.Name == "_" {
+		// The blank identifier, eg. of a blank import, declares nothing.
+		return []symbolLocationInformation{}, nil
 	}
+	return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, ident)
+}
 
-	firstNode := pathNodes[0]
-	switch node := firstNode.(type) {
+// definitionIdent returns the identifier to look up for the innermost node
+// at a position, or nil if there is none: the name of a type spec, the called
+// function of a call, the selected name of a selector or the type of a type
+// assertion.
+func definitionIdent(node ast.Node) *ast.Ident {
+	switch node := node.(type) {
 	case *ast.Ident:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node)
+		return node
 	case *ast.TypeSpec:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node.Name)
+		return node.Name
 	case *ast.CallExpr:
-		return h.lookupCallExprDefinition(ctx, conn, pkg, pathNodes, node)
+		switch fun := node.Fun.(type) {
+		case *ast.Ident:
+			return fun
+		case *ast.SelectorExpr:
+			return fun.Sel
+		}
 	case *ast.SelectorExpr:
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, node.Sel)
-	default:
-		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), firstNode)
-	}
-}
-
-func (h *LangHandler) lookupCallExprDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, pathNodes []ast.Node, call *ast.CallExpr) ([]symbolLocationInformation, error) {
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, ident)
+		return node.Sel
+	case *ast.TypeAssertExpr:
+		// node.Type is nil in the type switch form x.(type).
+		return source.TypeExprIdent(node.Type)
+	case *ast.StarExpr:
+		return source.TypeExprIdent(node)
 	}
-
-	if selExpr, ok := call.Fun.(*ast.SelectorExpr); ok {
-		return h.lookupIdentDefinition(ctx, conn, pkg, pathNodes, selExpr.Sel)
-	}
-
-	return nil, source.NewInvalidNodeError(pkg.GetFileSet(), pathNodes[0])
+	return nil
 }
 
 func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.JSONRPC2, pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) ([]symbolLocationInformation, error) {
@@ -105,24 +209,34 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 	obj := source.FindIdentObject(pkg, ident)
 	if obj != nil {
 		if typeVar, ok := obj.(*types.Var); ok && typeVar.Embedded() {
-			if t, ok := typeVar.Type().(*types.Named); ok {
+			if t, ok := source.Deref(typeVar.Type()).(*types.Named); ok {
+				// Offer both the embedding site of the field and the
+				// declaration of its type.
+				nodes = append(nodes, foundNode{
+					ident: &ast.Ident{NamePos: typeVar.Pos(), Name: typeVar.Name()},
+					typ:   source.TypeLookup(pkg.GetTypesInfo().TypeOf(ident)),
+				})
 				obj = t.Obj()
 			}
 		}
 
-		pos := obj.Pos()
+		pos, name := obj.Pos(), obj.Name()
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			// Jump to the package clause of the imported package rather
+			// than to the (possibly aliased) name in the import spec.
+			if id := importedPackageName(pkg, pkgName); id != nil {
+				pos, name = id.Pos(), id.Name
+			}
+		}
 		isBuiltIn := !pos.IsValid()
 		if !isBuiltIn {
 			nodes = append(nodes, foundNode{
-				ident: &ast.Ident{NamePos: pos, Name: obj.Name()},
+				ident: &ast.Ident{NamePos: pos, Name: name},
 				typ:   source.TypeLookup(pkg.GetTypesInfo().TypeOf(ident)),
 			})
 		} else {
-			// Builtins have an invalid Pos. Just don't emit a definition for
-			// them, for now. It's not that valuable to jump to their def.
-			//
-			// TODO(sqs): find a way to actually emit builtin locations
-			// (pointing to builtin/builtin.go).
+			// Builtins have an invalid Pos, look up their declaration in
+			// builtin/builtin.go of GOROOT instead.
 			pkg = h.project.GetBuiltinPackage()
 			if pkg == nil {
 				return []symbolLocationInformation{}, nil
@@ -142,19 +256,19 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 		}
 	}
 	if len(nodes) == 0 {
-		return nil, errors.New("definition not found")
+		// eg. the blank identifier, or a package name which is only
+		// declared by the package clause.
+		return []symbolLocationInformation{}, nil
 	}
 	findPackage := h.getFindPackageFunc()
 	locs := make([]symbolLocationInformation, 0, len(nodes))
 	for _, found := range nodes {
 		// Determine location information for the ident.
 		l := symbolLocationInformation{
-			Location: goRangeToLSPLocation(pkg.GetFileSet(), found.ident.Pos(), found.ident.Name),
+			Location: h.overlay.columns.goRangeToLSPLocation(pkg.GetFileSet(), found.ident.Pos(), found.ident.Name),
 		}
 		if found.typ != nil {
-			// We don't get an end position, but we can assume it's comparable to
-			// the length of the name, I hope.
-			l.TypeLocation = goRangeToLSPLocation(pkg.GetFileSet(), found.typ.Pos(), found.typ.Name())
+			l.TypeLocation = h.typeLocation(pkg, found.typ)
 		}
 
 		// Determine metadata information for the ident.
@@ -163,15 +277,30 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 			if err != nil {
 				// TODO: tracing
 				//log.Println("refs.DefInfo:", err)
-				h.notifyLog(fmt.Sprintf("refs.DelInfo: %s", err))
+				h.notifyDebug(fmt.Sprintf("refs.DefInfo: %s", err))
 			} else {
 				l.Symbol = symDesc
 			}
 		} else {
 			// TODO: tracing
-			h.notifyLog(fmt.Sprintf("refs.DelInfo: %s", err))
+			h.notifyDebug(fmt.Sprintf("refs.DefInfo: %s", err))
 		}
 		locs = append(locs, l)
 	}
 	return locs, nil
 }
+
+// importedPackageName returns the package clause name of the first file of
+// the package imported by pkgName, or nil if the package is not loaded.
+func importedPackageName(pkg source.Package, pkgName *types.PkgName) *ast.Ident {
+	imp := pkg.GetImport(pkgName.Imported().Path())
+	if imp == nil {
+		return nil
+	}
+	for _, f := range imp.GetSyntax() {
+		if f.Name != nil {
+			return f.Name
+		}
+	}
+	return nil
+}
@@ -5,21 +5,35 @@
 package langserver
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"go/token"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
 // NOTICE: Code adapted from https://github.com/golang/tools/blob/master/internal/lsp/diagnostics.go.
 
-func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnostic, error) {
+// analysisOptions sets the analysis diagnostics published along with the
+// compiler errors, see Config.DiagnosticsAnalyses.
+type analysisOptions struct {
+	enabled    bool
+	noAnalysis []string
+	extra      []*analysis.Analyzer
+}
+
+func diagnostics(ctx context.Context, v source.View, f source.File, encoding string, analyses analysisOptions) (map[string][]lsp.Diagnostic, error) {
 	pkg := f.GetPackage(ctx)
 	if pkg == nil {
 		return nil, fmt.Errorf("package is null for file")
@@ -48,31 +62,151 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 	}
 	for _, err := range errors {
 		pos := parseErrorPos(err)
-		line := pos.Line - 1
-		col := pos.Column - 1
+		if _, ok := reports[pos.Filename]; !ok {
+			continue
+		}
+		var content []byte
+		if diagFile, err := v.GetFile(ctx, span.FileURI(pos.Filename)); err == nil {
+			content = diagFile.GetContent(ctx)
+		}
+		// Only type errors point at the start of an identifier or expression.
+		extend := err.Kind == packages.TypeError
+		rng := errorRange(content, pos, extend, encoding)
+		if extend && isUnusedImportError(err.Msg) {
+			// An unused import covers its whole spec, eg. f "fmt".
+			if r, ok := importSpecRange(pkg, content, pos, encoding); ok {
+				rng = r
+			}
+		}
 		diagnostic := lsp.Diagnostic{
-			// TODO(rstambler): Add support for diagnostic ranges.
-			Range: lsp.Range{
-				Start: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
-				End: lsp.Position{
-					Line:      line,
-					Character: col,
-				},
-			},
+			Range:    rng,
 			Severity: lsp.Error,
 			Source:   "LSP: Go compiler",
 			Message:  err.Msg,
 		}
-		if _, ok := reports[pos.Filename]; ok {
-			reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
+		reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
+	}
+	if len(errors) > 0 || !analyses.enabled {
+		return reports, nil
+	}
+
+	// The compiler errors are reported again by source.Diagnostics, only the
+	// analysis diagnostics are kept.
+	analysisReports, err := source.Diagnostics(ctx, v, f.URI(), analyses.noAnalysis, analyses.extra...)
+	if err != nil {
+		return reports, nil
+	}
+	for uri, diags := range analysisReports {
+		filename, err := uri.Filename()
+		if _, ok := reports[filename]; err != nil || !ok {
+			continue
+		}
+		var content []byte
+		if diagFile, err := v.GetFile(ctx, uri); err == nil {
+			content = diagFile.GetContent(ctx)
+		}
+		for _, diag := range diags {
+			if diag.Severity != source.SeverityWarning {
+				continue
+			}
+			pos := token.Position{Filename: filename, Line: diag.Start().Line(), Column: diag.Start().Column()}
+			reports[filename] = append(reports[filename], lsp.Diagnostic{
+				Range:    errorRange(content, pos, true, encoding),
+				Severity: lsp.Warning,
+				Source:   diag.Source,
+				Message:  diag.Message,
+			})
 		}
 	}
 	return reports, nil
 }
 
+// isUnusedImportError reports whether msg is the type error of an unused
+// import, eg. `"fmt" imported and not used`.
+func isUnusedImportError(msg string) bool {
+	return strings.Contains(msg, " imported ") && strings.HasSuffix(msg, " not used")
+}
+
+// importSpecRange returns the range of the import spec of pkg starting at
+// pos. It reports false if there is none.
+func importSpecRange(pkg source.Package, content []byte, pos token.Position, encoding string) (lsp.Range, bool) {
+	fset := pkg.GetFileSet()
+	for _, file := range pkg.GetSyntax() {
+		for _, spec := range file.Imports {
+			// The position of a parse error has no offset, only the line
+			// and the column are compared.
+			start := fset.Position(spec.Pos())
+			if start.Line != pos.Line || start.Column != pos.Column || !util.PathEqual(start.Filename, pos.Filename) {
+				continue
+			}
+			end := errorRange(content, fset.Position(spec.End()), false, encoding)
+			return lsp.Range{Start: errorRange(content, pos, false, encoding).Start, End: end.Start}, true
+		}
+	}
+	return lsp.Range{}, false
+}
+
+// errorRange returns the range of the error at pos, whose characters are
+// counted in the code units of encoding. If content is available and extend
+// is true, the range is extended to the end of the identifier, or otherwise
+// of the word, starting at pos, eg. to x but not x.y for "undefined: x".
+// Otherwise the range is empty.
+func errorRange(content []byte, pos token.Position, extend bool, encoding string) lsp.Range {
+	line := pos.Line - 1
+	col := pos.Column - 1
+	if line < 0 {
+		line = 0
+	}
+	if col < 0 {
+		col = 0
+	}
+	rng := lsp.Range{
+		Start: lsp.Position{Line: line, Character: col},
+		End:   lsp.Position{Line: line, Character: col},
+	}
+
+	offset := 0
+	for i := 0; i < line; i++ {
+		n := bytes.IndexByte(content[offset:], '\n')
+		if n < 0 {
+			return rng
+		}
+		offset += n + 1
+	}
+	start := offset
+	offset += col
+	if offset > len(content) {
+		return rng
+	}
+	rng.Start.Character = codeUnits(content[start:offset], encoding)
+	rng.End.Character = rng.Start.Character
+	if !extend || offset == len(content) {
+		return rng
+	}
+	if l := identifierLength(content[offset:]); l > 0 {
+		rng.End.Character += codeUnits(content[offset:offset+l], encoding)
+		return rng
+	}
+	if l := bytes.IndexAny(content[offset:], " \t\n,():;[]{}"); l > 0 {
+		rng.End.Character += codeUnits(content[offset:offset+l], encoding)
+	}
+	return rng
+}
+
+// identifierLength returns the length in bytes of the identifier at the start
+// of b, 0 if there is none.
+func identifierLength(b []byte) int {
+	n := 0
+	for n < len(b) {
+		r, size := utf8.DecodeRune(b[n:])
+		if !unicode.IsLetter(r) && r != '_' && (n == 0 || !unicode.IsDigit(r)) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
 func parseErrorPos(pkgErr packages.Error) (pos token.Position) {
 	remainder1, first, hasLine := chop(pkgErr.Pos)
 	remainder2, second, hasColumn := chop(remainder1)
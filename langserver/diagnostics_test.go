@@ -0,0 +1,69 @@
+package langserver
+
+import (
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestParseErrorPos(t *testing.T) {
+	tests := []struct {
+		pos  string
+		want token.Position
+	}{
+		{"/p/a.go:3:5", token.Position{Filename: "/p/a.go", Line: 3, Column: 5}},
+		{"/p/a.go:3", token.Position{Filename: "/p/a.go", Line: 3}},
+		{"/p/a.go", token.Position{}},
+	}
+	for _, test := range tests {
+		got := parseErrorPos(packages.Error{Pos: test.pos})
+		if got != test.want {
+			t.Errorf("parseErrorPos(%q) = %v, want %v", test.pos, got, test.want)
+		}
+	}
+}
+
+func TestErrorRange(t *testing.T) {
+	content := []byte("package p\n\nvar _ = undefinedName(1)\n")
+	multiByte := []byte("package p\n\nvar _ = \"é😀\" + undefinedName\n")
+	selector := []byte("package p\n\nvar _ = x.y + \"é\"\n")
+	tests := []struct {
+		content  []byte
+		pos      token.Position
+		encoding string
+		want     string
+	}{
+		{content, token.Position{Line: 3, Column: 9}, positionEncodingUTF16, "2:8-2:21"},
+		{content, token.Position{Line: 3, Column: 5}, positionEncodingUTF16, "2:4-2:5"},
+		{nil, token.Position{Line: 3, Column: 9}, positionEncodingUTF16, "2:8-2:8"},
+		{content, token.Position{Line: 9, Column: 1}, positionEncodingUTF16, "8:0-8:0"},
+		{multiByte, token.Position{Line: 3, Column: 20}, positionEncodingUTF16, "2:16-2:29"},
+		{multiByte, token.Position{Line: 3, Column: 20}, positionEncodingUTF32, "2:15-2:28"},
+		{multiByte, token.Position{Line: 3, Column: 20}, positionEncodingUTF8, "2:19-2:32"},
+		{selector, token.Position{Line: 3, Column: 9}, positionEncodingUTF16, "2:8-2:9"},
+		{selector, token.Position{Line: 3, Column: 15}, positionEncodingUTF16, "2:14-2:17"},
+	}
+	for _, test := range tests {
+		got := errorRange(test.content, test.pos, true, test.encoding)
+		if got.String() != test.want {
+			t.Errorf("errorRange(%v) = %v, want %v", test.pos, got, test.want)
+		}
+	}
+}
+
+func TestIsUnusedImportError(t *testing.T) {
+	tests := map[string]bool{
+		`"fmt" imported and not used`:      true,
+		`"fmt" imported but not used`:      true,
+		`"fmt" imported as f and not used`: true,
+		`undefined: fmt`:                   false,
+		`could not import x (no metadata)`: false,
+		`x declared and not used`:          false,
+	}
+	for msg, want := range tests {
+		if got := isUnusedImportError(msg); got != want {
+			t.Errorf("isUnusedImportError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
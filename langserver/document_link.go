@@ -0,0 +1,77 @@
+package langserver
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DocumentLinkParams are the parameters of the textDocument/documentLink
+// request.
+type DocumentLinkParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentLink is a range of a document linked to a URI.
+type DocumentLink struct {
+	Range  lsp.Range `json:"range"`
+	Target string    `json:"target,omitempty"`
+}
+
+type documentLinkOptions struct {
+	ResolveProvider bool `json:"resolveProvider"`
+}
+
+// handleDocumentLink links the import paths of a document to the directories
+// of the packages, or to their documentation under Config.GodocURL. Only the
+// imports of the document are parsed, it is not type checked.
+func (h *LangHandler) handleDocumentLink(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params DocumentLinkParams) ([]DocumentLink, error) {
+	if err := checkFileURI(params.TextDocument.URI); err != nil {
+		return nil, err
+	}
+
+	filename := util.UriToRealPath(params.TextDocument.URI)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, h.overlay.content(filename), parser.ImportsOnly)
+	if file == nil {
+		return nil, err
+	}
+
+	links := []DocumentLink{}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || path == "C" {
+			continue
+		}
+		target := h.importTarget(path)
+		if target == "" {
+			continue
+		}
+		links = append(links, DocumentLink{
+			Range:  h.overlay.columns.rangeForNode(fset, spec.Path),
+			Target: target,
+		})
+	}
+	return links, nil
+}
+
+// importTarget returns the target of the link of the import path, or "" if
+// the package is unknown.
+func (h *LangHandler) importTarget(path string) string {
+	if h.config.GodocURL != "" {
+		return strings.TrimSuffix(h.config.GodocURL, "/") + "/" + path
+	}
+
+	pkg := h.project.GetStdlibPackage(path)
+	if pkg == nil || len(pkg.GetFilenames()) == 0 {
+		return ""
+	}
+	return string(util.PathToURI(filepath.Dir(pkg.GetFilenames()[0])))
+}
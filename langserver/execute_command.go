@@ -0,0 +1,187 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The commands of workspace/executeCommand, their only argument is the URI of
+// a document, unless noted otherwise.
+const (
+	// commandOrganizeImports applies the edits of the organize imports code
+	// action to the document.
+	commandOrganizeImports = "bingo.organizeImports"
+
+	// commandRunGoGenerate runs go generate in the directory of the document.
+	commandRunGoGenerate = "bingo.runGoGenerate"
+
+	// commandListUnusedExported returns the locations of the exported
+	// symbols of the main modules which are not used. Its optional argument
+	// is a boolean, if true the uses in the test files do not count.
+	commandListUnusedExported = "bingo.listUnusedExported"
+
+	// commandListImplementedInterfaces returns the named interfaces
+	// satisfied by a type. Its arguments are the URI of a document and the
+	// position of the type in it.
+	commandListImplementedInterfaces = "bingo.listImplementedInterfaces"
+
+	// commandReloadProject loads the project again from scratch, eg. after
+	// switching between module and GOPATH mode. Its optional arguments are
+	// environment variables of the go commands, eg. "GO111MODULE=on", which
+	// replace the ones set before, except GOPATH and GOROOT. It returns once
+	// the reload is started, the requests wait until it is done.
+	commandReloadProject = "bingo.reloadProject"
+)
+
+// executeCommands are the commands advertised in the executeCommandProvider
+// capability.
+var executeCommands = []string{commandOrganizeImports, commandRunGoGenerate, commandListUnusedExported, commandListImplementedInterfaces, commandReloadProject}
+
+// applyWorkspaceEditParams are the parameters of the workspace/applyEdit
+// request sent to the client.
+type applyWorkspaceEditParams struct {
+	Label string            `json:"label,omitempty"`
+	Edit  lsp.WorkspaceEdit `json:"edit"`
+}
+
+type applyWorkspaceEditResult struct {
+	Applied bool `json:"applied"`
+}
+
+func (h *LangHandler) handleExecuteCommand(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ExecuteCommandParams) (interface{}, error) {
+	if params.Command == commandListUnusedExported {
+		var excludeTests bool
+		if len(params.Arguments) > 0 {
+			exclude, ok := params.Arguments[0].(bool)
+			if !ok {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid exclude tests argument %v", params.Arguments[0])}
+			}
+			excludeTests = exclude
+		}
+		return h.listUnusedExported(ctx, excludeTests)
+	}
+
+	if params.Command == commandListImplementedInterfaces {
+		if len(params.Arguments) != 2 {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("command %s expects a document URI and a position arguments", params.Command)}
+		}
+		uri, ok := params.Arguments[0].(string)
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid document URI %v", params.Arguments[0])}
+		}
+		// The position is decoded as a map, it is converted back.
+		var position lsp.Position
+		if b, err := json.Marshal(params.Arguments[1]); err != nil || json.Unmarshal(b, &position) != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid position %v", params.Arguments[1])}
+		}
+		return h.listImplementedInterfaces(ctx, lsp.DocumentURI(uri), position)
+	}
+
+	if params.Command == commandReloadProject {
+		var env []string
+		for _, arg := range params.Arguments {
+			kv, ok := arg.(string)
+			if !ok || !strings.Contains(kv, "=") {
+				return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid environment variable %v, want NAME=value", arg)}
+			}
+			env = append(env, kv)
+		}
+		if err := cache.CheckReloadEnv(env); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+		}
+		h.reloadProject(env)
+		return nil, nil
+	}
+
+	if len(params.Arguments) != 1 {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("command %s expects a document URI argument", params.Command)}
+	}
+	uri, ok := params.Arguments[0].(string)
+	if !ok {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid document URI %v", params.Arguments[0])}
+	}
+	fileURI := lsp.DocumentURI(uri)
+	if err := checkFileURI(fileURI); err != nil {
+		return nil, err
+	}
+
+	switch params.Command {
+	case commandOrganizeImports:
+		edits, err := organizeImports(ctx, h.View(), h.overlay.columns, fileURI, h.config.ImportsLocalPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if len(edits) == 0 {
+			return nil, nil
+		}
+		var res applyWorkspaceEditResult
+		err = conn.Call(ctx, "workspace/applyEdit", &applyWorkspaceEditParams{
+			Label: "Organize Imports",
+			Edit:  lsp.WorkspaceEdit{Changes: map[string][]lsp.TextEdit{uri: edits}},
+		}, &res)
+		return nil, err
+
+	case commandRunGoGenerate:
+		dir := filepath.Dir(util.UriToRealPath(fileURI))
+		if out, err := h.runGoGenerate(ctx, dir); err != nil {
+			h.notifyError(fmt.Sprintf("go generate in %s failed: %s\n%s", dir, err, out))
+		} else {
+			h.notifyInfo(fmt.Sprintf("go generate in %s succeeded", dir))
+		}
+		return nil, nil
+
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("unknown command %s", params.Command)}
+	}
+}
+
+// reloadProject reloads the project with env in the background. As for the
+// initialization, the requests received meanwhile wait until it is done, and
+// the reload waits until the requests in progress are done.
+func (h *LangHandler) reloadProject(env []string) {
+	initDone := make(chan struct{})
+	h.mu.Lock()
+	h.initDone = initDone
+	h.mu.Unlock()
+
+	go func() {
+		defer close(initDone)
+		defer func() {
+			if err := util.Panicf(recover(), "reloading the project"); err != nil {
+				h.notifyError(err.Error())
+			}
+		}()
+		if err := h.project.Reload(env); err != nil {
+			h.notifyError(err.Error())
+		}
+	}()
+}
+
+// runGoGenerate runs go generate in dir with the build tags and the target
+// platform of the config, it returns the combined output.
+func (h *LangHandler) runGoGenerate(ctx context.Context, dir string) ([]byte, error) {
+	args := []string{"generate"}
+	if len(h.config.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(h.config.BuildTags, " "))
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	if h.config.GOOS != "" {
+		cmd.Env = append(cmd.Env, "GOOS="+h.config.GOOS)
+	}
+	if h.config.GOARCH != "" {
+		cmd.Env = append(cmd.Env, "GOARCH="+h.config.GOARCH)
+	}
+	return cmd.CombinedOutput()
+}
@@ -21,15 +21,38 @@ const (
 )
 
 func (h *LangHandler) handleTextDocumentFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, nil, h.DefaultConfig.FormatStyle == goimportsStyle)
+	return formatRange(ctx, h.View(), h.overlay.columns, params.TextDocument.URI, nil, h.config.FormatStyle == goimportsStyle, h.config.ImportsLocalPrefix)
 }
 
 func (h *LangHandler) handleTextDocumentRangeFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentRangeFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, &params.Range, h.DefaultConfig.FormatStyle == goimportsStyle)
+	return formatRange(ctx, h.View(), h.overlay.columns, params.TextDocument.URI, &params.Range, h.config.FormatStyle == goimportsStyle, h.config.ImportsLocalPrefix)
+}
+
+// onTypeFormattingTriggers are the characters triggering
+// textDocument/onTypeFormatting, the first one and the others.
+var onTypeFormattingTriggers = []string{"}", "\n"}
+
+// handleTextDocumentOnTypeFormatting formats the node completed by the
+// character typed, see source.FormatOnType.
+func (h *LangHandler) handleTextDocumentOnTypeFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentOnTypeFormattingParams) ([]lsp.TextEdit, error) {
+	sourceURI, err := fromProtocolURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	f, err := h.View().GetFile(ctx, sourceURI)
+	if err != nil {
+		return nil, err
+	}
+	tok := f.GetToken(ctx)
+	if tok == nil {
+		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", params.TextDocument.URI))
+	}
+	pos := h.overlay.columns.fromProtocolPosition(tok, params.Position)
+	return toProtocolEdits(ctx, h.overlay.columns, f, source.FormatOnType(ctx, f, pos, params.Ch)), nil
 }
 
 // formatRange formats a document with a given range.
-func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *lsp.Range, imports bool) ([]lsp.TextEdit, error) {
+func formatRange(ctx context.Context, v source.View, columns *columnMapper, uri lsp.DocumentURI, rng *lsp.Range, imports bool, localPrefix string) ([]lsp.TextEdit, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
 		return nil, err
@@ -47,22 +70,22 @@ func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *l
 		r.Start = tok.Pos(0)
 		r.End = tok.Pos(tok.Size())
 	} else {
-		r = fromProtocolRange(tok, *rng)
+		r = columns.fromProtocolRange(tok, *rng)
 	}
 
 	var edits []source.TextEdit
 	if imports {
-		edits, err = source.Imports(ctx, f, r)
+		edits, err = source.Imports(ctx, f, r, localPrefix)
 	} else {
 		edits, err = source.Format(ctx, f, r)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return toProtocolEdits(ctx, f, edits), nil
+	return toProtocolEdits(ctx, columns, f, edits), nil
 }
 
-func toProtocolEdits(ctx context.Context, f source.File, edits []source.TextEdit) []lsp.TextEdit {
+func toProtocolEdits(ctx context.Context, columns *columnMapper, f source.File, edits []source.TextEdit) []lsp.TextEdit {
 	if edits == nil {
 		return []lsp.TextEdit{}
 	}
@@ -70,7 +93,7 @@ func toProtocolEdits(ctx context.Context, f source.File, edits []source.TextEdit
 	result := make([]lsp.TextEdit, len(edits))
 	for i, edit := range edits {
 		result[i] = lsp.TextEdit{
-			Range:   toProtocolRange(edit.Span),
+			Range:   columns.toProtocolRange(edit.Span),
 			NewText: edit.NewText,
 		}
 	}
@@ -78,9 +101,9 @@ func toProtocolEdits(ctx context.Context, f source.File, edits []source.TextEdit
 }
 
 // toProtocolRange converts from a source range back to a protocol range.
-func toProtocolRange(s span.Span) lsp.Range {
+func (m *columnMapper) toProtocolRange(s span.Span) lsp.Range {
 	return lsp.Range{
-		Start: toProtocolPosition(s.Start()),
-		End:   toProtocolPosition(s.End()),
+		Start: m.toProtocolPosition(s.URI(), s.Start()),
+		End:   m.toProtocolPosition(s.URI(), s.End()),
 	}
 }
@@ -5,11 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -91,10 +94,42 @@ type overlay struct {
 	conn             *jsonrpc2.Conn
 	project          *cache.Project
 	diagnosticsStyle DiagnosticsStyleEnum
+	debouncer        *debouncer
+
+	// columns converts the columns of the positions in the encoding
+	// negotiated with the client.
+	columns *columnMapper
+
+	// symbols caches the document and package symbols, the entries of a
+	// document are invalidated when its content is set.
+	symbols *symbolCache
+
+	// initDone returns the channel closed once the project is ready to serve
+	// the requests, the diagnostics wait for it.
+	initDone func() <-chan struct{}
+
+	// analyses sets the analysis diagnostics published with the compiler
+	// errors.
+	analyses analysisOptions
+}
+
+func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum, encoding string, embeddedFields bool) *overlay {
+	h := &overlay{
+		conn:             conn,
+		project:          project,
+		diagnosticsStyle: diagnosticsStyle,
+		debouncer:        newDebouncer(diagnosticsDelay),
+	}
+	h.columns = &columnMapper{encoding: encoding, content: h.content}
+	h.symbols = newSymbolCache(h.columns, embeddedFields)
+	return h
 }
 
-func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum) *overlay {
-	return &overlay{conn: conn, project: project, diagnosticsStyle: diagnosticsStyle}
+// content returns the content of the file, the overlay if it is open. The
+// file is not added to the view, eg. a file of GOROOT which is only shown in
+// a location.
+func (h *overlay) content(filename string) []byte {
+	return h.project.ReadFile(filename)
 }
 
 func (h *overlay) view() source.View {
@@ -135,24 +170,31 @@ func (h *overlay) didSave(ctx context.Context, param *lsp.DidSaveTextDocumentPar
 		log.Fatal(err)
 		return
 	}
-	h.diagnosetics(ctx, f)
+	// The file system requests are handled in order by the connection, which
+	// must not wait for the project held by a reload.
+	go h.diagnosetics(ctx, f)
 }
 
 func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, text []byte) {
 	sourceURI := span.FromDocumentURI(uri)
 	h.setContent(ctx, sourceURI, text)
-	f, err := h.view().GetFile(ctx, sourceURI)
-	if err != nil {
-		return
-	}
 	if h.diagnosticsStyle != instantDiagnostics {
 		return
 	}
 
-	go h.diagnosetics(ctx, f)
+	// Rapid edits only produce diagnostics once the file has been quiet for
+	// a while, the computation for an older edit is canceled.
+	h.debouncer.schedule(sourceURI, func(ctx context.Context) {
+		f, err := h.view().GetFile(ctx, sourceURI)
+		if err != nil {
+			return
+		}
+		h.diagnosetics(ctx, f)
+	})
 }
 
 func (h *overlay) setContent(ctx context.Context, uri span.URI, content []byte) error {
+	h.symbols.invalidate(uri)
 	return h.view().SetContent(ctx, uri, content)
 }
 
@@ -165,8 +207,13 @@ const (
 )
 
 func (h *overlay) diagnosetics(ctx context.Context, f source.File) {
-	reports, err := diagnostics(ctx, f)
-	if err == nil {
+	if waitInit(ctx, h.initDone()) != nil {
+		return
+	}
+	h.project.RLock()
+	defer h.project.RUnlock()
+	reports, err := diagnostics(ctx, h.view(), f, h.columns.encoding, h.analyses)
+	if err == nil && ctx.Err() == nil {
 		for filename, diagnostics := range reports {
 			fileURI := source.ToURI(filename)
 			params := &lsp.PublishDiagnosticsParams{
@@ -179,7 +226,66 @@ func (h *overlay) diagnosetics(ctx context.Context, f source.File) {
 	}
 }
 
-func bytesOffset(content []byte, pos lsp.Position) int {
+// diagnosticsDelay is the quiet period after the last change of a file
+// before its diagnostics are computed.
+const diagnosticsDelay = 300 * time.Millisecond
+
+// debouncer runs the latest function scheduled for a key once no other
+// function has been scheduled for that key during delay.
+type debouncer struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	pending map[span.URI]*debounced
+}
+
+type debounced struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{delay: delay, pending: make(map[span.URI]*debounced)}
+}
+
+// schedule runs fn after the delay unless schedule is called again for uri
+// before. The context passed to fn is canceled as soon as a newer function
+// is scheduled for uri, fn must not publish its result once it is canceled.
+func (d *debouncer) schedule(uri span.URI, fn func(ctx context.Context)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.pending[uri]; ok {
+		prev.timer.Stop()
+		prev.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &debounced{cancel: cancel}
+	entry.timer = time.AfterFunc(d.delay, func() {
+		// fn runs outside of any request, a panic would take the
+		// server down.
+		func() {
+			defer func() {
+				util.Panicf(recover(), "the function scheduled for %s", uri)
+			}()
+			fn(ctx)
+		}()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.pending[uri] == entry {
+			delete(d.pending, uri)
+		}
+		cancel()
+	})
+	d.pending[uri] = entry
+}
+
+// bytesOffset returns the byte offset of pos in content, whose characters
+// are counted in the code units of encoding, or -1 if pos is outside of
+// content.
+func bytesOffset(content []byte, pos lsp.Position, encoding string) int {
 	var line, char, offset int
 
 	for len(content) > 0 {
@@ -187,14 +293,17 @@ func bytesOffset(content []byte, pos lsp.Position) int {
 			return offset
 		}
 		r, size := utf8.DecodeRune(content)
-		char++
-		// The offsets are based on a UTF-16 string representation.
-		// So the rune should be checked twice for two code units in UTF-16.
-		if r >= 0x10000 {
-			if line == int(pos.Line) && char == int(pos.Character) {
-				return offset
-			}
+		switch encoding {
+		case positionEncodingUTF8:
+			char += size
+		case positionEncodingUTF32:
 			char++
+		default:
+			char += utf16Len(r)
+		}
+		// A position in the middle of a rune is the start of the rune.
+		if line == int(pos.Line) && char > int(pos.Character) {
+			return offset
 		}
 		offset += size
 		content = content[size:]
@@ -216,13 +325,10 @@ func newJsonrpc2Errorf(code int64, message string) error {
 }
 
 func (h *overlay) applyChanges(ctx context.Context, params *lsp.DidChangeTextDocumentParams) ([]byte, error) {
-	if len(params.ContentChanges) == 1 && params.ContentChanges[0].Range == nil {
-		// If range is empty, we expect the full content of file, i.e. a single change with no range.
-		change := params.ContentChanges[0]
-		if change.RangeLength != 0 {
-			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "unexpected change range provided")
-		}
-		return []byte(change.Text), nil
+	if params.ContentChanges[0].Range == nil {
+		// The first change replaces the full content of file, the current
+		// content is not needed.
+		return applyContentChanges(nil, params.ContentChanges, h.columns.encoding)
 	}
 
 	sourceURI, err := fromProtocolURI(params.TextDocument.URI)
@@ -235,14 +341,29 @@ func (h *overlay) applyChanges(ctx context.Context, params *lsp.DidChangeTextDoc
 		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "file not found")
 	}
 
-	content := file.GetContent(ctx)
-	for _, change := range params.ContentChanges {
-		start := bytesOffset(content, change.Range.Start)
+	return applyContentChanges(file.GetContent(ctx), params.ContentChanges, h.columns.encoding)
+}
+
+// applyContentChanges applies the changes to content in order. A change
+// without range replaces the full content, the other ones replace the given
+// range of the content produced by the previous changes, whose characters are
+// counted in the code units of encoding.
+func applyContentChanges(content []byte, changes []lsp.TextDocumentContentChangeEvent, encoding string) ([]byte, error) {
+	for _, change := range changes {
+		if change.Range == nil {
+			if change.RangeLength != 0 {
+				return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "unexpected change range provided")
+			}
+			content = []byte(change.Text)
+			continue
+		}
+
+		start := bytesOffset(content, change.Range.Start, encoding)
 		if start == -1 {
 			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "invalid range for content change")
 		}
-		end := bytesOffset(content, change.Range.End)
-		if end == -1 {
+		end := bytesOffset(content, change.Range.End, encoding)
+		if end == -1 || end < start {
 			return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "invalid range for content change")
 		}
 		var buf bytes.Buffer
@@ -0,0 +1,150 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestApplyContentChanges(t *testing.T) {
+	rng := func(sl, sc, el, ec int) *lsp.Range {
+		return &lsp.Range{
+			Start: lsp.Position{Line: sl, Character: sc},
+			End:   lsp.Position{Line: el, Character: ec},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		changes []lsp.TextDocumentContentChangeEvent
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "full",
+			content: "package p",
+			changes: []lsp.TextDocumentContentChangeEvent{{Text: "package q"}},
+			want:    "package q",
+		},
+		{
+			name:    "incremental",
+			content: "package p\n\nvar a = 1\n",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: rng(2, 4, 2, 5), Text: "b"},
+				{Range: rng(2, 8, 2, 9), Text: "42"},
+			},
+			want: "package p\n\nvar b = 42\n",
+		},
+		{
+			name:    "full then incremental",
+			content: "package p",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Text: "package q\n"},
+				{Range: rng(1, 0, 1, 0), Text: "var x int\n"},
+			},
+			want: "package q\nvar x int\n",
+		},
+		{
+			name:    "utf-16",
+			content: "var s = \"😀世\"; var a",
+			changes: []lsp.TextDocumentContentChangeEvent{{Range: rng(0, 19, 0, 20), Text: "b"}},
+			want:    "var s = \"😀世\"; var b",
+		},
+		{
+			name:    "invalid range",
+			content: "package p",
+			changes: []lsp.TextDocumentContentChangeEvent{{Range: rng(5, 0, 5, 1), Text: "x"}},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		got, err := applyContentChanges([]byte(test.content), test.changes, positionEncodingUTF16)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	d := newDebouncer(20 * time.Millisecond)
+	uri := span.FileURI("/a.go")
+
+	var mu sync.Mutex
+	var got []int
+	done := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		i := i
+		d.schedule(uri, func(ctx context.Context) {
+			if ctx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+			close(done)
+		})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("debounced function was not called")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != 4 {
+		t.Errorf("got %v, want [4]", got)
+	}
+}
+
+func TestDebouncerCancel(t *testing.T) {
+	d := newDebouncer(0)
+	uri := span.FileURI("/a.go")
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	d.schedule(uri, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+	})
+	<-started
+	d.schedule(uri, func(ctx context.Context) {})
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight function was not canceled")
+	}
+}
+
+func TestDebouncerPanic(t *testing.T) {
+	d := newDebouncer(0)
+	uri := span.FileURI("/a.go")
+
+	panicked := make(chan struct{})
+	d.schedule(uri, func(ctx context.Context) {
+		close(panicked)
+		panic("diagnostics")
+	})
+	<-panicked
+
+	done := make(chan struct{})
+	d.schedule(uri, func(ctx context.Context) { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("function scheduled after a panic was not called")
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/imports"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/ctxcheck"
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
 	"github.com/sourcegraph/jsonrpc2"
@@ -70,6 +72,16 @@ type LangHandler struct {
 	// config is the language handler configuration. It is a combination of
 	// DefaultConfig and InitializationOptions.
 	config *Config // pointer so we panic if someone reads before we set it.
+
+	// trace is the protocol tracing, set by the initialize request and by
+	// $/setTrace, see logTrace.
+	trace string
+
+	// initDone is closed once the project is ready to serve the requests,
+	// see cache.Project.Ready, its packages are then loaded in the background
+	// unless Config.WarmupOnInitialize is set. A reload of the project
+	// replaces it, see reloadProject.
+	initDone <-chan struct{}
 }
 
 // doInit clears all internal state in h.
@@ -86,20 +98,113 @@ func (h *LangHandler) doInit(ctx context.Context, conn *jsonrpc2.Conn, init *Ini
 	imports.LocalPrefix = h.config.GoimportsLocalPrefix
 	h.init = init
 	h.cancel = NewCancel()
+	h.logLevel = cache.LogLevel(h.config.LogLevel)
+	h.trace = traceOff
+	if init.Trace == traceMessages || init.Trace == traceVerbose {
+		h.trace = init.Trace
+	}
 
 	rootPath := h.FilePath(init.Root())
 	buildFlags := []string{}
 	if len(h.config.BuildTags) > 0 {
 		buildFlags = append(buildFlags, "-tags", strings.Join(h.config.BuildTags, " "))
 	}
-	h.project = cache.NewProject(ctx, conn, rootPath, buildFlags)
-	h.overlay = newOverlay(conn, h.project, DiagnosticsStyleEnum(h.DefaultConfig.DiagnosticsStyle))
-	if err := h.project.Init(ctx, cache.CacheStyle(h.DefaultConfig.GlobalCacheStyle)); err != nil {
+	buildFlags = append(buildFlags, h.config.BuildFlags...)
+	var env []string
+	if h.config.GOOS != "" {
+		env = append(env, "GOOS="+h.config.GOOS)
+	}
+	if h.config.GOARCH != "" {
+		env = append(env, "GOARCH="+h.config.GOARCH)
+	}
+	h.project = cache.NewProject(ctx, conn, rootPath, buildFlags, env, h.config.MaxParallelism)
+	h.project.SetWalkOptions(h.config.ExcludeDirs, h.config.MaxWalkDepth)
+	h.project.SetLoadBatchSize(h.config.LoadBatchSize)
+	h.project.SetLogLevel(h.logLevel)
+	// The work done token of the initialize request cannot report a warmup
+	// which outlives it.
+	var progressToken interface{}
+	if h.config.WarmupOnInitialize {
+		progressToken = init.WorkDoneToken
+	}
+	h.project.SetWorkDoneProgress(progressToken, init.Capabilities.Window.WorkDoneProgress)
+	h.project.SetWatchedByClient(watchesFiles(init.Capabilities))
+	encoding := negotiatePositionEncoding(init.Capabilities.General.PositionEncodings)
+	h.overlay = newOverlay(conn, h.project, DiagnosticsStyleEnum(h.config.DiagnosticsStyle), encoding, h.config.SymbolEmbeddedFields)
+	h.initDone = h.project.Ready()
+	h.overlay.initDone = h.getInitDone
+	h.overlay.analyses = analysisOptions{
+		enabled:    h.config.DiagnosticsAnalyses || h.config.CtxCheck,
+		noAnalysis: h.config.NoAnalysisDirectives,
+	}
+	if h.config.CtxCheck {
+		h.overlay.analyses.extra = append(h.overlay.analyses.extra, ctxcheck.Analyzer)
+	}
+	cacheStyle := cache.CacheStyle(h.config.GlobalCacheStyle)
+	if !h.config.WarmupOnInitialize {
+		go func() {
+			defer func() {
+				if err := util.Panicf(recover(), "loading the packages of %s", rootPath); err != nil {
+					h.notifyError(err.Error())
+				}
+			}()
+			if err := h.project.Init(ctx, cacheStyle, h.config.MaxCachedPackages); err != nil {
+				h.notifyError(err.Error())
+			}
+		}()
+		return nil
+	}
+	if err := h.project.Init(ctx, cacheStyle, h.config.MaxCachedPackages); err != nil {
 		return err
 	}
 	return nil
 }
 
+// getInitDone returns the channel closed once the project is initialized, or
+// initialized again after a reload.
+func (h *LangHandler) getInitDone() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.initDone
+}
+
+// waitInit waits until the project is ready to serve the requests or ctx is
+// done.
+func waitInit(ctx context.Context, initDone <-chan struct{}) error {
+	if initDone == nil {
+		return nil
+	}
+	select {
+	case <-initDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// usesProject reports whether the request method uses the project, and must
+// wait until it is ready. The file system requests only set the content
+// of the files, their diagnostics wait for it.
+func usesProject(method string) bool {
+	switch method {
+	case "initialize", "initialized", "shutdown", "exit", "$/setTrace", "$/cancelRequest":
+		return false
+	}
+	return !isFileSystemRequest(method)
+}
+
+// deadlineExceeded reports whether ctx is done because the request timed out,
+// see Config.RequestTimeout.
+func deadlineExceeded(ctx context.Context) bool {
+	return ctx.Err() == context.DeadlineExceeded
+}
+
+// notifyPartialResults tells the user that the results of method are partial
+// because the request timed out.
+func (h *LangHandler) notifyPartialResults(method string) {
+	h.notifyInfo(fmt.Sprintf("%s timed out after %s, the results are partial", method, h.config.RequestTimeout))
+}
+
 // handle implements jsonrpc2.Handler.
 func (h *LangHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
 	return h.Handle(ctx, conn, req)
@@ -110,16 +215,19 @@ func (h *LangHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 // jsonrpc2.AsyncHandler. Ensure you have the same ordering as used in the
 // NewHandler implementation.
 func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) (result interface{}, err error) {
-	// Prevent any uncaught panics from taking the entire server down.
+	// Prevent any uncaught panics from taking the entire server down, eg.
+	// on an unexpected syntax tree. The panic is logged with its stack and
+	// only fails the request.
 	defer func() {
 		if perr := util.Panicf(recover(), "%v", req.Method); perr != nil {
-			err = perr
+			result, err = nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: perr.Error()}
 		}
 	}()
 
 	var cancelManager *cancel
 	h.mu.Lock()
 	cancelManager = h.cancel
+	initDone := h.initDone
 	if req.Method != "initialize" && h.init == nil {
 		h.mu.Unlock()
 		return nil, errors.New("server must be initialized")
@@ -132,6 +240,13 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		return nil, err
 	}
 
+	if trace := h.getTrace(); trace == traceMessages || trace == traceVerbose {
+		start := time.Now()
+		defer func() {
+			h.logTrace(conn, req, trace, time.Since(start), err)
+		}()
+	}
+
 	// Notifications don't have an ID, so they can't be cancelled
 	if cancelManager != nil && !req.Notif {
 		var cancel func()
@@ -139,6 +254,30 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		defer cancel()
 	}
 
+	// The packages are loaded while initializing, it is not timed out, and
+	// the config is not set yet.
+	if req.Method != "initialize" && !req.Notif && h.config.RequestTimeout > 0 {
+		timeout := h.config.RequestTimeout
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && deadlineExceeded(ctx) {
+				err = &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: fmt.Sprintf("%s timed out after %s", req.Method, timeout)}
+			}
+		}()
+	}
+
+	if usesProject(req.Method) {
+		if err := waitInit(ctx, initDone); err != nil {
+			return nil, err
+		}
+		// A reload of the project waits until the requests using it are
+		// done.
+		h.project.RLock()
+		defer h.project.RUnlock()
+	}
+
 	switch req.Method {
 	case "initialize":
 		if h.init != nil {
@@ -163,35 +302,60 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 
 		kind := lsp.TDSKIncremental
-		completionOp := &lsp.CompletionOptions{TriggerCharacters: []string{"."}}
+		completionOp := &lsp.CompletionOptions{ResolveProvider: true, TriggerCharacters: []string{"."}}
+
+		capabilities := lsp.ServerCapabilities{
+			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+				Kind:    &kind,
+				Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
+			},
+			CodeActionProvider:              true,
+			CompletionProvider:              completionOp,
+			DefinitionProvider:              true,
+			TypeDefinitionProvider:          true,
+			DocumentFormattingProvider:      true,
+			DocumentRangeFormattingProvider: true,
+			DocumentSymbolProvider:          true,
+			HoverProvider:                   true,
+			ReferencesProvider:              true,
+			RenameProvider:                  true,
+			WorkspaceSymbolProvider:         true,
+			ImplementationProvider:          true,
+			XWorkspaceReferencesProvider:    true,
+			XDefinitionProvider:             true,
+			XWorkspaceSymbolByProperties:    true,
+			SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+			ExecuteCommandProvider:          &lsp.ExecuteCommandOptions{Commands: executeCommands},
+			DocumentOnTypeFormattingProvider: &lsp.DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: onTypeFormattingTriggers[0],
+				MoreTriggerCharacter:  onTypeFormattingTriggers[1:],
+			},
+		}
 
-		return lsp.InitializeResult{
-			Capabilities: lsp.ServerCapabilities{
-				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-					Kind:    &kind,
-					Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				ServerCapabilities:    capabilities,
+				DeclarationProvider:   true,
+				CallHierarchyProvider: true,
+				PositionEncoding:      h.overlay.columns.encoding,
+				DocumentLinkProvider:  &documentLinkOptions{},
+				SemanticTokensProvider: &semanticTokensOptions{
+					Legend: SemanticTokensLegend{
+						TokenTypes:     semanticTokenTypes,
+						TokenModifiers: semanticTokenModifiers,
+					},
+					Full: true,
 				},
-				CodeActionProvider:              false,
-				CompletionProvider:              completionOp,
-				DefinitionProvider:              true,
-				TypeDefinitionProvider:          true,
-				DocumentFormattingProvider:      true,
-				DocumentRangeFormattingProvider: true,
-				DocumentSymbolProvider:          true,
-				HoverProvider:                   true,
-				ReferencesProvider:              true,
-				RenameProvider:                  true,
-				WorkspaceSymbolProvider:         true,
-				ImplementationProvider:          true,
-				XWorkspaceReferencesProvider:    true,
-				XDefinitionProvider:             true,
-				XWorkspaceSymbolByProperties:    true,
-				SignatureHelpProvider:           &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
 			},
 		}, nil
 
 	case "initialized":
-		// A notification that the client is ready to receive requests. Ignore
+		// A notification that the client is ready to receive requests.
+		if watchesFiles(h.init.Capabilities) {
+			if err := h.registerFileWatchers(ctx, conn); err != nil {
+				log.Printf("register the file watchers: %s", err)
+			}
+		}
 		return nil, nil
 
 	case "shutdown":
@@ -204,6 +368,18 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return nil, nil
 
+	case "$/setTrace":
+		// notification, don't send back results/errors
+		if req.Params == nil {
+			return nil, nil
+		}
+		var params SetTraceParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, nil
+		}
+		h.setTrace(params.Value)
+		return nil, nil
+
 	case "$/cancelRequest":
 		// notification, don't send back results/errors
 		if req.Params == nil {
@@ -243,6 +419,66 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleDefinition(ctx, conn, req, params)
 
+	case "textDocument/declaration":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDeclaration(ctx, conn, req, params)
+
+	case "textDocument/prepareCallHierarchy":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePrepareCallHierarchy(ctx, conn, req, params)
+
+	case "callHierarchy/incomingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params CallHierarchyCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleIncomingCalls(ctx, conn, req, params)
+
+	case "callHierarchy/outgoingCalls":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params CallHierarchyCallsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleOutgoingCalls(ctx, conn, req, params)
+
+	case "textDocument/semanticTokens/full":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params SemanticTokensParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleSemanticTokensFull(ctx, conn, req, params)
+
+	case "textDocument/documentLink":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params DocumentLinkParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleDocumentLink(ctx, conn, req, params)
+
 	case "textDocument/typeDefinition":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -273,11 +509,21 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentCompletion(ctx, conn, req, params)
 
+	case "completionItem/resolve":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params completionItem
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleCompletionResolve(ctx, conn, req, params)
+
 	case "textDocument/references":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
 		}
-		var params lsp.ReferenceParams
+		var params ReferenceParams
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
@@ -287,7 +533,7 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
 		}
-		var params lsp.TextDocumentPositionParams
+		var params ImplementationParams
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
@@ -333,6 +579,16 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 		return h.handleTextDocumentRangeFormatting(ctx, conn, req, params)
 
+	case "textDocument/onTypeFormatting":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.DocumentOnTypeFormattingParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleTextDocumentOnTypeFormatting(ctx, conn, req, params)
+
 	case "workspace/symbol":
 		if req.Params == nil {
 			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
@@ -374,6 +630,67 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 
 		return h.handleCodeAction(ctx, conn, req, params)
 
+	case "workspace/executeCommand":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.ExecuteCommandParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleExecuteCommand(ctx, conn, req, params)
+
+	case "workspace/didChangeWatchedFiles":
+		// notification, don't send back results/errors
+		if req.Params == nil {
+			return nil, nil
+		}
+		var params lsp.DidChangeWatchedFilesParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, nil
+		}
+		h.handleDidChangeWatchedFiles(ctx, conn, req, params)
+		return nil, nil
+
+	case "bingo/apiSurface":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params APISurfaceParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleAPISurface(ctx, conn, req, params)
+
+	case "bingo/packageDoc":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params PackageDocParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handlePackageDoc(ctx, conn, req, params)
+
+	case "bingo/alternate":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.TextDocumentPositionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleAlternate(ctx, conn, req, params)
+
+	case "bingo/listTests":
+		return h.handleListTests(ctx, conn, req)
+
+	case "bingo/packages":
+		return h.handlePackages(ctx, conn, req)
+
+	case "bingo/status":
+		return h.handleStatus(ctx, conn, req)
+
 	default:
 		if isFileSystemRequest(req.Method) {
 			err := h.handleFileSystemRequest(ctx, req)
@@ -14,7 +14,8 @@ import (
 // HandlerShared contains data structures that a build server and its
 // wrapped lang server may share in memory.
 type HandlerShared struct {
-	overlay *overlay // files to overlay
+	overlay  *overlay       // files to overlay
+	logLevel cache.LogLevel // filters the messages logged to the client
 }
 
 func (h *HandlerShared) FilePath(uri lsp.DocumentURI) string {
@@ -36,7 +37,26 @@ func (h *HandlerShared) notifyInfo(message string) {
 
 // NotifyLog notify log to lsp client
 func (h *HandlerShared) notifyLog(message string) {
-	_ = h.overlay.conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
+	h.logMessage(cache.LogInfo, lsp.Info, message)
+}
+
+// notifyDebug logs message to the client if the log level is debug or above,
+// eg. the duration of a search.
+func (h *HandlerShared) notifyDebug(message string) {
+	h.logMessage(cache.LogDebug, lsp.Log, message)
+}
+
+// notifyTrace logs message to the client if the log level is trace, eg. the
+// node a request resolves its position to.
+func (h *HandlerShared) notifyTrace(message string) {
+	h.logMessage(cache.LogTrace, lsp.Log, message)
+}
+
+func (h *HandlerShared) logMessage(level cache.LogLevel, typ lsp.MessageType, message string) {
+	if !h.logLevel.Enabled(level) {
+		return
+	}
+	_ = h.overlay.conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: typ, Message: message})
 }
 
 func (h *HandlerShared) View() source.View {
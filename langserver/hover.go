@@ -5,15 +5,21 @@ import (
 	"context"
 	"fmt"
 	"go/ast"
-	"go/build"
+	"go/constant"
+	godoc "go/doc"
 	"go/format"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	doc "github.com/slimsag/godocmd"
 
+	"github.com/saibing/bingo/langserver/internal/refs"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 
@@ -24,16 +30,7 @@ import (
 func (h *LangHandler) handleHover(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) (*lsp.Hover, error) {
 	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no information.
-		if _, ok := err.(*source.InvalidNodeError); ok {
-			return nil, nil
-		}
-		// This is a common error we get in production when a user is
-		// browsing a go pkg which only contains files we can't
-		// analyse (usually due to build tags). To reduce signal of
-		// actual bad errors, we return no error in this case.
-		if _, ok := err.(*build.NoGoError); ok {
+		if isNoResult(err) {
 			return nil, nil
 		}
 		return nil, err
@@ -44,22 +41,62 @@ func (h *LangHandler) handleHover(ctx context.Context, conn jsonrpc2.JSONRPC2, r
 		return nil, err
 	}
 
+	if hover := h.hoverFormatDirective(pkg, pathNodes, pos); hover != nil {
+		return hover, nil
+	}
+
+	hover, err := h.hoverNode(pkg, pathNodes, params.Position)
+	if hover == nil && err == nil && h.config.HoverASTNode {
+		return h.hoverASTNode(pkg, pathNodes[0]), nil
+	}
+	return hover, err
+}
+
+// hoverNode returns the hover of the innermost node of pathNodes, or nil if
+// it has none.
+func (h *LangHandler) hoverNode(pkg source.Package, pathNodes []ast.Node, position lsp.Position) (*lsp.Hover, error) {
 	switch node := pathNodes[0].(type) {
 	case *ast.Ident:
-		return h.hoverIdent(pkg, pathNodes, node, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node, position)
 	case *ast.BasicLit:
-		return h.hoverBasicLit(pkg, pathNodes, node, params.Position)
+		return h.hoverBasicLit(pkg, pathNodes, node, position)
 	case *ast.TypeSpec:
-		return h.hoverIdent(pkg, pathNodes, node.Name, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node.Name, position)
 	case *ast.CallExpr:
-		return h.hoverCallExpr(pkg, pathNodes, node, params.Position)
+		if hover := h.hoverConstExpr(pkg, node); hover != nil {
+			return hover, nil
+		}
+		return h.hoverCallExpr(pkg, pathNodes, node, position)
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.ParenExpr:
+		return h.hoverConstExpr(pkg, node.(ast.Expr)), nil
 	case *ast.SelectorExpr:
-		return h.hoverIdent(pkg, pathNodes, node.Sel, params.Position)
+		return h.hoverIdent(pkg, pathNodes, node.Sel, position)
+	case *ast.TypeAssertExpr:
+		// node.Type is nil in the type switch form x.(type).
+		if ident := source.TypeExprIdent(node.Type); ident != nil {
+			return h.hoverIdent(pkg, pathNodes, ident, position)
+		}
+	case *ast.StarExpr:
+		if ident := source.TypeExprIdent(node); ident != nil {
+			return h.hoverIdent(pkg, pathNodes, ident, position)
+		}
 	}
 
 	return nil, nil
 }
 
+// hoverASTNode returns the kind of node, eg. *ast.IfStmt, and its source.
+func (h *LangHandler) hoverASTNode(pkg source.Package, node ast.Node) *lsp.Hover {
+	contents := []lsp.MarkedString{lsp.RawMarkedString(fmt.Sprintf("%T", node))}
+	// Not every node can be formatted, eg. an *ast.FieldList.
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pkg.GetFileSet(), node); err == nil {
+		contents = append(contents, lsp.MarkedString{Language: "go", Value: buf.String()})
+	}
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), node)
+	return &lsp.Hover{Contents: contents, Range: &r}
+}
+
 func (h *LangHandler) hoverCallExpr(pkg source.Package, nodes []ast.Node, call *ast.CallExpr, position lsp.Position) (*lsp.Hover, error) {
 	if ident, ok := call.Fun.(*ast.Ident); ok {
 		return h.hoverIdent(pkg, nodes, ident, position)
@@ -72,6 +109,32 @@ func (h *LangHandler) hoverCallExpr(pkg source.Package, nodes []ast.Node, call *
 	return nil, source.NewInvalidNodeError(pkg.GetFileSet(), nodes[0])
 }
 
+// hoverConstExpr returns the folded value of the constant expression expr,
+// eg. len("abc") or 1 << 10. It returns nil if expr is not constant.
+func (h *LangHandler) hoverConstExpr(pkg source.Package, expr ast.Expr) *lsp.Hover {
+	tv, ok := pkg.GetTypesInfo().Types[expr]
+	if !ok || tv.Value == nil {
+		return nil
+	}
+
+	s := types.ExprString(expr) + " = " + formatConstValue(tv.Value)
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), expr)
+	return &lsp.Hover{
+		Contents: []lsp.MarkedString{{Language: "go", Value: s}},
+		Range:    &r,
+	}
+}
+
+// formatConstValue renders v, followed by its hex form for the integers
+// which do not fit in a byte.
+func formatConstValue(v constant.Value) string {
+	s := v.ExactString()
+	if x, exact := constant.Int64Val(v); exact && v.Kind() == constant.Int && (x <= -256 || x >= 256) {
+		s += fmt.Sprintf(" // %#x", x)
+	}
+	return s
+}
+
 func (h *LangHandler) hoverBasicLit(pkg source.Package, nodes []ast.Node, basicLit *ast.BasicLit, position lsp.Position) (*lsp.Hover, error) {
 	if len(nodes) == 1 {
 		return nil, nil
@@ -80,36 +143,133 @@ func (h *LangHandler) hoverBasicLit(pkg source.Package, nodes []ast.Node, basicL
 	if node, ok := nodes[1].(*ast.ImportSpec); ok {
 		importPkg := pkg.GetImport(strings.Trim(node.Path.Value, `"`))
 		comments := source.PackageDoc(importPkg.GetSyntax(), importPkg.GetName())
-		r := rangeForNode(pkg.GetFileSet(), node)
+		r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), node)
 		return &lsp.Hover{
 			Contents: maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: "package " + importPkg.GetName()}}),
 			Range:    &r,
 		}, nil
 	}
 
+	if field, ok := nodes[1].(*ast.Field); ok && field.Tag == basicLit {
+		return h.hoverStructTag(pkg, basicLit), nil
+	}
+
 	return nil, nil
 }
 
+// hoverStructTag lists the key/value pairs of the struct tag lit, eg.
+// `json:"name,omitempty"`. It returns nil if the tag is empty or does not
+// follow the conventional format.
+func (h *LangHandler) hoverStructTag(pkg source.Package, lit *ast.BasicLit) *lsp.Hover {
+	tag, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil
+	}
+	pairs := parseStructTag(tag)
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, pair := range pairs {
+		fmt.Fprintf(&b, "- `%s`: `%s`\n", pair.key, pair.value)
+	}
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), lit)
+	return &lsp.Hover{
+		Contents: []lsp.MarkedString{lsp.RawMarkedString(b.String())},
+		Range:    &r,
+	}
+}
+
+type structTagPair struct {
+	key, value string
+}
+
+// parseStructTag returns the key/value pairs of tag in order, following the
+// conventional format of reflect.StructTag. The pairs after a malformed one
+// are dropped.
+func parseStructTag(tag string) []structTagPair {
+	var pairs []structTagPair
+	for tag != "" {
+		// Skip the leading space.
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		// Scan to the colon. A space, a quote or a control character is a
+		// syntax error.
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		// Scan the quoted string to find the value.
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			break
+		}
+		tag = tag[i+1:]
+		pairs = append(pairs, structTagPair{key: key, value: value})
+	}
+	return pairs
+}
+
 func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident, position lsp.Position) (*lsp.Hover, error) {
 	o := source.FindIdentObject(pkg, ident)
 	t := source.FindIdentType(pkg, ident)
 
 	if o == nil && t == nil {
+		if hover := h.hoverTypeSwitchGuard(pkg, pathNodes, ident); hover != nil {
+			return hover, nil
+		}
 		if ident.Obj != nil {
 			contents := maybeAddComments("", []lsp.MarkedString{{Language: "go", Value: ident.String()}})
-			r := rangeForNode(pkg.GetFileSet(), ident)
+			r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), ident)
 			return &lsp.Hover{Contents: contents, Range: &r}, nil
 		}
 		return h.packageStatement(pkg, ident, position)
 	}
 
+	if o != nil && h.skipHover(pkg, o) {
+		return nil, nil
+	}
+
+	var footer string
+	if h.config.HoverSymbolFooter {
+		footer = h.symbolFooter(pkg, ident, o)
+	}
+
 	isBuiltIn, builtInObject := o != nil && !o.Pos().IsValid(), o
 	if isBuiltIn {
 		// Only builtins have invalid position, and don't have useful info.
-		pkg = h.project.GetBuiltinPackage()
-		if pkg == nil {
-			return nil, nil
+		builtinPkg := h.project.GetBuiltinPackage()
+		if builtinPkg == nil {
+			// The builtin package of GOROOT is not loaded, eg. if GOROOT
+			// is not found, the object is all there is to show.
+			r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), ident)
+			return &lsp.Hover{Contents: []lsp.MarkedString{{Language: "go", Value: types.ObjectString(o, nil)}}, Range: &r}, nil
 		}
+		pkg = builtinPkg
 		o = source.FindObject(pkg, o)
 		if o == nil {
 			return nil, nil
@@ -124,7 +284,9 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		// TODO(sqs): make this be like (T).F not "struct field F string".
 		s = "struct " + o.String()
 	} else if o != nil {
-		if obj, ok := o.(*types.TypeName); ok {
+		if obj, ok := o.(*types.TypeName); ok && isAlias(obj) {
+			s, extra = aliasHover(pkg, obj, qf)
+		} else if obj, ok := o.(*types.TypeName); ok {
 			typ := obj.Type().Underlying()
 			if _, ok := typ.(*types.Struct); ok {
 				s = "type " + obj.Name() + " struct"
@@ -156,10 +318,17 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		s = types.TypeString(t, qf)
 	}
 
+	if path := promotionPath(pkg, pathNodes, ident, qf); path != "" {
+		s += " // promoted via " + path
+	}
+
 	comments, err := source.FindComments(pkg, pkg.GetFileSet(), o, ident.Name)
 	if err != nil {
 		return nil, err
 	}
+	if comments == "" {
+		comments = h.findStdlibComments(o, ident.Name)
+	}
 	contents := maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: s}})
 	if extra != "" {
 		// If we have extra info, ensure it comes after the usually
@@ -167,15 +336,695 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 		contents = append(contents, lsp.MarkedString{Language: "go", Value: extra})
 	}
 
-	r := rangeForNode(pkg.GetFileSet(), ident)
+	if !isBuiltIn {
+		if example := h.findExample(pkg.GetFileSet(), o); example != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: example})
+		}
+	}
+
+	if v, ok := o.(*types.Var); ok {
+		if origin := varTypeOrigin(pkg, v, qf); origin != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: origin})
+		}
+	}
+
+	if c, ok := o.(*types.Const); ok {
+		if flags := flagCombination(pkg, c); flags != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: flags})
+		}
+	}
+
+	if c, ok := o.(*types.Const); ok && h.config.HoverBitFlags {
+		if flags := bitFlagGroup(pkg, c); flags != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: flags})
+		}
+	}
+
+	if fn, ok := o.(*types.Func); ok && h.config.HoverMethodSet {
+		if methods := methodSet(fn, qf); methods != "" {
+			contents = append(contents, lsp.MarkedString{Language: "go", Value: methods})
+		}
+	}
+
+	if footer != "" {
+		contents = append(contents, lsp.MarkedString{Language: "", Value: footer})
+	}
+
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), ident)
 	return &lsp.Hover{Contents: contents, Range: &r}, nil
 }
 
+// promotionPath returns the embedded fields through which the selector ident
+// of pathNodes is promoted, eg. "X.Reader" for x.ReadByte when the ReadByte
+// method comes from the Reader field embedded in X. It returns an empty string
+// if ident is not promoted.
+func promotionPath(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident, qf types.Qualifier) string {
+	var sel *types.Selection
+	for _, n := range pathNodes {
+		if selExpr, ok := n.(*ast.SelectorExpr); ok && selExpr.Sel == ident {
+			sel = pkg.GetTypesInfo().Selections[selExpr]
+			break
+		}
+	}
+	if sel == nil || len(sel.Index()) < 2 {
+		return ""
+	}
+
+	typ := sel.Recv()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	path := []string{types.TypeString(typ, qf)}
+	if named, ok := typ.(*types.Named); ok {
+		path[0] = named.Obj().Name()
+	}
+	for _, i := range sel.Index()[:len(sel.Index())-1] {
+		if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return ""
+		}
+		field := st.Field(i)
+		path = append(path, field.Name())
+		typ = field.Type()
+	}
+	return strings.Join(path, ".")
+}
+
+// methodSet renders the method set of the receiver type of the method fn,
+// including the methods of its pointer type, one method per line. The
+// methods promoted from an embedded field are marked with a comment. It
+// returns an empty string if fn is not a method of a named type.
+func methodSet(fn *types.Func, qf types.Qualifier) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	recvType := recv.Type()
+	if ptr, ok := recvType.(*types.Pointer); ok {
+		recvType = ptr.Elem()
+	}
+	named, ok := recvType.(*types.Named)
+	if !ok {
+		return ""
+	}
+
+	var typ types.Type = named
+	if !types.IsInterface(named) {
+		typ = types.NewPointer(named)
+	}
+	mset := types.NewMethodSet(typ)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// method set of %s\n", types.TypeString(typ, qf))
+	for i := 0; i < mset.Len(); i++ {
+		sel := mset.At(i)
+		m := sel.Obj().(*types.Func)
+		sig := m.Type().(*types.Signature)
+		fmt.Fprintf(&b, "func (%s) %s%s", types.TypeString(sig.Recv().Type(), qf), m.Name(), strings.TrimPrefix(types.TypeString(sig, qf), "func"))
+		if len(sel.Index()) > 1 {
+			if st, ok := named.Underlying().(*types.Struct); ok {
+				fmt.Fprintf(&b, " // promoted from %s", st.Field(sel.Index()[0]).Name())
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// aliasHover returns the declaration of the alias obj as written, eg.
+// "type T = pkg.Concrete", and the underlying type of the aliased type if it
+// is a struct or an interface.
+func aliasHover(pkg source.Package, obj *types.TypeName, qf types.Qualifier) (string, string) {
+	s := types.ObjectString(obj, qf)
+	if rhs := aliasedTypeExpr(pkg, obj); rhs != nil {
+		s = "type " + obj.Name() + " = " + types.ExprString(rhs)
+	}
+
+	var extra string
+	switch typ := obj.Type().Underlying().(type) {
+	case *types.Struct, *types.Interface:
+		extra = prettyPrintTypesString(types.TypeString(typ, qf))
+	}
+	return s, extra
+}
+
+// aliasedTypeExpr returns the type expression of the declaration of the
+// alias obj, or nil if it is not found.
+func aliasedTypeExpr(pkg source.Package, obj *types.TypeName) ast.Expr {
+	declPkg := pkg
+	if obj.Pkg() != nil && obj.Pkg().Path() != pkg.GetPkgPath() {
+		declPkg = pkg.GetImport(obj.Pkg().Path())
+		if declPkg == nil {
+			return nil
+		}
+	}
+
+	for _, file := range declPkg.GetSyntax() {
+		if obj.Pos() < file.Pos() || obj.Pos() >= file.End() {
+			continue
+		}
+		var rhs ast.Expr
+		ast.Inspect(file, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok && spec.Name.Pos() == obj.Pos() && spec.Assign.IsValid() {
+				rhs = spec.Type
+			}
+			return rhs == nil
+		})
+		return rhs
+	}
+	return nil
+}
+
+// symbolFooter returns the descriptor ID of the symbol of ident, eg.
+// "github.com/foo/bar/-/T/M", followed by the kind of o. It returns an empty
+// string for the symbols declared in a function, which have no descriptor.
+func (h *LangHandler) symbolFooter(pkg source.Package, ident *ast.Ident, o types.Object) string {
+	if o == nil {
+		return ""
+	}
+	if scope := o.Parent(); scope != nil && scope != types.Universe && (o.Pkg() == nil || scope != o.Pkg().Scope()) {
+		return ""
+	}
+
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), ident.Pos(), ident.Pos())
+	if err != nil {
+		return ""
+	}
+	def, err := refs.DefInfo(pkg.GetTypes(), pkg.GetTypesInfo(), pathNodes, ident.Pos())
+	if err != nil {
+		return ""
+	}
+	desc, err := defSymbolDescriptor(pkg, h.project, *def, h.getFindPackageFunc())
+	if err != nil {
+		return ""
+	}
+
+	if kind := objectKind(o); kind != "" {
+		return desc.ID + " (" + kind + ")"
+	}
+	return desc.ID
+}
+
+// objectKind returns the keyword of the kind of o, as used by the symbol
+// queries, see keywords.
+func objectKind(o types.Object) string {
+	switch o := o.(type) {
+	case *types.PkgName:
+		return "package"
+	case *types.TypeName:
+		return "type"
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.Builtin:
+		return "func"
+	case *types.Var:
+		if o.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.Const:
+		return "const"
+	}
+	return ""
+}
+
+// findStdlibComments finds the comments of a standard library object. In
+// module mode the standard library packages may be absent from the workspace
+// cache, so they are loaded from GOROOT on demand.
+func (h *LangHandler) findStdlibComments(o types.Object, name string) string {
+	if o == nil || o.Pkg() == nil {
+		return ""
+	}
+
+	if _, ok := o.(*types.PkgName); ok {
+		return ""
+	}
+
+	stdPkg := h.project.GetStdlibPackage(o.Pkg().Path())
+	if stdPkg == nil {
+		return ""
+	}
+
+	stdObj := source.FindObject(stdPkg, o)
+	if stdObj == nil {
+		return ""
+	}
+
+	comments, err := source.FindComments(stdPkg, stdPkg.GetFileSet(), stdObj, name)
+	if err != nil {
+		return ""
+	}
+	return comments
+}
+
+// skipHover reports whether the hover of o is filtered out by the
+// HoverSkipUnexported and HoverSkipExternal options.
+func (h *LangHandler) skipHover(pkg source.Package, o types.Object) bool {
+	if _, ok := o.(*types.PkgName); ok {
+		return false
+	}
+	if h.config.HoverSkipUnexported && !o.Exported() {
+		return true
+	}
+	if h.config.HoverSkipExternal {
+		// Only builtins have invalid position, they are in GOROOT.
+		if !o.Pos().IsValid() {
+			return true
+		}
+		return h.project.IsExternal(pkg.GetFileSet().Position(o.Pos()).Filename)
+	}
+	return false
+}
+
+// findExample returns the code of the example of the exported package level
+// function, type or method o, eg. ExampleFoo, ExampleFoo_suffix or ExampleT_M,
+// found in the test files of the directory of its package, see
+// cache.Project.Examples. The example without suffix is preferred, only one
+// example is returned.
+func (h *LangHandler) findExample(fset *token.FileSet, o types.Object) string {
+	if o == nil || o.Pkg() == nil || !o.Exported() {
+		return ""
+	}
+
+	var name string
+	switch o := o.(type) {
+	case *types.TypeName:
+		name = o.Name()
+	case *types.Func:
+		recv := o.Type().(*types.Signature).Recv()
+		if recv == nil {
+			name = o.Name()
+			break
+		}
+		recvType := recv.Type()
+		if ptr, ok := recvType.(*types.Pointer); ok {
+			recvType = ptr.Elem()
+		}
+		named, ok := recvType.(*types.Named)
+		if !ok {
+			return ""
+		}
+		name = named.Obj().Name() + "_" + o.Name()
+	default:
+		return ""
+	}
+	if o.Parent() != nil && o.Parent() != o.Pkg().Scope() {
+		return ""
+	}
+
+	filename := fset.Position(o.Pos()).Filename
+	if filename == "" {
+		return ""
+	}
+	examplesFset, examples := h.project.Examples(context.Background(), filepath.Dir(filename))
+
+	var example *godoc.Example
+	for _, ex := range examples {
+		if ex.Name == name {
+			example = ex
+			break
+		}
+		if example == nil && isExampleSuffix(ex.Name, name) {
+			example = ex
+		}
+	}
+	if example == nil {
+		return ""
+	}
+	return exampleCode(examplesFset, example)
+}
+
+// isExampleSuffix reports whether exampleName is name followed by a suffix,
+// which starts with a lower case letter.
+func isExampleSuffix(exampleName, name string) bool {
+	if !strings.HasPrefix(exampleName, name+"_") {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(exampleName[len(name)+1:])
+	return unicode.IsLower(r)
+}
+
+// exampleCode renders the body of the example without its braces, followed
+// by its expected output if any.
+func exampleCode(fset *token.FileSet, example *godoc.Example) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, example.Code); err != nil {
+		return ""
+	}
+	code := buf.String()
+	if _, ok := example.Code.(*ast.BlockStmt); ok {
+		code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+		lines := strings.Split(strings.Trim(code, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimPrefix(line, "\t")
+		}
+		code = strings.Join(lines, "\n")
+	}
+
+	if example.Output != "" {
+		code += "\n\n// Output:"
+		for _, line := range strings.Split(strings.TrimSuffix(example.Output, "\n"), "\n") {
+			code += "\n// " + line
+		}
+	}
+	return code
+}
+
+// bitFlagGroup renders the constants of the `1 << iota` bit flag group which
+// declares c, with their values in hex and binary. It returns an empty string
+// if c is not part of such a group.
+func bitFlagGroup(pkg source.Package, c *types.Const) string {
+	declPkg := declPackage(pkg, c)
+	if declPkg == nil {
+		return ""
+	}
+
+	info := declPkg.GetTypesInfo()
+	decl := findConstDecl(declPkg.GetSyntax(), c.Pos())
+	if info == nil || decl == nil || !isBitFlagDecl(info, decl) {
+		return ""
+	}
+
+	var b bytes.Buffer
+	b.WriteString("const (\n")
+	for _, spec := range decl.Specs {
+		for _, name := range spec.(*ast.ValueSpec).Names {
+			flag, ok := info.Defs[name].(*types.Const)
+			if !ok || name.Name == "_" {
+				continue
+			}
+			v, exact := constant.Uint64Val(constant.ToInt(flag.Val()))
+			if !exact {
+				continue
+			}
+			fmt.Fprintf(&b, "\t%s = %#x // 0b%s\n", name.Name, v, strconv.FormatUint(v, 2))
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// varTypeOrigin tells whether the type of the variable v is declared or
+// inferred from its value, eg. "// type inferred by :=". If v has an
+// interface type, the concrete type of the value it is initialized with is
+// added, eg. "// type declared, initialized with *T". It returns
+// an empty string for the fields and the parameters, or if the declaration
+// of v is not found.
+func varTypeOrigin(pkg source.Package, v *types.Var, qf types.Qualifier) string {
+	if v.IsField() || !v.Pos().IsValid() {
+		return ""
+	}
+	declPkg := declPackage(pkg, v)
+	if declPkg == nil || declPkg.GetTypesInfo() == nil {
+		return ""
+	}
+	nodes, err := source.GetPathNodes(declPkg, declPkg.GetFileSet(), v.Pos(), v.Pos())
+	if err != nil || len(nodes) < 2 {
+		return ""
+	}
+	ident, ok := nodes[0].(*ast.Ident)
+	if !ok {
+		return ""
+	}
+
+	var origin string
+	var value ast.Expr
+	switch decl := nodes[1].(type) {
+	case *ast.AssignStmt:
+		if decl.Tok != token.DEFINE {
+			return ""
+		}
+		if len(nodes) > 2 {
+			if ts, ok := nodes[2].(*ast.TypeSwitchStmt); ok && ts.Assign == decl {
+				if clause := typeSwitchClause(declPkg, ts, v); clause != nil {
+					return "// type of " + caseString(clause)
+				}
+			}
+		}
+		origin = "type inferred by :="
+		value = assignedValue(ident, decl.Lhs, decl.Rhs)
+	case *ast.ValueSpec:
+		origin = "type inferred from the value"
+		if decl.Type != nil {
+			origin = "type declared"
+		}
+		lhs := make([]ast.Expr, len(decl.Names))
+		for i, name := range decl.Names {
+			lhs[i] = name
+		}
+		value = assignedValue(ident, lhs, decl.Values)
+	case *ast.RangeStmt:
+		origin = "type inferred by range"
+	default:
+		return ""
+	}
+
+	if value != nil && types.IsInterface(v.Type()) {
+		typ := declPkg.GetTypesInfo().TypeOf(value)
+		if typ != nil && !types.IsInterface(typ) && typ != types.Typ[types.UntypedNil] {
+			origin += ", initialized with " + types.TypeString(typ, qf)
+		}
+	}
+	return "// " + origin
+}
+
+// assignedValue returns the value of rhs assigned to ident of lhs, or nil if
+// there is none, eg. if a call returning several values is assigned.
+func assignedValue(ident *ast.Ident, lhs, rhs []ast.Expr) ast.Expr {
+	if len(lhs) != len(rhs) {
+		return nil
+	}
+	for i, expr := range lhs {
+		if expr == ident {
+			return rhs[i]
+		}
+	}
+	return nil
+}
+
+// hoverTypeSwitchGuard returns the hover of the symbol ident declared by the
+// guard of a type switch, eg. v in switch v := x.(type), or nil if ident is
+// not such a symbol. The symbol has no object, each clause declares its own
+// variable with the type of the clause, they are listed in order.
+func (h *LangHandler) hoverTypeSwitchGuard(pkg source.Package, pathNodes []ast.Node, ident *ast.Ident) *lsp.Hover {
+	if len(pathNodes) < 3 {
+		return nil
+	}
+	assign, ok := pathNodes[1].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || assign.Lhs[0] != ident {
+		return nil
+	}
+	ts, ok := pathNodes[2].(*ast.TypeSwitchStmt)
+	if !ok || ts.Assign != assign {
+		return nil
+	}
+
+	qf := func(*types.Package) string { return "" }
+	var lines []string
+	for _, stmt := range ts.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if obj := pkg.GetTypesInfo().Implicits[clause]; obj != nil {
+			lines = append(lines, types.ObjectString(obj, qf)+" // "+caseString(clause))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), ident)
+	return &lsp.Hover{
+		Contents: []lsp.MarkedString{{Language: "go", Value: strings.Join(lines, "\n")}},
+		Range:    &r,
+	}
+}
+
+// typeSwitchClause returns the clause of the type switch ts declaring the
+// variable v, or nil if there is none.
+func typeSwitchClause(pkg source.Package, ts *ast.TypeSwitchStmt, v *types.Var) *ast.CaseClause {
+	for _, stmt := range ts.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if pkg.GetTypesInfo().Implicits[clause] == v {
+			return clause
+		}
+	}
+	return nil
+}
+
+// caseString returns the head of clause, eg. "case int, string" or
+// "default".
+func caseString(clause *ast.CaseClause) string {
+	if clause.List == nil {
+		return "default"
+	}
+	exprs := make([]string, len(clause.List))
+	for i, expr := range clause.List {
+		exprs[i] = types.ExprString(expr)
+	}
+	return "case " + strings.Join(exprs, ", ")
+}
+
+// declPackage returns the package declaring obj, pkg or one of its imports,
+// or nil if it is not found.
+func declPackage(pkg source.Package, obj types.Object) source.Package {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	if obj.Pkg().Path() != pkg.GetPkgPath() {
+		return pkg.GetImport(obj.Pkg().Path())
+	}
+	return pkg
+}
+
+// flagCombination renders the value of c as an OR of the single bit constants
+// of its type declared in its package, eg. "RW = R | W // 0x3" for
+// `const RW = R | W`. The constants named by the value of c are preferred. It
+// returns an empty string if the value of c is not an expression containing
+// `|` or cannot be decomposed.
+func flagCombination(pkg source.Package, c *types.Const) string {
+	declPkg := declPackage(pkg, c)
+	if declPkg == nil {
+		return ""
+	}
+	v, exact := constant.Uint64Val(constant.ToInt(c.Val()))
+	if !exact || v == 0 {
+		return ""
+	}
+	info := declPkg.GetTypesInfo()
+	decl := findConstDecl(declPkg.GetSyntax(), c.Pos())
+	if info == nil || decl == nil {
+		return ""
+	}
+	expr := constValueExpr(decl, c)
+	if expr == nil || !containsOr(expr) {
+		return ""
+	}
+
+	// The constants named by the expression first, then the others of the
+	// package in the order of their declaration.
+	var flags []*types.Const
+	ast.Inspect(expr, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if flag, ok := info.Uses[id].(*types.Const); ok {
+			flags = append(flags, flag)
+		}
+		return true
+	})
+	scope := c.Pkg().Scope()
+	var siblings []*types.Const
+	for _, name := range scope.Names() {
+		if flag, ok := scope.Lookup(name).(*types.Const); ok {
+			siblings = append(siblings, flag)
+		}
+	}
+	sort.Slice(siblings, func(i, j int) bool {
+		return siblings[i].Pos() < siblings[j].Pos()
+	})
+	flags = append(flags, siblings...)
+
+	names := make(map[uint64]string)
+	var bits []uint64
+	rest := v
+	for _, flag := range flags {
+		if flag == c || !types.Identical(flag.Type(), c.Type()) {
+			continue
+		}
+		bit, exact := constant.Uint64Val(constant.ToInt(flag.Val()))
+		if !exact || bit == 0 || bit&(bit-1) != 0 || rest&bit == 0 {
+			continue
+		}
+		rest &^= bit
+		names[bit] = flag.Name()
+		bits = append(bits, bit)
+	}
+	if rest != 0 || len(bits) == 0 {
+		return ""
+	}
+	sort.Slice(bits, func(i, j int) bool { return bits[i] < bits[j] })
+	or := make([]string, len(bits))
+	for i, bit := range bits {
+		or[i] = names[bit]
+	}
+	return fmt.Sprintf("%s = %s // %#x", c.Name(), strings.Join(or, " | "), v)
+}
+
+// constValueExpr returns the expression of the value of c in decl, which is
+// repeated from the previous specs if its spec has no value, as with iota.
+func constValueExpr(decl *ast.GenDecl, c *types.Const) ast.Expr {
+	var values []ast.Expr
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		if len(spec.Values) > 0 {
+			values = spec.Values
+		}
+		for i, name := range spec.Names {
+			if name.Pos() == c.Pos() && i < len(values) {
+				return values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// containsOr reports whether expr contains a `|` operation.
+func containsOr(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if b, ok := n.(*ast.BinaryExpr); ok && b.Op == token.OR {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// findConstDecl returns the const declaration enclosing pos.
+func findConstDecl(files []*ast.File, pos token.Pos) *ast.GenDecl {
+	for _, file := range files {
+		if pos < file.Pos() || pos >= file.End() {
+			continue
+		}
+		for _, d := range file.Decls {
+			if decl, ok := d.(*ast.GenDecl); ok && decl.Tok == token.CONST && decl.Pos() <= pos && pos < decl.End() {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// isBitFlagDecl reports whether decl contains a `1 << iota` value.
+func isBitFlagDecl(info *types.Info, decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		for _, value := range spec.(*ast.ValueSpec).Values {
+			expr, ok := value.(*ast.BinaryExpr)
+			if !ok || expr.Op != token.SHL {
+				continue
+			}
+			one, ok := expr.X.(*ast.BasicLit)
+			if !ok || one.Value != "1" {
+				continue
+			}
+			if id, ok := expr.Y.(*ast.Ident); ok && info.Uses[id] == types.Universe.Lookup("iota") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (h *LangHandler) packageStatement(pkg source.Package, ident *ast.Ident, position lsp.Position) (*lsp.Hover, error) {
 	comments := source.PackageDoc(pkg.GetSyntax(), ident.Name)
 
 	// Package statement idents don't have an object, so try that separately.
-	r := rangeForNode(pkg.GetFileSet(), ident)
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), ident)
 	if pkgName := packageStatementName(pkg.GetFileSet(), pkg.GetSyntax(), ident); pkgName != "" {
 		return &lsp.Hover{
 			Contents: maybeAddComments(comments, []lsp.MarkedString{{Language: "go", Value: "package " + pkgName}}),
@@ -218,19 +1067,40 @@ func commentsToText(cgroups []*ast.CommentGroup) (text string) {
 	return text
 }
 
+const (
+	// prettyPrintMaxDepth is the maximum nesting of the structs and
+	// interfaces printed by prettyPrintTypesString, the fields of the deeper
+	// ones are elided, eg. struct {…}.
+	prettyPrintMaxDepth = 8
+
+	// prettyPrintMaxLen is the length in bytes after which the output of
+	// prettyPrintTypesString is truncated, so that the hover of a huge
+	// generated type does not flood the editor.
+	prettyPrintMaxLen = 16 << 10
+)
+
 // prettyPrintTypesString is pretty printing specific to the output of
 // types.*String. Instead of re-implementing the printer, we can just
-// transform its output.
+// transform its output. The nesting and the length of the output are
+// bounded by prettyPrintMaxDepth and prettyPrintMaxLen, … marks what is
+// elided.
 func prettyPrintTypesString(s string) string {
 	// Don't bother including the fields if it is empty
 	if strings.HasSuffix(s, "{}") {
 		return ""
 	}
 	var b bytes.Buffer
-	b.Grow(len(s))
+	if len(s) < prettyPrintMaxLen {
+		b.Grow(len(s))
+	} else {
+		b.Grow(prettyPrintMaxLen)
+	}
 	depth := 0
 	var inTag bool
 	for i := 0; i < len(s); i++ {
+		if b.Len() > prettyPrintMaxLen {
+			return truncateTypesString(b.String())
+		}
 		c := s[i]
 		switch c {
 		case ';':
@@ -259,7 +1129,7 @@ func prettyPrintTypesString(s string) string {
 			if i == len(s)-1 {
 				// This should never happen, but in case it
 				// does give up
-				return s
+				return truncateTypesString(s)
 			}
 
 			n := s[i+1]
@@ -268,6 +1138,13 @@ func prettyPrintTypesString(s string) string {
 				b.WriteString("{}")
 				// We have already written }, so skip
 				i++
+			} else if depth == prettyPrintMaxDepth {
+				end := closingBrace(s, i)
+				if end < 0 {
+					return truncateTypesString(s)
+				}
+				b.WriteString(" {…}")
+				i = end
 			} else {
 				// We expect fields to follow, insert a newline and space
 				depth++
@@ -280,7 +1157,7 @@ func prettyPrintTypesString(s string) string {
 		case '}':
 			depth--
 			if depth < 0 {
-				return s
+				return truncateTypesString(s)
 			}
 			b.WriteString("\n}")
 
@@ -291,6 +1168,48 @@ func prettyPrintTypesString(s string) string {
 	return b.String()
 }
 
+// closingBrace returns the index of the brace closing the one at index open
+// in the output s of types.*String, -1 if there is none. The braces of the
+// struct tags do not count.
+func closingBrace(s string, open int) int {
+	depth := 0
+	inTag := false
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			inTag = !inTag
+		case '{':
+			if !inTag {
+				depth++
+			}
+		case '}':
+			if inTag {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// truncateTypesString truncates s to prettyPrintMaxLen bytes, at a rune
+// boundary, and appends … if it is longer.
+func truncateTypesString(s string) string {
+	if len(s) <= prettyPrintMaxLen {
+		return s
+	}
+	i := prettyPrintMaxLen
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + "…"
+}
+
 // packageForFile returns the import path and pkg from pkgs that contains the
 // named file.
 func packageForFile(pkgs map[string]*ast.Package, filename string) (string, *ast.Package, error) {
@@ -1,6 +1,8 @@
 package langserver
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/sourcegraph/go-lsp"
@@ -70,3 +72,52 @@ func TestPrettyprintTypesString(t *testing.T) {
 		require.Equal(testCase.expected, actual)
 	}
 }
+
+func TestPrettyprintTypesStringBounds(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// A struct nested 50 times, eg. struct{F struct{F int}}, whose
+	// innermost struct has a tag with braces.
+	nested := strings.Repeat("struct{F ", 50) + `int "json:\"{}\""` + strings.Repeat("}", 50)
+	actual := prettyPrintTypesString(nested)
+	expected := "struct {\n"
+	for depth := 1; depth < prettyPrintMaxDepth; depth++ {
+		expected += strings.Repeat("    ", depth) + "F struct {\n"
+	}
+	expected += strings.Repeat("    ", prettyPrintMaxDepth) + "F struct {…}"
+	expected += strings.Repeat("\n}", prettyPrintMaxDepth)
+	require.Equal(expected, actual)
+
+	var fields []string
+	for i := 0; i < 10000; i++ {
+		fields = append(fields, fmt.Sprintf("Field%d int", i))
+	}
+	actual = prettyPrintTypesString("struct{" + strings.Join(fields, "; ") + "}")
+	require.True(strings.HasSuffix(actual, "…"))
+	require.True(len(actual) <= prettyPrintMaxLen+len("…"), "got %d bytes", len(actual))
+	require.True(strings.HasPrefix(actual, "struct {\n    Field0 int\n    Field1 int\n"))
+}
+
+func TestParseStructTag(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	testCases := []struct {
+		input    string
+		expected []structTagPair
+	}{
+		{input: ``},
+		{input: `json:"name"`, expected: []structTagPair{{key: "json", value: "name"}}},
+		{
+			input:    `json:"name,omitempty"  xml:"a\"b" gorm:"unique;not null"`,
+			expected: []structTagPair{{key: "json", value: "name,omitempty"}, {key: "xml", value: `a"b`}, {key: "gorm", value: "unique;not null"}},
+		},
+		{input: `json:"name" broken`, expected: []structTagPair{{key: "json", value: "name"}}},
+		{input: `json:name`},
+	}
+
+	for _, testCase := range testCases {
+		require.Equal(testCase.expected, parseStructTag(testCase.input), testCase.input)
+	}
+}
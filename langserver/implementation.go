@@ -3,9 +3,11 @@ package langserver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go/ast"
 	"go/types"
 	"sort"
+	"sync"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -16,13 +18,29 @@ import (
 	"golang.org/x/tools/go/types/typeutil"
 )
 
-func (h *LangHandler) handleTextDocumentImplementation(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.TextDocumentPositionParams) ([]*lspext.ImplementationLocation, error) {
+// The directions of the implementation relationships returned by
+// textDocument/implementation.
+const (
+	implementationBoth = "both"
+	implementationTo   = "to"
+	implementationFrom = "from"
+)
+
+func (h *LangHandler) handleTextDocumentImplementation(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params ImplementationParams) ([]*lspext.ImplementationLocation, error) {
+	direction := params.Direction
+	if direction == "" {
+		direction = h.config.ImplementationDirection
+	}
+	switch direction {
+	case "", implementationBoth, implementationTo, implementationFrom:
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid implementation direction %q", direction)}
+	}
+
 	// Do initial cached, standard typeCheck pass to get position arg.
 	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no information.
-		if _, ok := err.(*source.InvalidNodeError); ok {
+		if isNoResult(err) {
 			return []*lspext.ImplementationLocation{}, nil
 		}
 		return nil, err
@@ -31,12 +49,37 @@ func (h *LangHandler) handleTextDocumentImplementation(ctx context.Context, conn
 	pathNodes, _ := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
 	pathNodes, action := findInterestingNode(pkg, pathNodes)
 
-	return implements(h.project, pkg, pathNodes, action)
+	locs, err := implements(ctx, h.project, h.overlay.columns.cached(), pkg, pathNodes, action, h.config.ImplementationStdlib, h.config.ImplementationMethods)
+	if err != nil {
+		return nil, err
+	}
+	return filterImplementations(locs, direction), nil
+}
+
+// filterImplementations returns the locations of locs whose relationship
+// has the given direction, all of them if direction is "both" or empty.
+func filterImplementations(locs []*lspext.ImplementationLocation, direction string) []*lspext.ImplementationLocation {
+	if direction == "" || direction == implementationBoth {
+		return locs
+	}
+
+	filtered := make([]*lspext.ImplementationLocation, 0, len(locs))
+	for _, loc := range locs {
+		if loc.Type == direction {
+			filtered = append(filtered, loc)
+		}
+	}
+	return filtered
 }
 
 // Adapted from golang.org/x/tools/cmd/guru (Copyright (c) 2013 The Go Authors). All rights
 // reserved. See NOTICE for full license.
-func implements(project *cache.Project, pkg source.Package, path []ast.Node, action action) ([]*lspext.ImplementationLocation, error) {
+//
+// The standard library packages imported by the project are only searched if
+// stdlib is true, as scanning them is expensive. If methods is true and the
+// selected type is an interface, the types implementing it are located by
+// their methods satisfying it.
+func implements(ctx context.Context, project *cache.Project, columns *columnMapper, pkg source.Package, path []ast.Node, action action, stdlib, methods bool) ([]*lspext.ImplementationLocation, error) {
 	var method *types.Func
 	var T types.Type // selected type (receiver if method != nil)
 
@@ -66,36 +109,20 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 		return nil, errors.New("not a type, method, or value")
 	}
 
-	// Find all named types, even local types (which can have
-	// methods due to promotion) and the built-in "error".
-	// We ignore aliases 'type M = N' to avoid duplicate
-	// reporting of the Named type N.
-	var allNamed []*types.Named
-
-	f := func(p source.Package) error {
-		for _, obj := range p.GetTypesInfo().Defs {
-			if obj, ok := obj.(*types.TypeName); ok && !isAlias(obj) {
-				if named, ok := obj.Type().(*types.Named); ok {
-					allNamed = append(allNamed, named)
-				}
-			}
-		}
-
-		return nil
-	}
-
-	err := project.Search(f)
+	allNamed, err := namedTypes(ctx, project, stdlib)
 	if err != nil {
 		return nil, err
 	}
 
-	allNamed = append(allNamed, types.Universe.Lookup("error").Type().(*types.Named))
-
 	var msets typeutil.MethodSetCache
 
 	// Test each named type.
 	var to, from, fromPtr []types.Type
+	checker := cancelChecker{ctx: ctx}
 	for _, U := range allNamed {
+		if err := checker.err(); err != nil {
+			return nil, err
+		}
 		if isInterface(T) {
 			if msets.MethodSet(T).Len() == 0 {
 				continue // empty interface
@@ -165,19 +192,32 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 		}
 
 		return &lspext.ImplementationLocation{
-			Location: goRangeToLSPLocation(pkg.GetFileSet(), obj.Pos(), obj.Name()),
+			Location: columns.goRangeToLSPLocation(pkg.GetFileSet(), obj.Pos(), obj.Name()),
 			Method:   method != nil,
 		}
 	}
 
-	locs := make([]*lspext.ImplementationLocation, 0, len(to)+len(from)+len(fromPtr))
+	// The methods of the interface, if its implementations are located by
+	// them.
+	toMethods := []*types.Func{method}
+	if method == nil && methods && isInterface(T) {
+		mset := msets.MethodSet(T)
+		toMethods = make([]*types.Func, 0, mset.Len())
+		for i := 0; i < mset.Len(); i++ {
+			toMethods = append(toMethods, mset.At(i).Obj().(*types.Func))
+		}
+	}
+
+	locs := make([]*lspext.ImplementationLocation, 0, len(to)*len(toMethods)+len(from)+len(fromPtr))
 	for _, t := range to {
-		loc := toLocation(t, method)
-		if loc == nil {
-			continue
+		for _, m := range toMethods {
+			loc := toLocation(t, m)
+			if loc == nil {
+				continue
+			}
+			loc.Type = "to"
+			locs = append(locs, loc)
 		}
-		loc.Type = "to"
-		locs = append(locs, loc)
 	}
 	for _, t := range from {
 		loc := toLocation(t, method)
@@ -199,6 +239,130 @@ func implements(project *cache.Project, pkg source.Package, path []ast.Node, act
 	return locs, nil
 }
 
+// ImplementedInterface is a named interface satisfied by a type, a result of
+// the bingo.listImplementedInterfaces command.
+type ImplementedInterface struct {
+	// Name is the name of the interface qualified by the path of its
+	// package, eg. io.Reader.
+	Name string `json:"name"`
+
+	// Location is the name of the declaration of the interface, nil for
+	// the predeclared error.
+	Location *lsp.Location `json:"location,omitempty"`
+
+	// Ptr is set if only the pointer to the type satisfies the interface.
+	Ptr bool `json:"ptr,omitempty"`
+}
+
+// listImplementedInterfaces returns the named interfaces satisfied by the
+// type at position, sorted by name: the "from" implementations of the type
+// only. The standard library interfaces are searched as with
+// textDocument/implementation.
+func (h *LangHandler) listImplementedInterfaces(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) ([]ImplementedInterface, error) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		if isNoResult(err) {
+			return []ImplementedInterface{}, nil
+		}
+		return nil, err
+	}
+
+	pathNodes, _ := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	pathNodes, action := findInterestingNode(pkg, pathNodes)
+	if action != actionType {
+		return nil, errors.New("not a type")
+	}
+	T := pkg.GetTypesInfo().TypeOf(pathNodes[0].(ast.Expr))
+	if T == nil {
+		return nil, errors.New("not a type")
+	}
+
+	allNamed, err := namedTypes(ctx, h.project, h.config.ImplementationStdlib)
+	if err != nil {
+		return nil, err
+	}
+
+	var msets typeutil.MethodSetCache
+	var ifaces []types.Type
+	ptr := make(map[types.Type]bool)
+	for _, U := range allNamed {
+		if !isInterface(U) || msets.MethodSet(U).Len() == 0 || types.Identical(T, U) {
+			continue
+		}
+		if types.AssignableTo(T, U) {
+			ifaces = append(ifaces, U)
+		} else if !isInterface(T) && types.AssignableTo(types.NewPointer(T), U) {
+			ifaces = append(ifaces, U)
+			ptr[U] = true
+		}
+	}
+	sort.Sort(typesByString(ifaces))
+
+	// The package is also loaded with its tests, the same interface may be
+	// found several times.
+	seen := make(map[lsp.Location]bool)
+	result := []ImplementedInterface{}
+	for _, U := range ifaces {
+		iface := ImplementedInterface{Name: U.String(), Ptr: ptr[U]}
+		if obj := U.(*types.Named).Obj(); obj.Pkg() != nil {
+			loc := h.overlay.columns.goRangeToLSPLocation(pkg.GetFileSet(), obj.Pos(), obj.Name())
+			if seen[loc] {
+				continue
+			}
+			seen[loc] = true
+			iface.Location = &loc
+		}
+		result = append(result, iface)
+	}
+	return result, nil
+}
+
+// namedTypes returns all the named types of the packages of the cache, even
+// local types (which can have methods due to promotion) and the built-in
+// "error". The standard library packages imported by the project are only
+// searched if stdlib is true.
+func namedTypes(ctx context.Context, project *cache.Project, stdlib bool) ([]*types.Named, error) {
+	// We ignore aliases 'type M = N' to avoid duplicate
+	// reporting of the Named type N.
+	var (
+		allNamed []*types.Named
+		mu       sync.Mutex
+	)
+
+	f := func(p source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !stdlib && project.IsStdlib(p) {
+			return nil
+		}
+
+		var named []*types.Named
+		checker := cancelChecker{ctx: ctx}
+		for _, obj := range p.GetTypesInfo().Defs {
+			if err := checker.err(); err != nil {
+				return err
+			}
+			if obj, ok := obj.(*types.TypeName); ok && !isAlias(obj) {
+				if t, ok := obj.Type().(*types.Named); ok {
+					named = append(named, t)
+				}
+			}
+		}
+
+		mu.Lock()
+		allNamed = append(allNamed, named...)
+		mu.Unlock()
+		return nil
+	}
+
+	if err := project.Search(ctx, f); err != nil {
+		return nil, err
+	}
+	return append(allNamed, types.Universe.Lookup("error").Type().(*types.Named)), nil
+}
+
 func isInterface(T types.Type) bool { return types.IsInterface(T) }
 
 type typesByString []types.Type
@@ -19,6 +19,16 @@ type InitializationOptions struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle *string `json:"diagnosticsStyle"`
 
+	// DiagnosticsAnalyses is an optional version of Config.DiagnosticsAnalyses
+	DiagnosticsAnalyses *bool `json:"diagnosticsAnalyses"`
+
+	// NoAnalysisDirectives is an optional version of
+	// Config.NoAnalysisDirectives
+	NoAnalysisDirectives []string `json:"noAnalysisDirectives"`
+
+	// CtxCheck is an optional version of Config.CtxCheck
+	CtxCheck *bool `json:"ctxCheck"`
+
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
 	//
 	// Defaults to false if not specified
@@ -38,16 +48,180 @@ type InitializationOptions struct {
 	// Config.GoimportsLocalPrefix
 	GoimportsLocalPrefix *string `json:"goimportsLocalPrefix"`
 
+	// ImportsLocalPrefix is an optional version of Config.ImportsLocalPrefix
+	ImportsLocalPrefix *string `json:"importsLocalPrefix"`
+
 	// MaxParallelism is an optional version of Config.MaxParallelism
 	MaxParallelism *int `json:"maxParallelism"`
 
 	// BuildTags is an optional version of Config.BuildTags
 	BuildTags []string `json:"buildTags"`
+
+	// BuildFlags is an optional version of Config.BuildFlags
+	BuildFlags []string `json:"buildFlags"`
+
+	// WarmupOnInitialize is an optional version of Config.WarmupOnInitialize
+	WarmupOnInitialize *bool `json:"warmupOnInitialize"`
+
+	// GOOS is an optional version of Config.GOOS
+	GOOS *string `json:"goos"`
+
+	// GOARCH is an optional version of Config.GOARCH
+	GOARCH *string `json:"goarch"`
+
+	// SortReferencesByProximity is an optional version of
+	// Config.SortReferencesByProximity
+	SortReferencesByProximity *bool `json:"sortReferencesByProximity"`
+
+	// HoverBitFlags is an optional version of Config.HoverBitFlags
+	HoverBitFlags *bool `json:"hoverBitFlags"`
+
+	// HoverSymbolFooter is an optional version of Config.HoverSymbolFooter
+	HoverSymbolFooter *bool `json:"hoverSymbolFooter"`
+
+	// HoverMethodSet is an optional version of Config.HoverMethodSet
+	HoverMethodSet *bool `json:"hoverMethodSet"`
+
+	// HoverSkipUnexported is an optional version of Config.HoverSkipUnexported
+	HoverSkipUnexported *bool `json:"hoverSkipUnexported"`
+
+	// HoverSkipExternal is an optional version of Config.HoverSkipExternal
+	HoverSkipExternal *bool `json:"hoverSkipExternal"`
+
+	// HoverASTNode is an optional version of Config.HoverASTNode
+	HoverASTNode *bool `json:"hoverASTNode"`
+
+	// PrintfFuncs is an optional version of Config.PrintfFuncs
+	PrintfFuncs []string `json:"printfFuncs"`
+
+	// MaxCachedPackages is an optional version of Config.MaxCachedPackages
+	MaxCachedPackages *int `json:"maxCachedPackages"`
+
+	// LoadBatchSize is an optional version of Config.LoadBatchSize
+	LoadBatchSize *int `json:"loadBatchSize"`
+
+	// ExcludeInternalPackages is an optional version of
+	// Config.ExcludeInternalPackages
+	ExcludeInternalPackages *bool `json:"excludeInternalPackages"`
+
+	// ExcludeDirs is an optional version of Config.ExcludeDirs
+	ExcludeDirs []string `json:"excludeDirs"`
+
+	// MaxWalkDepth is an optional version of Config.MaxWalkDepth
+	MaxWalkDepth *int `json:"maxWalkDepth"`
+
+	// RequestTimeout is an optional version of Config.RequestTimeout, as a
+	// duration string, eg. "10s".
+	RequestTimeout *string `json:"requestTimeout"`
+
+	// ImplementationDirection is an optional version of
+	// Config.ImplementationDirection
+	ImplementationDirection *string `json:"implementationDirection"`
+
+	// ImplementationStdlib is an optional version of
+	// Config.ImplementationStdlib
+	ImplementationStdlib *bool `json:"implementationStdlib"`
+
+	// ImplementationMethods is an optional version of
+	// Config.ImplementationMethods
+	ImplementationMethods *bool `json:"implementationMethods"`
+
+	// TypeDefinitionFullRange is an optional version of
+	// Config.TypeDefinitionFullRange
+	TypeDefinitionFullRange *bool `json:"typeDefinitionFullRange"`
+
+	// ReferencesTests is an optional version of Config.ReferencesTests
+	ReferencesTests *string `json:"referencesTests"`
+
+	// ReferencesFollowAliases is an optional version of
+	// Config.ReferencesFollowAliases
+	ReferencesFollowAliases *bool `json:"referencesFollowAliases"`
+
+	// GodocURL is an optional version of Config.GodocURL
+	GodocURL *string `json:"godocURL"`
+
+	// SymbolEmbeddedFields is an optional version of
+	// Config.SymbolEmbeddedFields
+	SymbolEmbeddedFields *bool `json:"symbolEmbeddedFields"`
+
+	// SymbolMaxResults is an optional version of Config.SymbolMaxResults
+	SymbolMaxResults *int `json:"symbolMaxResults"`
+
+	// SymbolMainModulesOnly is an optional version of
+	// Config.SymbolMainModulesOnly
+	SymbolMainModulesOnly *bool `json:"symbolMainModulesOnly"`
+
+	// SymbolWeights is an optional version of Config.SymbolWeights, the
+	// weights it does not set keep their value.
+	SymbolWeights *SymbolWeightsOptions `json:"symbolWeights"`
+
+	// LogLevel is an optional version of Config.LogLevel
+	LogLevel *string `json:"logLevel"`
+}
+
+// SymbolWeightsOptions are the weights of workspace/symbol supported by
+// go-langserver. It is the SymbolWeights struct, but each field is optional.
+type SymbolWeightsOptions struct {
+	ContainerPrefix  *int `json:"containerPrefix"`
+	NamePrefix       *int `json:"namePrefix"`
+	ExactContainer   *int `json:"exactContainer"`
+	ExactName        *int `json:"exactName"`
+	ExactNameNotLast *int `json:"exactNameNotLast"`
+	FilePath         *int `json:"filePath"`
+	FileBasePrefix   *int `json:"fileBasePrefix"`
+	NonVendor        *int `json:"nonVendor"`
+	Exported         *int `json:"exported"`
+	QualifiedName    *int `json:"qualifiedName"`
+}
+
+// initializeResult is lsp.InitializeResult with the capabilities go-lsp does
+// not support yet.
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities,omitempty"`
+}
+
+type serverCapabilities struct {
+	lsp.ServerCapabilities
+
+	// DeclarationProvider reports textDocument/declaration support.
+	DeclarationProvider bool `json:"declarationProvider,omitempty"`
+
+	// CallHierarchyProvider reports textDocument/prepareCallHierarchy,
+	// callHierarchy/incomingCalls and callHierarchy/outgoingCalls support.
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
+
+	// SemanticTokensProvider reports textDocument/semanticTokens/full
+	// support.
+	SemanticTokensProvider *semanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	// DocumentLinkProvider reports textDocument/documentLink support.
+	DocumentLinkProvider *documentLinkOptions `json:"documentLinkProvider,omitempty"`
+
+	// PositionEncoding is the encoding of the characters of the positions,
+	// negotiated from the general.positionEncodings client capability.
+	PositionEncoding string `json:"positionEncoding,omitempty"`
+}
+
+type semanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Full   bool                 `json:"full"`
 }
 
 type InitializeParams struct {
 	lsp.InitializeParams
 
+	// Capabilities shadows the capabilities of lsp.InitializeParams to
+	// support the capabilities go-lsp does not.
+	Capabilities ClientCapabilities `json:"capabilities"`
+
+	// WorkDoneToken, if set, is the token of the $/progress notifications
+	// reporting the loading of the packages during the initialize request.
+	WorkDoneToken interface{} `json:"workDoneToken,omitempty"`
+
+	// Trace is the initial value of the protocol tracing, "off", "messages"
+	// or "verbose", which $/setTrace changes.
+	Trace string `json:"trace,omitempty"`
+
 	InitializationOptions *InitializationOptions `json:"initializationOptions,omitempty"`
 
 	// TODO these should be InitializationOptions
@@ -57,3 +231,105 @@ type InitializeParams struct {
 	// path for "github.com/golang/tools".
 	RootImportPath string
 }
+
+// ClientCapabilities is lsp.ClientCapabilities with the capabilities go-lsp
+// does not support yet.
+type ClientCapabilities struct {
+	lsp.ClientCapabilities
+
+	General GeneralClientCapabilities `json:"general,omitempty"`
+
+	Window WindowClientCapabilities `json:"window,omitempty"`
+
+	TextDocument TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+// TextDocumentClientCapabilities is lsp.TextDocumentClientCapabilities with
+// the completion capabilities go-lsp does not support yet.
+type TextDocumentClientCapabilities struct {
+	lsp.TextDocumentClientCapabilities
+
+	Completion CompletionClientCapabilities `json:"completion,omitempty"`
+}
+
+type CompletionClientCapabilities struct {
+	CompletionItem struct {
+		DocumentationFormat []lsp.DocumentationFormat `json:"documentationFormat,omitempty"`
+		SnippetSupport      bool                      `json:"snippetSupport,omitempty"`
+
+		// ResolveSupport lists the properties of a completion item the
+		// client can resolve lazily with completionItem/resolve.
+		ResolveSupport *struct {
+			Properties []string `json:"properties"`
+		} `json:"resolveSupport,omitempty"`
+	} `json:"completionItem,omitempty"`
+
+	CompletionItemKind struct {
+		ValueSet []lsp.CompletionItemKind `json:"valueSet,omitempty"`
+	} `json:"completionItemKind,omitempty"`
+
+	ContextSupport bool `json:"contextSupport,omitempty"`
+}
+
+type WindowClientCapabilities struct {
+	// WorkDoneProgress reports whether the client supports the progress
+	// created by the server with window/workDoneProgress/create.
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
+type GeneralClientCapabilities struct {
+	// PositionEncodings are the encodings of the characters of the
+	// positions supported by the client, in order of preference.
+	PositionEncodings []string `json:"positionEncodings,omitempty"`
+}
+
+// APISurfaceParams are the parameters of the bingo/apiSurface request.
+type APISurfaceParams struct {
+	// TextDocument is any document of the package.
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SetTraceParams are the parameters of the $/setTrace notification.
+type SetTraceParams struct {
+	Value string `json:"value"`
+}
+
+// LogTraceParams are the parameters of the $/logTrace notification. Verbose
+// is only set if the trace is verbose.
+type LogTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+// PackageDocParams are the parameters of the bingo/packageDoc request, the
+// package is named by its import path or by any of its documents.
+type PackageDocParams struct {
+	ImportPath   string                      `json:"importPath,omitempty"`
+	TextDocument *lsp.TextDocumentIdentifier `json:"textDocument,omitempty"`
+}
+
+// ReferenceParams are the parameters of the textDocument/references request.
+type ReferenceParams struct {
+	lsp.ReferenceParams
+
+	// PartialResultToken, if set, is the token of the $/progress
+	// notifications which stream the references found in each package before
+	// the complete response.
+	PartialResultToken interface{} `json:"partialResultToken,omitempty"`
+}
+
+// ProgressParams are the parameters of the $/progress notification.
+type ProgressParams struct {
+	Token interface{} `json:"token"`
+	Value interface{} `json:"value"`
+}
+
+// ImplementationParams are the parameters of the textDocument/implementation
+// request.
+type ImplementationParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Direction optionally overrides Config.ImplementationDirection for this
+	// request.
+	Direction string `json:"direction,omitempty"`
+}
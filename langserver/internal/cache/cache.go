@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"container/list"
+	"context"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -19,11 +23,16 @@ const (
 	None     CacheStyle = "none"
 	Ondemand CacheStyle = "on-demand"
 	Always   CacheStyle = "always"
+
+	// Lazy sets up the global cache like Always, but the packages of the
+	// project are only loaded by the first TypeCheck or Search.
+	Lazy CacheStyle = "lazy"
 )
 
 type GlobalPackage struct {
 	pkg     *Package
 	modTime time.Time
+	elem    *list.Element
 }
 
 func (p *GlobalPackage) Package() *Package {
@@ -64,6 +73,22 @@ type GlobalCache struct {
 	idMap   id2Package
 	pathMap path2Package
 	fileMap file2Package
+
+	// maxPackages is the maximum number of retained packages, 0 means no limit.
+	maxPackages int
+	// keep reports whether the package of the import path must never be evicted.
+	keep func(pkgPath string) bool
+	// lru orders the packages from the most to the least recently used.
+	lru *list.List
+	// holds maps the import paths of the packages used by the requests to the
+	// time until which they are not evicted.
+	holds map[string]time.Time
+	// evictedPaths and evictedFiles record the evicted packages, so they can be
+	// reloaded on demand.
+	evictedPaths map[string]bool
+	evictedFiles map[string]bool
+	// peak is the maximum number of packages retained at once.
+	peak int
 }
 
 // debugCache trace package cache
@@ -71,7 +96,30 @@ var debugCache = false
 
 // NewCache new a package cache
 func NewCache() *GlobalCache {
-	return &GlobalCache{idMap: id2Package{}, pathMap: path2Package{}, fileMap: file2Package{}}
+	return &GlobalCache{
+		idMap:        id2Package{},
+		pathMap:      path2Package{},
+		fileMap:      file2Package{},
+		lru:          list.New(),
+		holds:        map[string]time.Time{},
+		evictedPaths: map[string]bool{},
+		evictedFiles: map[string]bool{},
+	}
+}
+
+// SetLimit sets the maximum number of retained packages. The least recently
+// used packages are evicted when the limit is exceeded, except the ones keep
+// reports true for. A limit of 0 means no limit.
+func (c *GlobalCache) SetLimit(maxPackages int, keep func(pkgPath string) bool) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.maxPackages = maxPackages
+	c.keep = keep
+	c.evict()
 }
 
 func (c *GlobalCache) put(pkg *Package) {
@@ -85,11 +133,105 @@ func (c *GlobalCache) put(pkg *Package) {
 
 	c.delete(pkg.id)
 	p := &GlobalPackage{pkg: pkg, modTime: getPackageModTime(pkg)}
+	p.elem = c.lru.PushFront(p)
 	c.idMap[pkg.id] = p
 	c.pathMap[pkg.pkgPath] = p
+	delete(c.evictedPaths, pkg.pkgPath)
+
+	for _, file := range pkg.allFiles() {
+		file = util.LowerDriver(file)
+		c.fileMap[file] = p
+		delete(c.evictedFiles, file)
+	}
+	if len(c.idMap) > c.peak {
+		c.peak = len(c.idMap)
+	}
+}
+
+// Counts returns the number of packages retained and the maximum number of
+// packages retained at once so far, before the evictions.
+func (c *GlobalCache) Counts() (count, peak int) {
+	if c == nil {
+		return 0, 0
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.idMap), c.peak
+}
+
+// touch marks p as the most recently used package.
+func (c *GlobalCache) touch(p *GlobalPackage) {
+	if p != nil && p.elem != nil {
+		c.lru.MoveToFront(p.elem)
+	}
+}
+
+// evict removes the least recently used packages until the number of
+// retained packages fits the limit. A package is evicted with the packages
+// importing it, which would keep it reachable otherwise, so it is skipped if
+// one of them is kept or held.
+func (c *GlobalCache) evict() {
+	if c.maxPackages <= 0 || len(c.idMap) <= c.maxPackages {
+		return
+	}
+
+	now := time.Now()
+	for pkgPath, until := range c.holds {
+		if !now.Before(until) {
+			delete(c.holds, pkgPath)
+		}
+	}
+
+	importers := make(map[*Package][]*Package)
+	for _, p := range c.idMap {
+		for _, imp := range p.pkg.imports {
+			importers[imp] = append(importers[imp], p.pkg)
+		}
+	}
+
+	var lru []*Package
+	for e := c.lru.Back(); e != nil; e = e.Prev() {
+		lru = append(lru, e.Value.(*GlobalPackage).pkg)
+	}
+	for _, pkg := range lru {
+		if len(c.idMap) <= c.maxPackages {
+			return
+		}
+		if p := c.idMap[pkg.id]; p == nil || p.pkg != pkg {
+			// Already evicted as an importer.
+			continue
+		}
+
+		closure := []*Package{pkg}
+		seen := map[*Package]bool{pkg: true}
+		evictable := true
+		for i := 0; i < len(closure) && evictable; i++ {
+			q := closure[i]
+			if _, held := c.holds[q.pkgPath]; held || (c.keep != nil && c.keep(q.pkgPath)) {
+				evictable = false
+			}
+			for _, importer := range importers[q] {
+				if !seen[importer] {
+					seen[importer] = true
+					closure = append(closure, importer)
+				}
+			}
+		}
+		if !evictable {
+			continue
+		}
 
-	for _, file := range pkg.files {
-		c.fileMap[util.LowerDriver(file)] = p
+		for _, q := range closure {
+			if debugCache {
+				log.Printf("evict %s %p\n", q.id, q)
+			}
+			c.delete(q.id)
+			c.evictedPaths[q.pkgPath] = true
+			for _, file := range q.allFiles() {
+				c.evictedFiles[util.LowerDriver(file)] = true
+			}
+		}
 	}
 }
 
@@ -122,8 +264,12 @@ func (c *GlobalCache) delete(id string) {
 
 	delete(c.idMap, id)
 	delete(c.pathMap, p.pkg.pkgPath)
+	if p.elem != nil {
+		c.lru.Remove(p.elem)
+		p.elem = nil
+	}
 
-	for _, file := range p.pkg.files {
+	for _, file := range p.pkg.allFiles() {
 		delete(c.fileMap, util.LowerDriver(file))
 	}
 }
@@ -179,12 +325,44 @@ func (c *GlobalCache) Get(pkgPath string) *GlobalPackage {
 		return nil
 	}
 
-	c.RLock()
+	c.Lock()
 	p := c.pathMap[pkgPath]
-	c.RUnlock()
+	c.touch(p)
+	c.Unlock()
 	return p
 }
 
+// getByID returns the cached package of the id, or nil if there is none.
+func (c *GlobalCache) getByID(id string) *Package {
+	if c == nil {
+		return nil
+	}
+
+	c.Lock()
+	p := c.idMap[id]
+	c.touch(p)
+	c.Unlock()
+	return p.Package()
+}
+
+// putIfAbsent caches pkg unless a package of the same id is cached already,
+// and returns the cached package.
+func (c *GlobalCache) putIfAbsent(pkg *Package) *Package {
+	if c == nil {
+		return pkg
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if p := c.idMap[pkg.id]; p != nil {
+		c.touch(p)
+		return p.pkg
+	}
+	c.put(pkg)
+	c.evict()
+	return pkg
+}
+
 func (c *GlobalCache) Put(pkg *Package) {
 	if c == nil {
 		return
@@ -193,6 +371,7 @@ func (c *GlobalCache) Put(pkg *Package) {
 	c.Lock()
 	defer c.Unlock()
 	c.put(pkg)
+	c.evict()
 }
 
 func (c *GlobalCache) Delete(id string) {
@@ -210,18 +389,145 @@ func (c *GlobalCache) GetByURI(filename string) *Package {
 	if c == nil {
 		return nil
 	}
-	c.RLock()
+	c.Lock()
 	p := c.fileMap[util.LowerDriver(filename)]
-	c.RUnlock()
+	c.touch(p)
+	c.Unlock()
 	return p.Package()
 }
 
+// IsEvicted reports whether the package of the import path has been evicted
+// and not been reloaded since.
+func (c *GlobalCache) IsEvicted(pkgPath string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return c.evictedPaths[pkgPath]
+}
+
+// IsFileEvicted reports whether the package of the file has been evicted and
+// not been reloaded since.
+func (c *GlobalCache) IsFileEvicted(filename string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return c.evictedFiles[util.LowerDriver(filename)]
+}
+
+// holdPeriod is how long a package used by a request without deadline is not
+// evicted.
+const holdPeriod = time.Minute
+
+// Hold prevents the package of the import path from being evicted until the
+// deadline of ctx, or for holdPeriod if it has none.
+func (c *GlobalCache) Hold(ctx context.Context, pkgPath string) {
+	if c == nil {
+		return
+	}
+
+	until, ok := ctx.Deadline()
+	if !ok {
+		until = time.Now().Add(holdPeriod)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.maxPackages > 0 && until.After(c.holds[pkgPath]) {
+		c.holds[pkgPath] = until
+	}
+}
+
+// walkPackage calls walkFunc with pkg in a goroutine of WalkParallel, a panic
+// of walkFunc is returned as an error since the handler of the request cannot
+// recover it.
+func walkPackage(walkFunc source.WalkFunc, pkg *Package) (err error) {
+	defer func() {
+		if perr := util.Panicf(recover(), "walking %s", pkg.GetPkgPath()); perr != nil {
+			err = perr
+		}
+	}()
+	return walkFunc(pkg)
+}
+
 // Walk walk the global package cache
 func (c *GlobalCache) Walk(walkFunc source.WalkFunc, ranks []string) error {
+	return c.WalkParallel(context.Background(), walkFunc, ranks, 1)
+}
+
+// WalkParallel walks the global package cache with at most parallelism
+// concurrent calls of walkFunc, so walkFunc must be safe for concurrent use.
+// The packages are dispatched in the order of ranks. The walk stops at the
+// first error returned by walkFunc or when ctx is done.
+func (c *GlobalCache) WalkParallel(ctx context.Context, walkFunc source.WalkFunc, ranks []string, parallelism int) error {
 	if c == nil {
 		return nil
 	}
 
+	pkgs := c.rankedPackages(ranks)
+
+	if parallelism <= 1 {
+		for _, pkg := range pkgs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := walkFunc(pkg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	walkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	pkgCh := make(chan *Package)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range pkgCh {
+				if err := walkPackage(walkFunc, pkg); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, pkg := range pkgs {
+		select {
+		case pkgCh <- pkg:
+		case <-walkCtx.Done():
+			break dispatch
+		}
+	}
+	close(pkgCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// rankedPackages returns a snapshot of the cached packages, sorted by the
+// index of the first rank prefixing their id, then by id. Walking a snapshot
+// allows walkFunc to access the cache.
+func (c *GlobalCache) rankedPackages(ranks []string) []*Package {
 	c.RLock()
 	defer c.RUnlock()
 
@@ -259,18 +565,11 @@ func (c *GlobalCache) Walk(walkFunc source.WalkFunc, ranks []string) error {
 		return false
 	})
 
-	return c.walk(idList, walkFunc)
-}
-
-func (c *GlobalCache) walk(idList []string, walkFunc source.WalkFunc) error {
+	pkgs := make([]*Package, 0, len(idList))
 	for _, id := range idList {
-		pkg := c.get(id)
-		if err := walkFunc(pkg); err != nil {
-			return err
-		}
+		pkgs = append(pkgs, c.get(id))
 	}
-
-	return nil
+	return pkgs
 }
 
 func (c *GlobalCache) Add(pkg *packages.Package) {
@@ -282,6 +581,104 @@ func (c *GlobalCache) Add(pkg *packages.Package) {
 	defer c.Unlock()
 
 	c.recusiveAdd(pkg, nil)
+	c.evict()
+}
+
+// Importers returns the import paths of the cached packages importing,
+// directly or not, the cached packages of the directory dir.
+func (c *GlobalCache) Importers(dir string) []string {
+	if c == nil {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	ids := c.dirIDs(dir)
+	var pkgPaths []string
+	for id, pkg := range c.importerClosure(ids) {
+		if !ids[id] {
+			pkgPaths = append(pkgPaths, pkg.pkgPath)
+		}
+	}
+	sort.Strings(pkgPaths)
+	return pkgPaths
+}
+
+// Replace replaces the cached packages of the directory dir, and those of the
+// same id, with pkgs. The cached packages are shared with the requests, so the
+// packages importing the replaced ones are not linked to the new ones but
+// evicted with them, and are reloaded on demand unless pkgs has them too.
+func (c *GlobalCache) Replace(dir string, pkgs []*packages.Package) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	ids := c.dirIDs(dir)
+	for _, pkg := range pkgs {
+		ids[pkg.ID] = true
+	}
+	for id, pkg := range c.importerClosure(ids) {
+		c.delete(id)
+		if !ids[id] {
+			c.evictedPaths[pkg.pkgPath] = true
+			for _, file := range pkg.allFiles() {
+				c.evictedFiles[util.LowerDriver(file)] = true
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		// The directory has no package anymore if its files were deleted.
+		if len(pkg.CompiledGoFiles) > 0 {
+			c.recusiveAdd(pkg, nil)
+		}
+	}
+	c.evict()
+}
+
+// dirIDs returns the ids of the cached packages of the directory dir.
+func (c *GlobalCache) dirIDs(dir string) map[string]bool {
+	dir = util.LowerDriver(dir)
+	ids := make(map[string]bool)
+	for file, p := range c.fileMap {
+		if filepath.Dir(file) == dir {
+			ids[p.pkg.id] = true
+		}
+	}
+	return ids
+}
+
+// importerClosure returns the cached packages of ids and the cached packages
+// importing them, directly or not, by id.
+func (c *GlobalCache) importerClosure(ids map[string]bool) map[string]*Package {
+	importers := make(map[*Package][]*Package)
+	for _, p := range c.idMap {
+		for _, imp := range p.pkg.imports {
+			importers[imp] = append(importers[imp], p.pkg)
+		}
+	}
+
+	closure := make(map[string]*Package)
+	var queue []*Package
+	for id := range ids {
+		if p := c.idMap[id]; p != nil {
+			closure[id] = p.pkg
+			queue = append(queue, p.pkg)
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		for _, importer := range importers[queue[i]] {
+			if _, ok := closure[importer.id]; !ok {
+				closure[importer.id] = importer
+				queue = append(queue, importer)
+			}
+		}
+	}
+	return closure
 }
 
 func (c *GlobalCache) recusiveAdd(pkg *packages.Package, parent *Package) {
@@ -311,11 +708,13 @@ func create(pkg *packages.Package) *Package {
 		id:        pkg.ID,
 		pkgPath:   pkg.PkgPath,
 		files:     pkg.CompiledGoFiles,
+		cgoFiles:  cgoFiles(pkg),
 		syntax:    pkg.Syntax,
 		errors:    pkg.Errors,
 		types:     pkg.Types,
 		typesInfo: pkg.TypesInfo,
 		fset:      pkg.Fset,
 		imports:   make(map[string]*Package),
+		analyses:  make(map[*analysis.Analyzer]*analysisEntry),
 	}
 }
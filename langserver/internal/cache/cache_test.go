@@ -0,0 +1,1173 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/packages"
+)
+
+func newTestPackage(pkgPath string) *Package {
+	return &Package{
+		id:      pkgPath,
+		pkgPath: pkgPath,
+		files:   []string{"/src/" + pkgPath + "/a.go"},
+		imports: make(map[string]*Package),
+	}
+}
+
+func TestGlobalCacheEviction(t *testing.T) {
+	c := NewCache()
+	c.SetLimit(2, func(pkgPath string) bool { return pkgPath == "main" })
+
+	c.Put(newTestPackage("main"))
+	c.Put(newTestPackage("a"))
+	c.Put(newTestPackage("b"))
+
+	if c.Get("main") == nil {
+		t.Error("kept package main was evicted")
+	}
+	if c.Get("a") != nil || !c.IsEvicted("a") || !c.IsFileEvicted("/src/a/a.go") {
+		t.Error("least recently used package a was not evicted")
+	}
+
+	// A reloaded package is no longer reported as evicted.
+	c.Put(newTestPackage("a"))
+	if c.Get("a") == nil || c.IsEvicted("a") {
+		t.Error("reloaded package a is reported as evicted")
+	}
+	if c.Get("b") != nil {
+		t.Error("least recently used package b was not evicted")
+	}
+
+	c.Hold(context.Background(), "a")
+	c.Put(newTestPackage("c"))
+	if c.Get("a") == nil {
+		t.Error("held package a was evicted")
+	}
+
+	// A hold ends at the deadline of the request.
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	c.Hold(ctx, "c")
+	c.Put(newTestPackage("d"))
+	if c.Get("c") != nil {
+		t.Error("package c held by an expired request was not evicted")
+	}
+}
+
+func TestGlobalCacheEvictionImporters(t *testing.T) {
+	c := NewCache()
+	c.SetLimit(2, nil)
+
+	a, b, unused := newTestPackage("a"), newTestPackage("b"), newTestPackage("unused")
+	b.imports["a"] = a
+	c.Put(a)
+	c.Put(b)
+	c.Put(unused)
+
+	// The least recently used package a is evicted with its importer b,
+	// which would keep it reachable otherwise.
+	if c.Get("a") != nil || c.Get("b") != nil || !c.IsEvicted("b") {
+		t.Error("package a was not evicted with its importer b")
+	}
+	if c.Get("unused") == nil {
+		t.Error("package unused was evicted")
+	}
+
+	// A package imported by a held package is not evicted.
+	c = NewCache()
+	c.SetLimit(1, nil)
+	a, b = newTestPackage("a"), newTestPackage("b")
+	b.imports["a"] = a
+	c.Put(a)
+	c.Hold(context.Background(), "b")
+	c.Put(b)
+	if c.Get("a") == nil || c.Get("b") == nil {
+		t.Error("package a imported by the held package b was evicted")
+	}
+}
+
+func TestGlobalCacheWalkParallel(t *testing.T) {
+	c := NewCache()
+	for _, pkgPath := range []string{"a", "b", "c", "d", "e"} {
+		c.Put(newTestPackage(pkgPath))
+	}
+
+	var (
+		mu      sync.Mutex
+		visited []string
+	)
+	err := c.WalkParallel(context.Background(), func(pkg source.Package) error {
+		mu.Lock()
+		visited = append(visited, pkg.GetPkgPath())
+		mu.Unlock()
+		return nil
+	}, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("got %v, want %v", visited, want)
+	}
+
+	errStop := errors.New("stop")
+	err = c.WalkParallel(context.Background(), func(pkg source.Package) error {
+		return errStop
+	}, nil, 3)
+	if err != errStop {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+
+	err = c.WalkParallel(context.Background(), func(pkg source.Package) error {
+		panic("walk")
+	}, nil, 3)
+	if err == nil || err.Error() != "unexpected panic: walk" {
+		t.Errorf("got error %v, want the panic", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.WalkParallel(ctx, func(pkg source.Package) error {
+		return nil
+	}, nil, 3)
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestViewLoadConfigEnv(t *testing.T) {
+	v := NewView(&packages.Config{})
+	if cfg := v.loadConfig(packages.LoadImports); cfg.Env != nil || cfg.Mode != packages.LoadImports {
+		t.Errorf("got env %v and mode %v, want the process environment and LoadImports", cfg.Env, cfg.Mode)
+	}
+
+	v.env = []string{"GOOS=windows", "GOARCH=arm"}
+	cfg := v.loadConfig(packages.LoadAllSyntax)
+	if n := len(cfg.Env); n < 2 || cfg.Env[n-2] != "GOOS=windows" || cfg.Env[n-1] != "GOARCH=arm" {
+		t.Errorf("got env %v, want GOOS and GOARCH appended", cfg.Env)
+	}
+}
+
+func TestViewReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := NewView(&packages.Config{Overlay: make(map[string][]byte)})
+	uri := span.FileURI(filename)
+	if got := string(v.ReadFile(uri)); got != "package a\n" {
+		t.Errorf("got %q, want the content on disk", got)
+	}
+	if _, ok := v.files[uri]; ok {
+		t.Error("got the file added to the view, want it read only")
+	}
+
+	// The overlay of an open file is read instead.
+	f := v.getFile(uri)
+	v.applyContentChange(uri, []byte("package b\n"))
+	if got := string(v.ReadFile(f.URI())); got != "package b\n" {
+		t.Errorf("got %q, want the overlay", got)
+	}
+}
+
+func TestViewExamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-examples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "a_test.go")
+	if err := ioutil.WriteFile(filename, []byte("package a\n\nfunc ExampleFoo() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := NewView(&packages.Config{Overlay: make(map[string][]byte)})
+	names := func() string {
+		_, examples := v.Examples(context.Background(), dir)
+		var names []string
+		for _, ex := range examples {
+			names = append(names, ex.Name)
+		}
+		return strings.Join(names, ", ")
+	}
+	if got := names(); got != "Foo" {
+		t.Errorf("got %q, want the example on disk", got)
+	}
+
+	// The examples are parsed once, until a file of the directory changes.
+	if err := ioutil.WriteFile(filename, []byte("package a\n\nfunc ExampleBar() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); got != "Foo" {
+		t.Errorf("got %q, want the cached example", got)
+	}
+
+	// The overlay of an open file is parsed instead.
+	if err := v.SetContent(context.Background(), span.FileURI(filename), []byte("package a\n\nfunc ExampleBaz() {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); got != "Baz" {
+		t.Errorf("got %q, want the example of the overlay", got)
+	}
+}
+
+func TestIsInternalPackage(t *testing.T) {
+	tests := map[string]bool{
+		"internal":                     true,
+		"internal/poll":                true,
+		"github.com/a/b/internal":      true,
+		"github.com/a/b/internal/c":    true,
+		"github.com/a/internalize":     false,
+		"github.com/a/b/c":             false,
+		"github.com/a/b/internalpkg/c": false,
+		"github.com/a/b/myinternal/c":  false,
+	}
+	for pkgPath, want := range tests {
+		if got := IsInternalPackage(pkgPath); got != want {
+			t.Errorf("IsInternalPackage(%q) = %v, want %v", pkgPath, got, want)
+		}
+	}
+}
+
+// logRecorder is a jsonrpc2.JSONRPC2 which records the logged messages.
+type logRecorder struct {
+	jsonrpc2.JSONRPC2
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *logRecorder) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	if p, ok := params.(*lsp.LogMessageParams); ok {
+		r.mu.Lock()
+		r.messages = append(r.messages, p.Message)
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+func TestProjectCreateGoModule(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFile := func(name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var gomodList []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeFile(name+"/go.mod", fmt.Sprintf("module example.com/%s\n", name))
+		writeFile(name+"/p.go", "package "+name+"\n")
+		gomodList = append(gomodList, filepath.Join(root, name, "go.mod"))
+	}
+	writeFile("broken/go.mod", "not a go.mod\n")
+	gomodList = append(gomodList, filepath.Join(root, "broken", "go.mod"))
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, nil, 2)
+	p.context = context.Background()
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+	if err := p.createGoModule(gomodList, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.modules) != len(gomodList) {
+		t.Fatalf("got %d modules, want %d", len(p.modules), len(gomodList))
+	}
+	for i := 1; i < len(p.modules); i++ {
+		if p.modules[i-1].rootDir < p.modules[i].rootDir {
+			t.Errorf("modules are not sorted: %s before %s", p.modules[i-1].rootDir, p.modules[i].rootDir)
+		}
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if p.newCache.Get("example.com/"+name) == nil {
+			t.Errorf("package example.com/%s is not cached", name)
+		}
+	}
+	if len(conn.messages) != 1 || !strings.HasPrefix(conn.messages[0], "notify: ") {
+		t.Errorf("got log messages %q, want the error of the broken module", conn.messages)
+	}
+}
+
+// progressRecorder is a jsonrpc2.JSONRPC2 which records the work done
+// progress requests and notifications.
+type progressRecorder struct {
+	jsonrpc2.JSONRPC2
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *progressRecorder) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	r.mu.Lock()
+	r.events = append(r.events, fmt.Sprintf("%s %v", method, params.(*workDoneProgressCreateParams).Token))
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *progressRecorder) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	p, ok := params.(*progressParams)
+	if !ok {
+		return nil
+	}
+	v := p.Value.(*workDoneProgressValue)
+	event := fmt.Sprintf("%v %s", p.Token, v.Kind)
+	if v.Percentage != nil {
+		event += fmt.Sprintf(" %d%%", *v.Percentage)
+	}
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+	return nil
+}
+
+func TestProjectWorkDoneProgress(t *testing.T) {
+	conn := &progressRecorder{}
+	p := NewProject(context.Background(), conn, "/src", nil, nil, 1)
+	p.context = context.Background()
+
+	if progress := p.beginProgress("Loading packages"); progress != nil {
+		t.Fatal("got a progress without a token")
+	}
+
+	p.SetWorkDoneProgress("init", false)
+	progress := p.beginProgress("Loading packages")
+	progress.setTotal(4)
+	for i := 0; i < 4; i++ {
+		progress.step("module")
+	}
+	progress.end("Packages loaded")
+
+	p.SetWorkDoneProgress(nil, true)
+	progress = p.beginProgress("Loading packages")
+	progress.end("Packages loaded")
+
+	want := []string{
+		"init begin 0%", "init report 25%", "init report 50%", "init report 75%", "init report 100%", "init end",
+		"window/workDoneProgress/create bingo/progress/1", "bingo/progress/1 begin 0%", "bingo/progress/1 end",
+	}
+	if !reflect.DeepEqual(conn.events, want) {
+		t.Errorf("\ngot\n\t%q\nwant\n\t%q", conn.events, want)
+	}
+}
+
+func TestProjectCreateGoModuleReplace(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-replace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"main/go.mod": "module example.com/main\n\nrequire example.com/fork v1.0.0\n\nreplace example.com/fork => ../fork\n",
+		"main/p.go":   "package main\n\nimport \"example.com/fork\"\n\nvar _ = fork.F\n",
+		"fork/go.mod": "module example.com/fork\n",
+		"fork/f.go":   "package fork\n\nfunc F() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, []string{"GOFLAGS=-mod=mod"}, 1)
+	p.context = context.Background()
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+	if err := p.createGoModule([]string{filepath.Join(root, "main", "go.mod")}, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	fork := p.newCache.Get("example.com/fork")
+	if fork == nil {
+		t.Fatalf("the replaced package is not cached, log: %q", conn.messages)
+	}
+	want := filepath.Join(root, "fork", "f.go")
+	if got := fork.Package().GetFilenames(); len(got) != 1 || got[0] != want {
+		t.Errorf("got files %v of the replaced package, want %s", got, want)
+	}
+	if !p.IsMainPackage("example.com/fork") {
+		t.Error("the package replaced by a project directory is not a main package")
+	}
+}
+
+func TestProjectDidChangeWatchedFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-watched")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"go.mod":  "module example.com/m\n",
+		"main.go": "package main\n\nimport \"example.com/m/a\"\n\nfunc main() { a.F() }\n",
+		"a/a.go":  "package a\n\nfunc F() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, []string{"GOFLAGS=-mod=mod"}, 1)
+	p.context = context.Background()
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+	if err := p.createGoModule([]string{filepath.Join(root, gomod)}, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func(name string) bool {
+		pkg := p.GetFromPkgPath("example.com/m/a")
+		if pkg == nil {
+			t.Fatalf("example.com/m/a is not cached, log: %q", conn.messages)
+		}
+		main := p.GetFromPkgPath("example.com/m")
+		if main == nil || main.GetImport("example.com/m/a") != pkg {
+			t.Fatal("the importer of example.com/m/a is not linked to the reloaded package")
+		}
+		if imports := main.GetTypes().Imports(); len(imports) != 1 || imports[0] != pkg.GetTypes() {
+			t.Error("the importer of example.com/m/a is not type-checked against the reloaded package")
+		}
+		return pkg.GetTypes().Scope().Lookup(name) != nil
+	}
+
+	// The packages held by the requests are not changed by the reload.
+	oldMain, oldA := p.GetFromPkgPath("example.com/m"), p.GetFromPkgPath("example.com/m/a")
+	if oldMain == nil || oldA == nil {
+		t.Fatalf("the packages are not cached, log: %q", conn.messages)
+	}
+
+	b := filepath.Join(root, "a", "b.go")
+	if err := ioutil.WriteFile(b, []byte("package a\n\nfunc G() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.DidChangeWatchedFile(b)
+	if oldMain.GetImport("example.com/m/a") != oldA {
+		t.Error("the importer held before the reload is linked to the reloaded package")
+	}
+	if !lookup("G") {
+		t.Error("the created file is not in the reloaded package")
+	}
+	if p.GetFromURI(lsp.DocumentURI("file://"+filepath.ToSlash(b))) == nil {
+		t.Error("the created file is not cached")
+	}
+
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	p.DidChangeWatchedFile(b)
+	if lookup("G") {
+		t.Error("the deleted file is still in the reloaded package")
+	}
+}
+
+func TestProjectFindGoModFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{"a", "testdata/b", "c/d/e"} {
+		path := filepath.Join(root, dir)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, gomod), []byte("module m\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, nil, 1)
+	p.context = context.Background()
+	p.SetWalkOptions([]string{"testdata"}, 2)
+	got := p.findGoModFiles()
+	if want := []string{filepath.Join(root, "a", gomod)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got go.mod files %q, want %q", got, want)
+	}
+	skipped := filepath.Join(root, "c", "d", "e") + " is skipped"
+	found := false
+	for _, message := range conn.messages {
+		found = found || strings.HasPrefix(message, skipped)
+	}
+	if !found {
+		t.Errorf("the skipped directory is not logged: %q", conn.messages)
+	}
+}
+
+func TestProjectWalkDirDepth(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-walk-depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// With a maximum depth of 3, a/b/c is walked and d/e/f/g is not. The
+	// siblings of the directories walked keep their own depth.
+	for _, dir := range []string{"a/b/c", "a/b2", "d/e/f/g"} {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "x.go"), []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProject(context.Background(), &logRecorder{}, root, nil, nil, 1)
+	p.context = context.Background()
+	p.SetWalkOptions(nil, 3)
+	var got []string
+	err = p.walkDir(p.rootDir, 0, func(path string, name string) {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"a/b/c", "a/b2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the files of %q, want %q", got, want)
+	}
+}
+
+func TestMultipleGoPaths(t *testing.T) {
+	defer func(paths []string) { gopaths = paths }(gopaths)
+	defer os.Setenv(gopathEnv, os.Getenv(gopathEnv))
+
+	first, second := filepath.Join("/first", "go"), filepath.Join("/second", "go")
+	os.Setenv(gopathEnv, first+string(os.PathListSeparator)+second)
+	gopaths = getGoPaths()
+
+	tests := []struct {
+		rootDir    string
+		importPath string
+	}{
+		{filepath.Join(first, "src", "example.com", "a"), "example.com/a"},
+		{filepath.Join(second, "src", "example.com", "b"), "example.com/b"},
+		{filepath.Join(second, "src"), ""},
+		{filepath.Join(second, "srcx", "c"), ""},
+		{filepath.Join("/third", "src", "d"), ""},
+	}
+	for _, test := range tests {
+		p := &Project{rootDir: util.LowerDriver(filepath.ToSlash(test.rootDir))}
+		if got := p.getImportPath(); got != test.importPath {
+			t.Errorf("import path of %s = %q, want %q", test.rootDir, got, test.importPath)
+		}
+	}
+
+	for filename, want := range map[string]bool{
+		filepath.Join(first, "pkg", "mod", "example.com", "m@v1.0.0", "m.go"):  true,
+		filepath.Join(second, "pkg", "mod", "example.com", "m@v1.0.0", "m.go"): true,
+		filepath.Join(second, "src", "example.com", "m", "m.go"):               false,
+	} {
+		if got := isFileInsideGomod(filename); got != want {
+			t.Errorf("isFileInsideGomod(%s) = %t, want %t", filename, got, want)
+		}
+	}
+}
+
+func TestViewVendorMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v := NewView(&packages.Config{})
+	v.env = []string{"GOFLAGS=-mod=vendor"}
+	if !v.vendorMode(dir) {
+		t.Error("GOFLAGS=-mod=vendor is not vendor mode")
+	}
+
+	v.env = []string{"GOFLAGS="}
+	if v.vendorMode(dir) {
+		t.Error("a module without vendor directory is in vendor mode")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, vendor), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, vendor, "modules.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !v.vendorMode(dir) {
+		t.Error("a module with vendor/modules.txt is not in vendor mode")
+	}
+
+	v.Config.BuildFlags = []string{"-mod=mod"}
+	if v.vendorMode(dir) {
+		t.Error("-mod=mod is vendor mode")
+	}
+}
+
+func TestParseGoWorkUses(t *testing.T) {
+	src := `go 1.18
+
+use ./a // the first module
+use (
+	./b
+	"./c d"
+)
+
+replace example.com/x => ./x
+`
+	want := []string{"./a", "./b", "./c d"}
+	if got := parseGoWorkUses([]byte(src)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got uses %q, want %q", got, want)
+	}
+}
+
+func TestProjectGoWork(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-gowork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"go.work":  "go 1.18\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.18\n",
+		"a/a.go":   "package a\n\nimport \"example.com/b\"\n\nvar _ = b.B\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.18\n",
+		"b/b.go":   "package b\n\nfunc B() {}\n",
+		// c is not used by the workspace.
+		"c/go.mod": "module example.com/c\n\ngo 1.18\n",
+		"c/c.go":   "package c\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, []string{"GOFLAGS=", "GOWORK="}, 2)
+	p.context = context.Background()
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+
+	gomodList := p.findGoModules()
+	want := []string{filepath.Join(root, "a", gomod), filepath.Join(root, "b", gomod)}
+	if !reflect.DeepEqual(gomodList, want) {
+		t.Fatalf("got modules %q, want %q", gomodList, want)
+	}
+	if err := p.createGoModule(gomodList, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	b := p.newCache.Get("example.com/b")
+	if b == nil {
+		t.Fatalf("the package of the other module is not cached, log: %q", conn.messages)
+	}
+	if got, want := b.Package().GetFilenames(), filepath.Join(root, "b", "b.go"); len(got) != 1 || got[0] != want {
+		t.Errorf("got files %v of the other module, want %s", got, want)
+	}
+	if p.newCache.Get("example.com/c") != nil {
+		t.Error("the package of a module outside of the workspace is cached")
+	}
+	for _, m := range p.modules {
+		want := []string{"example.com/a", "example.com/b"}
+		if filepath.Base(m.rootDir) == "b" {
+			want = []string{"example.com/b", "example.com/a"}
+		}
+		if got := m.localPaths(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got local paths %q of %s, want %q", got, m.rootDir, want)
+		}
+	}
+}
+
+func TestProjectInitLazy(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-lazy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "b", "b.go"), []byte("package b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProject(context.Background(), &logRecorder{}, root, nil, nil, 1)
+	if err := p.Init(context.Background(), Lazy, 0); err != nil {
+		t.Fatal(err)
+	}
+	if p.getCache().Get("example.com/a") != nil {
+		t.Fatal("the package is loaded by Init")
+	}
+	if !p.watching {
+		t.Error("the files of the lazy project are not watched")
+	}
+
+	// A type check only loads the package of the file.
+	pkg, _, err := p.TypeCheck(context.Background(), util.PathToURI(filepath.Join(root, "a.go")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.GetPkgPath() != "example.com/a" || p.getCache().Get("example.com/a") == nil {
+		t.Errorf("got package %s, want example.com/a loaded by the type check", pkg.GetPkgPath())
+	}
+	if p.getCache().Get("example.com/a/b") != nil {
+		t.Error("the package of another file is loaded by the type check")
+	}
+
+	// A canceled search does not build the project.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Search(ctx, func(source.Package) error { return nil }); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if p.isBuilt() {
+		t.Error("the project is built by a canceled search")
+	}
+
+	var found []string
+	var mu sync.Mutex
+	err = p.Search(context.Background(), func(pkg source.Package) error {
+		mu.Lock()
+		found = append(found, pkg.GetPkgPath())
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(found)
+	if i := sort.SearchStrings(found, "example.com/a/b"); i == len(found) || found[i] != "example.com/a/b" {
+		t.Errorf("got packages %v, want example.com/a/b loaded by the first search", found)
+	}
+}
+
+func TestProjectReadyBeforeLoad(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProject(context.Background(), &logRecorder{}, root, nil, nil, 1)
+	// The packages of the module are loaded once released, the builtin
+	// package is not held back.
+	release := make(chan struct{})
+	p.load = func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		if len(patterns) == 1 && strings.HasSuffix(patterns[0], "/...") {
+			<-release
+		}
+		return packages.Load(cfg, patterns...)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Init(context.Background(), Always, 0)
+	}()
+
+	select {
+	case <-p.Ready():
+	case <-time.After(time.Minute):
+		close(release)
+		t.Fatal("the project is not ready before its packages are loaded")
+	}
+
+	// The package of a file is type-checked on demand meanwhile.
+	pkg, _, err := p.TypeCheck(context.Background(), util.PathToURI(filepath.Join(root, "a.go")))
+	close(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.GetPkgPath() != "example.com/a" {
+		t.Errorf("got package %s, want example.com/a", pkg.GetPkgPath())
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectReload(t *testing.T) {
+	gp, err := ioutil.TempDir("", "bingo-gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gp)
+	// GOPATH is read when the server starts, a reload cannot change it.
+	defer func(paths []string) { gopaths = paths }(gopaths)
+	defer os.Setenv(gopathEnv, os.Getenv(gopathEnv))
+	os.Setenv(gopathEnv, gp)
+	gopaths = getGoPaths()
+
+	root := filepath.Join(gp, "src", "example.com", "a")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := []string{"GOPATH=" + gp, "GO111MODULE=off", "GOFLAGS=", "GOWORK=off"}
+	p := NewProject(context.Background(), &logRecorder{}, root, nil, env, 1)
+	if err := p.Init(context.Background(), Always, 0); err != nil {
+		t.Fatal(err)
+	}
+	if p.gopath == nil || len(p.modules) != 0 {
+		t.Fatalf("got %d modules and GOPATH %v, want GOPATH mode", len(p.modules), p.gopath)
+	}
+	if p.getCache().Get("example.com/a") == nil {
+		t.Fatal("package example.com/a is not cached in GOPATH mode")
+	}
+
+	for _, kv := range []string{"GOPATH=" + root, "GOROOT=" + root} {
+		if err := p.Reload([]string{kv}); err == nil {
+			t.Errorf("reload with %s succeeded, want an error", kv)
+		}
+	}
+	if p.gopath == nil {
+		t.Fatal("a rejected reload reset the project")
+	}
+
+	// The reload waits until the readers of the project release it.
+	p.RLock()
+	done := make(chan error)
+	go func() { done <- p.Reload([]string{"GO111MODULE=on"}) }()
+	select {
+	case <-done:
+		t.Fatal("the reload did not wait for the reader of the project")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.RUnlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := p.view.getenv(go111module); got != "on" {
+		t.Errorf("got GO111MODULE=%s, want on", got)
+	}
+	if p.gopath != nil || len(p.modules) != 1 {
+		t.Fatalf("got %d modules and GOPATH %v, want module mode", len(p.modules), p.gopath)
+	}
+	if p.getCache().Get("example.com/a") == nil {
+		t.Error("package example.com/a is not cached in module mode")
+	}
+}
+
+func TestProjectMissingGoroot(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-goroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(dir string) { goroot = dir }(goroot)
+	goroot = util.LowerDriver(filepath.ToSlash(filepath.Join(root, "goroot", "src")))
+
+	conn := &logRecorder{}
+	p := NewProject(context.Background(), conn, root, nil, nil, 1)
+	if err := p.Init(context.Background(), Lazy, 0); err != nil {
+		t.Fatal(err)
+	}
+	if p.GetBuiltinPackage() != nil {
+		t.Error("got a builtin package without GOROOT")
+	}
+	if isStdlibPackage("fmt") {
+		t.Error("fmt is a standard library package without GOROOT")
+	}
+
+	found := false
+	for _, msg := range conn.messages {
+		if strings.Contains(msg, "cannot load the builtin package") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("got messages %q, want the builtin package reported missing", conn.messages)
+	}
+}
+
+func TestLogLevelEnabled(t *testing.T) {
+	tests := []struct {
+		level LogLevel
+		want  []LogLevel
+	}{
+		{LogError, []LogLevel{LogError}},
+		{LogInfo, []LogLevel{LogError, LogInfo}},
+		{"", []LogLevel{LogError, LogInfo}},
+		{"verbose", []LogLevel{LogError, LogInfo}},
+		{LogDebug, []LogLevel{LogError, LogInfo, LogDebug}},
+		{LogTrace, []LogLevel{LogError, LogInfo, LogDebug, LogTrace}},
+	}
+	for _, test := range tests {
+		var got []LogLevel
+		for _, level := range []LogLevel{LogError, LogInfo, LogDebug, LogTrace} {
+			if test.level.Enabled(level) {
+				got = append(got, level)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v enabled, want %v", test.level, got, test.want)
+		}
+	}
+}
+
+func TestViewTypeCheckMissingImport(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	filename := filepath.Join(root, "a.go")
+	files := map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a.go":   "package m\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/missing\"\n)\n\nvar _ = missing.X + undefinedName\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	p := NewProject(ctx, &logRecorder{}, root, nil, []string{"GO111MODULE=on", "GOFLAGS=-mod=readonly", "GOPROXY=off"}, 2)
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+	f, err := p.view.GetFile(ctx, span.FileURI(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil {
+		t.Fatal("no package")
+	}
+
+	var got []string
+	for _, err := range pkg.GetErrors() {
+		if err.Kind == packages.TypeError {
+			got = append(got, err.Msg)
+		}
+	}
+	sort.Strings(got)
+	want := []string{`"fmt" imported and not used`, "could not import example.com/missing (cannot find module", "undefined: undefinedName"}
+	if len(got) != len(want) {
+		t.Fatalf("got type errors %q, want %q", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("got type error %q, want %q", got[i], want[i])
+		}
+	}
+}
+
+func TestProjectLoadParallelism(t *testing.T) {
+	p := NewProject(context.Background(), &logRecorder{}, "/", nil, nil, 2)
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+	p.load = func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.loadPackages(&packages.Config{}, "./..."); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak < 1 || peak > 2 {
+		t.Errorf("got a peak of %d loads at once, want at most 2", peak)
+	}
+}
+
+func TestImportBatches(t *testing.T) {
+	newPkg := func(path string, imports ...*packages.Package) *packages.Package {
+		pkg := &packages.Package{ID: path, PkgPath: path, Imports: make(map[string]*packages.Package)}
+		for _, imp := range imports {
+			pkg.Imports[imp.PkgPath] = imp
+		}
+		return pkg
+	}
+	fmtPkg := newPkg("fmt")
+	c := newPkg("example.com/c", fmtPkg)
+	b := newPkg("example.com/b", c)
+	a := newPkg("example.com/a", b, fmtPkg)
+	d := newPkg("example.com/d")
+
+	ids := func(batches [][]*packages.Package) [][]string {
+		var res [][]string
+		for _, batch := range batches {
+			var batchIDs []string
+			for _, pkg := range batch {
+				batchIDs = append(batchIDs, pkg.ID)
+			}
+			res = append(res, batchIDs)
+		}
+		return res
+	}
+	none := func(id string) bool { return false }
+	stdlib := func(id string) bool { return id == "fmt" }
+
+	got := ids(importBatches([]*packages.Package{a, b, c, d}, 2, none))
+	want := [][]string{{"fmt", "example.com/c"}, {"example.com/b", "example.com/a"}, {"example.com/d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got batches %q, want %q", got, want)
+	}
+
+	// The cached packages are not loaded again.
+	got = ids(importBatches([]*packages.Package{a, d}, 2, stdlib))
+	want = [][]string{{"example.com/c", "example.com/b"}, {"example.com/a", "example.com/d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got batches %q, want %q", got, want)
+	}
+}
+
+func TestProjectLoadBatches(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-batches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"go.mod":      "module example.com/m\n",
+		"a/a.go":      "package a\n\nimport \"example.com/m/b\"\n\nvar A = b.B\n",
+		"b/b.go":      "package b\n\nimport \"example.com/m/c\"\n\nvar B = c.C\n",
+		"c/c.go":      "package c\n\nconst C = 1\n",
+		"d/d.go":      "package d\n\nimport \"example.com/m/c\"\n\nvar D = c.C + undefinedName\n",
+		"d/d_test.go": "package d\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProject(context.Background(), &logRecorder{}, root, nil, []string{"GO111MODULE=on"}, 2)
+	p.context = context.Background()
+	p.newCache = p.newGlobalCache()
+	p.view.gcache = p.newCache
+	p.SetLoadBatchSize(2)
+	if err := p.createGoModule([]string{filepath.Join(root, "go.mod")}, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs := make(map[string]*Package)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		pkg := p.newCache.Get("example.com/m/" + name).Package()
+		if pkg == nil {
+			t.Fatalf("package example.com/m/%s is not cached", name)
+		}
+		pkgs[name] = pkg
+	}
+
+	// c is type-checked once, its importers share its types.
+	for _, name := range []string{"b", "d"} {
+		if imp := pkgs[name].imports["example.com/m/c"]; imp != pkgs["c"] {
+			t.Errorf("%s imports %p, want the cached c %p", name, imp, pkgs["c"])
+		}
+		if imps := pkgs[name].types.Imports(); len(imps) != 1 || imps[0] != pkgs["c"].types {
+			t.Errorf("%s imports the types %v, want the ones of the cached c", name, imps)
+		}
+	}
+	if obj := pkgs["a"].types.Scope().Lookup("A"); obj == nil || obj.Type().String() != "int" {
+		t.Errorf("got %v for a.A, want an int variable", obj)
+	}
+
+	var typeErrors []string
+	for _, err := range pkgs["d"].errors {
+		if err.Kind == packages.TypeError {
+			typeErrors = append(typeErrors, err.Msg)
+		}
+	}
+	if want := []string{"undefined: undefinedName"}; !reflect.DeepEqual(typeErrors, want) {
+		t.Errorf("got type errors %q for d, want %q", typeErrors, want)
+	}
+
+	if count, peak := p.newCache.Counts(); count == 0 || peak < count {
+		t.Errorf("got %d packages cached and a peak of %d", count, peak)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/scanner"
+	"go/token"
 	"go/types"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -82,12 +85,19 @@ func (v *View) cachePackage(pkg *Package) {
 			log.Printf("no token.File for %v", file.Name)
 			continue
 		}
-		fURI := span.FileURI(tok.Name())
-		f := v.getFile(fURI)
-		f.token = tok
-		f.ast = file
-		f.imports = f.ast.Imports
-		f.pkg = pkg
+		filenames := []string{tok.Name()}
+		// The line directives of a file generated by cgo map it to the
+		// file it is generated from, which is cached with it.
+		if name := v.Config.Fset.Position(file.Pos()).Filename; name != tok.Name() {
+			filenames = append(filenames, name)
+		}
+		for _, filename := range filenames {
+			f := v.getFile(span.FileURI(filename))
+			f.token = tok
+			f.ast = file
+			f.imports = f.ast.Imports
+			f.pkg = pkg
+		}
 	}
 }
 
@@ -97,28 +107,112 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 		return nil, err
 	}
 	if v.reparseImports(ctx, f, filename) {
-		cfg := v.Config
-		cfg.Mode = packages.LoadImports
+		cfg := v.loadConfig(packages.LoadImports)
 		cfg.Dir = filepath.Dir(filename)
 		pkgs, err := packages.Load(&cfg, fmt.Sprintf("file=%s", filename))
+		var sizes types.Sizes
+		if !anyIncludesFile(pkgs, filename) {
+			// The file is excluded by the build constraints of the
+			// configured platform, it is loaded for the platform of its
+			// name, eg. windows for foo_windows.go.
+			if platform := v.filePlatform(filename); platform != nil {
+				env := v.goEnv()
+				if env == nil {
+					env = os.Environ()
+				}
+				cfg.Env = append(env, platform...)
+				if platformPkgs, _ := packages.Load(&cfg, fmt.Sprintf("file=%s", filename)); anyIncludesFile(platformPkgs, filename) {
+					pkgs, err = platformPkgs, nil
+					sizes = types.SizesFor("gc", strings.TrimPrefix(platform[1], "GOARCH="))
+				}
+			}
+		}
 		if len(pkgs) == 0 {
 			if err == nil {
 				err = fmt.Errorf("no packages found for %s", filename)
 			}
 			return nil, err
 		}
+		// The metadata of a package path is the one of the variant linked
+		// last, so the variants including the file are linked last.
+		sort.SliceStable(pkgs, func(i, j int) bool {
+			return !includesFile(pkgs[i], filename) && includesFile(pkgs[j], filename)
+		})
 		for _, pkg := range pkgs {
-			// If the package comes back with errors from `go list`, don't bother
-			// type-checking it.
-			if len(pkg.Errors) > 0 {
+			// If the package comes back with errors from `go list` and
+			// without its files, don't bother type-checking it. Otherwise
+			// it is type-checked without the imports which could not be
+			// loaded, eg. the ones missing from the module cache, so that
+			// the errors of its files are still reported.
+			if len(pkg.Errors) > 0 && len(pkg.CompiledGoFiles) == 0 {
 				return pkg.Errors, fmt.Errorf("package %s has errors, skipping type-checking", pkg.PkgPath)
 			}
-			v.link(pkg.PkgPath, pkg, nil)
+			v.link(pkg.PkgPath, pkg, nil).sizes = sizes
 		}
 	}
 	return nil, nil
 }
 
+// includesFile reports whether filename is one of the files of pkg compiled
+// for the platform it is loaded for.
+func includesFile(pkg *packages.Package, filename string) bool {
+	for _, files := range [][]string{pkg.CompiledGoFiles, pkg.GoFiles} {
+		for _, f := range files {
+			if sameFile(f, filename) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func anyIncludesFile(pkgs []*packages.Package, filename string) bool {
+	for _, pkg := range pkgs {
+		if includesFile(pkg, filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// filePlatform returns the GOOS and GOARCH variables of the platform the
+// name of filename is for, eg. GOOS=windows for foo_windows.go, if the file
+// is compiled for it. The platform not given by the name is the configured
+// one. It returns nil if there is none.
+func (v *View) filePlatform(filename string) []string {
+	goos, goarch := v.getenv("GOOS"), v.getenv("GOARCH")
+	if goos == "" {
+		goos = build.Default.GOOS
+	}
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+
+	dir, base := filepath.Split(filename)
+	elts := strings.Split(strings.TrimSuffix(strings.TrimSuffix(base, ".go"), "_test"), "_")
+	var candidates [][2]string
+	if n := len(elts); n >= 3 {
+		candidates = append(candidates, [2]string{elts[n-2], elts[n-1]})
+	}
+	if n := len(elts); n >= 2 {
+		candidates = append(candidates, [2]string{elts[n-1], goarch}, [2]string{goos, elts[n-1]})
+	}
+	for _, c := range candidates {
+		// MatchFile matches an element of the name against both GOOS
+		// and GOARCH, eg. GOOS=386 for foo_386.go, the architectures
+		// are told apart by their sizes.
+		if types.SizesFor("gc", c[1]) == nil || types.SizesFor("gc", c[0]) != nil {
+			continue
+		}
+		ctxt := build.Default
+		ctxt.GOOS, ctxt.GOARCH = c[0], c[1]
+		if ok, err := ctxt.MatchFile(dir, base); err == nil && ok {
+			return []string{"GOOS=" + c[0], "GOARCH=" + c[1]}
+		}
+	}
+	return nil
+}
+
 // reparseImports reparses a file's import declarations to determine if they
 // have changed.
 func (v *View) reparseImports(ctx context.Context, f *File, filename string) bool {
@@ -156,7 +250,10 @@ func (v *View) link(pkgPath string, pkg *packages.Package, parent *metadata) *me
 	// Reset any field that could have changed across calls to packages.Load.
 	m.name = pkg.Name
 	m.files = pkg.CompiledGoFiles
-	for _, filename := range m.files {
+	m.cgoFiles = cgoFiles(pkg)
+	m.sizes = nil
+	m.errors = pkg.Errors
+	for _, filename := range m.allFiles() {
 		if f, ok := v.files[span.FileURI(filename)]; ok {
 			f.meta = m
 		}
@@ -186,6 +283,10 @@ func (v *View) link(pkgPath string, pkg *packages.Package, parent *metadata) *me
 type importer struct {
 	view *View
 
+	// sizes is the sizes the packages are type-checked with, nil means
+	// the view's.
+	sizes types.Sizes
+
 	// circular maintains the set of previously imported packages.
 	// If we have seen a package that is already in this map, we have a circular import.
 	circular map[string]struct{}
@@ -224,6 +325,11 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	if !ok {
 		return nil, fmt.Errorf("no metadata for %v", pkgPath)
 	}
+	// An import which go list could not load is reported as such by the
+	// type checker of its importer, rather than as an empty package.
+	if isImport && len(meta.files) == 0 && len(meta.errors) > 0 {
+		return nil, fmt.Errorf("%s", meta.errors[0].Msg)
+	}
 	// Use the default type information for the unsafe package.
 	var typ *types.Package
 	if meta.pkgPath == "unsafe" {
@@ -232,13 +338,14 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 		typ = types.NewPackage(meta.pkgPath, meta.name)
 	}
 	pkg := &Package{
-		id:      meta.id,
-		pkgPath: meta.pkgPath,
-		name:    meta.name,
-		files:   meta.files,
-		imports: make(map[string]*Package),
-		types:   typ,
-		fset:    imp.view.Config.Fset,
+		id:       meta.id,
+		pkgPath:  meta.pkgPath,
+		name:     meta.name,
+		files:    meta.files,
+		cgoFiles: meta.cgoFiles,
+		imports:  make(map[string]*Package),
+		types:    typ,
+		fset:     imp.view.Config.Fset,
 		typesInfo: &types.Info{
 			Types:      make(map[ast.Expr]types.TypeAndValue),
 			Defs:       make(map[*ast.Ident]types.Object),
@@ -267,10 +374,22 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	newCircular := copySet(imp.circular)
 	newCircular[pkgPath] = struct{}{}
 
+	// The imports of a package loaded for another platform are checked
+	// for it too.
+	sizes := imp.sizes
+	if meta.sizes != nil {
+		sizes = meta.sizes
+	}
+	if sizes == nil {
+		sizes = imp.view.sizes
+	}
+
 	cfg := &types.Config{
+		Sizes: sizes,
 		Error: appendError,
 		Importer: &importer{
 			view:     imp.view,
+			sizes:    sizes,
 			circular: newCircular,
 		},
 	}
@@ -284,7 +403,8 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 	defer imp.view.pcache.mu.Unlock()
 
 	for importPath := range meta.children {
-		if importEntry, ok := imp.view.pcache.packages[importPath]; ok {
+		// The imports which could not be loaded have no package.
+		if importEntry, ok := imp.view.pcache.packages[importPath]; ok && importEntry.pkg != nil {
 			pkg.imports[importPath] = importEntry.pkg
 		}
 	}
@@ -321,9 +441,11 @@ func copySet(m map[string]struct{}) map[string]struct{} {
 }
 
 func (v *View) appendPkgError(pkg *Package, err error) {
-	if err == nil {
-		return
-	}
+	pkg.errors = append(pkg.errors, packageErrors(v.Config.Fset, err)...)
+}
+
+// packageErrors converts a parse or type error to the errors of a package.
+func packageErrors(fset *token.FileSet, err error) []packages.Error {
 	var errs []packages.Error
 	switch err := err.(type) {
 	case *scanner.Error:
@@ -343,12 +465,12 @@ func (v *View) appendPkgError(pkg *Package, err error) {
 		}
 	case types.Error:
 		errs = append(errs, packages.Error{
-			Pos:  v.Config.Fset.Position(err.Pos).String(),
+			Pos:  fset.Position(err.Pos).String(),
 			Msg:  err.Msg,
 			Kind: packages.TypeError,
 		})
 	}
-	pkg.errors = append(pkg.errors, errs...)
+	return errs
 }
 
 // We use a counting semaphore to limit
@@ -8,13 +8,15 @@ import (
 	"strings"
 )
 
-// invokeGo returns the stdout of a go command invocation.
-func invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, error) {
+// invokeGo returns the stdout of a go command invocation, env is the
+// environment of the command, nil means the process environment.
+func invokeGo(ctx context.Context, dir string, env []string, args ...string) (*bytes.Buffer, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
 
 	cmd := exec.CommandContext(ctx, "go", args...)
 	cmd.Dir = dir
+	cmd.Env = env
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
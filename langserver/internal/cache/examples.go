@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirExamples holds the examples of the test files of a directory, and the
+// file set they are parsed in.
+type dirExamples struct {
+	fset     *token.FileSet
+	examples []*doc.Example
+}
+
+// Examples returns the examples of the test files of the directory dir, the
+// content of the open files is the one of the editor. They are parsed once,
+// until a file of the directory changes.
+func (v *View) Examples(ctx context.Context, dir string) (*token.FileSet, []*doc.Example) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.contentChanges) > 0 {
+		// The pending changes invalidate the examples of their directory.
+		v.mcache.mu.Lock()
+		err := v.applyContentChanges(ctx)
+		v.mcache.mu.Unlock()
+		if err != nil {
+			return nil, nil
+		}
+	}
+
+	e, ok := v.examples[dir]
+	if !ok {
+		e = v.parseExamples(dir)
+		v.examples[dir] = e
+	}
+	return e.fset, e.examples
+}
+
+// parseExamples parses the test files of the directory dir, including the
+// ones only open in the editor, and collects their examples. It assumes that
+// the caller is holding the view's mutex.
+func (v *View) parseExamples(dir string) *dirExamples {
+	filenames := make(map[string]bool)
+	if fis, err := ioutil.ReadDir(dir); err == nil {
+		for _, fi := range fis {
+			filenames[filepath.Join(dir, fi.Name())] = true
+		}
+	}
+	for filename := range v.Config.Overlay {
+		if filepath.Dir(filename) == dir {
+			filenames[filename] = true
+		}
+	}
+	var sorted []string
+	for filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			sorted = append(sorted, filename)
+		}
+	}
+	sort.Strings(sorted)
+
+	e := &dirExamples{fset: token.NewFileSet()}
+	var files []*ast.File
+	for _, filename := range sorted {
+		src, ok := v.Config.Overlay[filename]
+		if !ok {
+			var err error
+			if src, err = ioutil.ReadFile(filename); err != nil {
+				continue
+			}
+		}
+		file, err := parser.ParseFile(e.fset, filename, src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	e.examples = doc.Examples(files...)
+	return e
+}
+
+// forgetExamples invalidates the examples of the directory of filename. It
+// assumes that the caller is holding the view's mutex.
+func (v *View) forgetExamples(filename string) {
+	delete(v.examples, filepath.Dir(filename))
+}
@@ -91,19 +91,20 @@ func (f *File) GetPackage(ctx context.Context) source.Package {
 // read is the internal part of GetContent. It assumes that the caller is
 // holding the mutex of the file's view.
 func (f *File) read(ctx context.Context) {
-	if f.content != nil {
-		if len(f.view.contentChanges) == 0 {
-			return
-		}
-
+	if len(f.view.contentChanges) > 0 {
+		// Apply the pending overlay changes first, the file may have been
+		// opened or edited since it was last read.
 		f.view.mcache.mu.Lock()
 		err := f.view.applyContentChanges(ctx)
 		f.view.mcache.mu.Unlock()
 
-		if err == nil {
+		if err == nil && f.content != nil {
 			return
 		}
 	}
+	if f.content != nil {
+		return
+	}
 	// We don't know the content yet, so read it.
 	filename, err := f.uri.Filename()
 	if err != nil {
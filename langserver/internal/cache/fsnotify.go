@@ -69,7 +69,7 @@ func (s *fsSubject) watch(rootDir string, watcher *fsnotify.Watcher) {
 	}
 
 	for _, fi := range files {
-		if isExclude(fi.Name()) {
+		if s.observer.isExclude(fi.Name()) {
 			continue
 		}
 
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"os"
 	"sync"
 
 	"golang.org/x/tools/go/packages"
@@ -12,6 +14,10 @@ type gopath struct {
 	rootDir     string
 	importPath  string
 	underGoroot bool
+
+	// env holds the environment variables which replace the ones of the
+	// view when loading the packages, eg. GO111MODULE for GOROOT.
+	env []string
 }
 
 func newGopath(project *Project, rootDir string, importPath string, underGoroot bool) *gopath {
@@ -29,7 +35,7 @@ func (p *gopath) init() (err error) {
 		return err
 	}
 
-	return p.buildCache()
+	return p.buildCache(context.Background())
 }
 
 func (p *gopath) doInit() error {
@@ -37,17 +43,25 @@ func (p *gopath) doInit() error {
 }
 
 func (p *gopath) rebuildCache() (bool, error) {
-	err := p.buildCache()
+	err := p.buildCache(context.Background())
 	return err == nil, err
 }
 
-func (p *gopath) buildCache() error {
+// buildCache loads the packages of the GOPATH workspace into the global cache,
+// the load is canceled when ctx is done.
+func (p *gopath) buildCache(ctx context.Context) error {
 	p.project.view.mu.Lock()
 	defer p.project.view.mu.Unlock()
 
-	cfg := p.project.view.Config
+	cfg := p.project.view.loadConfig(packages.LoadAllSyntax)
+	cfg.Context = ctx
 	cfg.Dir = p.rootDir
-	cfg.Mode = packages.LoadAllSyntax
+	if len(p.env) > 0 {
+		if cfg.Env == nil {
+			cfg.Env = os.Environ()
+		}
+		cfg.Env = append(cfg.Env, p.env...)
+	}
 
 	var pattern string
 	if p.underGoroot {
@@ -56,11 +70,5 @@ func (p *gopath) buildCache() error {
 		pattern = p.importPath + "/..."
 	}
 
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
-	}
-
-	p.project.setCache(pkgs)
-	return nil
+	return p.project.loadIntoCache(&cfg, p.project.view.sizes, pattern)
 }
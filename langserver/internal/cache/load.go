@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// SetLoadBatchSize sets the maximum number of packages of a module or of the
+// GOPATH workspace type-checked at once, see loadIntoCache. A size <= 0 loads
+// them all at once.
+func (p *Project) SetLoadBatchSize(size int) {
+	p.loadBatchSize = size
+}
+
+// loadPackages loads the packages matching patterns. At most parallelism
+// loads of the project run at once, the others wait for one to end, so that
+// the syntax and the types held at once by go/packages are bounded.
+func (p *Project) loadPackages(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	p.loadSem <- struct{}{}
+	defer func() { <-p.loadSem }()
+	return p.load(cfg, patterns...)
+}
+
+// loadIntoCache loads the packages matching pattern, and their dependencies,
+// into the global cache. If the load batch size is set, go/packages only
+// lists their import graph, then the packages which are not cached yet are
+// type-checked in batches of that many packages, the imported packages
+// first, against the cached packages they import. So the syntax and the
+// types of the whole graph are not held at once before being cached, and the
+// dependencies shared by several batches are loaded once. sizes are the
+// sizes the packages are type-checked with.
+func (p *Project) loadIntoCache(cfg *packages.Config, sizes types.Sizes, pattern string) error {
+	if p.loadBatchSize <= 0 {
+		pkgs, err := p.loadPackages(cfg, pattern)
+		if err != nil {
+			return err
+		}
+		p.setCache(pkgs)
+		return nil
+	}
+
+	listCfg := *cfg
+	listCfg.Mode = packages.LoadImports
+	roots, err := p.loadPackages(&listCfg, pattern)
+	if err != nil {
+		return err
+	}
+
+	cached := func(id string) bool {
+		return p.newCache.getByID(id) != nil
+	}
+	checker := &batchChecker{cfg: cfg, sizes: sizes, cache: p.newCache, checking: make(map[string]bool)}
+	batches := importBatches(roots, p.loadBatchSize, cached)
+	for i, batch := range batches {
+		if cfg.Context != nil && cfg.Context.Err() != nil {
+			return cfg.Context.Err()
+		}
+
+		start := time.Now()
+		p.loadSem <- struct{}{}
+		for _, pkg := range batch {
+			checker.check(pkg)
+		}
+		<-p.loadSem
+		count, peak := p.newCache.Counts()
+		p.notifyDebug(fmt.Sprintf("load %s: batch %d/%d of %d packages checked in %s, %d packages cached, peak %d",
+			pattern, i+1, len(batches), len(batch), time.Since(start), count, peak))
+	}
+	return nil
+}
+
+// importBatches returns the packages of the import graph of roots, but the
+// ones cached reports true for, in batches of at most size packages, ordered
+// so that a package comes after the packages it imports, unless there is an
+// import cycle. The imports of a cached package are cached too, they are
+// skipped with it.
+func importBatches(roots []*packages.Package, size int, cached func(id string) bool) [][]*packages.Package {
+	var order []*packages.Package
+	seen := make(map[string]bool)
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if pkg.ID == "" || seen[pkg.ID] {
+			return
+		}
+		seen[pkg.ID] = true
+		if cached(pkg.ID) {
+			return
+		}
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			visit(pkg.Imports[path])
+		}
+		order = append(order, pkg)
+	}
+	for _, pkg := range roots {
+		visit(pkg)
+	}
+
+	var batches [][]*packages.Package
+	for len(order) > size {
+		batches = append(batches, order[:size:size])
+		order = order[size:]
+	}
+	if len(order) > 0 {
+		batches = append(batches, order)
+	}
+	return batches
+}
+
+// batchChecker type-checks the packages listed by go/packages into the global
+// cache, their imports are taken from the cache.
+type batchChecker struct {
+	cfg   *packages.Config
+	sizes types.Sizes
+	cache *GlobalCache
+
+	// checking is the set of the ids of the packages being type-checked, to
+	// break the import cycles.
+	checking map[string]bool
+}
+
+// check returns the cached package of meta, it is type-checked and cached
+// first if it is not cached yet, eg. if it was evicted since its batch. It
+// returns nil for a package of an import cycle.
+func (c *batchChecker) check(meta *packages.Package) *Package {
+	if pkg := c.cache.getByID(meta.ID); pkg != nil {
+		return pkg
+	}
+	if c.checking[meta.ID] {
+		return nil
+	}
+	c.checking[meta.ID] = true
+	defer delete(c.checking, meta.ID)
+
+	pkg := &Package{
+		id:       meta.ID,
+		pkgPath:  meta.PkgPath,
+		name:     meta.Name,
+		files:    meta.CompiledGoFiles,
+		cgoFiles: cgoFiles(meta),
+		errors:   append([]packages.Error(nil), meta.Errors...),
+		imports:  make(map[string]*Package),
+		fset:     c.cfg.Fset,
+		typesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+		analyses: make(map[*analysis.Analyzer]*analysisEntry),
+	}
+	appendError := func(err error) {
+		pkg.errors = append(pkg.errors, packageErrors(c.cfg.Fset, err)...)
+	}
+
+	// Use the default type information for the unsafe package.
+	if meta.PkgPath == "unsafe" {
+		pkg.types = types.Unsafe
+		return c.cache.putIfAbsent(pkg)
+	}
+	pkg.types = types.NewPackage(meta.PkgPath, meta.Name)
+
+	imports := make(map[string]*types.Package)
+	for path, imp := range meta.Imports {
+		// An import which go list could not load is reported as such by the
+		// type checker.
+		if dep := c.check(imp); dep != nil {
+			pkg.imports[dep.pkgPath] = dep
+			imports[path] = dep.types
+		}
+	}
+
+	for _, filename := range pkg.files {
+		src, ok := c.cfg.Overlay[filename]
+		if !ok {
+			var err error
+			if src, err = ioutil.ReadFile(filename); err != nil {
+				appendError(err)
+				continue
+			}
+		}
+		// ParseFile may return both an AST and an error.
+		file, err := c.cfg.ParseFile(c.cfg.Fset, filename, src)
+		if file != nil {
+			pkg.syntax = append(pkg.syntax, file)
+		}
+		appendError(err)
+	}
+
+	tc := &types.Config{
+		Sizes: c.sizes,
+		Error: appendError,
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if typ := imports[path]; typ != nil {
+				return typ, nil
+			}
+			return nil, fmt.Errorf("could not import %s", path)
+		}),
+	}
+	types.NewChecker(tc, c.cfg.Fset, pkg.types, pkg.typesInfo).Files(pkg.syntax)
+	return c.cache.putIfAbsent(pkg)
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,39 @@
+package cache
+
+// LogLevel is the verbosity of the messages logged to the client with
+// window/logMessage. The errors are shown with window/showMessage whatever
+// the level.
+type LogLevel string
+
+const (
+	// LogError logs nothing but the errors.
+	LogError LogLevel = "error"
+
+	// LogInfo logs the loading of the packages and the failures of the
+	// requests.
+	LogInfo LogLevel = "info"
+
+	// LogDebug also logs the rebuilds of the cache and the durations of the
+	// searches.
+	LogDebug LogLevel = "debug"
+
+	// LogTrace also logs the node each request resolves its position to.
+	LogTrace LogLevel = "trace"
+)
+
+var logLevelOrder = map[LogLevel]int{
+	LogError: 0,
+	LogInfo:  1,
+	LogDebug: 2,
+	LogTrace: 3,
+}
+
+// Enabled reports whether the messages of level are logged at l. An unknown
+// or empty l is LogInfo.
+func (l LogLevel) Enabled(level LogLevel) bool {
+	order, ok := logLevelOrder[l]
+	if !ok {
+		order = logLevelOrder[LogInfo]
+	}
+	return logLevelOrder[level] <= order
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,6 +21,10 @@ type moduleInfo struct {
 	Version  string    `json:"Version"`
 	Time     time.Time `json:"Time"`
 	Indirect bool      `json:"Indirect"`
+
+	// Replace is the replacement of the module, its Version is empty if it
+	// is a directory.
+	Replace *moduleInfo `json:"Replace"`
 }
 
 type module struct {
@@ -28,21 +33,18 @@ type module struct {
 	rootDir        string
 	mainModulePath string
 	moduleMap      map[string]moduleInfo
+
+	// localDeps are the paths of the other modules of the project the
+	// module depends on, which are handled like the main module: the other
+	// modules of the go.work workspace and the dependencies replaced by a
+	// directory of the project, eg. a local fork.
+	localDeps []string
 }
 
 func newModule(gc *Project, rootDir string) *module {
 	return &module{project: gc, rootDir: rootDir}
 }
 
-func (m *module) init() (err error) {
-	err = m.doInit()
-	if err != nil {
-		return err
-	}
-
-	return m.buildCache()
-}
-
 func (m *module) doInit() error {
 	moduleMap, err := m.readGoModule()
 	if err != nil {
@@ -54,7 +56,14 @@ func (m *module) doInit() error {
 }
 
 func (m *module) readGoModule() (map[string]moduleInfo, error) {
-	buf, err := invokeGo(context.Background(), m.rootDir, "list", "-m", "-json", "all")
+	view := m.project.view
+	args := []string{"list", "-m", "-json", "all"}
+	if view.vendorMode(m.rootDir) {
+		// The module graph cannot be computed from the vendor directory, the
+		// vendored packages are loaded with the packages of the module.
+		args = args[:len(args)-1]
+	}
+	buf, err := invokeGo(context.Background(), m.rootDir, view.goEnv(), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,15 +98,42 @@ func (m *module) readGoModule() (map[string]moduleInfo, error) {
 func (m *module) initModule(moduleMap map[string]moduleInfo) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	for _, module := range moduleMap {
-		if module.Main {
-			m.mainModulePath = module.Path
+	var mainPath string
+	var mains, localDeps []string
+	for dir, module := range moduleMap {
+		switch {
+		case module.Main && dir == m.rootDir:
+			mainPath = module.Path
+		case module.Main:
+			// In workspace mode, all the modules of the workspace are main
+			// modules.
+			mains = append(mains, module.Path)
+		case module.Replace != nil && module.Replace.Version == "" && m.project.isInsideProject(dir):
+			localDeps = append(localDeps, module.Path)
 		}
 	}
+	if mainPath == "" && len(mains) == 1 {
+		mainPath, mains = mains[0], nil
+	}
+	m.mainModulePath = mainPath
+	m.localDeps = append(localDeps, mains...)
+	sort.Strings(m.localDeps)
 
 	m.moduleMap = moduleMap
 }
 
+// localPaths returns the paths of the main module and of the other modules of
+// the project it depends on.
+func (m *module) localPaths() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var paths []string
+	if m.mainModulePath != "." && m.mainModulePath != "" {
+		paths = append(paths, m.mainModulePath)
+	}
+	return append(paths, m.localDeps...)
+}
+
 func (m *module) checkModuleCache() (bool, error) {
 	moduleMap, err := m.readGoModule()
 	if err != nil {
@@ -122,7 +158,7 @@ func (m *module) rebuildCache() (bool, error) {
 		return false, nil
 	}
 
-	err = m.buildCache()
+	err = m.buildCache(context.Background())
 	return err == nil, err
 }
 
@@ -137,20 +173,31 @@ func (m *module) hasChanged(moduleMap map[string]moduleInfo) bool {
 	return false
 }
 
-func (m *module) buildCache() error {
+// buildCache loads the packages of the module into the global cache, the load
+// is canceled when ctx is done.
+func (m *module) buildCache(ctx context.Context) error {
+	// The modules are loaded in parallel, so the view is only locked while
+	// its config, including a copy of the overlay, is taken.
 	m.project.view.mu.Lock()
-	defer m.project.view.mu.Unlock()
+	cfg := m.project.view.loadConfig(packages.LoadAllSyntax)
+	cfg.Overlay = copyOverlay(cfg.Overlay)
+	sizes := m.project.view.sizes
+	m.project.view.mu.Unlock()
 
-	cfg := m.project.view.Config
+	cfg.Context = ctx
 	cfg.Dir = m.rootDir
-	cfg.Mode = packages.LoadAllSyntax
 	pattern := cfg.Dir + "/..."
 
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
-	}
+	return m.project.loadIntoCache(&cfg, sizes, pattern)
+}
 
-	m.project.setCache(pkgs)
-	return nil
+func copyOverlay(overlay map[string][]byte) map[string][]byte {
+	if overlay == nil {
+		return nil
+	}
+	res := make(map[string][]byte, len(overlay))
+	for filename, content := range overlay {
+		res[filename] = content
+	}
+	return res
 }
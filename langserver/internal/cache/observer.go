@@ -10,6 +10,7 @@ type Observer interface {
 	notifyLog(message string)
 	notifyError(message string)
 	getContext() context.Context
+	isExclude(dir string) bool
 }
 
 type Subject interface {
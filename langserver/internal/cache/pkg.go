@@ -25,6 +25,10 @@ type Package struct {
 	typesInfo   *types.Info
 	fset        *token.FileSet
 
+	// cgoFiles are the files of the package processed by cgo, files holds
+	// the files cgo generated from them instead.
+	cgoFiles []string
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
@@ -109,6 +113,32 @@ func (pkg *Package) GetFilenames() []string {
 	return pkg.files
 }
 
+// allFiles returns the files of the package and the files processed by cgo.
+func (pkg *Package) allFiles() []string {
+	if len(pkg.cgoFiles) == 0 {
+		return pkg.files
+	}
+	return append(append([]string(nil), pkg.files...), pkg.cgoFiles...)
+}
+
+// cgoFiles returns the files of pkg processed by cgo: the go files which are
+// not compiled as is.
+func cgoFiles(pkg *packages.Package) []string {
+	var files []string
+	for _, filename := range pkg.GoFiles {
+		compiled := false
+		for _, name := range pkg.CompiledGoFiles {
+			if name == filename {
+				compiled = true
+				break
+			}
+		}
+		if !compiled {
+			files = append(files, filename)
+		}
+	}
+	return files
+}
 func (pkg *Package) GetSyntax() []*ast.File {
 	return pkg.syntax
 }
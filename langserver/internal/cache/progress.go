@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// progressTokens numbers the work done progress tokens created by the server.
+var progressTokens int64
+
+type workDoneProgressCreateParams struct {
+	Token interface{} `json:"token"`
+}
+
+type progressParams struct {
+	Token interface{} `json:"token"`
+	Value interface{} `json:"value"`
+}
+
+type workDoneProgressValue struct {
+	Kind        string `json:"kind"`
+	Title       string `json:"title,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Percentage  *int   `json:"percentage,omitempty"`
+	Cancellable bool   `json:"cancellable,omitempty"`
+}
+
+// workDoneProgress reports the progress of a task to the client with the
+// $/progress notifications of the work done progress protocol: a begin, then
+// a report per step and an end. A nil *workDoneProgress reports nothing.
+type workDoneProgress struct {
+	ctx   context.Context
+	conn  jsonrpc2.JSONRPC2
+	token interface{}
+
+	mu    sync.Mutex
+	total int
+	done  int
+}
+
+// SetWorkDoneProgress sets how the loading of the packages is reported to the
+// client. token is the work done token of the initialize request, it is only
+// used while the project is initialized. If create is set, the other loadings
+// create their token with the window/workDoneProgress/create request. Nothing
+// is reported if token is nil and create is not set.
+func (p *Project) SetWorkDoneProgress(token interface{}, create bool) {
+	p.progressToken = token
+	p.createProgress = create
+}
+
+// beginProgress begins the progress of a task titled title, it returns nil if
+// the client does not support it.
+func (p *Project) beginProgress(title string) *workDoneProgress {
+	token := p.progressToken
+	if token == nil {
+		if !p.createProgress {
+			return nil
+		}
+		token = fmt.Sprintf("bingo/progress/%d", atomic.AddInt64(&progressTokens, 1))
+		if err := p.conn.Call(p.context, "window/workDoneProgress/create", &workDoneProgressCreateParams{Token: token}, nil); err != nil {
+			p.notify(err)
+			return nil
+		}
+	}
+
+	progress := &workDoneProgress{ctx: p.context, conn: p.conn, token: token}
+	percentage := 0
+	progress.notify(&workDoneProgressValue{Kind: "begin", Title: title, Percentage: &percentage})
+	return progress
+}
+
+// setTotal sets the number of steps of the task.
+func (w *workDoneProgress) setTotal(total int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.total = total
+	w.mu.Unlock()
+}
+
+// step reports that one more step is done, message describes it.
+func (w *workDoneProgress) step(message string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done++
+	value := &workDoneProgressValue{Kind: "report", Message: fmt.Sprintf("%s (%d/%d)", message, w.done, w.total)}
+	if w.total > 0 {
+		percentage := w.done * 100 / w.total
+		if percentage > 100 {
+			percentage = 100
+		}
+		value.Percentage = &percentage
+	}
+	// The reports are sent in order, so that the percentage increases.
+	w.notify(value)
+}
+
+// end ends the task with message.
+func (w *workDoneProgress) end(message string) {
+	if w == nil {
+		return
+	}
+	w.notify(&workDoneProgressValue{Kind: "end", Message: message})
+}
+
+func (w *workDoneProgress) notify(value *workDoneProgressValue) {
+	_ = w.conn.Notify(w.ctx, "$/progress", &progressParams{Token: w.token, Value: value})
+}
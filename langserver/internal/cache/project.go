@@ -4,14 +4,16 @@ import (
 	"context"
 	"fmt"
 	"go/ast"
+	"go/doc"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -26,8 +28,11 @@ import (
 const (
 	goext           = ".go"
 	gomod           = "go.mod"
+	gowork          = "go.work"
+	goworkEnv       = "GOWORK"
 	vendor          = "vendor"
 	gopathEnv       = "GOPATH"
+	gorootEnv       = "GOROOT"
 	go111module     = "GO111MODULE"
 	emacsLockPrefix = ".#"
 )
@@ -37,25 +42,44 @@ var (
 	gopaths = getGoPaths()
 )
 
+// getGoRoot returns the src directory of GOROOT, in slash form, or an empty
+// string if GOROOT is unknown.
 func getGoRoot() string {
-	root := runtime.GOROOT()
+	root := util.GoRoot()
+	if root == "" {
+		return ""
+	}
 	root = filepath.ToSlash(filepath.Join(root, "src"))
 	return util.LowerDriver(root)
 }
 
+// getGoPaths returns the entries of GOPATH, in slash form. The empty entries
+// are skipped.
 func getGoPaths() []string {
 	gopath := os.Getenv(gopathEnv)
 	if gopath == "" {
 		gopath = filepath.Join(os.Getenv("HOME"), "go")
 	}
 
-	paths := strings.Split(gopath, string(os.PathListSeparator))
+	var paths []string
+	for _, path := range filepath.SplitList(gopath) {
+		if path != "" {
+			paths = append(paths, util.LowerDriver(filepath.ToSlash(path)))
+		}
+	}
 	return paths
 }
 
+// isFileInsideGomod reports whether path is in the module cache of one of the
+// GOPATH entries.
 func isFileInsideGomod(path string) bool {
-	gomodpath := filepath.Join(gopaths[0], "pkg", "mod")
-	return strings.HasPrefix(path, gomodpath)
+	path = util.LowerDriver(filepath.ToSlash(path))
+	for _, gopath := range gopaths {
+		if strings.HasPrefix(path, gopath+"/pkg/mod/") {
+			return true
+		}
+	}
+	return false
 }
 
 // FindPackageFunc matches the signature of loader.Config.FindPackage, except
@@ -73,12 +97,66 @@ type Project struct {
 	gopath        *gopath
 	cached        bool
 	newCache      *GlobalCache
+	maxPackages   int
+	parallelism   int
+	loadBatchSize int
+	excludeDirs   []string
+	maxDepth      int
 	changedCount  int
 	lastBuildTime time.Time
+
+	// lazy is set if the packages of the project are loaded on demand: the
+	// package of a file by TypeCheck, all of them by the first Search which
+	// is not canceled. built is set once they are all loaded, buildMu guards
+	// it.
+	lazy    bool
+	buildMu sync.Mutex
+	built   bool
+
+	// progressToken and createProgress set how the loading of the packages
+	// is reported, see SetWorkDoneProgress.
+	progressToken  interface{}
+	createProgress bool
+
+	// loadSem bounds the number of packages.Load calls, and of batches
+	// type-checked, running at once to parallelism, see loadPackages.
+	loadSem chan struct{}
+
+	// load is packages.Load, the tests replace it.
+	load func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error)
+
+	// watchedByClient is set if the client notifies the changes of the files
+	// with workspace/didChangeWatchedFiles, see SetWatchedByClient.
+	watchedByClient bool
+
+	// logLevel filters the messages logged to the client, see SetLogLevel.
+	logLevel LogLevel
+
+	// cacheStyle is the cache style of Init, which Reload uses again.
+	cacheStyle CacheStyle
+
+	// watching is set once the files of the project are watched, so that
+	// a reload does not watch them twice.
+	watching bool
+
+	// status is the state of the project after its last build, statusMu
+	// guards it, see Status.
+	statusMu sync.Mutex
+	status   ProjectStatus
+
+	// reloadMu is held for writing by Reload while the project is reset and
+	// initialized again, and for reading by the users of the project, see
+	// RLock, and by the watcher of its files.
+	reloadMu sync.RWMutex
+
+	// ready is closed once Init has set up the global cache, see Ready.
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
-// NewProject new project
-func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags []string) *Project {
+// NewProject new project, env holds the environment variables, eg. GOOS and
+// GOARCH, which are added to the process environment when loading packages.
+func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, buildFlags []string, env []string, parallelism int) *Project {
 	cfg := &packages.Config{
 		Context: ctx,
 		Dir:     rootPath,
@@ -92,21 +170,52 @@ func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, bu
 		BuildFlags: buildFlags,
 	}
 	view := NewView(cfg)
+	view.setEnv(env)
+	if parallelism < 1 {
+		parallelism = 1
+	}
 
 	p := &Project{
-		conn:    conn,
-		view:    view,
-		rootDir: util.LowerDriver(rootPath),
+		conn:        conn,
+		view:        view,
+		rootDir:     util.LowerDriver(rootPath),
+		parallelism: parallelism,
+		maxDepth:    defaultMaxDepth,
+		loadSem:     make(chan struct{}, parallelism),
+		load:        packages.Load,
+		ready:       make(chan struct{}),
 	}
 
 	p.vendorDir = filepath.Join(p.rootDir, vendor)
 	return p
 }
 
+// SetWalkOptions sets the names of the directories skipped, in addition to
+// defaultExcludeDir, and the maximum depth of the directories walked when
+// the go.mod files of the project are searched. A maxDepth <= 0 keeps
+// defaultMaxDepth.
+func (p *Project) SetWalkOptions(excludeDirs []string, maxDepth int) {
+	p.excludeDirs = excludeDirs
+	if maxDepth > 0 {
+		p.maxDepth = maxDepth
+	}
+}
+
 func (p *Project) View() source.View {
 	return p.getView()
 }
 
+// ReadFile returns the content of the file, see View.ReadFile.
+func (p *Project) ReadFile(filename string) []byte {
+	return p.view.ReadFile(span.FileURI(filename))
+}
+
+// Examples returns the examples of the test files of the directory dir, see
+// View.Examples.
+func (p *Project) Examples(ctx context.Context, dir string) (*token.FileSet, []*doc.Example) {
+	return p.getView().Examples(ctx, dir)
+}
+
 func (p *Project) getView() *View {
 	return p.view
 }
@@ -117,44 +226,218 @@ func (p *Project) notify(err error) {
 	}
 }
 
-// Init init project
-func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
+// Init init project, maxPackages limits the number of packages retained in
+// the global cache, 0 means no limit.
+func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle, maxPackages int) error {
+	defer p.setReady()
 	p.context = ctx
+	p.maxPackages = maxPackages
+	p.cacheStyle = globalCacheStyle
 	start := time.Now()
 	defer func() {
+		// The token of the initialize request ends with it.
+		p.progressToken = nil
+		if p.lazy {
+			p.notifyInfo(fmt.Sprintf("init %s successfully! cache style: %s, the packages are loaded by the first request.",
+				p.rootDir, globalCacheStyle))
+			return
+		}
 		elapsedTime := time.Since(start) / time.Second
-		p.notifyInfo(fmt.Sprintf("load %s successfully! elapsed time: %d seconds, cache: %t, go module: %t.",
-			p.rootDir, elapsedTime, p.cached, len(p.modules) > 0))
+		p.notifyInfo(fmt.Sprintf("load %s successfully! elapsed time: %d seconds, cache style: %s, cache: %t, go module: %t.",
+			p.rootDir, elapsedTime, globalCacheStyle, p.cached, len(p.modules) > 0))
 	}()
 
 	if globalCacheStyle == None {
 		return nil
 	}
 
-	p.newCache = NewCache()
-	p.getView().gcache = p.newCache
-	err := p.createBuiltin()
-	if err != nil {
-		p.notify(err)
+	p.newCache = p.newGlobalCache()
+	v := p.getView()
+	v.mu.Lock()
+	v.gcache = p.newCache
+	v.mu.Unlock()
+	if err := p.createBuiltin(); err != nil {
+		p.logError(fmt.Sprintf("cannot load the builtin package, the hover, definition and signature help of the builtin identifiers are degraded: %s", err))
+	}
+	p.recordStatus()
+
+	if globalCacheStyle == Lazy {
+		// The modules or the GOPATH workspace are found, and their files
+		// watched, but no package is loaded.
+		p.lazy = true
+		p.notify(p.createProject(nil, false))
+		p.setReady()
+		p.fsnotify()
+		return nil
 	}
 
 	if globalCacheStyle != Always {
 		return nil
 	}
 
-	err = p.createProject()
+	p.build()
+	return nil
+}
+
+// Ready returns a channel closed once Init has set up the global cache and
+// found the modules or the GOPATH workspace of the project, before their
+// packages are loaded, or once Init returns otherwise. The packages of the
+// requests received meanwhile are type-checked on demand.
+func (p *Project) Ready() <-chan struct{} {
+	return p.ready
+}
+
+func (p *Project) setReady() {
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+// Reload loads the project again as Init did, after the environment
+// variables env of the go commands, eg. GO111MODULE=on, replace the ones set
+// before: the module or GOPATH mode is evaluated again, the modules or the
+// GOPATH workspace are found again, and the global cache and the packages of
+// the open files are rebuilt. It waits until the readers of the project
+// release it, see RLock, and holds them back until it is done.
+func (p *Project) Reload(env []string) error {
+	if err := CheckReloadEnv(env); err != nil {
+		return err
+	}
+
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	v := p.getView()
+	v.mu.Lock()
+	v.setEnv(env)
+	v.mu.Unlock()
+	v.reset()
+
+	p.modules = nil
+	p.gopath = nil
+	p.cached = false
+	p.lazy = false
+	p.buildMu.Lock()
+	p.built = false
+	p.buildMu.Unlock()
+	p.changedCount = 0
+	return p.Init(p.context, p.cacheStyle, p.maxPackages)
+}
+
+// CheckReloadEnv returns an error if env sets a variable which Reload cannot
+// change: GOPATH and GOROOT are only read when the server starts.
+func CheckReloadEnv(env []string) error {
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if name == gopathEnv || name == gorootEnv {
+			return fmt.Errorf("%s cannot be changed by a reload, restart the server instead", name)
+		}
+	}
+	return nil
+}
+
+// RLock holds the project for reading until RUnlock: a Reload waits until no
+// reader holds it, and the readers wait until a Reload in progress is done.
+// It must not be called again by a reader which already holds the project.
+func (p *Project) RLock() {
+	p.reloadMu.RLock()
+}
+
+// RUnlock releases the project held by RLock.
+func (p *Project) RUnlock() {
+	p.reloadMu.RUnlock()
+}
+
+// build loads the packages of the project into the global cache and watches
+// its files.
+func (p *Project) build() {
+	progress := p.beginProgress("Loading packages")
+	err := p.createProject(progress, true)
 	p.notify(err)
 	p.lastBuildTime = time.Now()
+	p.recordStatus()
+	count, peak := p.getCache().Counts()
+	p.notifyDebug(fmt.Sprintf("%d packages cached, peak %d", count, peak))
+	if err != nil {
+		progress.end(err.Error())
+	} else {
+		progress.end("Packages loaded")
+	}
 
 	p.fsnotify()
+}
+
+// buildLazily loads all the packages of a lazy project unless they are
+// already, the callers wait for the end of the load. The load stops when ctx
+// is done, and is done again by the next caller.
+func (p *Project) buildLazily(ctx context.Context) error {
+	if !p.lazy {
+		return nil
+	}
+
+	p.buildMu.Lock()
+	defer p.buildMu.Unlock()
+	if p.built {
+		return nil
+	}
+
+	start := time.Now()
+	for _, m := range p.modules {
+		if err := m.buildCache(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.notify(err)
+		}
+	}
+	if p.gopath != nil {
+		if err := p.gopath.buildCache(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.notify(err)
+		}
+	}
+	p.built = true
+	p.lastBuildTime = time.Now()
+	p.recordStatus()
+
+	elapsedTime := time.Since(start) / time.Second
+	p.notifyInfo(fmt.Sprintf("load %s successfully! elapsed time: %d seconds, cache style: %s, cache: %t, go module: %t.",
+		p.rootDir, elapsedTime, Lazy, p.cached, len(p.modules) > 0))
+	return nil
+}
+
+// isBuilt reports whether all the packages of the project are loaded, which
+// a lazy project does on demand.
+func (p *Project) isBuilt() bool {
+	if !p.lazy {
+		return true
+	}
+	p.buildMu.Lock()
+	defer p.buildMu.Unlock()
+	return p.built
+}
+
+// loadLazily loads the package of filename into the global cache if the
+// project is lazy and it is not loaded yet. The load stops when ctx is done.
+func (p *Project) loadLazily(ctx context.Context, filename string) error {
+	if !p.lazy || !p.isInsideProject(filename) || p.getCache().GetByURI(filename) != nil {
+		return nil
+	}
+	if err := p.reload(ctx, "file="+filename); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	return nil
 }
 
 func (p *Project) fsnotify() {
-	if !p.cached {
+	if !p.cached || p.watchedByClient || p.watching {
 		return
 	}
 
+	p.watching = true
 	subject := newSubject(p)
 	go subject.notify()
 }
@@ -164,12 +447,14 @@ func (p *Project) Contain(fileURI lsp.DocumentURI) bool {
 	return strings.HasPrefix(filePath, p.rootDir)
 }
 
+// getImportPath returns the import path of the root directory of the project
+// in the first GOPATH entry containing it, or "" if it is out of GOPATH.
 func (p *Project) getImportPath() string {
+	rootDir := filepath.ToSlash(p.rootDir)
 	for _, path := range gopaths {
-		path = util.LowerDriver(filepath.ToSlash(path))
-		srcDir := filepath.Join(path, "src")
-		if strings.HasPrefix(p.rootDir, srcDir) && p.rootDir != srcDir {
-			return filepath.ToSlash(p.rootDir[len(srcDir)+1:])
+		srcDir := path + "/src/"
+		if strings.HasPrefix(rootDir, srcDir) {
+			return strings.TrimSuffix(rootDir[len(srcDir):], "/")
 		}
 	}
 
@@ -177,7 +462,37 @@ func (p *Project) getImportPath() string {
 }
 
 func (p *Project) isUnderGoroot() bool {
-	return strings.HasPrefix(p.rootDir, goroot)
+	return goroot != "" && strings.HasPrefix(p.rootDir, goroot)
+}
+
+// inGoroot reports whether filename is under the src directory of GOROOT.
+func inGoroot(filename string) bool {
+	return goroot != "" && strings.HasPrefix(util.LowerDriver(filepath.ToSlash(filename)), goroot+"/")
+}
+
+// IsStdlib reports whether pkg is a standard library package loaded from
+// GOROOT as a dependency of the project. No package is if the project itself
+// is under GOROOT.
+func (p *Project) IsStdlib(pkg source.Package) bool {
+	if p.isUnderGoroot() {
+		return false
+	}
+
+	for _, filename := range pkg.GetFilenames() {
+		if inGoroot(filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExternal reports whether filename is in GOROOT or in the module cache. No
+// file under GOROOT is if the project itself is under GOROOT.
+func (p *Project) IsExternal(filename string) bool {
+	if !p.isUnderGoroot() && inGoroot(filename) {
+		return true
+	}
+	return isFileInsideGomod(filename)
 }
 
 var siteLenMap = map[string]int{
@@ -186,26 +501,31 @@ var siteLenMap = map[string]int{
 	"gopkg.in":   2,
 }
 
-func (p *Project) createProject() error {
-	value := os.Getenv(go111module)
+// createProject finds the modules or the GOPATH workspace of the project, and
+// loads their packages if load is set. Each module or the GOPATH workspace is
+// a step of progress.
+func (p *Project) createProject(progress *workDoneProgress, load bool) error {
+	value := p.view.getenv(go111module)
 
 	if value == "on" {
 		p.notifyLog("GO111MODULE=on, module mode")
-		gomodList := p.findGoModFiles()
-		return p.createGoModule(gomodList)
+		gomodList := p.findGoModules()
+		return p.createGoModule(gomodList, progress, load)
 	}
 
 	if p.isUnderGoroot() {
 		p.notifyLog(fmt.Sprintf("%s under go root dir %s", p.rootDir, goroot))
-		return p.createGoPath("", true)
+		progress.setTotal(1)
+		defer progress.step(p.rootDir)
+		return p.createGoPath("", true, load)
 	}
 
 	importPath := p.getImportPath()
 	p.notifyLog(fmt.Sprintf("GOPATH: %v, import path: %s", gopaths, importPath))
 	if (value == "" || value == "auto") && importPath == "" {
 		p.notifyLog("GO111MODULE=auto, module mode")
-		gomodList := p.findGoModFiles()
-		return p.createGoModule(gomodList)
+		gomodList := p.findGoModules()
+		return p.createGoModule(gomodList, progress, load)
 	}
 
 	if importPath == "" {
@@ -220,7 +540,9 @@ func (p *Project) createProject() error {
 	}
 
 	p.notifyLog("GOPATH mode")
-	return p.createGoPath(importPath, false)
+	progress.setTotal(1)
+	defer progress.step(importPath)
+	return p.createGoPath(importPath, false, load)
 }
 
 // BuiltinPkg builtin package
@@ -231,14 +553,54 @@ func (p *Project) GetBuiltinPackage() source.Package {
 	return p.GetFromPkgPath(BuiltinPkg)
 }
 
-func (p *Project) createGoModule(gomodList []string) error {
-	for _, v := range gomodList {
-		module := newModule(p, util.LowerDriver(filepath.Dir(v)))
-		err := module.init()
+// GetStdlibPackage get standard library package by import path, it will be
+// loaded from GOROOT on demand if it is not in the global cache yet.
+func (p *Project) GetStdlibPackage(pkgPath string) source.Package {
+	if pkg := p.GetFromPkgPath(pkgPath); pkg != nil {
+		return pkg
+	}
+
+	if !isStdlibPackage(pkgPath) {
+		return nil
+	}
+
+	if err := p.createGoroot(pkgPath); err != nil {
 		p.notify(err)
-		p.modules = append(p.modules, module)
+		return nil
+	}
+
+	return p.GetFromPkgPath(pkgPath)
+}
+
+func isStdlibPackage(pkgPath string) bool {
+	if goroot == "" || pkgPath == "" || strings.Contains(strings.Split(pkgPath, "/")[0], ".") {
+		return false
 	}
 
+	fi, err := os.Stat(filepath.Join(goroot, pkgPath))
+	return err == nil && fi.IsDir()
+}
+
+// createGoModule initializes the modules of gomodList with up to parallelism
+// goroutines, their packages are then loaded if load is set, once the project
+// is ready, see Ready. The error of a module is reported without aborting the
+// others. Each module initialized, or loaded if load is set, is a step of
+// progress.
+func (p *Project) createGoModule(gomodList []string, progress *workDoneProgress, load bool) error {
+	progress.setTotal(len(gomodList))
+	modules := make([]*module, len(gomodList))
+	initialized := make([]bool, len(gomodList))
+	p.parallel(len(gomodList), func(i int) error {
+		modules[i] = newModule(p, util.LowerDriver(filepath.Dir(gomodList[i])))
+		err := modules[i].doInit()
+		initialized[i] = err == nil
+		if !load || err != nil {
+			progress.step(modules[i].rootDir)
+		}
+		return err
+	})
+	p.modules = append(p.modules, modules...)
+
 	if len(p.modules) == 0 {
 		return nil
 	}
@@ -248,28 +610,174 @@ func (p *Project) createGoModule(gomodList []string) error {
 		return p.modules[i].rootDir >= p.modules[j].rootDir
 	})
 
+	if !load {
+		return nil
+	}
+	// The modules are found, the requests are served on demand while their
+	// packages are loaded.
+	p.setReady()
+	p.parallel(len(modules), func(i int) error {
+		if !initialized[i] {
+			return nil
+		}
+		defer progress.step(modules[i].rootDir)
+		return modules[i].buildCache(context.Background())
+	})
 	return nil
 }
 
-func (p *Project) createGoPath(importPath string, underGoroot bool) error {
+// parallel calls f for each index below n with up to parallelism goroutines,
+// the errors are reported once all the calls are done.
+func (p *Project) parallel(n int, f func(i int) error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	parallelism := p.parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	indexes := make(chan int)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := f(i); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		p.notify(err)
+	}
+}
+
+func (p *Project) createGoPath(importPath string, underGoroot bool, load bool) error {
 	gopath := newGopath(p, p.rootDir, importPath, underGoroot)
-	err := gopath.init()
+	err := gopath.doInit()
+	p.gopath = gopath
 	p.cached = err == nil
-	return err
+	if err != nil || !load {
+		return err
+	}
+	// As for the modules, the requests are served on demand while the
+	// packages are loaded.
+	p.setReady()
+	return gopath.buildCache(context.Background())
 }
 
+// createBuiltin loads the builtin package of GOROOT, which declares the
+// builtin identifiers, eg. append or error.
 func (p *Project) createBuiltin() error {
-	value := os.Getenv(go111module)
+	if goroot == "" {
+		return fmt.Errorf("GOROOT not found by go env GOROOT")
+	}
+	dir := filepath.Join(goroot, BuiltinPkg)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("builtin package not found in GOROOT %s: %s", filepath.Dir(goroot), err)
+	}
+	if err := p.createGoroot(BuiltinPkg); err != nil {
+		return err
+	}
+	if p.GetBuiltinPackage() == nil {
+		return fmt.Errorf("builtin package not loaded from %s", dir)
+	}
+	return nil
+}
 
-	if value == "on" {
-		_ = os.Setenv(go111module, "auto")
-		defer func() {
-			_ = os.Setenv(go111module, value)
-		}()
+func (p *Project) createGoroot(pkgPath string) error {
+	stdlib := newGopath(p, filepath.ToSlash(filepath.Join(goroot, pkgPath)), "", true)
+	if p.view.getenv(go111module) == "on" {
+		// Only the go command loading the GOROOT packages runs out of
+		// module mode, the concurrent loads keep the environment.
+		stdlib.env = []string{go111module + "=auto"}
+	}
+	return stdlib.init()
+}
+
+// findGoModules returns the go.mod files of the modules of the project: the
+// modules used by the go.work workspace of the project if there is one, or
+// all the go.mod files under the root.
+func (p *Project) findGoModules() []string {
+	goworkPath := p.view.getenv(goworkEnv)
+	switch goworkPath {
+	case "off":
+		return p.findGoModFiles()
+	case "":
+		goworkPath = filepath.Join(p.rootDir, gowork)
 	}
 
-	bulitin := newGopath(p, filepath.ToSlash(filepath.Join(goroot, BuiltinPkg)), "", true)
-	return bulitin.init()
+	data, err := ioutil.ReadFile(goworkPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.notify(err)
+		}
+		return p.findGoModFiles()
+	}
+
+	p.notifyLog(fmt.Sprintf("go workspace %s", goworkPath))
+	var gomodList []string
+	for _, dir := range parseGoWorkUses(data) {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(goworkPath), dir)
+		}
+		fullpath := filepath.Join(dir, gomod)
+		if _, err := os.Stat(fullpath); err != nil {
+			p.notify(err)
+			continue
+		}
+		gomodList = append(gomodList, fullpath)
+		p.notifyLog(fullpath)
+	}
+	return gomodList
+}
+
+// parseGoWorkUses returns the module directories of the use directives of a
+// go.work file, either single or in a block.
+func parseGoWorkUses(data []byte) []string {
+	var dirs []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock:
+			dirs = append(dirs, unquoteGoWorkPath(line))
+		case strings.HasPrefix(line, "use(") || strings.HasPrefix(line, "use ") || strings.HasPrefix(line, "use\t"):
+			arg := strings.TrimSpace(line[len("use"):])
+			if arg == "(" {
+				inBlock = true
+			} else {
+				dirs = append(dirs, unquoteGoWorkPath(arg))
+			}
+		}
+	}
+	return dirs
+}
+
+func unquoteGoWorkPath(path string) string {
+	if s, err := strconv.Unquote(path); err == nil {
+		return s
+	}
+	return path
 }
 
 func (p *Project) findGoModFiles() []string {
@@ -289,18 +797,30 @@ func (p *Project) findGoModFiles() []string {
 
 var defaultExcludeDir = []string{".git", ".svn", ".hg", ".vscode", ".idea", "node_modules", vendor}
 
-func isExclude(dir string) bool {
+// defaultMaxDepth is the default maximum depth of the directories walked.
+const defaultMaxDepth = 8
+
+// isExclude reports whether the directories named dir are skipped when the
+// project is walked.
+func (p *Project) isExclude(dir string) bool {
 	for _, d := range defaultExcludeDir {
 		if d == dir {
 			return true
 		}
 	}
 
+	for _, d := range p.excludeDirs {
+		if d == dir {
+			return true
+		}
+	}
+
 	return false
 }
 
 func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, string)) error {
-	if level > 8 {
+	if level > p.maxDepth {
+		p.notifyLog(fmt.Sprintf("%s is skipped, it is deeper than %d directories", rootDir, p.maxDepth))
 		return nil
 	}
 
@@ -311,17 +831,15 @@ func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, strin
 	}
 
 	for _, fi := range files {
-		if isExclude(fi.Name()) {
+		if p.isExclude(fi.Name()) {
 			continue
 		}
 
 		if fi.IsDir() {
-			level++
-			err = p.walkDir(filepath.Join(rootDir, fi.Name()), level, walkFunc)
+			err = p.walkDir(filepath.Join(rootDir, fi.Name()), level+1, walkFunc)
 			if err != nil {
 				return err
 			}
-			level--
 		} else {
 			walkFunc(rootDir, fi.Name())
 		}
@@ -330,10 +848,130 @@ func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, strin
 	return nil
 }
 
+func (p *Project) newGlobalCache() *GlobalCache {
+	c := NewCache()
+	c.SetLimit(p.maxPackages, p.IsMainPackage)
+	return c
+}
+
+// IsMainPackage reports whether the package of the import path belongs to
+// the main modules of the project, or to a dependency replaced by a directory
+// of the project, such packages are never evicted. The builtin package is a
+// main package.
+func (p *Project) IsMainPackage(pkgPath string) bool {
+	if pkgPath == BuiltinPkg {
+		return true
+	}
+
+	for _, m := range p.modules {
+		for _, modulePath := range m.localPaths() {
+			if pkgPath == modulePath || strings.HasPrefix(pkgPath, modulePath+"/") {
+				return true
+			}
+		}
+	}
+
+	if p.gopath != nil && p.gopath.importPath != "" {
+		return pkgPath == p.gopath.importPath || strings.HasPrefix(pkgPath, p.gopath.importPath+"/")
+	}
+	return false
+}
+
+// reload loads the packages matching pattern into the global cache again
+// after they have been evicted, or for the first time in a lazy project. The
+// load stops when ctx is done.
+func (p *Project) reload(ctx context.Context, pattern string) error {
+	c := p.getCache()
+
+	// As in buildCache, the view is only locked while its config is taken.
+	p.view.mu.Lock()
+	cfg := p.view.loadConfig(packages.LoadAllSyntax)
+	cfg.Overlay = copyOverlay(cfg.Overlay)
+	p.view.mu.Unlock()
+
+	cfg.Context = ctx
+	start := time.Now()
+	pkgs, err := p.loadPackages(&cfg, pattern)
+	if err != nil {
+		p.notifyLog(fmt.Sprintf("reload %s: %s", pattern, err))
+		return err
+	}
+	p.notifyDebug(fmt.Sprintf("reload %s: %d packages loaded in %s", pattern, len(pkgs), time.Since(start)))
+
+	for _, pkg := range pkgs {
+		c.Add(pkg)
+	}
+	return nil
+}
+
+// reloadDir loads the packages of the directory dir again in place of the
+// cached ones.
+func (p *Project) reloadDir(dir string) {
+	c := p.getCache()
+
+	// As in buildCache, the view is only locked while its config is taken.
+	p.view.mu.Lock()
+	cfg := p.view.loadConfig(packages.LoadAllSyntax)
+	cfg.Overlay = copyOverlay(cfg.Overlay)
+	p.view.mu.Unlock()
+
+	// The importers of the packages of dir are loaded with them, so they are
+	// type-checked against the new packages.
+	patterns := append([]string{"."}, c.Importers(dir)...)
+
+	start := time.Now()
+	cfg.Dir = dir
+	pkgs, err := p.loadPackages(&cfg, patterns...)
+	if err != nil {
+		p.notifyLog(fmt.Sprintf("reload %s: %s", dir, err))
+		return
+	}
+	p.notifyDebug(fmt.Sprintf("reload %s: %d packages loaded in %s", dir, len(pkgs), time.Since(start)))
+
+	c.Replace(dir, pkgs)
+}
+
+// SetWatchedByClient sets whether the client watches the files of the project
+// and notifies their changes with DidChangeWatchedFile, in which case the
+// project does not watch them itself. It must be called before Init.
+func (p *Project) SetWatchedByClient(watched bool) {
+	p.watchedByClient = watched
+}
+
+// DidChangeWatchedFile updates the caches after filename has been created,
+// changed or deleted on disk: the packages of its directory are reloaded if it
+// is a Go file, the module cache is rebuilt if it is a go.mod. The files open
+// in the editor are skipped, their content is the one of the editor.
+func (p *Project) DidChangeWatchedFile(filename string) {
+	v := p.getView()
+	v.mu.Lock()
+	_, open := v.Config.Overlay[filename]
+	v.mu.Unlock()
+	if open {
+		return
+	}
+
+	switch {
+	case filepath.Base(filename) == gomod:
+		if p.cached {
+			p.rebuildModuleCache(util.LowerDriver(filename))
+		}
+	case strings.HasSuffix(filename, goext):
+		v.FileChanged(span.FileURI(filename))
+		if p.cached {
+			p.reloadDir(filepath.Dir(filename))
+		}
+	}
+}
+
 // GetFromURI get package from document uri.
 func (p *Project) GetFromURI(uri lsp.DocumentURI) source.Package {
 	filename, _ := source.FromDocumentURI(uri).Filename()
 	pkg := p.getCache().GetByURI(filename)
+	if pkg == nil && p.getCache().IsFileEvicted(filename) {
+		p.reload(context.Background(), "file="+filename)
+		pkg = p.getCache().GetByURI(filename)
+	}
 	if pkg == nil {
 		return nil
 	}
@@ -351,6 +989,10 @@ func (p *Project) getCache() *GlobalCache {
 // GetFromPkgPath get package from package import path.
 func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 	pkg := p.getCache().Get(pkgPath)
+	if pkg == nil && p.getCache().IsEvicted(pkgPath) {
+		p.reload(context.Background(), pkgPath)
+		pkg = p.getCache().Get(pkgPath)
+	}
 	if pkg == nil {
 		return nil
 	}
@@ -358,17 +1000,34 @@ func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 }
 
 func (p *Project) update(eventName string) {
+	p.reloadMu.RLock()
+	defer p.reloadMu.RUnlock()
+
+	if strings.HasSuffix(eventName, goext) {
+		v := p.getView()
+		v.mu.Lock()
+		v.forgetExamples(eventName)
+		v.mu.Unlock()
+	}
+
 	if p.needRebuild(eventName) {
 		p.notifyLog("fsnotify " + eventName)
-		p.newCache = NewCache()
-		p.newCache.Put(p.GetBuiltinPackage().(*Package))
-		p.rebuildGopapthCache(eventName)
-		p.rebuildModuleCache(eventName)
+		p.newCache = p.newGlobalCache()
+		if builtin, ok := p.GetBuiltinPackage().(*Package); ok {
+			p.newCache.Put(builtin)
+		}
+		// The packages of a lazy project which is not built yet are loaded
+		// again on demand.
+		if p.isBuilt() {
+			p.rebuildGopapthCache(eventName)
+			p.rebuildModuleCache(eventName)
+		}
 		p.lastBuildTime = time.Now()
 
 		p.view.mu.Lock()
 		p.view.gcache = p.newCache
 		p.view.mu.Unlock()
+		p.recordStatus()
 	}
 }
 
@@ -410,6 +1069,7 @@ func (p *Project) rebuildGopapthCache(eventName string) {
 	}
 
 	if strings.HasSuffix(eventName, p.gopath.rootDir) {
+		p.notifyDebug(fmt.Sprintf("rebuild gopath cache for %s changed", eventName))
 		p.gopath.rebuildCache()
 	}
 }
@@ -429,6 +1089,8 @@ func (p *Project) rebuildModuleCache(eventName string) {
 
 			if rebuild {
 				p.notifyInfo(fmt.Sprintf("rebuild module cache for %s changed", eventName))
+			} else {
+				p.notifyDebug(fmt.Sprintf("module cache of %s is up to date", m.rootDir))
 			}
 
 			return
@@ -448,9 +1110,35 @@ func (p *Project) notifyInfo(message string) {
 
 // NotifyLog notify log to lsp client
 func (p *Project) notifyLog(message string) {
+	if !p.logLevel.Enabled(LogInfo) {
+		return
+	}
 	_ = p.conn.Notify(p.context, "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
 }
 
+// logError logs message to the client as an error.
+func (p *Project) logError(message string) {
+	if !p.logLevel.Enabled(LogError) {
+		return
+	}
+	_ = p.conn.Notify(p.context, "window/logMessage", &lsp.LogMessageParams{Type: lsp.MTError, Message: message})
+}
+
+// notifyDebug logs message to the client if the log level is LogDebug or
+// above.
+func (p *Project) notifyDebug(message string) {
+	if !p.logLevel.Enabled(LogDebug) {
+		return
+	}
+	_ = p.conn.Notify(p.context, "window/logMessage", &lsp.LogMessageParams{Type: lsp.Log, Message: message})
+}
+
+// SetLogLevel sets the level of the messages logged to the client, LogInfo
+// by default.
+func (p *Project) SetLogLevel(level LogLevel) {
+	p.logLevel = level
+}
+
 func (p *Project) root() string {
 	return p.rootDir
 }
@@ -459,17 +1147,94 @@ func (p *Project) getContext() context.Context {
 	return p.context
 }
 
-// Search serach package cache
-func (p *Project) Search(walkFunc source.WalkFunc) error {
+// Search serach package cache, walkFunc is called concurrently by at most
+// parallelism goroutines. A lazy project is built first.
+func (p *Project) Search(ctx context.Context, walkFunc source.WalkFunc) error {
+	if err := p.buildLazily(ctx); err != nil {
+		return err
+	}
+
 	var ranks []string
 	for _, module := range p.modules {
-		if module.mainModulePath == "." || module.mainModulePath == "" {
+		ranks = append(ranks, module.localPaths()...)
+	}
+
+	start := time.Now()
+	err := p.getCache().WalkParallel(ctx, walkFunc, ranks, p.parallelism)
+	p.notifyDebug(fmt.Sprintf("search of the package cache done in %s", time.Since(start)))
+	return err
+}
+
+// SearchWithProgress is Search, each package walked is reported to the client
+// as a step of the task titled title.
+func (p *Project) SearchWithProgress(ctx context.Context, title string, walkFunc source.WalkFunc) error {
+	if err := p.buildLazily(ctx); err != nil {
+		return err
+	}
+
+	total := 0
+	if cache := p.getCache(); cache != nil {
+		total = len(cache.rankedPackages(nil))
+	}
+	progress := p.beginProgress(title)
+	progress.setTotal(total)
+
+	err := p.Search(ctx, func(pkg source.Package) error {
+		defer progress.step(pkg.GetPkgPath())
+		return walkFunc(pkg)
+	})
+	if err != nil {
+		progress.end(err.Error())
+	} else {
+		progress.end(title + " done")
+	}
+	return err
+}
+
+// PackageInfo describes a package of the global cache.
+type PackageInfo struct {
+	PkgPath string
+	Name    string
+	Dir     string
+
+	// IsMain is set if the package belongs to the main modules of the
+	// project, see IsMainPackage.
+	IsMain bool
+}
+
+// Packages returns the packages of the global cache sorted by import path,
+// without loading any. The test variants of a package are listed once, and
+// the generated main packages of the tests are skipped.
+func (p *Project) Packages() []PackageInfo {
+	var infos []PackageInfo
+	seen := make(map[string]bool)
+	for _, pkg := range p.getCache().rankedPackages(nil) {
+		files := pkg.allFiles()
+		if pkg.pkgPath == BuiltinPkg || strings.HasSuffix(pkg.pkgPath, ".test") || len(files) == 0 || seen[pkg.pkgPath] {
 			continue
 		}
-		ranks = append(ranks, module.mainModulePath)
+		seen[pkg.pkgPath] = true
+		// The files generated by cgo are in the build cache.
+		dir := filepath.Dir(files[len(files)-1])
+		infos = append(infos, PackageInfo{
+			PkgPath: pkg.pkgPath,
+			Name:    pkg.name,
+			Dir:     dir,
+			IsMain:  p.IsMainPackage(pkg.pkgPath),
+		})
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].PkgPath < infos[j].PkgPath })
+	return infos
+}
 
-	return p.getCache().Walk(walkFunc, ranks)
+// IsInternalPackage reports whether pkgPath is the import path of a package
+// under an internal directory, which can only be imported by the packages
+// rooted at the parent of that directory.
+func IsInternalPackage(pkgPath string) bool {
+	return pkgPath == "internal" ||
+		strings.HasPrefix(pkgPath, "internal/") ||
+		strings.HasSuffix(pkgPath, "/internal") ||
+		strings.Contains(pkgPath, "/internal/")
 }
 
 func (p *Project) setCache(pkgs []*packages.Package) {
@@ -483,17 +1248,25 @@ func (p *Project) Cache() *GlobalCache {
 }
 
 func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (source.Package, source.File, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
 	uri := span.FromDocumentURI(fileURI)
+	filename, _ := uri.Filename()
+	if err := p.loadLazily(ctx, filename); err != nil {
+		return nil, nil, err
+	}
 
 	v := p.getView()
 	v.mu.Lock()
 	f := v.files[uri]
 	v.mu.Unlock()
 
-	filename, _ := uri.Filename()
 	if f == nil || (f.pkg == nil && !p.isInsideProject(filename)) {
 		pkg := p.GetFromURI(fileURI)
 		if pkg != nil {
+			p.getCache().Hold(ctx, pkg.GetPkgPath())
 			return pkg, nil, nil
 		}
 
@@ -510,6 +1283,7 @@ func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (sourc
 		return nil, nil, fmt.Errorf("package is null for file %s", uri)
 	}
 
+	p.getCache().Hold(ctx, pkg.GetPkgPath())
 	return pkg, f, nil
 }
 
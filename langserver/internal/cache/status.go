@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"time"
+)
+
+// ProjectStatus is the state of the global cache and of the modules of a
+// project, see Project.Status.
+type ProjectStatus struct {
+	// Mode is "module" or "gopath", empty if the project is not built yet.
+	Mode string
+
+	// Modules are the root directories of the modules of the project.
+	Modules []string
+
+	CacheStyle CacheStyle
+
+	// Cached is set if the packages of the project are in the global cache.
+	Cached bool
+
+	// LastBuildTime is the time of the last build of the global cache, zero
+	// if it was never built.
+	LastBuildTime time.Time
+
+	// Packages is the number of packages in the global cache, PeakPackages
+	// its maximum since the cache was created.
+	Packages     int
+	PeakPackages int
+}
+
+// Status returns the state of the project. It is cheap and does not wait for
+// a build in progress: the state of the modules is the one of the last build.
+func (p *Project) Status() ProjectStatus {
+	p.statusMu.Lock()
+	status := p.status
+	p.statusMu.Unlock()
+	status.Modules = append([]string(nil), status.Modules...)
+	status.Packages, status.PeakPackages = p.getCache().Counts()
+	return status
+}
+
+// recordStatus records the state of the modules after a build, which Status
+// returns. It must be called by the goroutine building the project.
+func (p *Project) recordStatus() {
+	status := ProjectStatus{
+		CacheStyle:    p.cacheStyle,
+		Cached:        p.cached,
+		LastBuildTime: p.lastBuildTime,
+	}
+	switch {
+	case len(p.modules) > 0:
+		status.Mode = "module"
+		for _, m := range p.modules {
+			status.Modules = append(status.Modules, m.rootDir)
+		}
+	case p.gopath != nil:
+		status.Mode = "gopath"
+	}
+
+	p.statusMu.Lock()
+	p.status = status
+	p.statusMu.Unlock()
+}
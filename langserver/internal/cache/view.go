@@ -6,7 +6,13 @@ package cache
 
 import (
 	"context"
+	"go/build"
 	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -48,6 +54,18 @@ type View struct {
 
 	// gcache caches all package for project
 	gcache *GlobalCache
+
+	// env holds the environment variables, eg. GOOS and GOARCH, which are
+	// added to the process environment when loading packages.
+	env []string
+
+	// sizes is the sizes of the target architecture, the configured GOARCH
+	// or the one of the go command.
+	sizes types.Sizes
+
+	// examples caches the examples of the test files by directory, see
+	// Examples.
+	examples map[string]*dirExamples
 }
 
 type metadataCache struct {
@@ -58,7 +76,24 @@ type metadataCache struct {
 type metadata struct {
 	id, pkgPath, name string
 	files             []string
+	cgoFiles          []string // see Package.cgoFiles
 	parents, children map[string]bool
+
+	// sizes is the sizes of the platform the package is loaded for if it
+	// is not the configured one, eg. for foo_386.go, nil means the view's.
+	sizes types.Sizes
+
+	// errors is the errors of go list for the package, eg. when its module
+	// is not in the module cache.
+	errors []packages.Error
+}
+
+// allFiles returns the files of the package and the files processed by cgo.
+func (m *metadata) allFiles() []string {
+	if len(m.cgoFiles) == 0 {
+		return m.files
+	}
+	return append(append([]string(nil), m.files...), m.cgoFiles...)
 }
 
 type packageCache struct {
@@ -87,9 +122,116 @@ func NewView(config *packages.Config) *View {
 		pcache: &packageCache{
 			packages: make(map[string]*entry),
 		},
+		examples: make(map[string]*dirExamples),
+	}
+}
+
+// loadConfig returns a copy of the view's packages.Config for the load mode.
+// The process environment is read on each call.
+func (v *View) loadConfig(mode packages.LoadMode) packages.Config {
+	cfg := v.Config
+	cfg.Mode = mode
+	cfg.Env = v.goEnv()
+	return cfg
+}
+
+// goEnv returns the environment of the go commands run for the view, nil
+// means the process environment.
+func (v *View) goEnv() []string {
+	if len(v.env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), v.env...)
+}
+
+// setEnv sets the environment variables env of the go commands, which
+// replace the ones of the same names set before, and the sizes of the target
+// architecture. It is assumed that the caller holds the view's mutex.
+func (v *View) setEnv(env []string) {
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		var kept []string
+		for _, old := range v.env {
+			if !strings.HasPrefix(old, name+"=") {
+				kept = append(kept, old)
+			}
+		}
+		v.env = append(kept, kv)
+	}
+
+	// The sizes of the constants, eg. unsafe.Sizeof(uintptr(0)), are the
+	// ones of the target, not go/types' default.
+	goarch := v.getenv("GOARCH")
+	if goarch == "" {
+		goarch = build.Default.GOARCH
+	}
+	v.sizes = types.SizesFor("gc", goarch)
+}
+
+// reset forgets the metadata and the type information of the packages of the
+// open files, eg. after the environment of the go commands changed. The
+// content of the files is kept.
+func (v *View) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.mcache.mu.Lock()
+	v.mcache.packages = make(map[string]*metadata)
+	v.mcache.mu.Unlock()
+
+	v.pcache.mu.Lock()
+	v.pcache.packages = make(map[string]*entry)
+	v.pcache.mu.Unlock()
+
+	for _, f := range v.files {
+		f.meta = nil
+		f.pkg = nil
 	}
 }
 
+// getenv returns the value of the environment variable of the go commands,
+// the last definition wins as with exec.Cmd.
+func (v *View) getenv(key string) string {
+	env := v.goEnv()
+	if env == nil {
+		env = os.Environ()
+	}
+	var value string
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = strings.TrimPrefix(kv, key+"=")
+		}
+	}
+	return value
+}
+
+// goflags returns the flags of the GOFLAGS environment variable of the go
+// commands.
+func (v *View) goflags() []string {
+	return strings.Fields(v.getenv("GOFLAGS"))
+}
+
+// vendorMode reports whether the go commands run in the module rooted at dir
+// load the dependencies from its vendor directory: GOFLAGS or the build
+// flags set -mod=vendor, or no -mod flag is set and the module has a
+// vendor/modules.txt, which the go command uses by default since Go 1.14.
+func (v *View) vendorMode(dir string) bool {
+	var mode string
+	for _, flag := range append(v.goflags(), v.Config.BuildFlags...) {
+		if strings.HasPrefix(flag, "-mod=") {
+			mode = strings.TrimPrefix(flag, "-mod=")
+		}
+	}
+	if mode != "" {
+		return mode == "vendor"
+	}
+	_, err := os.Stat(filepath.Join(dir, vendor, "modules.txt"))
+	return err == nil
+}
+
 func (v *View) BackgroundContext() context.Context {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -118,6 +260,46 @@ func (v *View) SetContent(ctx context.Context, uri span.URI, content []byte) err
 	return nil
 }
 
+// FileChanged invalidates the file changed on disk, created or deleted, and
+// the packages of its directory, unless it is open in the editor: the content
+// and the metadata of its files are read again. As with SetContent, the change
+// is applied before the next type check.
+func (v *View) FileChanged(uri span.URI) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// A pending change of the editor supersedes the content on disk.
+	if _, ok := v.contentChanges[uri]; ok {
+		return
+	}
+	filename, err := uri.Filename()
+	if err != nil {
+		return
+	}
+
+	v.contentChanges[uri] = func() {
+		v.forgetExamples(filename)
+		dir := filepath.Dir(filename)
+		seen := make(map[string]bool)
+		for fileURI, f := range v.files {
+			name, err := fileURI.Filename()
+			if err != nil || filepath.Dir(name) != dir {
+				continue
+			}
+			if fileURI == uri && !f.active {
+				f.content = nil
+				f.ast = nil
+				f.token = nil
+			}
+			// The files of the package may have changed too.
+			f.meta = nil
+			if f.pkg != nil {
+				v.remove(f.pkg.pkgPath, seen)
+			}
+		}
+	}
+}
+
 // applyContentChanges applies all of the changed content stored in the view.
 // It is assumed that the caller has locked both the view's and the mcache's
 // mutexes.
@@ -142,6 +324,9 @@ func (v *View) applyContentChanges(ctx context.Context) error {
 func (v *View) applyContentChange(uri span.URI, content []byte) {
 	f := v.getFile(uri)
 	f.content = content
+	if filename, err := uri.Filename(); err == nil {
+		v.forgetExamples(filename)
+	}
 
 	// TODO(rstambler): Should we recompute these here?
 	f.ast = nil
@@ -187,7 +372,7 @@ func (v *View) remove(pkgPath string, seen map[string]bool) {
 	}
 	// All of the files in the package may also be holding a pointer to the
 	// invalidated package.
-	for _, filename := range m.files {
+	for _, filename := range m.allFiles() {
 		if f, ok := v.files[span.FileURI(filename)]; ok {
 			f.pkg = nil
 		}
@@ -208,6 +393,31 @@ func (v *View) GetFile(ctx context.Context, uri span.URI) (source.File, error) {
 	return v.getFile(uri), nil
 }
 
+// ReadFile returns the content of the file, the overlay if it is open, without
+// adding the file to the managed set as GetFile does. It returns nil if the
+// file cannot be read.
+func (v *View) ReadFile(uri span.URI) []byte {
+	v.mu.Lock()
+	var content []byte
+	if f, ok := v.files[uri]; ok {
+		content = f.content
+	}
+	v.mu.Unlock()
+	if content != nil {
+		return content
+	}
+
+	filename, err := uri.Filename()
+	if err != nil {
+		return nil
+	}
+	content, err = ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	return content
+}
+
 // getFile is the unlocked internal implementation of GetFile.
 func (v *View) getFile(uri span.URI) *File {
 	f, found := v.files[uri]
@@ -0,0 +1,82 @@
+// Package ctxcheck defines an Analyzer that reports the misuses of
+// context.Context which go against https://golang.org/pkg/context:
+// a context which is not the first parameter of a function, and a context
+// stored in a struct type.
+package ctxcheck
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the conventions of context.Context
+
+The context.Context should be the first parameter of a function, and it
+should not be stored in a struct type but passed to each function that needs
+it, see https://golang.org/pkg/context.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "ctxcheck",
+	Doc:  Doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				checkParams(pass, n.Type.Params)
+			case *ast.FuncLit:
+				checkParams(pass, n.Type.Params)
+			case *ast.StructType:
+				checkFields(pass, n.Fields)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// checkParams reports the context parameters which are not the first one.
+func checkParams(pass *analysis.Pass, params *ast.FieldList) {
+	if params == nil {
+		return
+	}
+
+	i := 0
+	for _, field := range params.List {
+		if i > 0 && isContext(pass.TypesInfo.TypeOf(field.Type)) {
+			pass.Reportf(field.Pos(), "context.Context should be the first parameter of a function")
+		}
+		if len(field.Names) == 0 {
+			i++
+		} else {
+			i += len(field.Names)
+		}
+	}
+}
+
+// checkFields reports the fields of a struct type which store a context.
+func checkFields(pass *analysis.Pass, fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+
+	for _, field := range fields.List {
+		if isContext(pass.TypesInfo.TypeOf(field.Type)) {
+			pass.Reportf(field.Pos(), "do not store a context.Context in a struct type, pass it to the functions that need it instead")
+		}
+	}
+}
+
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
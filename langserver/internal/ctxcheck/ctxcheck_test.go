@@ -0,0 +1,67 @@
+package ctxcheck
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const src = `package p
+
+import "context"
+
+type S struct {
+	ctx context.Context
+	n   int
+}
+
+func ok(ctx context.Context, n int) {}
+
+func bad(n int, ctx context.Context) {}
+
+func (S) method(a, b int, ctx context.Context) {}
+
+var _ = func(ctx context.Context) {}
+
+var _ = func(n int, ctx context.Context) {}
+`
+
+func TestAnalyzer(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     []*ast.File{f},
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(d analysis.Diagnostic) {
+			got = append(got, fset.Position(d.Pos).Line)
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{6, 12, 14, 18}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics on lines %v, want %v", got, want)
+	}
+}
@@ -237,6 +237,9 @@ func DefInfo(pkg *types.Package, info *types.Info, nodes []ast.Node, pos token.P
 	} else if selX == nil {
 		if pkg.Scope().Lookup(identX.Name) == obj {
 			return objectString(obj), nil
+		} else if obj.Pkg() != nil && obj.Pkg() != pkg && obj.Pkg().Scope().Lookup(identX.Name) == obj {
+			// Dot-imported top-level def of another package.
+			return objectString(obj), nil
 		} else if types.Universe.Lookup(identX.Name) == obj {
 			return &Def{ImportPath: "builtin", PackageName: "builtin", Path: obj.Name()}, nil
 		}
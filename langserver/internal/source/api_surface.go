@@ -0,0 +1,83 @@
+package source
+
+import (
+	"go/types"
+	"sort"
+)
+
+// APISurface returns the exported declarations of pkg, one per line and
+// sorted, so that the results of two versions of a package can be diffed to
+// detect breaking changes. Types of other packages are qualified by their
+// full import path.
+func APISurface(pkg Package) []string {
+	tpkg := pkg.GetTypes()
+	info := pkg.GetTypesInfo()
+	if tpkg == nil || info == nil {
+		return nil
+	}
+
+	qf := func(p *types.Package) string {
+		if p == tpkg {
+			return ""
+		}
+		return p.Path()
+	}
+
+	var lines []string
+	for _, obj := range info.Defs {
+		if obj == nil || !obj.Exported() || obj.Parent() != tpkg.Scope() {
+			continue
+		}
+
+		switch obj := obj.(type) {
+		case *types.Const:
+			lines = append(lines, types.ObjectString(obj, qf)+" = "+obj.Val().ExactString())
+		case *types.TypeName:
+			lines = append(lines, typeSurface(obj, qf)...)
+		default:
+			lines = append(lines, types.ObjectString(obj, qf))
+		}
+	}
+
+	sort.Strings(lines)
+	return lines
+}
+
+// typeSurface returns the exported declarations of the type name obj: the
+// type itself, its exported fields or interface methods, and its exported
+// methods.
+func typeSurface(obj *types.TypeName, qf types.Qualifier) []string {
+	if obj.IsAlias() {
+		return []string{types.ObjectString(obj, qf)}
+	}
+
+	var lines []string
+	switch u := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		lines = append(lines, "type "+obj.Name()+" struct")
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Exported() {
+				lines = append(lines, "field "+obj.Name()+"."+f.Name()+" "+types.TypeString(f.Type(), qf))
+			}
+		}
+	case *types.Interface:
+		lines = append(lines, "type "+obj.Name()+" interface")
+		for i := 0; i < u.NumMethods(); i++ {
+			if m := u.Method(i); m.Exported() {
+				lines = append(lines, types.ObjectString(m, qf))
+			}
+		}
+		return lines
+	default:
+		lines = append(lines, types.ObjectString(obj, qf))
+	}
+
+	if named, ok := obj.Type().(*types.Named); ok {
+		for i := 0; i < named.NumMethods(); i++ {
+			if m := named.Method(i); m.Exported() {
+				lines = append(lines, types.ObjectString(m, qf))
+			}
+		}
+	}
+	return lines
+}
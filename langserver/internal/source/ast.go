@@ -117,6 +117,26 @@ func FetchIdentFromPathNodes(fset *token.FileSet, nodes []ast.Node) (*ast.Ident,
 	}
 }
 
+// TypeExprIdent returns the identifier naming the type of the type
+// expression expr, skipping pointers and parentheses. It returns nil if expr
+// does not name a type, eg. a type literal.
+func TypeExprIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			return e.Sel
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
 func NewInvalidNodeError(fset *token.FileSet, node ast.Node) *InvalidNodeError {
 	lineCol := func(p token.Pos) string {
 		pp := fset.Position(p)
@@ -155,9 +175,41 @@ func GetSyntaxFile(pkg Package, filename string) *ast.File {
 		}
 	}
 
+	// A file processed by cgo is only found by the line directives of the
+	// file generated from it.
+	for _, f := range pkg.GetSyntax() {
+		if util.PathEqual(pkg.GetFileSet().Position(f.Pos()).Filename, filename) {
+			return f
+		}
+	}
+
 	return nil
 }
 
+// GeneratedPos returns the position of file, generated by cgo, which its
+// line directives map to line and column, both 1-based, of the file it is
+// generated from. It returns the position in the node starting the nearest
+// before the column on the line, or token.NoPos if there is none. The
+// position is exact in an identifier, as cgo only rewrites the references to
+// C.
+func GeneratedPos(fset *token.FileSet, file *ast.File, line, column int) token.Pos {
+	pos, start := token.NoPos, 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		p := fset.Position(n.Pos())
+		if p.Line == line && p.Column <= column && p.Column > start {
+			pos, start = n.Pos()+token.Pos(column-p.Column), p.Column
+		}
+		return true
+	})
+	if tok := fset.File(file.Pos()); pos.IsValid() && int(pos) > tok.Base()+tok.Size() {
+		return token.NoPos
+	}
+	return pos
+}
+
 func FindIdentObject(pkg Package, ident *ast.Ident) types.Object {
 	return pkg.GetTypesInfo().ObjectOf(ident)
 }
@@ -177,16 +229,30 @@ func CallExpr(fset *token.FileSet, nodes []ast.Node) *ast.CallExpr {
 	return nil
 }
 
-// FindObject find object
+// FindObject looks up the object o in the package scope of pkg, it also
+// resolves the methods of named types. It finds the declaration of a universe
+// object in the builtin package, eg. the Error method of the error interface,
+// and the object of a package which has been type checked more than once.
 func FindObject(pkg Package, o types.Object) types.Object {
-	for _, def := range pkg.GetTypesInfo().Defs {
-		if def == nil {
-			continue
-		}
-		if def.Name() == o.Name() {
-			return def
+	if pkg == nil || pkg.GetTypes() == nil {
+		return nil
+	}
+
+	scope := pkg.GetTypes().Scope()
+	if f, ok := o.(*types.Func); ok {
+		if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+			named, ok := Deref(recv.Type()).(*types.Named)
+			if !ok {
+				return nil
+			}
+			tn, ok := scope.Lookup(named.Obj().Name()).(*types.TypeName)
+			if !ok {
+				return nil
+			}
+			obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg.GetTypes(), f.Name())
+			return obj
 		}
 	}
 
-	return nil
+	return scope.Lookup(o.Name())
 }
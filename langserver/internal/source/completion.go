@@ -3,11 +3,13 @@ package source
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 	"log"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -18,7 +20,24 @@ type CompletionItem struct {
 	Label, Detail string
 	Kind          CompletionItemKind
 	Score         float64
-	Documentation string
+
+	// Object is the object completed, if any. Its declaration and its
+	// documentation are not part of the item, they are resolved later.
+	Object types.Object
+
+	// ImportPath is the path of the package the item is found in when the
+	// file may not import it, eg. the members of a package found by its name
+	// in the cache. See AddImport.
+	ImportPath string
+
+	// InsertText is the text inserted if it is not the label, eg. "Name: "
+	// for the key of a struct literal.
+	InsertText string
+
+	// Qualifier is the name of the package prefixed to the text inserted,
+	// eg. strings for Title completed as strings.Title, when the item is
+	// found by its unqualified name in a package the file does not import.
+	Qualifier string
 }
 
 type CompletionItemKind int
@@ -48,8 +67,10 @@ type finder func(types.Object, float64, []CompletionItem) []CompletionItem
 // a file and a position. The prefix is computed based on the preceding
 // identifier and can be used by the client to score the quality of the
 // completion. For instance, some clients may tolerate imperfect matches as
-// valid completion results, since users may make typos.
-func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items []CompletionItem, prefix string, err error) {
+// valid completion results, since users may make typos. Unless detailed is
+// set, the detail of the items of the objects declared in a package is not
+// computed, it is left to be resolved later.
+func Completion(ctx context.Context, f File, pos token.Pos, cache Cache, detailed bool) (items []CompletionItem, prefix string, err error) {
 	file := f.GetAST(ctx)
 	pkg := f.GetPackage(ctx)
 	if pkg.IsIllTyped() {
@@ -93,9 +114,12 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 	// Save certain facts about the query position, including the expected type
 	// of the completion result, the signature of the function enclosing the
 	// position.
-	typ := expectedType(path, pos, pkg.GetTypesInfo())
+	typ := expectedType(path, pos, f.GetToken(ctx), pkg.GetTypesInfo())
 	sig := enclosingFunction(path, pos, pkg.GetTypesInfo())
 	pkgStringer := qualifier(file, pkg.GetTypes(), pkg.GetTypesInfo())
+	// The packages of the cache are only walked to find the ones the file
+	// does not import.
+	cache = importableCache{Cache: cache, importable: importable(file, pkg.GetTypes(), pkg.GetTypesInfo())}
 
 	seen := make(map[types.Object]bool)
 
@@ -112,15 +136,8 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 			}
 			item := formatCompletion(obj, pkgStringer, weight, func(v *types.Var) bool {
 				return isParameter(sig, v)
-			})
-
-			// TODO(mbana): figure out how to get `golang.org/x/tools/go/packages.Packages` from `go/types.Package`.
-			// pkg, ok := obj.Pkg().(pkg.GetTypes())
-			ok := true
-			if ok {
-				// FindComments(obj.Pkg(), obj, "")
-			}
-
+			}, detailed)
+			item.Object = obj
 			items = append(items, item)
 		}
 		return items
@@ -195,10 +212,12 @@ func selector(sel *ast.SelectorExpr, pos token.Pos, info *types.Info, found find
 		if !ok {
 			f := func(p Package) error {
 				if p.GetName() == id.Name {
+					n := len(items)
 					scope := p.GetTypes().Scope()
 					for _, name := range scope.Names() {
 						items = found(scope.Lookup(name), stdScore, items)
 					}
+					setImportPath(items[n:], p.GetPkgPath())
 				}
 
 				return nil
@@ -237,6 +256,80 @@ func selector(sel *ast.SelectorExpr, pos token.Pos, info *types.Info, found find
 	return items, nil
 }
 
+// setImportPath sets the import path of items, which are found in the
+// package importPath.
+func setImportPath(items []CompletionItem, importPath string) {
+	for i := range items {
+		items[i].ImportPath = importPath
+	}
+}
+
+// importableCache walks the packages of a cache which the file completed can
+// import, once per import path, see importable.
+type importableCache struct {
+	Cache
+	importable func(Package) bool
+}
+
+func (c importableCache) Walk(walkFunc WalkFunc, ranks []string) error {
+	seen := make(map[string]bool)
+	return c.Cache.Walk(func(p Package) error {
+		if seen[p.GetPkgPath()] || !c.importable(p) {
+			return nil
+		}
+		seen[p.GetPkgPath()] = true
+		return walkFunc(p)
+	}, ranks)
+}
+
+// importable returns a function reporting whether the file f of the package
+// pkg can import a package without conflict: the package is not pkg, nor the
+// builtin package, nor a main package, nor a test package or the test variant
+// of a package, nor an internal package of another tree, and f does not
+// import another package of the same name.
+func importable(f *ast.File, pkg *types.Package, info *types.Info) func(Package) bool {
+	names := make(map[string]string)
+	for imported, name := range importNames(f, info) {
+		names[name] = imported.Path()
+	}
+	return func(p Package) bool {
+		path := p.GetPkgPath()
+		if path == pkg.Path() || path == "builtin" || p.GetName() == "main" || strings.HasSuffix(path, "_test") {
+			return false
+		}
+		for _, filename := range p.GetFilenames() {
+			if strings.HasSuffix(filename, "_test.go") {
+				return false
+			}
+		}
+		if !canImportInternal(pkg.Path(), path) {
+			return false
+		}
+		imported, ok := names[p.GetName()]
+		return !ok || imported == path
+	}
+}
+
+// canImportInternal reports whether the package importer may import the
+// package path as far as the internal directories are concerned: a package
+// under an internal directory can only be imported by the packages rooted at
+// the parent of that directory, the standard library ones if it is at the
+// root.
+func canImportInternal(importer, path string) bool {
+	var parent string
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		parent = strings.TrimSuffix(path, "/internal")
+	case strings.Contains(path, "/internal/"):
+		parent = path[:strings.LastIndex(path, "/internal/")]
+	case path == "internal" || strings.HasPrefix(path, "internal/"):
+		return !strings.Contains(strings.Split(importer, "/")[0], ".")
+	default:
+		return true
+	}
+	return importer == parent || strings.HasPrefix(importer, parent+"/")
+}
+
 func getPrefix(cursorIdent string) string {
 	if cursorIdent != "" && cursorIdent[len(cursorIdent) -1] == '.' {
 		return ""
@@ -327,17 +420,12 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 	visit1 := func(prefix string) {
 		f := func(p Package) error {
 			if p.GetName() == prefix && p.GetPkgPath() != pkg.Path() {
+				n := len(items)
 				scope := p.GetTypes().Scope()
 				for _, name := range scope.Names() {
-					obj := scope.Lookup(name)
-					items = found(obj, score, items)
-
-					itemIndex := len(items) - 1
-					comments, err := FindComments(p, p.GetFileSet(), obj, prefix)
-					if err == nil && len(items) > 1 {
-						items[itemIndex].Documentation = comments
-					}
+					items = found(scope.Lookup(name), score, items)
 				}
+				setImportPath(items[n:], p.GetPkgPath())
 			}
 			return nil
 		}
@@ -352,10 +440,11 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 			}
 
 			item := CompletionItem{
-				Label:  p.GetName(),
-				Detail: p.GetPkgPath(),
-				Kind:   PackageCompletionItem,
-				Score:  score,
+				Label:      p.GetName(),
+				Detail:     p.GetPkgPath(),
+				Kind:       PackageCompletionItem,
+				Score:      score,
+				ImportPath: p.GetPkgPath(),
 			}
 			items = append(items, item)
 			return nil
@@ -364,22 +453,71 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 		cache.Walk(f, []string{})
 	}
 
+	// visit3 finds the exported members of the packages the file does not
+	// import whose name starts with prefix, they are qualified by the name
+	// of their package, eg. strings.Title for Tit.
+	visit3 := func(prefix string) {
+		if !ast.IsExported(prefix) {
+			return
+		}
+		imported := make(map[string]bool)
+		if file, ok := path[len(path)-1].(*ast.File); ok {
+			for p := range importNames(file, info) {
+				imported[p.Path()] = true
+			}
+		}
+		count := 0
+		f := func(p Package) error {
+			if imported[p.GetPkgPath()] || p.GetTypes() == nil {
+				return nil
+			}
+			scope := p.GetTypes().Scope()
+			names := scope.Names()
+			for i := sort.SearchStrings(names, prefix); i < len(names) && strings.HasPrefix(names[i], prefix); i++ {
+				if count == maxUnimportedCandidates {
+					return errUnimportedLimit
+				}
+				n := len(items)
+				items = found(scope.Lookup(names[i]), stdScore*0.5, items)
+				for j := n; j < len(items); j++ {
+					items[j].ImportPath = p.GetPkgPath()
+					items[j].Qualifier = p.GetName()
+					count++
+				}
+			}
+			return nil
+		}
+
+		cache.Walk(f, []string{})
+	}
+
 	if cursorIdent != "" {
 		l := len(cursorIdent)
 		if cursorIdent[l-1] == '.' {
 			visit1(cursorIdent[:l-1])
 		} else {
 			visit2(cursorIdent)
+			visit3(cursorIdent)
 		}
 		return items
 	}
 
 	if id, ok := path[0].(*ast.Ident); ok {
 		visit2(id.Name)
+		visit3(id.Name)
 	}
 	return items
 }
 
+// maxUnimportedCandidates bounds the number of members of the packages the
+// file does not import completed by their unqualified name, a short prefix
+// matches too many of them.
+const maxUnimportedCandidates = 100
+
+// errUnimportedLimit stops the walk of the cache once
+// maxUnimportedCandidates are found.
+var errUnimportedLimit = errors.New("too many unimported candidates")
+
 // inComment checks if given token position is inside ast.Comment node.
 func inComment(pos token.Pos, commentGroups []*ast.CommentGroup) bool {
 	for _, g := range commentGroups {
@@ -396,6 +534,9 @@ func inComment(pos token.Pos, commentGroups []*ast.CommentGroup) bool {
 // It reports whether the node was handled as part of a composite literal.
 func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Info, found finder, pkgIdent string, cache Cache) (items []CompletionItem, prefix string, ok bool) {
 	var lit *ast.CompositeLit
+	// The fields are inserted with a colon, unless they complete the key of
+	// a key-value expression.
+	withColon := true
 	prefix = pkgIdent
 	// First, determine if the pos is within a composite literal.
 	switch n := path[0].(type) {
@@ -418,8 +559,11 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 		lit = n
 		// If the position belongs to a key-value expression and is after the colon,
 		// don't show composite literal completions.
-		if kv, ok := expr.(*ast.KeyValueExpr); ok && pos > kv.Colon {
-			lit = nil
+		if kv, ok := expr.(*ast.KeyValueExpr); ok {
+			withColon = false
+			if pos > kv.Colon {
+				lit = nil
+			}
 		}
 	case *ast.KeyValueExpr:
 		// If the enclosing node is a key-value expression (e.g. &x{foo: <>}),
@@ -429,6 +573,7 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 				lit = l
 			}
 		}
+		withColon = false
 	case *ast.Ident:
 		prefix = n.Name[:pos-n.Pos()]
 
@@ -446,6 +591,7 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 					if l.Lbrace <= pos && pos <= l.Rbrace {
 						lit = l
 						if kv, ok := path[1].(*ast.KeyValueExpr); ok {
+							withColon = false
 							if pos > kv.Colon {
 								lit = nil
 							}
@@ -479,17 +625,22 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 		}
 	}
 	// If the underlying type of the composite literal is a struct,
-	// collect completions for the fields of this struct.
+	// collect completions for the fields of this struct. The type of a
+	// literal whose &T is elided, eg. in []*T{{}}, is a pointer.
 	if tv, ok := info.Types[lit]; ok {
 		var structPkg *types.Package // package containing the struct type declaration
-		if s, ok := tv.Type.Underlying().(*types.Struct); ok {
+		if s, ok := Deref(tv.Type).Underlying().(*types.Struct); ok {
 			for i := 0; i < s.NumFields(); i++ {
 				field := s.Field(i)
 				if i == 0 {
 					structPkg = field.Pkg()
 				}
-				if !addedFields[field] {
-					items = found(field, 10.0, items)
+				if addedFields[field] {
+					continue
+				}
+				n := len(items)
+				if items = found(field, 10.0, items); withColon && len(items) > n {
+					items[n].InsertText = field.Name() + ": "
 				}
 			}
 			// Add lexical completions if the user hasn't typed a key value expression
@@ -503,10 +654,16 @@ func complit(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 	return items, prefix, false
 }
 
-// formatCompletion creates a completion item for a given types.Object.
-func formatCompletion(obj types.Object, qualifier types.Qualifier, score float64, isParam func(*types.Var) bool) CompletionItem {
+// formatCompletion creates a completion item for a given types.Object. The
+// detail is only computed if detailed is set or if obj has no declaration,
+// eg. a builtin.
+func formatCompletion(obj types.Object, qualifier types.Qualifier, score float64, isParam func(*types.Var) bool, detailed bool) CompletionItem {
 	label := obj.Name()
-	detail := types.TypeString(obj.Type(), qualifier)
+	detailed = detailed || obj.Pkg() == nil || !obj.Pos().IsValid()
+	var detail string
+	if detailed {
+		detail = types.TypeString(obj.Type(), qualifier)
+	}
 	var kind CompletionItemKind
 
 	switch o := obj.(type) {
@@ -539,7 +696,9 @@ func formatCompletion(obj types.Object, qualifier types.Qualifier, score float64
 	case *types.Func:
 		if sig, ok := o.Type().(*types.Signature); ok {
 			label += formatParams(sig.Params(), sig.Variadic(), qualifier)
-			detail = strings.Trim(types.TypeString(sig.Results(), qualifier), "()")
+			if detailed {
+				detail = strings.Trim(types.TypeString(sig.Results(), qualifier), "()")
+			}
 			kind = FunctionCompletionItem
 			if sig.Recv() != nil {
 				kind = MethodCompletionItem
@@ -560,6 +719,9 @@ func formatCompletion(obj types.Object, qualifier types.Qualifier, score float64
 		detail = ""
 	}
 	detail = strings.TrimPrefix(detail, "untyped ")
+	if !detailed && kind != PackageCompletionItem {
+		detail = ""
+	}
 
 	return CompletionItem{
 		Label:  label,
@@ -623,7 +785,22 @@ func isParameter(sig *types.Signature, v *types.Var) bool {
 // qualifier returns a function that appropriately formats a types.PkgName
 // appearing in a *ast.File.
 func qualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifier {
-	// Construct mapping of import paths to their defined or implicit names.
+	imports := importNames(f, info)
+	// Define qualifier to replace full package paths with names of the imports.
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		if name, ok := imports[p]; ok {
+			return name
+		}
+		return p.Name()
+	}
+}
+
+// importNames maps the packages imported by f to their defined or implicit
+// names in f.
+func importNames(f *ast.File, info *types.Info) map[*types.Package]string {
 	imports := make(map[*types.Package]string)
 	for _, imp := range f.Imports {
 		var obj types.Object
@@ -632,20 +809,12 @@ func qualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifie
 		} else {
 			obj = info.Implicits[imp]
 		}
-		if pkgname, ok := obj.(*types.PkgName); ok {
+		// The blank imports do not name their package.
+		if pkgname, ok := obj.(*types.PkgName); ok && pkgname.Name() != "_" {
 			imports[pkgname.Imported()] = pkgname.Name()
 		}
 	}
-	// Define qualifier to replace full package paths with names of the imports.
-	return func(p *types.Package) string {
-		if p == pkg {
-			return ""
-		}
-		if name, ok := imports[p]; ok {
-			return name
-		}
-		return p.Name()
-	}
+	return imports
 }
 
 // enclosingFunction returns the signature of the function enclosing the given
@@ -666,13 +835,40 @@ func enclosingFunction(path []ast.Node, pos token.Pos, info *types.Info) *types.
 	return nil
 }
 
-// expectedType returns the expected type for an expression at the query position.
-func expectedType(path []ast.Node, pos token.Pos, info *types.Info) types.Type {
+// expectedType returns the expected type for an expression at the query
+// position: the type of the other operand of a binary expression, of the
+// variable assigned, of the parameter of a call or of the result returned.
+// tok is the file of the position.
+func expectedType(path []ast.Node, pos token.Pos, tok *token.File, info *types.Info) types.Type {
+	depth := 2
+	if len(path) > 1 {
+		// The selector completed, eg. return strings.‸, is the expression.
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+			depth++
+		}
+	}
 	for i, node := range path {
-		if i == 2 {
+		if i == depth {
 			break
 		}
 		switch expr := node.(type) {
+		case *ast.BlockStmt:
+			// Nothing is typed yet after the return, eg. return ‸.
+			if i == 0 {
+				if ret := returnBefore(expr, pos, tok); ret != nil {
+					return resultType(path, pos, ret, info)
+				}
+			}
+		case *ast.ReturnStmt:
+			return resultType(path, pos, expr, info)
+		case *ast.ValueSpec:
+			// Only rank completions if you are on the right side of the =.
+			if expr.Type == nil || len(expr.Values) == 0 || pos < expr.Values[0].Pos() {
+				break
+			}
+			if tv, ok := info.Types[expr.Type]; ok {
+				return tv.Type
+			}
 		case *ast.BinaryExpr:
 			// Determine if query position comes from left or right of op.
 			e := expr.X
@@ -713,8 +909,42 @@ func expectedType(path []ast.Node, pos token.Pos, info *types.Info) types.Type {
 	return nil
 }
 
+// resultType returns the type of the result of the function enclosing ret
+// at the query position, nil if the function has no results.
+func resultType(path []ast.Node, pos token.Pos, ret *ast.ReturnStmt, info *types.Info) types.Type {
+	sig := enclosingFunction(path, pos, info)
+	if sig == nil || sig.Results().Len() == 0 {
+		return nil
+	}
+	i := exprAtPos(pos, ret.Results)
+	if i >= sig.Results().Len() {
+		i = sig.Results().Len() - 1
+	}
+	return sig.Results().At(i).Type()
+}
+
+// returnBefore returns the return statement without results of block which
+// ends before pos on the same line, nil if there is none.
+func returnBefore(block *ast.BlockStmt, pos token.Pos, tok *token.File) *ast.ReturnStmt {
+	if tok == nil {
+		return nil
+	}
+	for _, stmt := range block.List {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) > 0 || ret.End() > pos {
+			continue
+		}
+		if tok.Line(ret.End()) == tok.Line(pos) {
+			return ret
+		}
+	}
+	return nil
+}
+
 // matchingTypes reports whether actual is a good candidate type
-// for a completion in a context of the expected type.
+// for a completion in a context of the expected type: it is identical or
+// assignable to the expected type, eg. a concrete type implementing the
+// expected interface.
 func matchingTypes(expected, actual types.Type) bool {
 	// Use a function's return type as its type.
 	if sig, ok := actual.(*types.Signature); ok {
@@ -722,7 +952,19 @@ func matchingTypes(expected, actual types.Type) bool {
 			actual = sig.Results().At(0).Type()
 		}
 	}
-	return types.Identical(types.Default(expected), types.Default(actual))
+	if types.Identical(types.Default(expected), types.Default(actual)) {
+		return true
+	}
+	// An invalid type, eg. of a package name, is assignable to anything.
+	if !isValid(expected) || !isValid(actual) {
+		return false
+	}
+	return types.AssignableTo(actual, expected)
+}
+
+// isValid reports whether typ is a valid type.
+func isValid(typ types.Type) bool {
+	return typ != nil && typ != types.Typ[types.Invalid]
 }
 
 // exprAtPos returns the index of the expression containing pos.
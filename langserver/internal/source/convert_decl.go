@@ -0,0 +1,248 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ConvertVarConst returns the edit which converts the var declaration
+// enclosing the given range into a const declaration, or vice versa. The
+// returned token is the keyword of the converted declaration. No edit is
+// returned if the conversion is not legal.
+func ConvertVarConst(ctx context.Context, f File, rng span.Range) ([]TextEdit, token.Token, error) {
+	fAST := f.GetAST(ctx)
+	pkg := f.GetPackage(ctx)
+	if fAST == nil || pkg == nil || pkg.IsIllTyped() {
+		return nil, token.ILLEGAL, fmt.Errorf("package for %s is ill typed", f.URI())
+	}
+
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	var decl *ast.GenDecl
+	for _, node := range path {
+		if d, ok := node.(*ast.GenDecl); ok {
+			decl = d
+			break
+		}
+	}
+	if decl == nil {
+		return nil, token.ILLEGAL, nil
+	}
+
+	var newTok token.Token
+	switch decl.Tok {
+	case token.VAR:
+		if !canConvertToConst(pkg, decl) {
+			return nil, token.ILLEGAL, nil
+		}
+		newTok = token.CONST
+	case token.CONST:
+		if !canConvertToVar(pkg, decl) {
+			return nil, token.ILLEGAL, nil
+		}
+		newTok = token.VAR
+	default:
+		return nil, token.ILLEGAL, nil
+	}
+
+	end := decl.TokPos + token.Pos(len(decl.Tok.String()))
+	s, err := span.NewRange(f.GetFileSet(ctx), decl.TokPos, end).Span()
+	if err != nil {
+		return nil, token.ILLEGAL, err
+	}
+	return []TextEdit{{Span: s, NewText: newTok.String()}}, newTok, nil
+}
+
+// canConvertToConst reports whether every variable of decl has a basic type,
+// is initialized by a constant expression and is never modified or addressed,
+// even implicitly by a method with a pointer receiver. The exported package
+// variables are never converted since their uses in the importing packages
+// are not checked.
+func canConvertToConst(pkg Package, decl *ast.GenDecl) bool {
+	info := pkg.GetTypesInfo()
+	objs := make(map[types.Object]bool)
+	for _, spec := range decl.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok || len(vs.Values) != len(vs.Names) {
+			return false
+		}
+		for _, value := range vs.Values {
+			if tv, ok := info.Types[value]; !ok || tv.Value == nil {
+				return false
+			}
+		}
+		for _, name := range vs.Names {
+			obj := info.Defs[name]
+			if obj == nil {
+				continue
+			}
+			if !isConstType(obj.Type()) || isExportedGlobal(pkg, obj) {
+				return false
+			}
+			objs[obj] = true
+		}
+	}
+
+	isVar := func(expr ast.Expr) bool {
+		id, ok := astutil.Unparen(expr).(*ast.Ident)
+		return ok && objs[info.Uses[id]]
+	}
+
+	legal := true
+	for _, file := range pkg.GetSyntax() {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					if isVar(lhs) {
+						legal = false
+					}
+				}
+			case *ast.RangeStmt:
+				if n.Tok == token.ASSIGN && (isVar(n.Key) || isVar(n.Value)) {
+					legal = false
+				}
+			case *ast.IncDecStmt:
+				if isVar(n.X) {
+					legal = false
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.AND && isVar(n.X) {
+					legal = false
+				}
+			case *ast.SelectorExpr:
+				// x.M() is (&x).M() if M has a pointer receiver.
+				if sel := info.Selections[n]; sel != nil && sel.Kind() == types.MethodVal && isVar(n.X) {
+					if recv := sel.Obj().(*types.Func).Type().(*types.Signature).Recv(); recv != nil {
+						if _, ok := recv.Type().(*types.Pointer); ok {
+							legal = false
+						}
+					}
+				}
+			}
+			return legal
+		})
+	}
+	return legal
+}
+
+// canConvertToVar reports whether every constant of decl has an explicit
+// value without iota, is never used where a constant is required, and would
+// be used as a variable of the same type: an untyped constant becomes a
+// variable of its default type, eg. float64 for 1.0, which breaks its uses as
+// another type. A local constant must be used, which a variable must be. The
+// exported package constants are never converted since their uses in the
+// importing packages are not checked.
+func canConvertToVar(pkg Package, decl *ast.GenDecl) bool {
+	info := pkg.GetTypesInfo()
+	objs := make(map[types.Object]bool)
+	for _, spec := range decl.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok || len(vs.Values) == 0 {
+			return false
+		}
+		for _, value := range vs.Values {
+			usesIota := false
+			ast.Inspect(value, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && info.Uses[id] == types.Universe.Lookup("iota") {
+					usesIota = true
+				}
+				return !usesIota
+			})
+			if usesIota {
+				return false
+			}
+		}
+		for _, name := range vs.Names {
+			obj := info.Defs[name]
+			if obj == nil {
+				continue
+			}
+			if isExportedGlobal(pkg, obj) {
+				return false
+			}
+			objs[obj] = true
+		}
+	}
+
+	usesConst := func(n ast.Node) bool {
+		found := false
+		ast.Inspect(n, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && objs[info.Uses[id]] {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+
+	used := make(map[types.Object]bool)
+	legal := true
+	for _, file := range pkg.GetSyntax() {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				obj := info.Uses[n]
+				if !objs[obj] {
+					break
+				}
+				used[obj] = true
+				if tv, ok := info.Types[n]; ok && !types.Identical(tv.Type, types.Default(obj.Type())) {
+					legal = false
+				}
+			case *ast.GenDecl:
+				if n != decl && n.Tok == token.CONST && usesConst(n) {
+					legal = false
+				}
+			case *ast.ArrayType:
+				if n.Len != nil && usesConst(n.Len) {
+					legal = false
+				}
+			case *ast.CompositeLit:
+				// The indexes of the array and slice literals are
+				// constants.
+				tv, ok := info.Types[n]
+				if !ok {
+					break
+				}
+				switch tv.Type.Underlying().(type) {
+				case *types.Array, *types.Slice:
+					for _, elt := range n.Elts {
+						if kv, ok := elt.(*ast.KeyValueExpr); ok && usesConst(kv.Key) {
+							legal = false
+						}
+					}
+				}
+			}
+			return legal
+		})
+	}
+	if !legal {
+		return false
+	}
+
+	for obj := range objs {
+		if obj.Parent() != pkg.GetTypes().Scope() && !used[obj] {
+			return false
+		}
+	}
+	return true
+}
+
+// isConstType reports whether t is the type of a constant: a boolean, numeric
+// or string type.
+func isConstType(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsConstType != 0
+}
+
+// isExportedGlobal reports whether obj is an exported package level object,
+// which the other packages can use.
+func isExportedGlobal(pkg Package, obj types.Object) bool {
+	return obj.Exported() && obj.Parent() == pkg.GetTypes().Scope()
+}
@@ -0,0 +1,145 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ConvertReceiver returns the edit which converts the value receiver of the
+// method whose signature encloses the given range into a pointer receiver, or
+// vice versa, eg. (t T) into (t *T). The returned boolean reports whether the
+// receiver becomes a pointer. No edit is returned if the range is not in the
+// signature of a method.
+//
+// A pointer receiver requires addressable operands, so the converted method
+// can break some of its uses in the package, eg. T{}.M() or m[k].M(), which
+// are returned as broken. The uses in the other packages and the interfaces
+// no longer satisfied by T are not checked.
+func ConvertReceiver(ctx context.Context, f File, rng span.Range) ([]TextEdit, bool, []span.Span, error) {
+	fAST := f.GetAST(ctx)
+	pkg := f.GetPackage(ctx)
+	if fAST == nil || pkg == nil || pkg.IsIllTyped() {
+		return nil, false, nil, fmt.Errorf("package for %s is ill typed", f.URI())
+	}
+
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	var decl *ast.FuncDecl
+	for _, node := range path {
+		if d, ok := node.(*ast.FuncDecl); ok {
+			decl = d
+			break
+		}
+	}
+	if decl == nil || decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return nil, false, nil, nil
+	}
+	if decl.Body != nil && rng.Start >= decl.Body.Lbrace {
+		return nil, false, nil, nil
+	}
+
+	fset := f.GetFileSet(ctx)
+	typ := decl.Recv.List[0].Type
+	var edit span.Range
+	var newText string
+	toPointer := false
+	if star, ok := typ.(*ast.StarExpr); ok {
+		edit = span.NewRange(fset, star.Star, star.Star+1)
+	} else {
+		edit = span.NewRange(fset, typ.Pos(), typ.Pos())
+		newText = "*"
+		toPointer = true
+	}
+	s, err := edit.Span()
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	var broken []span.Span
+	if method, ok := pkg.GetTypesInfo().Defs[decl.Name].(*types.Func); ok && toPointer {
+		for _, pos := range brokenReceivers(pkg, method) {
+			if s, err := span.NewRange(fset, pos, pos).Span(); err == nil {
+				broken = append(broken, s)
+			}
+		}
+	}
+	return []TextEdit{{Span: s, NewText: newText}}, toPointer, broken, nil
+}
+
+// brokenReceivers returns the positions of the uses of the method in pkg
+// which are not legal anymore once its receiver is a pointer: the method
+// values and calls of non-addressable operands, and the method expressions,
+// eg. T.M instead of (*T).M.
+func brokenReceivers(pkg Package, method *types.Func) []token.Pos {
+	info := pkg.GetTypesInfo()
+	var broken []token.Pos
+	for _, file := range pkg.GetSyntax() {
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			selection := info.Selections[sel]
+			if selection == nil || selection.Obj() != method {
+				return true
+			}
+			switch selection.Kind() {
+			case types.MethodVal:
+				if !selection.Indirect() && !isPointer(selection.Recv()) && !addressable(info, sel.X) {
+					broken = append(broken, sel.Pos())
+				}
+			case types.MethodExpr:
+				if !isPointer(selection.Recv()) {
+					broken = append(broken, sel.Pos())
+				}
+			}
+			return true
+		})
+	}
+	return broken
+}
+
+// addressable reports whether the operand expr is addressable: a variable,
+// a pointer indirection, a slice index, or a field or array index of an
+// addressable operand.
+func addressable(info *types.Info, expr ast.Expr) bool {
+	switch e := astutil.Unparen(expr).(type) {
+	case *ast.Ident:
+		_, ok := info.ObjectOf(e).(*types.Var)
+		return ok
+	case *ast.StarExpr:
+		return true
+	case *ast.IndexExpr:
+		tv, ok := info.Types[e.X]
+		if !ok {
+			return false
+		}
+		switch t := tv.Type.Underlying().(type) {
+		case *types.Slice:
+			return true
+		case *types.Pointer:
+			_, ok := t.Elem().Underlying().(*types.Array)
+			return ok
+		case *types.Array:
+			return addressable(info, e.X)
+		}
+		return false
+	case *ast.SelectorExpr:
+		selection := info.Selections[e]
+		if selection == nil {
+			// A qualified identifier, eg. pkg.V.
+			_, ok := info.Uses[e.Sel].(*types.Var)
+			return ok
+		}
+		if selection.Kind() != types.FieldVal {
+			return false
+		}
+		return selection.Indirect() || isPointer(info.TypeOf(e.X)) || addressable(info, e.X)
+	}
+	return false
+}
@@ -8,7 +8,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"go/ast"
 	"log"
+	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/span"
 	"golang.org/x/tools/go/analysis"
@@ -51,7 +53,17 @@ const (
 	SeverityError
 )
 
-func Diagnostics(ctx context.Context, v View, uri span.URI) (map[span.URI][]Diagnostic, error) {
+// DefaultNoAnalysisDirectives are the directives which suppress the analysis
+// diagnostics of a file when one of them starts a comment above its package
+// clause, eg. in generated code.
+var DefaultNoAnalysisDirectives = []string{"//lint:file-ignore", "//bingo:noanalysis"}
+
+// Diagnostics returns the parse and type errors of the package of uri, or the
+// analysis diagnostics if there are none. The analysis diagnostics are not
+// reported for the files which have one of the noAnalysis directives, see
+// DefaultNoAnalysisDirectives. The extra analyzers, eg. ctxcheck.Analyzer,
+// are run along with the vet suite.
+func Diagnostics(ctx context.Context, v View, uri span.URI, noAnalysis []string, extra ...*analysis.Analyzer) (map[span.URI][]Diagnostic, error) {
 	f, err := v.GetFile(ctx, uri)
 	if err != nil {
 		return nil, err
@@ -117,8 +129,18 @@ func Diagnostics(ctx context.Context, v View, uri span.URI) (map[span.URI][]Diag
 	if len(diags) > 0 {
 		return reports, nil
 	}
-	// Type checking and parsing succeeded. Run analyses.
-	runAnalyses(ctx, v, pkg, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+	// Type checking and parsing succeeded. Run analyses, unless every file
+	// of the package opted out of them.
+	ignored := make(map[span.URI]bool)
+	for i, file := range pkg.GetSyntax() {
+		if i < len(pkg.GetFilenames()) && hasFileDirective(file, noAnalysis) {
+			ignored[span.FileURI(pkg.GetFilenames()[i])] = true
+		}
+	}
+	if len(ignored) == len(pkg.GetSyntax()) {
+		return reports, nil
+	}
+	runAnalyses(ctx, v, pkg, extra, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
 		r := span.NewRange(v.FileSet(), diag.Pos, 0)
 		s, err := r.Span()
 		if err != nil {
@@ -126,6 +148,9 @@ func Diagnostics(ctx context.Context, v View, uri span.URI) (map[span.URI][]Diag
 			//we don't have anywhere to put this error though
 			log.Print(err)
 		}
+		if ignored[s.URI()] {
+			return
+		}
 		category := a.Name
 		if diag.Category != "" {
 			category += "." + category
@@ -142,7 +167,25 @@ func Diagnostics(ctx context.Context, v View, uri span.URI) (map[span.URI][]Diag
 	return reports, nil
 }
 
-func runAnalyses(ctx context.Context, v View, pkg Package, report func(a *analysis.Analyzer, diag analysis.Diagnostic)) error {
+// hasFileDirective reports whether one of the comments above the package
+// clause of file starts with one of directives.
+func hasFileDirective(file *ast.File, directives []string) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, c := range group.List {
+			for _, d := range directives {
+				if d != "" && strings.HasPrefix(c.Text, d) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func runAnalyses(ctx context.Context, v View, pkg Package, extra []*analysis.Analyzer, report func(a *analysis.Analyzer, diag analysis.Diagnostic)) error {
 	// the traditional vet suite:
 	analyzers := []*analysis.Analyzer{
 		asmdecl.Analyzer,
@@ -168,6 +211,7 @@ func runAnalyses(ctx context.Context, v View, pkg Package, report func(a *analys
 		unsafeptr.Analyzer,
 		unusedresult.Analyzer,
 	}
+	analyzers = append(analyzers, extra...)
 
 	roots := analyze(ctx, v, []Package{pkg}, analyzers)
 
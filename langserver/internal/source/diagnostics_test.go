@@ -0,0 +1,29 @@
+package source
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestHasFileDirective(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"package p", false},
+		{"//bingo:noanalysis\n\npackage p", true},
+		{"// Code generated by foo. DO NOT EDIT.\n//lint:file-ignore U1000 generated\npackage p", true},
+		{"// bingo:noanalysis\npackage p", false},
+		{"package p\n\n//bingo:noanalysis\nvar x int", false},
+	}
+	for _, test := range tests {
+		file, err := parser.ParseFile(token.NewFileSet(), "a.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasFileDirective(file, DefaultNoAnalysisDirectives); got != test.want {
+			t.Errorf("hasFileDirective(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// FillStruct returns the edits which insert the missing fields of the struct
+// composite literal enclosing the given range, each with a zero value. The
+// unexported fields of the other packages, and the fields whose zero value
+// names a package the file does not import, are left out.
+func FillStruct(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
+	fAST := f.GetAST(ctx)
+	pkg := f.GetPackage(ctx)
+	if fAST == nil || pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("package for %s is ill typed", f.URI())
+	}
+
+	path, _ := astutil.PathEnclosingInterval(fAST, rng.Start, rng.End)
+	var lit *ast.CompositeLit
+	for _, node := range path {
+		if c, ok := node.(*ast.CompositeLit); ok {
+			lit = c
+			break
+		}
+	}
+	if lit == nil {
+		return nil, nil
+	}
+
+	typ := pkg.GetTypesInfo().TypeOf(lit)
+	if typ == nil {
+		return nil, nil
+	}
+	st, ok := Deref(typ).Underlying().(*types.Struct)
+	if !ok {
+		return nil, nil
+	}
+
+	present := make(map[string]bool)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			// Positional fields can not be mixed with keyed fields.
+			return nil, nil
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok {
+			present[key.Name] = true
+		}
+	}
+
+	// The types of the zero values are qualified by the names of the
+	// imports of the file, the fields whose zero value needs a package the
+	// file does not import are not filled.
+	imports := importNames(fAST, pkg.GetTypesInfo())
+	unimported := false
+	qf := func(p *types.Package) string {
+		if p == pkg.GetTypes() {
+			return ""
+		}
+		name, ok := imports[p]
+		if !ok {
+			unimported = true
+			return p.Name()
+		}
+		if name == "." {
+			return ""
+		}
+		return name
+	}
+
+	fset := f.GetFileSet(ctx)
+	indent := lineIndent(f.GetContent(ctx), fset.Position(lit.Pos()).Offset)
+
+	var b bytes.Buffer
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if present[field.Name()] {
+			continue
+		}
+		if !field.Exported() && field.Pkg() != pkg.GetTypes() {
+			// Unexported fields of other packages can not be set.
+			continue
+		}
+		unimported = false
+		zero := ZeroValue(field.Type(), qf)
+		if unimported {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\t%s: %s,\n", indent, field.Name(), zero)
+	}
+	if b.Len() == 0 {
+		return nil, nil
+	}
+
+	pos := lit.Rbrace
+	var text string
+	switch {
+	case len(lit.Elts) == 0:
+		text = "\n" + b.String() + indent
+	case fset.Position(lit.Elts[len(lit.Elts)-1].End()).Line == fset.Position(lit.Rbrace).Line:
+		text = "\n" + b.String() + indent
+		last := fset.Position(lit.Elts[len(lit.Elts)-1].End()).Offset
+		if !hasTrailingComma(f.GetContent(ctx), last, fset.Position(lit.Rbrace).Offset) {
+			text = "," + text
+		}
+	default:
+		// The closing brace is on its own line, insert before its indentation.
+		rbrace := fset.Position(lit.Rbrace).Offset
+		content := f.GetContent(ctx)
+		if rbrace > len(content) {
+			return nil, fmt.Errorf("invalid offset %d for %s", rbrace, f.URI())
+		}
+		pos = fset.File(lit.Rbrace).Pos(bytes.LastIndexByte(content[:rbrace], '\n') + 1)
+		text = b.String()
+	}
+
+	s, err := span.NewRange(fset, pos, pos).Span()
+	if err != nil {
+		return nil, err
+	}
+	return []TextEdit{{Span: s, NewText: text}}, nil
+}
+
+// ZeroValue returns the source text of the zero value of type t.
+func ZeroValue(t types.Type, qf types.Qualifier) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		default:
+			return "nil"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(t, qf) + "{}"
+	default:
+		return "nil"
+	}
+}
+
+// lineIndent returns the leading white space of the line containing offset.
+func lineIndent(content []byte, offset int) string {
+	if offset > len(content) {
+		return ""
+	}
+	start := bytes.LastIndexByte(content[:offset], '\n') + 1
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[start:end])
+}
+
+func hasTrailingComma(content []byte, start, end int) bool {
+	if start < 0 || end > len(content) || start > end {
+		return false
+	}
+	return bytes.ContainsRune(content[start:end], ',')
+}
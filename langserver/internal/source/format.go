@@ -11,6 +11,11 @@ import (
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/saibing/bingo/langserver/internal/diff"
@@ -55,15 +60,254 @@ func Format(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
 	return computeTextEdits(ctx, f, buf.String()), nil
 }
 
-// Imports formats a file using the goimports tool.
-func Imports(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
-	formatted, err := imports.Process(f.GetToken(ctx).Name(), f.GetContent(ctx), nil)
+// Imports formats a file using the goimports tool. If localPrefix is not
+// empty, the imports are then grouped into standard library, third-party and
+// local blocks, see groupImports.
+func Imports(ctx context.Context, f File, rng span.Range, localPrefix string) ([]TextEdit, error) {
+	filename := f.GetToken(ctx).Name()
+	formatted, err := imports.Process(filename, f.GetContent(ctx), nil)
 	if err != nil {
 		return nil, err
 	}
+	if localPrefix != "" {
+		formatted, err = groupImports(filename, formatted, localPrefix)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return computeTextEdits(ctx, f, string(formatted)), nil
 }
 
+// AddImport returns the edit which imports the package importPath in a file,
+// or nil if the file already imports it. Unlike Imports, the file is not
+// formatted, it may not even parse while a completion is typed: the import
+// spec is inserted in the sorted import block, in the group of the standard
+// library or of the other packages as goimports does, after the last import
+// declaration, or after the package clause.
+func AddImport(ctx context.Context, f File, importPath string) ([]TextEdit, error) {
+	fAST := f.GetAST(ctx)
+	if fAST == nil || fAST.Name == nil {
+		return nil, fmt.Errorf("no AST for %s", f.URI())
+	}
+	for _, imp := range fAST.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == importPath {
+			return nil, nil
+		}
+	}
+
+	spec := strconv.Quote(importPath)
+	fset := f.GetFileSet(ctx)
+	line := func(pos token.Pos) int {
+		return fset.Position(pos).Line
+	}
+
+	var decl *ast.GenDecl
+	for _, d := range fAST.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			decl = d
+		}
+	}
+
+	var at int
+	var text string
+	switch {
+	case decl == nil:
+		at = line(fAST.Name.End()) + 1
+		text = "\nimport " + spec + "\n"
+	case decl.Lparen.IsValid() && line(decl.Lparen) != line(decl.Rparen):
+		at, text = importGroupLine(decl, importPath, line), "\t"+spec+"\n"
+		if at == 0 {
+			// No group matches, the import starts a new one: the standard
+			// library first, the other packages last.
+			if isStdlibPath(importPath) && len(decl.Specs) > 0 {
+				at, text = line(decl.Specs[0].Pos()), "\t"+spec+"\n\n"
+			} else {
+				at, text = line(decl.Rparen), "\n\t"+spec+"\n"
+			}
+		}
+	default:
+		at = line(decl.End()) + 1
+		text = "import " + spec + "\n"
+	}
+
+	s := span.New(f.URI(), span.NewPoint(at, 1, 0), span.NewPoint(at, 1, 0))
+	return []TextEdit{{Span: s, NewText: text}}, nil
+}
+
+// importGroupLine returns the line where the import spec of importPath is
+// inserted in the parenthesized import declaration decl: before the first
+// spec of its group whose path sorts after importPath, or after the last spec
+// of the group. The groups are separated by blank lines, the one of
+// importPath is the group of the standard library if importPath is in it, the
+// group of the other packages otherwise. A single group is used whatever its
+// paths. It returns 0 if no group matches.
+func importGroupLine(decl *ast.GenDecl, importPath string, line func(token.Pos) int) int {
+	var groups [][]*ast.ImportSpec
+	for i, s := range decl.Specs {
+		s := s.(*ast.ImportSpec)
+		if i == 0 || line(s.Pos()) > line(decl.Specs[i-1].End())+1 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], s)
+	}
+	if len(groups) == 0 {
+		return line(decl.Rparen)
+	}
+
+	std := isStdlibPath(importPath)
+	for _, group := range groups {
+		if len(groups) > 1 && !isImportGroup(group, std) {
+			continue
+		}
+		for _, s := range group {
+			if p, err := strconv.Unquote(s.Path.Value); err == nil && p > importPath {
+				return line(s.Pos())
+			}
+		}
+		return line(group[len(group)-1].End()) + 1
+	}
+	return 0
+}
+
+// isImportGroup reports whether the paths of the import specs of group are all
+// in the standard library if std is set, or all out of it otherwise.
+func isImportGroup(group []*ast.ImportSpec, std bool) bool {
+	for _, s := range group {
+		p, err := strconv.Unquote(s.Path.Value)
+		if err != nil || isStdlibPath(p) != std {
+			return false
+		}
+	}
+	return true
+}
+
+// isStdlibPath reports whether importPath is a package of the standard
+// library, whose first element has no dot, as goimports assumes.
+func isStdlibPath(importPath string) bool {
+	return !strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".")
+}
+
+// The import groups, in the order of their blocks.
+const (
+	importGroupStdlib = iota
+	importGroupThirdParty
+	importGroupLocal
+)
+
+// groupImports rewrites the parenthesized import declaration of src into
+// three blocks separated by blank lines: the standard library, the
+// third-party and the local imports, each sorted by path. The local imports
+// are those whose path starts with one of the comma-separated prefixes of
+// localPrefix, as with goimports -local.
+//
+// src is returned unchanged if it has several import declarations or
+// comments which are not attached to an import spec, which cannot be moved
+// safely.
+func groupImports(filename string, src []byte, localPrefix string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Decls) != 1 {
+		return src, nil
+	}
+	decl, ok := file.Decls[0].(*ast.GenDecl)
+	if !ok || decl.Tok != token.IMPORT || !decl.Lparen.IsValid() || len(decl.Specs) < 2 {
+		return src, nil
+	}
+
+	tok := fset.File(file.Pos())
+	lineStart := func(pos token.Pos) int {
+		return tok.Offset(tok.LineStart(tok.Line(pos)))
+	}
+	lineEnd := func(pos token.Pos) int {
+		end := tok.Offset(pos)
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			return end + i + 1
+		}
+		return len(src)
+	}
+
+	type importText struct {
+		path string
+		text []byte
+	}
+	groups := make([][]importText, importGroupLocal+1)
+	attached := make(map[*ast.CommentGroup]bool)
+	prevEnd := lineEnd(decl.Lparen)
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ImportSpec)
+		start, end := spec.Pos(), spec.End()
+		if spec.Doc != nil {
+			start = spec.Doc.Pos()
+			attached[spec.Doc] = true
+		}
+		if spec.Comment != nil {
+			end = spec.Comment.End()
+			attached[spec.Comment] = true
+		}
+		if lineStart(start) < prevEnd {
+			// Several specs, or a spec and the parenthesis, on the same
+			// line.
+			return src, nil
+		}
+		prevEnd = lineEnd(end)
+
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		group := importGroup(path, localPrefix)
+		groups[group] = append(groups[group], importText{path, src[lineStart(start):prevEnd]})
+	}
+	if lineStart(decl.Rparen) < prevEnd {
+		return src, nil
+	}
+	for _, cg := range file.Comments {
+		if cg.Pos() > decl.Lparen && cg.End() < decl.Rparen && !attached[cg] {
+			return src, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(src[:tok.Offset(decl.Lparen)+1])
+	buf.WriteByte('\n')
+	first := true
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if !first {
+			buf.WriteByte('\n')
+		}
+		first = false
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].path < group[j].path
+		})
+		for _, imp := range group {
+			buf.Write(imp.text)
+		}
+	}
+	buf.Write(src[lineStart(decl.Rparen):])
+	return format.Source(buf.Bytes())
+}
+
+// importGroup returns the group of the import path: local if it has one of
+// the prefixes, the standard library if isStdlibPath reports so, and
+// third-party otherwise.
+func importGroup(path, localPrefix string) int {
+	for _, prefix := range strings.Split(localPrefix, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" && strings.HasPrefix(path, prefix) {
+			return importGroupLocal
+		}
+	}
+	if isStdlibPath(path) {
+		return importGroupStdlib
+	}
+	return importGroupThirdParty
+}
+
 func computeTextEdits(ctx context.Context, file File, formatted string) (edits []TextEdit) {
 	u := strings.SplitAfter(string(file.GetContent(ctx)), "\n")
 	f := strings.SplitAfter(formatted, "\n")
@@ -80,3 +324,188 @@ func computeTextEdits(ctx context.Context, file File, formatted string) (edits [
 	}
 	return edits
 }
+
+// FormatOnType formats the node completed by typing ch at pos: on "}", the
+// outermost statement or declaration ending with the closed block, literal
+// or type, eg. the whole if statement; on a newline, the statement of the
+// previous line, or the composite literal it is an element of, so that its
+// fields are aligned. The edits replace the changed lines of the node only.
+// On a newline, the line of pos, which the editor indents, is left alone.
+//
+// No edits are returned if the node cannot be formatted, eg. while the code
+// typed does not parse yet.
+func FormatOnType(ctx context.Context, f File, pos token.Pos, ch string) []TextEdit {
+	fAST, tok := f.GetAST(ctx), f.GetToken(ctx)
+	if fAST == nil || tok == nil || int(pos) <= tok.Base() || int(pos) > tok.Base()+tok.Size() {
+		return nil
+	}
+	content := f.GetContent(ctx)
+
+	var node ast.Node
+	switch ch {
+	case "}":
+		node = closedNode(fAST, pos)
+	case "\n":
+		node = completedNode(fAST, tok, content, pos)
+	}
+	if node == nil || !formattable(node) {
+		return nil
+	}
+
+	var comments []*ast.CommentGroup
+	for _, c := range fAST.Comments {
+		if c.Pos() >= node.Pos() && c.End() <= node.End() {
+			comments = append(comments, c)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, f.GetFileSet(ctx), &printer.CommentedNode{Node: node, Comments: comments}); err != nil {
+		return nil
+	}
+
+	// The node is formatted at column 1, its lines but the first are
+	// indented like the line it starts on.
+	startLine, endLine := tok.Line(node.Pos()), tok.Line(node.End())
+	lineStart := tok.Offset(tok.LineStart(startLine))
+	lineEnd := len(content)
+	if endLine < tok.LineCount() {
+		lineEnd = tok.Offset(tok.LineStart(endLine + 1))
+	}
+	i := lineStart
+	for i < len(content) && (content[i] == '\t' || content[i] == ' ') {
+		i++
+	}
+	indent := string(content[lineStart:i])
+	formatted := strings.Replace(buf.String(), "\n", "\n"+indent, -1)
+	formatted = strings.Replace(formatted, "\n"+indent+"\n", "\n\n", -1)
+
+	u := strings.SplitAfter(string(content[lineStart:lineEnd]), "\n")
+	v := strings.SplitAfter(string(content[lineStart:tok.Offset(node.Pos())])+formatted+string(content[tok.Offset(node.End()):lineEnd]), "\n")
+	skip := -1
+	if ch == "\n" {
+		skip = tok.Line(pos) - startLine
+	}
+	return onTypeEdits(f.URI(), startLine, u, v, skip)
+}
+
+// closedNode returns the outermost statement, declaration or expression
+// ending at pos with the block, composite literal or type closed by the brace
+// before pos.
+func closedNode(fAST *ast.File, pos token.Pos) ast.Node {
+	path, _ := astutil.PathEnclosingInterval(fAST, pos-1, pos)
+	i := 0
+	for i < len(path) && !(path[i].End() == pos && isBraced(path[i])) {
+		i++
+	}
+	if i == len(path) {
+		return nil
+	}
+	for i+1 < len(path) && path[i+1].End() == pos && isFormattableKind(path[i+1]) {
+		i++
+	}
+	return path[i]
+}
+
+// completedNode returns the innermost statement, declaration or composite
+// literal of the end of the line before pos, or nil if it is blank or opens a
+// block.
+func completedNode(fAST *ast.File, tok *token.File, content []byte, pos token.Pos) ast.Node {
+	line := tok.Line(pos)
+	if line <= 1 {
+		return nil
+	}
+	end := tok.Offset(tok.LineStart(line)) - 1 // the newline typed
+	start := tok.Offset(tok.LineStart(line - 1))
+	for end > start && (content[end-1] == ' ' || content[end-1] == '\t' || content[end-1] == '\r') {
+		end--
+	}
+	if end == start {
+		return nil
+	}
+
+	path, _ := astutil.PathEnclosingInterval(fAST, tok.Pos(end-1), tok.Pos(end))
+	for _, node := range path {
+		switch node.(type) {
+		case *ast.BlockStmt, *ast.File:
+			return nil
+		case *ast.CompositeLit, ast.Stmt, ast.Decl:
+			return node
+		}
+	}
+	return nil
+}
+
+func isBraced(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.BlockStmt, *ast.CompositeLit, *ast.StructType, *ast.InterfaceType:
+		return true
+	}
+	return false
+}
+
+func isFormattableKind(node ast.Node) bool {
+	switch node.(type) {
+	case ast.Stmt, ast.Decl, ast.Spec, ast.Expr:
+		return true
+	}
+	return false
+}
+
+// formattable reports whether node can be formatted apart from its file: it
+// has no bad node, and no multi-line raw string whose lines would be
+// indented.
+func formattable(node ast.Node) bool {
+	ok := isFormattableKind(node)
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BadDecl, *ast.BadExpr, *ast.BadStmt:
+			ok = false
+		case *ast.BasicLit:
+			if n.Kind == token.STRING && strings.Contains(n.Value, "\n") {
+				ok = false
+			}
+		}
+		return ok
+	})
+	return ok
+}
+
+// onTypeEdits returns the edits turning the lines u, starting at line
+// startLine, into v. The lines replaced by as many lines are edited one by
+// one, the others together. The edits of the line skip of u, if any, are
+// dropped.
+func onTypeEdits(uri span.URI, startLine int, u, v []string, skip int) []TextEdit {
+	edit := func(i1, i2 int, text string) TextEdit {
+		return TextEdit{Span: span.New(uri, span.NewPoint(startLine+i1, 1, 0), span.NewPoint(startLine+i2, 1, 0)), NewText: text}
+	}
+	ops := diff.Operations(u, v)
+	var edits []TextEdit
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		if op.Kind == diff.Delete && i+1 < len(ops) && ops[i+1].Kind == diff.Insert && ops[i+1].I1 == op.I2 {
+			ins := ops[i+1]
+			i++
+			if op.I2-op.I1 == ins.J2-ins.J1 {
+				for k := 0; k < op.I2-op.I1; k++ {
+					if l := op.I1 + k; l != skip && u[l] != v[ins.J1+k] {
+						edits = append(edits, edit(l, l+1, v[ins.J1+k]))
+					}
+				}
+				continue
+			}
+			if skip < op.I1 || skip >= op.I2 {
+				edits = append(edits, edit(op.I1, op.I2, ins.Content))
+			}
+			continue
+		}
+		switch op.Kind {
+		case diff.Delete:
+			if skip < op.I1 || skip >= op.I2 {
+				edits = append(edits, edit(op.I1, op.I2, ""))
+			}
+		case diff.Insert:
+			edits = append(edits, edit(op.I1, op.I1, op.Content))
+		}
+	}
+	return edits
+}
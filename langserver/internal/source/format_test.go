@@ -0,0 +1,93 @@
+package source
+
+import "testing"
+
+func TestGroupImports(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			src: `package p
+
+import (
+	"example.com/mod/b"
+	"fmt"
+	"github.com/x/y"
+
+	"example.com/mod/a"
+	"os"
+)
+`,
+			want: `package p
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/x/y"
+
+	"example.com/mod/a"
+	"example.com/mod/b"
+)
+`,
+		},
+		{
+			// The comments of the specs move with them.
+			src: `package p
+
+import (
+	// b is local.
+	b "example.com/mod/b"
+	"github.com/x/y" // y is third-party.
+	"fmt"
+)
+`,
+			want: `package p
+
+import (
+	"fmt"
+
+	"github.com/x/y" // y is third-party.
+
+	// b is local.
+	b "example.com/mod/b"
+)
+`,
+		},
+		{
+			// A free-floating comment is left in place.
+			src: `package p
+
+import (
+	"example.com/mod/b"
+
+	// Free-floating.
+
+	"fmt"
+)
+`,
+		},
+		{
+			// Several import declarations are left in place.
+			src: `package p
+
+import "example.com/mod/b"
+
+import "fmt"
+`,
+		},
+	}
+	for _, test := range tests {
+		got, err := groupImports("a.go", []byte(test.src), "example.com/mod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := test.want
+		if want == "" {
+			want = test.src
+		}
+		if string(got) != want {
+			t.Errorf("groupImports(%q) = %q, want %q", test.src, got, want)
+		}
+	}
+}
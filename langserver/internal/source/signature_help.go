@@ -11,6 +11,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
@@ -23,6 +24,9 @@ type SignatureInformation struct {
 
 type ParameterInformation struct {
 	Label string
+
+	// Documentation describes the parameter, see paramDoc.
+	Documentation string
 }
 
 func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Package, enhance bool) (*SignatureInformation, error) {
@@ -36,7 +40,7 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 	var callExpr *ast.CallExpr
 	path, _ := astutil.PathEnclosingInterval(fAST, pos, pos)
 	if path == nil {
-		return nil, fmt.Errorf("cannot find node enclosing position")
+		return nil, nil
 	}
 	for _, node := range path {
 		if c, ok := node.(*ast.CallExpr); ok {
@@ -48,7 +52,10 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 		return nil, nil
 	}
 
-	// Get the type information for the function corresponding to the call expression.
+	// Get the type information for the function corresponding to the call
+	// expression. There is no signature help, rather than an error, for
+	// the calls of the other expressions, eg. f()(), of the unresolved
+	// functions or of the conversions.
 	var obj types.Object
 	switch t := callExpr.Fun.(type) {
 	case *ast.Ident:
@@ -56,12 +63,13 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 	case *ast.SelectorExpr:
 		obj = pkg.GetTypesInfo().ObjectOf(t.Sel)
 	default:
-		return nil, fmt.Errorf("the enclosing function is malformed")
+		return nil, nil
 	}
 	if obj == nil {
-		return nil, fmt.Errorf("cannot resolve %s", callExpr.Fun)
+		return nil, nil
 	}
 	// Find the signature corresponding to the object.
+	docPkg := pkg
 	var sig *types.Signature
 	switch obj.(type) {
 	case *types.Var:
@@ -72,15 +80,20 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 		sig = obj.Type().(*types.Signature)
 
 	case *types.Builtin:
+		docPkg = builtinPkg
 		obj = FindObject(builtinPkg, obj)
 		if _, ok := obj.(*types.Func); ok {
 			sig = obj.Type().(*types.Signature)
 		}
 	}
 	if sig == nil {
-		return nil, fmt.Errorf("no function signatures found for %s", obj.Name())
+		return nil, nil
 	}
 	pkgStringer := qualifier(fAST, pkg.GetTypes(), pkg.GetTypesInfo())
+	var funcDoc string
+	if docPkg != nil {
+		funcDoc, _ = FindComments(docPkg, docPkg.GetFileSet(), obj, obj.Name())
+	}
 	var paramInfo []ParameterInformation
 	for i := 0; i < sig.Params().Len(); i++ {
 		param := sig.Params().At(i)
@@ -89,7 +102,8 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 			label = fmt.Sprintf("%s %s", param.Name(), label)
 		}
 		paramInfo = append(paramInfo, ParameterInformation{
-			Label: label,
+			Label:         label,
+			Documentation: paramDoc(pkg, param, funcDoc),
 		})
 	}
 	// Determine the query position relative to the number of parameters in the function.
@@ -118,6 +132,35 @@ func SignatureHelp(ctx context.Context, f File, pos token.Pos, builtinPkg Packag
 	}, nil
 }
 
+// paramDoc returns the documentation of the parameter param of a function
+// documented by funcDoc: the description following "name:" at the start of a
+// line of funcDoc, eg. "width: the minimum width", or else the documentation
+// of the named type of the parameter.
+func paramDoc(pkg Package, param *types.Var, funcDoc string) string {
+	if param.Name() != "" && param.Name() != "_" {
+		for _, line := range strings.Split(funcDoc, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*"))
+			if strings.HasPrefix(line, param.Name()+":") {
+				return strings.TrimSpace(line[len(param.Name())+1:])
+			}
+		}
+	}
+
+	typ := param.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	doc, err := FindComments(pkg, pkg.GetFileSet(), named.Obj(), named.Obj().Name())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(doc)
+}
+
 func formatResults(t *types.Tuple, qualifier types.Qualifier) string {
 	if t.Len() == 0 {
 		return ""
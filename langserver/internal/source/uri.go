@@ -13,7 +13,6 @@ import (
 	"github.com/sourcegraph/go-lsp"
 	"net/url"
 	"path/filepath"
-	"runtime"
 	"strings"
 )
 
@@ -56,8 +55,7 @@ func ToURI(path string) URI {
 	const prefix = "$GOROOT"
 	if strings.EqualFold(prefix, path[:len(prefix)]) {
 		suffix := path[len(prefix):]
-		//TODO: we need a better way to get the GOROOT that uses the packages api
-		path = runtime.GOROOT() + suffix
+		path = util.GoRoot() + suffix
 	}
 
 	uri := filepath.ToSlash(util.LowerDriver(path))
@@ -8,10 +8,10 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 )
 
@@ -61,7 +61,7 @@ func FileURI(path string) URI {
 	const prefix = "$GOROOT"
 	if len(path) >= len(prefix) && strings.EqualFold(prefix, path[:len(prefix)]) {
 		suffix := path[len(prefix):]
-		path = runtime.GOROOT() + suffix
+		path = util.GoRoot() + suffix
 	}
 	if !isWindowsDrivePath(path) {
 		if abs, err := filepath.Abs(path); err == nil {
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	goRootOnce sync.Once
+	goRoot     string
+)
+
+// GoRoot returns the GOROOT of the go command, `go env GOROOT`, which may
+// differ from the one the server was built with, eg. in a container or with
+// a toolchain selected by GOTOOLCHAIN. It falls back to runtime.GOROOT if the
+// go command fails, and may be empty if both are unknown. It is computed
+// once.
+func GoRoot() string {
+	goRootOnce.Do(func() {
+		out, err := exec.Command("go", "env", "GOROOT").Output()
+		if root := strings.TrimSpace(string(out)); err == nil && root != "" {
+			goRoot = root
+			return
+		}
+		goRoot = runtime.GOROOT()
+	})
+	return goRoot
+}
@@ -0,0 +1,106 @@
+package langserver
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// TestPackage is a package of the workspace with its test functions.
+type TestPackage struct {
+	// Package is the import path of the package, eg. "foo/bar_test" for an
+	// external test package.
+	Package string `json:"package"`
+
+	Tests []TestFunction `json:"tests"`
+}
+
+// TestFunction is a top-level test, benchmark, example or fuzz function.
+type TestFunction struct {
+	Name     string       `json:"name"`
+	Location lsp.Location `json:"location"`
+}
+
+// testPrefixes are the prefixes of the function names run by go test.
+var testPrefixes = []string{"Test", "Benchmark", "Example", "Fuzz"}
+
+// handleListTests handles `bingo/listTests` requests. It returns the test
+// functions of the _test.go files of the workspace, grouped by package. Sub
+// tests are not listed.
+func (h *LangHandler) handleListTests(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) ([]TestPackage, error) {
+	var mu sync.Mutex
+	tests := make(map[string]map[lsp.Location]string)
+
+	f := func(pkg source.Package) error {
+		var found []symbolPair
+		for _, sym := range astPkgToSymbols(pkg, h.overlay.columns, false) {
+			if sym.Kind != lsp.SKFunction || !isTestFunc(sym.Name) {
+				continue
+			}
+			uri := sym.Location.URI
+			if !strings.HasSuffix(string(uri), "_test.go") || !h.project.Contain(uri) {
+				continue
+			}
+			found = append(found, sym)
+		}
+		if len(found) == 0 {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		// The package is also loaded without its tests, and as part of the
+		// test binary, the same functions may be found several times.
+		pkgPath := pkg.GetPkgPath()
+		if tests[pkgPath] == nil {
+			tests[pkgPath] = make(map[lsp.Location]string)
+		}
+		for _, sym := range found {
+			tests[pkgPath][sym.Location] = sym.Name
+		}
+		return nil
+	}
+
+	if err := h.project.Search(ctx, f); err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]TestPackage, 0, len(tests))
+	for pkgPath, funcs := range tests {
+		pkg := TestPackage{Package: pkgPath}
+		for loc, name := range funcs {
+			pkg.Tests = append(pkg.Tests, TestFunction{Name: name, Location: loc})
+		}
+		sort.Slice(pkg.Tests, func(i, j int) bool {
+			return lessLocation(pkg.Tests[i].Location, pkg.Tests[j].Location)
+		})
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Package < pkgs[j].Package
+	})
+	return pkgs, nil
+}
+
+// isTestFunc reports whether name is the name of a function run by go test,
+// ie. one of the testPrefixes not followed by a lower case letter.
+func isTestFunc(name string) bool {
+	for _, prefix := range testPrefixes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if len(name) == len(prefix) {
+			return true
+		}
+		r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+		return !unicode.IsLower(r)
+	}
+	return false
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/token"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
@@ -24,6 +26,20 @@ func checkFileURI(fileURI lsp.DocumentURI) error {
 	return nil
 }
 
+// isNoResult reports whether err only means that there is nothing to answer
+// a request about at a position, rather than an analysis failure: the node at
+// the position is not an identifier, eg. a comment or a string, or the
+// package only contains files which cannot be analysed, usually because of
+// build tags. The requests return an empty result then, an error would be
+// shown to the user by the editors.
+func isNoResult(err error) bool {
+	switch err.(type) {
+	case *source.InvalidNodeError, *build.NoGoError:
+		return true
+	}
+	return false
+}
+
 func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, position lsp.Position) (source.Package, token.Pos, error) {
 	pos := token.NoPos
 
@@ -52,10 +68,81 @@ func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, po
 	return pkg, pos, err
 }
 
+// identAt returns the identifier at position in the document fileURI, with
+// its path nodes.
+//
+// The cursor is often just past the end of an identifier, eg. after typing it,
+// where the innermost node is its parent or the next token, eg. the call
+// of "f|()". So if the node at position is not an identifier, the position one
+// byte left is probed, and its identifier is used only if it ends exactly at
+// position. Otherwise identOf extracts the identifier from the node at
+// position, eg. the function of a call, an InvalidNodeError is returned if it
+// returns nil. The identifier containing position is always preferred.
+func (h *LangHandler) identAt(ctx context.Context, fileURI lsp.DocumentURI, position lsp.Position, identOf func(ast.Node) *ast.Ident) (source.Package, []ast.Node, *ast.Ident, error) {
+	pkg, pos, err := h.typeCheck(ctx, fileURI, position)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fset := pkg.GetFileSet()
+	pathNodes, err := source.GetPathNodes(pkg, fset, pos, pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ident, ok := pathNodes[0].(*ast.Ident); ok {
+		h.traceIdent(pkg, fileURI, position, ident, pathNodes[0])
+		return pkg, pathNodes, ident, nil
+	}
+
+	if f := fset.File(pos); f != nil && int(pos) > f.Base() {
+		if leftNodes, err := source.GetPathNodes(pkg, fset, pos-1, pos-1); err == nil {
+			if ident, ok := leftNodes[0].(*ast.Ident); ok && ident.End() == pos {
+				h.traceIdent(pkg, fileURI, position, ident, leftNodes[0])
+				return pkg, leftNodes, ident, nil
+			}
+		}
+	}
+
+	if ident := identOf(pathNodes[0]); ident != nil {
+		h.traceIdent(pkg, fileURI, position, ident, pathNodes[0])
+		return pkg, pathNodes, ident, nil
+	}
+	h.notifyTrace(fmt.Sprintf("%s:%d:%d: no identifier in %T", fileURI, position.Line+1, position.Character+1, pathNodes[0]))
+	return nil, nil, nil, source.NewInvalidNodeError(fset, pathNodes[0])
+}
+
+// traceIdent logs the identifier position is resolved to, with the innermost
+// node at position and the object of the identifier, at the trace log level.
+func (h *LangHandler) traceIdent(pkg source.Package, fileURI lsp.DocumentURI, position lsp.Position, ident *ast.Ident, node ast.Node) {
+	if !h.logLevel.Enabled(cache.LogTrace) {
+		return
+	}
+	h.notifyTrace(fmt.Sprintf("%s:%d:%d: %T resolved to %s at %s, object %v", fileURI, position.Line+1, position.Character+1,
+		node, ident.Name, pkg.GetFileSet().Position(ident.Pos()), pkg.GetTypesInfo().ObjectOf(ident)))
+}
+
 func (h *LangHandler) getPosFromFile(ctx context.Context, pkg source.Package, f source.File, position lsp.Position) (token.Pos, error) {
 	tok := f.GetToken(ctx)
-	pos := fromProtocolPosition(tok, position)
-	return pos, nil
+	if tok == nil {
+		return token.NoPos, fmt.Errorf("%s token file does not exist", f.URI())
+	}
+	filename, err := f.URI().Filename()
+	if err != nil {
+		return token.NoPos, err
+	}
+	return h.documentPos(pkg.GetFileSet(), tok, f.GetAST(ctx), filename, position), nil
+}
+
+// documentPos converts position of the document filename to a position of
+// file. If file is generated by cgo from the document, the position is mapped
+// through its line directives.
+func (h *LangHandler) documentPos(fset *token.FileSet, tok *token.File, file *ast.File, filename string, position lsp.Position) token.Pos {
+	if util.PathEqual(tok.Name(), filename) || file == nil {
+		return h.overlay.columns.fromProtocolPosition(tok, position)
+	}
+
+	line := int(position.Line) + 1
+	return source.GeneratedPos(fset, file, line, h.overlay.columns.byteColumn(filename, line, position.Character))
 }
 
 func (h *LangHandler) getPosFromPkg(pkg source.Package, fileURI lsp.DocumentURI, position lsp.Position) (token.Pos, error) {
@@ -70,7 +157,7 @@ func (h *LangHandler) getPosFromPkg(pkg source.Package, fileURI lsp.DocumentURI,
 		return pos, fmt.Errorf("%s token file does not exist", fileURI)
 	}
 
-	pos = fromProtocolPosition(fToken, position)
+	pos = h.documentPos(pkg.GetFileSet(), fToken, fAST, util.UriToRealPath(fileURI), position)
 	return pos, nil
 }
 
@@ -0,0 +1,93 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var alternateContext = newTestContext(cache.Always)
+
+func TestAlternate(t *testing.T) {
+	t.Parallel()
+
+	alternateContext.setup(t)
+
+	test := func(t *testing.T, input string, output string) {
+		testAlternate(t, &alternateTestCase{input: input, output: output})
+	}
+
+	t.Run("interface", func(t *testing.T) {
+		test(t, "alternate/a.go:1:17", "alternate/a.go:1:50")
+	})
+
+	t.Run("interface method", func(t *testing.T) {
+		test(t, "alternate/a.go:1:30", "alternate/a.go:1:71")
+	})
+
+	t.Run("interface with several implementations", func(t *testing.T) {
+		test(t, "alternate/a.go:1:92", "")
+	})
+
+	t.Run("test file", func(t *testing.T) {
+		test(t, "alternate/a.go:1:1", "alternate/a_test.go:1:1")
+	})
+
+	t.Run("tested file", func(t *testing.T) {
+		test(t, "alternate/a_test.go:1:1", "alternate/a.go:1:1")
+	})
+
+	t.Run("no test file", func(t *testing.T) {
+		test(t, "alternate/b.go:1:1", "")
+	})
+}
+
+type alternateTestCase struct {
+	input  string
+	output string
+}
+
+func testAlternate(tb testing.TB, c *alternateTestCase) {
+	tbRun(tb, fmt.Sprintf("alternate-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(alternateContext.root())
+		if err != nil {
+			log.Fatal("testAlternate", err)
+		}
+		doAlternateTest(t, alternateContext.ctx, alternateContext.conn, util.PathToURI(dir), c.input, c.output)
+	})
+}
+
+func doAlternateTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := callAlternate(ctx, c, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if loc != nil {
+		got = fmt.Sprintf("%s:%d:%d", strings.TrimPrefix(string(loc.URI), string(rootURI)+"/"), loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func callAlternate(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) (*lsp.Location, error) {
+	var res *lsp.Location
+	err := c.Call(ctx, "bingo/alternate", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &res)
+	return res, err
+}
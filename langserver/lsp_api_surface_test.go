@@ -0,0 +1,76 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var apiSurfaceContext = newTestContext(cache.None)
+
+func TestAPISurface(t *testing.T) {
+	t.Parallel()
+
+	apiSurfaceContext.setup(t)
+
+	test := func(t *testing.T, input string, output string) {
+		testAPISurface(t, &apiSurfaceTestCase{input: input, output: output})
+	}
+
+	t.Run("api surface", func(t *testing.T) {
+		test(t, "apisurface/a.go", `const Max untyped int = 10
+field T.Name string
+field T.Writer io.Writer
+func (I).Do(n int) error
+func (T).Get() string
+func New() *T
+type A = T
+type I interface
+type T struct
+var Reader io.Reader
+`)
+	})
+}
+
+type apiSurfaceTestCase struct {
+	input  string
+	output string
+}
+
+func testAPISurface(tb testing.TB, c *apiSurfaceTestCase) {
+	tbRun(tb, fmt.Sprintf("apisurface-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(apiSurfaceContext.root())
+		if err != nil {
+			log.Fatal("testAPISurface", err)
+		}
+		doAPISurfaceTest(t, apiSurfaceContext.ctx, apiSurfaceContext.conn, util.PathToURI(dir), c.input, c.output)
+	})
+}
+
+func doAPISurfaceTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, file string, want string) {
+	got, err := callAPISurface(ctx, c, uriJoin(rootURI, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("\ngot %q, \nwant %q", got, want)
+	}
+}
+
+func callAPISurface(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI) (string, error) {
+	var surface string
+	err := c.Call(ctx, "bingo/apiSurface", APISurfaceParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}, &surface)
+	return surface, err
+}
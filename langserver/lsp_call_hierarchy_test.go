@@ -0,0 +1,149 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var (
+	callHierarchyContext         = newTestContext(cache.Always)
+	callHierarchyParallelContext = newTestContext(cache.Always)
+)
+
+func TestCallHierarchy(t *testing.T) {
+	t.Parallel()
+
+	callHierarchyContext.setup(t)
+
+	t.Run("prepare", func(t *testing.T) {
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:17", "", []string{"A callhierarchy/a.go:1:17"})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:23", "", []string{"B callhierarchy/a.go:1:40"})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:121", "", []string{"M callhierarchy/a.go:1:121"})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:9", "", []string{})
+	})
+
+	t.Run("incoming calls", func(t *testing.T) {
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:40", "callHierarchy/incomingCalls", []string{
+			"A callhierarchy/a.go:1:17 <- 1:23, 1:28",
+			"D callhierarchy/a.go:1:71 <- 1:77",
+		})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:121", "callHierarchy/incomingCalls", []string{"M callhierarchy/a.go:1:121 <- 1:136"})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:71", "callHierarchy/incomingCalls", []string{})
+	})
+
+	t.Run("outgoing calls", func(t *testing.T) {
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:17", "callHierarchy/outgoingCalls", []string{"B callhierarchy/a.go:1:40 <- 1:23, 1:28"})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:121", "callHierarchy/outgoingCalls", []string{
+			"A callhierarchy/a.go:1:17 <- 1:127",
+			"M callhierarchy/a.go:1:121 <- 1:136",
+		})
+		testCallHierarchy(t, callHierarchyContext, "callhierarchy/a.go:1:58", "callHierarchy/outgoingCalls", []string{})
+	})
+}
+
+// TestCallHierarchyParallel searches the incoming calls with several workers,
+// which collect the calls of their packages concurrently.
+func TestCallHierarchyParallel(t *testing.T) {
+	t.Parallel()
+
+	maxParallelism := 4
+	callHierarchyParallelContext.initOptions = &InitializationOptions{MaxParallelism: &maxParallelism}
+	callHierarchyParallelContext.setup(t)
+
+	want := []string{
+		"B callhierarchy/parallel/b/b.go:1:97 <- 1:105, 1:112",
+		"C callhierarchy/parallel/c/c.go:1:97 <- 1:105, 1:112",
+		"D callhierarchy/parallel/d/d.go:1:97 <- 1:105, 1:112",
+		"E callhierarchy/parallel/e/e.go:1:97 <- 1:105, 1:112",
+		"F callhierarchy/parallel/f/f.go:1:97 <- 1:105, 1:112",
+	}
+	for i := 0; i < 4; i++ {
+		testCallHierarchy(t, callHierarchyParallelContext, "callhierarchy/parallel/a/a.go:1:17", "callHierarchy/incomingCalls", want)
+	}
+}
+
+// testCallHierarchy prepares the call hierarchy at pos and, if method is not
+// empty, requests the calls of the prepared item with method.
+func testCallHierarchy(t *testing.T, tx *TestContext, pos, method string, want []string) {
+	t.Helper()
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.Abs(tx.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, conn := tx.ctx, tx.conn
+
+	var items []CallHierarchyItem
+	err = conn.Call(ctx, "textDocument/prepareCallHierarchy", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if method == "" {
+		got = make([]string, 0, len(items))
+		for _, item := range items {
+			got = append(got, formatCallHierarchyItem(tx.root(), item))
+		}
+	} else {
+		if len(items) != 1 {
+			t.Fatalf("%s: got %d items, want 1", pos, len(items))
+		}
+		got, err = callCallHierarchyCalls(ctx, conn, tx.root(), method, items[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s %s:\ngot  %q\nwant %q", method, pos, got, want)
+	}
+}
+
+// callCallHierarchyCalls returns the calls of item as
+// "name file:line:col <- line:col, ...".
+func callCallHierarchyCalls(ctx context.Context, c *jsonrpc2.Conn, rootDir, method string, item CallHierarchyItem) ([]string, error) {
+	var calls []struct {
+		From       *CallHierarchyItem `json:"from"`
+		To         *CallHierarchyItem `json:"to"`
+		FromRanges []lsp.Range        `json:"fromRanges"`
+	}
+	if err := c.Call(ctx, method, CallHierarchyCallsParams{Item: item}, &calls); err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(calls))
+	for _, call := range calls {
+		other := call.From
+		if other == nil {
+			other = call.To
+		}
+		var ranges []string
+		for _, r := range call.FromRanges {
+			ranges = append(ranges, fmt.Sprintf("%d:%d", r.Start.Line+1, r.Start.Character+1))
+		}
+		res = append(res, formatCallHierarchyItem(rootDir, *other)+" <- "+strings.Join(ranges, ", "))
+	}
+	return res, nil
+}
+
+func formatCallHierarchyItem(rootDir string, item CallHierarchyItem) string {
+	root := makePath(rootDir) + "/"
+	file := strings.TrimPrefix(filepath.ToSlash(util.UriToRealPath(item.URI)), root)
+	start := item.SelectionRange.Start
+	return fmt.Sprintf("%s %s:%d:%d", item.Name, file, start.Line+1, start.Character+1)
+}
@@ -0,0 +1,188 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var codeActionContext = newTestContext(cache.None)
+
+func TestCodeAction(t *testing.T) {
+	t.Parallel()
+
+	codeActionContext.setup(t)
+
+	test := func(t *testing.T, input string, title string, output map[string]string) {
+		testCodeAction(t, &codeActionTestCase{input: input, title: title, output: output})
+	}
+
+	t.Run("fill struct", func(t *testing.T) {
+		test(t, "fillstruct/a.go:11:10", "Fill struct", map[string]string{
+			"10:10-10:10": "\n\tA: 0,\n\tB: \"\",\n\tC: false,\n",
+		})
+		test(t, "fillstruct/a.go:13:10", "Fill struct", map[string]string{
+			"12:14-12:14": ",\n\tB: \"\",\n\tC: false,\n",
+		})
+		test(t, "fillstruct/a.go:15:10", "Fill struct", map[string]string{
+			"16:0-16:0": "\tB: \"\",\n\tC: false,\n",
+		})
+		test(t, "fillstruct/a.go:19:10", "Fill struct", nil)
+		// The unexported field is not filled, nor the time.Time field since
+		// the file does not import time, and u names the imported package.
+		test(t, "fillstruct/a.go:21:12", "Fill struct", map[string]string{
+			"20:12-20:12": "\n\tName: \"\",\n\tInner: u.Inner{},\n",
+		})
+	})
+
+	t.Run("convert var to const", func(t *testing.T) {
+		test(t, "convert/a.go:3:1", "Convert to const", map[string]string{
+			"2:0-2:3": "const",
+		})
+		test(t, "convert/a.go:7:1", "Convert to const", nil)
+		// An exported variable can be modified by the other packages.
+		test(t, "convert/a.go:13:1", "Convert to const", nil)
+		// An interface type is not the type of a constant.
+		test(t, "convert/a.go:17:1", "Convert to const", nil)
+		// n.inc() takes the address of n.
+		test(t, "convert/a.go:23:1", "Convert to const", nil)
+	})
+
+	t.Run("convert const to var", func(t *testing.T) {
+		test(t, "convert/a.go:5:1", "Convert to var", map[string]string{
+			"4:0-4:5": "var",
+		})
+		test(t, "convert/a.go:9:1", "Convert to var", nil)
+		// An exported constant can be required by the other packages.
+		test(t, "convert/a.go:15:1", "Convert to var", nil)
+		// A float64 variable is not an int.
+		test(t, "convert/a.go:27:1", "Convert to var", nil)
+		// The index of an array literal is a constant.
+		test(t, "convert/a.go:31:1", "Convert to var", nil)
+		// A local variable must be used.
+		test(t, "convert/a.go:36:2", "Convert to var", nil)
+	})
+
+	t.Run("convert receiver", func(t *testing.T) {
+		test(t, "receiver/a.go:5:10", "Convert to pointer receiver (breaks 4 uses)", map[string]string{
+			"4:8-4:8": "*",
+		})
+		test(t, "receiver/a.go:7:7", "Convert to value receiver", map[string]string{
+			"6:8-6:9": "",
+		})
+		test(t, "receiver/a.go:7:34", "Convert to value receiver", nil)
+		test(t, "receiver/a.go:9:7", "Convert to value receiver", nil)
+	})
+
+	t.Run("remove unused imports", func(t *testing.T) {
+		// The quick fix is only offered for the diagnostics of unused
+		// imports.
+		test(t, "unusedimport/a.go:4:2", "Remove unused imports", nil)
+
+		dir, err := filepath.Abs(codeActionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		diag := lsp.Diagnostic{
+			Range:   lsp.Range{Start: lsp.Position{Line: 3, Character: 1}, End: lsp.Position{Line: 3, Character: 8}},
+			Message: `"fmt" imported as f and not used`,
+		}
+		var actions []protocol.CodeAction
+		err = codeActionContext.conn.Call(codeActionContext.ctx, "textDocument/codeAction", lsp.CodeActionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "unusedimport/a.go")},
+			Range:        diag.Range,
+			Context:      lsp.CodeActionContext{Diagnostics: []lsp.Diagnostic{diag}},
+		}, &actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got *protocol.CodeAction
+		for i := range actions {
+			if actions[i].Title == "Remove unused imports" {
+				got = &actions[i]
+			}
+		}
+		if got == nil {
+			t.Fatalf("got %+v, want a Remove unused imports action", actions)
+		}
+		if got.Kind != protocol.QuickFix || !reflect.DeepEqual(got.Diagnostics, []lsp.Diagnostic{diag}) {
+			t.Errorf("got kind %q and diagnostics %+v, want a quick fix of %+v", got.Kind, got.Diagnostics, diag)
+		}
+		edits := map[string]string{}
+		for _, changes := range got.Edit.Changes {
+			for _, edit := range changes {
+				edits[edit.Range.String()] = edit.NewText
+			}
+		}
+		if want := map[string]string{"3:0-4:0": ""}; !reflect.DeepEqual(edits, want) {
+			t.Errorf("got edits %v, want %v", edits, want)
+		}
+	})
+}
+
+type codeActionTestCase struct {
+	input  string
+	title  string
+	output map[string]string
+}
+
+func testCodeAction(tb testing.TB, c *codeActionTestCase) {
+	tbRun(tb, fmt.Sprintf("codeaction-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(codeActionContext.root())
+		if err != nil {
+			log.Fatal("testCodeAction", err)
+		}
+		doCodeActionTest(t, codeActionContext.ctx, codeActionContext.conn, util.PathToURI(dir), c.input, c.title, c.output)
+	})
+}
+
+func doCodeActionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string, title string, want map[string]string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := callCodeAction(ctx, c, uriJoin(rootURI, file), line, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	for _, action := range actions {
+		if action.Title != title {
+			continue
+		}
+		got = map[string]string{}
+		for _, edits := range action.Edit.Changes {
+			for _, edit := range edits {
+				got[edit.Range.String()] = edit.NewText
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot %v, \nwant: %v", got, want)
+	}
+}
+
+func callCodeAction(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) ([]protocol.CodeAction, error) {
+	var actions []protocol.CodeAction
+	pos := lsp.Position{Line: line, Character: char}
+	err := c.Call(ctx, "textDocument/codeAction", lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Range:        lsp.Range{Start: pos, End: pos},
+	}, &actions)
+	return actions, err
+}
@@ -17,6 +17,12 @@ import (
 
 var completionContext = newTestContext(cache.None)
 
+var completionImportContext = newTestContext(cache.Always)
+
+var completionEagerContext = newTestContext(cache.None)
+
+var completionImportEagerContext = newTestContext(cache.Always)
+
 func TestCompletion(t *testing.T) {
 	t.Parallel()
 
@@ -32,8 +38,8 @@ func TestCompletion(t *testing.T) {
 
 	t.Run("xtest", func(t *testing.T) {
 		test(t, "xtest/x_test.go:1:87", "1:86-1:87 p module \"github.com/saibing/bingo/langserver/test/pkg/xtest\", panic(interface{}) function , print(args ...T) function , println(args ...T) function ")
-		test(t, "xtest/x_test.go:1:88", "1:88-1:88 A variable int, X variable int, Y() function int")
-		test(t, "xtest/b_test.go:1:35", "1:34-1:35 X variable int")
+		test(t, "xtest/x_test.go:1:88", "1:88-1:88 A variable , X variable , Y() function ")
+		test(t, "xtest/b_test.go:1:35", "1:34-1:35 X variable ")
 	})
 
 	t.Run("go subdirectory in repo", func(t *testing.T) {
@@ -42,7 +48,7 @@ func TestCompletion(t *testing.T) {
 
 	t.Run("go root", func(t *testing.T) {
 		test(t, "goroot/a.go:1:21", "1:20-1:21 fmt module \"fmt\", false constant , float32 typeParameter , float64 typeParameter ")
-		test(t, "goroot/a.go:1:44", "1:38-1:44 Println(a ...interface{}) function n int, err error")
+		test(t, "goroot/a.go:1:44", "1:38-1:44 Println(a ...interface{}) function ")
 	})
 
 	t.Run("go project workspace", func(t *testing.T) {
@@ -57,16 +63,268 @@ func TestCompletion(t *testing.T) {
 		test(t, "gomodule/b.go:1:40", "1:39-1:40 delete(m map[K]V, key K) function ")
 		test(t, "gomodule/b.go:1:63", "1:63-1:63 D() function ")
 
-		test(t, "gomodule/c.go:1:68", "1:68-1:68 D2 field int")
+		test(t, "gomodule/c.go:1:68", "1:68-1:68 D2 field ")
 	})
 
 	t.Run("completion", func(t *testing.T) {
-		test(t, "completion/a.go:6:7", "6:6-6:7 strings module \"strings\", s1 = 42 constant int, s2() function , s3 variable int, s4 variable func(), string typeParameter ")
+		test(t, "completion/a.go:6:7", "6:6-6:7 strings module \"strings\", s1 = 42 constant , s2() function , s3 variable , s4 variable , string typeParameter ")
 		test(t, "completion/a.go:7:7", "7:6-7:7 new(T) function *T, nil variable ")
 		test(t, "completion/a.go:12:11", "12:8-12:11 int typeParameter , int16 typeParameter , int32 typeParameter , int64 typeParameter , int8 typeParameter ")
-		test(t, "completion/b.go:1:44", "1:38-1:44 Println(a ...interface{}) function n int, err error")
-		test(t, "completion/c.go:8:11", "8:6-8:11 Print(a ...interface{}) function n int, err error, Printf(format string, a ...interface{}) function n int, err error, Println(a ...interface{}) function n int, err error")
+		test(t, "completion/b.go:1:44", "1:38-1:44 Println(a ...interface{}) function ")
+		test(t, "completion/c.go:8:11", "8:6-8:11 Print(a ...interface{}) function , Printf(format string, a ...interface{}) function , Println(a ...interface{}) function ")
 	})
+
+	t.Run("struct literal keys", testCompletionStructLiteralKeys)
+	t.Run("resolve", testCompletionResolve)
+	t.Run("expected type", testCompletionExpectedType)
+}
+
+// testCompletionExpectedType tests that the candidates of the expected type
+// are ranked first, in the completionContext set up by TestCompletion.
+func testCompletionExpectedType(t *testing.T) {
+	dir, err := filepath.Abs(completionContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first labels completed.
+	test := func(t *testing.T, line, char int, want string) {
+		t.Helper()
+		var res lsp.CompletionList
+		err := completionContext.conn.Call(completionContext.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "expected/a.go")},
+			Position:     lsp.Position{Line: line, Character: char},
+		}}, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for i, it := range res.Items {
+			if i == strings.Count(want, ",")+1 {
+				break
+			}
+			labels = append(labels, it.Label)
+		}
+		if got := strings.Join(labels, ", "); got != want {
+			t.Errorf("\ngot : %q, \nwant: %q", got, want)
+		}
+	}
+
+	// return ‸
+	test(t, 19, 8, "title(), zone")
+	// var v string = z‸
+	test(t, 23, 17, "zone, zero")
+	// describe(r‸)
+	test(t, 24, 11, "reading, ratio")
+	// return reading, f‸
+	test(t, 25, 18, "failure, fahrenheit()")
+}
+
+// TestCompletionEager tests that the completion items have their detail for
+// a client which does not resolve it.
+func TestCompletionEager(t *testing.T) {
+	t.Parallel()
+
+	completionEagerContext.noResolveSupport = true
+	completionEagerContext.setup(t)
+
+	dir, err := filepath.Abs(completionEagerContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		doCompletionTest(t, completionEagerContext.ctx, completionEagerContext.conn, util.PathToURI(dir), pos, want)
+	}
+
+	test(t, "xtest/x_test.go:1:88", "1:88-1:88 A variable int, X variable int, Y() function int")
+	test(t, "xtest/b_test.go:1:35", "1:34-1:35 X variable int")
+	test(t, "goroot/a.go:1:44", "1:38-1:44 Println(a ...interface{}) function n int, err error")
+	test(t, "gomodule/c.go:1:68", "1:68-1:68 D2 field int")
+	test(t, "completion/a.go:6:7", "6:6-6:7 strings module \"strings\", s1 = 42 constant int, s2() function , s3 variable int, s4 variable func(), string typeParameter ")
+	test(t, "completion/b.go:1:44", "1:38-1:44 Println(a ...interface{}) function n int, err error")
+	test(t, "completion/c.go:8:11", "8:6-8:11 Print(a ...interface{}) function n int, err error, Printf(format string, a ...interface{}) function n int, err error, Println(a ...interface{}) function n int, err error")
+}
+
+// testCompletionResolve tests that completionItem/resolve adds the detail and
+// the documentation of the items, in the completionContext set up by
+// TestCompletion.
+func testCompletionResolve(t *testing.T) {
+	dir, err := filepath.Abs(completionContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, line, char int, want string) {
+		t.Helper()
+		var res completionList
+		err := completionContext.conn.Call(completionContext.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "resolve/a.go")},
+			Position:     lsp.Position{Line: line, Character: char},
+		}}, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, it := range res.Items {
+			if it.Data == nil || it.Detail != "" {
+				t.Errorf("item %s is resolved by the completion", it.Label)
+			}
+			var resolved completionItem
+			if err := completionContext.conn.Call(completionContext.ctx, "completionItem/resolve", it, &resolved); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprintf("%s: %s %q", resolved.Label, resolved.Detail, resolved.Documentation))
+		}
+		if s := strings.Join(got, ", "); s != want {
+			t.Errorf("\ngot : %q, \nwant: %q", s, want)
+		}
+	}
+
+	test(t, 14, 11, `Limit = 10: const Limit untyped int "Limit is the limit of T.\n"`)
+	test(t, 15, 12, `M(): func (T).M() "M is a method.\n", F: field F int "F is a field.\n"`)
+}
+
+// testCompletionStructLiteralKeys tests the completion of the keys of the
+// struct literals, in the completionContext set up by TestCompletion.
+func testCompletionStructLiteralKeys(t *testing.T) {
+	dir, err := filepath.Abs(completionContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fields completed and the text they insert.
+	test := func(t *testing.T, line, char int, want string) {
+		t.Helper()
+		var res lsp.CompletionList
+		err := completionContext.conn.Call(completionContext.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "complit/a.go")},
+			Position:     lsp.Position{Line: line, Character: char},
+		}}, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields []string
+		for _, it := range res.Items {
+			if it.Kind == lsp.CIKField {
+				fields = append(fields, fmt.Sprintf("%s %q", it.Label, it.TextEdit.NewText))
+			}
+		}
+		if got := strings.Join(fields, ", "); got != want {
+			t.Errorf("\ngot : %q, \nwant: %q", got, want)
+		}
+	}
+
+	test(t, 10, 15, `Timeout "Timeout: ", Title "Title: ", Name "Name: "`)
+	test(t, 11, 25, `Timeout "Timeout: ", Title "Title: "`)
+	test(t, 12, 17, `Timeout "Timeout", Title "Title"`)
+	test(t, 13, 19, `Timeout "Timeout: ", Title "Title: ", Name "Name: "`)
+	test(t, 14, 18, `Name "Name: "`)
+}
+
+func TestCompletionImport(t *testing.T) {
+	t.Parallel()
+
+	completionImportContext.setup(t)
+
+	dir, err := filepath.Abs(completionImportContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootURI := util.PathToURI(dir)
+
+	complete := func(t *testing.T, pos string) completionList {
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res completionList
+		err = completionImportContext.conn.Call(completionImportContext.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+			Position:     lsp.Position{Line: line, Character: char},
+		}}, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	test := func(t *testing.T, pos, want string) {
+		testCompletionImportEdits(t, complete(t, pos), want)
+	}
+
+	t.Run("unimported package", func(t *testing.T) {
+		test(t, "completion/d.go:8:20", `Title(s string) (from "strings") 5:1-5:1 "\t\"strings\"\n"`)
+	})
+
+	t.Run("grouped imports", func(t *testing.T) {
+		test(t, "completion/e.go:11:20", `Title(s string) (from "strings") 5:1-5:1 "\t\"strings\"\n"`)
+		test(t, "completion/e.go:12:19", `Wrap(err error) (from "github.com/saibing/bingo/langserver/test/pkg/unimported/errors") 6:1-6:1 "\t\"github.com/saibing/bingo/langserver/test/pkg/unimported/errors\"\n"`)
+	})
+
+	t.Run("imported package", func(t *testing.T) {
+		test(t, "completion/c.go:8:11", "")
+	})
+
+	t.Run("unqualified member of unimported package", func(t *testing.T) {
+		test(t, "unimported/a.go:6:16", `Unimported() (from "github.com/saibing/bingo/langserver/test/pkg/unimported/lib") 4:1-4:1 "import \"github.com/saibing/bingo/langserver/test/pkg/unimported/lib\"\n"`)
+		for _, it := range complete(t, "unimported/a.go:6:16").Items {
+			if it.Label == "Unimported()" && !strings.HasPrefix(it.TextEdit.NewText, "lib.Unimported") {
+				t.Errorf("got %q, want it qualified by lib", it.TextEdit.NewText)
+			}
+		}
+	})
+
+	t.Run("unimportable package", func(t *testing.T) {
+		// An internal package of another tree.
+		test(t, "unimported/a.go:7:14", "")
+		// A main package.
+		test(t, "unimported/a.go:8:14", "")
+		// The test variant of a package.
+		test(t, "unimported/a.go:9:16", "")
+	})
+
+	t.Run("package named as an imported one", func(t *testing.T) {
+		for _, it := range complete(t, "unimported/a.go:10:12").Items {
+			if strings.HasSuffix(it.Detail, `(from "errors")`) {
+				t.Errorf("got %s %s, want no item of the errors package, the file imports another one", it.Label, it.Detail)
+			}
+		}
+	})
+}
+
+// testCompletionImportEdits tests the items of res importing a package and
+// their edits.
+func testCompletionImportEdits(t *testing.T, res completionList, want string) {
+	t.Helper()
+	var got []string
+	for _, item := range res.Items {
+		for _, e := range item.AdditionalTextEdits {
+			got = append(got, fmt.Sprintf("%s %s %d:%d-%d:%d %q", item.Label, item.Detail, e.Range.Start.Line+1, e.Range.Start.Character+1, e.Range.End.Line+1, e.Range.End.Character+1, e.NewText))
+		}
+	}
+	if s := strings.Join(got, ", "); s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+}
+
+// TestCompletionImportEager tests that the items importing a package have
+// their detail for a client which does not resolve it.
+func TestCompletionImportEager(t *testing.T) {
+	t.Parallel()
+
+	completionImportEagerContext.noResolveSupport = true
+	completionImportEagerContext.setup(t)
+
+	dir, err := filepath.Abs(completionImportEagerContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res completionList
+	err = completionImportEagerContext.conn.Call(completionImportEagerContext.ctx, "textDocument/completion", lsp.CompletionParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "completion/d.go")},
+		Position:     lsp.Position{Line: 7, Character: 19},
+	}}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCompletionImportEdits(t, res, `Title(s string) string (from "strings") 5:1-5:1 "\t\"strings\"\n"`)
 }
 
 type completionTestCase struct {
@@ -11,8 +11,30 @@ var testdata = []packagestest.Module{
 
 			"builtin/a.go": `package p; func A() { println("hello") }`,
 
+			"builtindef/a.go": `package p; func B(s []int) error { s = append(s, len(s)); var err error; _ = err.Error(); _ = make([]int, 0); return nil }`,
+
+			"builtinfunc/a.go": `package p; func A(c chan int, m map[int]int, s []int) { defer func() { recover() }(); print(); println(); x := complex(1, 2); _, _ = real(x), imag(x); close(c); delete(m, 0); copy(s, s); panic(nil) }`,
+
+			"ctxcheck/a.go": `package p; import "context"; func Ctx(n int, ctx context.Context) {}`,
+
+			"callhierarchy/a.go": `package p; func A() { B(); B() }; func B() { C() }; func C() {}; func D() { B() }; var _ = B; type T struct{}; func (T) M() { A(); T{}.M() }`,
+
+			"callhierarchy/parallel/a/a.go": `package a; func F() {}`,
+			"callhierarchy/parallel/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/callhierarchy/parallel/a"; func B() { a.F(); a.F() }`,
+			"callhierarchy/parallel/c/c.go": `package c; import "github.com/saibing/bingo/langserver/test/pkg/callhierarchy/parallel/a"; func C() { a.F(); a.F() }`,
+			"callhierarchy/parallel/d/d.go": `package d; import "github.com/saibing/bingo/langserver/test/pkg/callhierarchy/parallel/a"; func D() { a.F(); a.F() }`,
+			"callhierarchy/parallel/e/e.go": `package e; import "github.com/saibing/bingo/langserver/test/pkg/callhierarchy/parallel/a"; func E() { a.F(); a.F() }`,
+			"callhierarchy/parallel/f/f.go": `package f; import "github.com/saibing/bingo/langserver/test/pkg/callhierarchy/parallel/a"; func F() { a.F(); a.F() }`,
+
+			"constexpr/a.go": `package p; const KB = 1 << 10; const N = len("abc"); const Neg = -(1 << 12)`,
+
+			"declaration/a.go": `package p; type I interface{ M() }; type T struct{}; func (T) M() {}; func F(i I) { i.M() }`,
+
 			"detailed/a.go": `package p; type T struct { F string }`,
 
+			"embedded/a.go": `package p; type T struct{}; type S struct{ T }; var _ = S{}.T`,
+			"embedded/b.go": `package p; type Base struct{}; func (*Base) M() {}; type D struct{ *Base }; func F(d D) { d.M(); _ = d.Base }`,
+
 			"exported_on_unexported/a.go": `package p; type t struct { F string }`,
 
 			"gomodule/a.go": `package a; import "github.com/saibing/dep"; var _ = dep.D; var _ = dep.D`,
@@ -22,8 +44,25 @@ var testdata = []packagestest.Module{
 			"goproject/a/a.go": `package a; func A() {}`,
 			"goproject/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/goproject/a"; var _ = a.A`,
 
+			"importslocal/a.go": "package p\n\nimport (\n\t\"github.com/saibing/bingo/langserver/test/pkg/goproject/a\"\n\t\"fmt\"\n\n\t\"os\"\n)\n\nvar _ = a.A\nvar _ = fmt.Println\nvar _ = os.Args\n",
+
 			"goroot/a.go": `package p; import "fmt"; var _ = fmt.Println; var x int`,
 
+			"alias/a.go": `package p; import j "fmt"; var _ = j.Println`,
+
+			"analyses/a.go": `package p; import "fmt"; func Vet() { fmt.Printf("%d", "s") }`,
+			"analyses/b.go": "//bingo:noanalysis\n\npackage p; import \"fmt\"; func NoVet() { fmt.Printf(\"%d\", \"s\") }",
+
+			"alternate/a.go":      `package p; type I interface{ Alternate() }; type T struct{}; func (T) Alternate() {}; type J interface{ Alternates() }; type U struct{}; func (U) Alternates() {}; type V struct{}; func (V) Alternates() {}`,
+			"alternate/a_test.go": `package p`,
+			"alternate/b.go":      `package p`,
+
+			"links/a.go":    `package a; import ("fmt"; "github.com/saibing/bingo/langserver/test/pkg/links/b"); var _, _ = fmt.Sprint, b.B`,
+			"links/b/b.go":  `package b; const B = 1`,
+			"semantic/a.go": `package p; import "fmt"; type T struct{ F int }; type I interface{ M() }; const C = 1; func (t T) M() { fmt.Println(t.F, C, len("")) }`,
+
+			"stdlib/a.go": `package p; import "strings"; var _ = strings.Split`,
+
 			"implementations/i0.go":    `package p; type I0 interface { M0() }`,
 			"implementations/i1.go":    `package p; type I1 interface { M1() }`,
 			"implementations/i2.go":    `package p; type I2 interface { M1(); M2() }`,
@@ -32,6 +71,8 @@ var testdata = []packagestest.Module{
 			"implementations/t1e.go":   `package p; type T1E struct { T1 }; var _ = (T1E{}).M1`,
 			"implementations/t1p.go":   `package p; type T1P struct {}; func (*T1P) M1() {}`,
 			"implementations/p2/p2.go": `package p2; type T2 struct{}; func (T2) M1() {}`,
+			"implementations/w.go":     `package p; type W interface { WriteString(s string) (int, error) }`,
+			"implementations/e.go":     `package p; type E struct{}; func (*E) Error() string { return "" }; type Errorer interface { Error() string }`,
 
 			"lookup/a/a.go": `package a; type A int; func A1() A { var A A = 1; return A }`,
 			"lookup/b/b.go": `package b; import "github.com/saibing/bingo/langserver/test/pkg/lookup/a"; func Dummy() a.A { x := a.A1(); return x }`,
@@ -50,15 +91,41 @@ package main;  func B() { p.A(); B() }`,
 			"subdirectory/a.go":    `package d; func A() { A() }`,
 			"subdirectory/d2/b.go": `package d2; import "github.com/saibing/bingo/langserver/test/pkg/subdirectory"; func B() { d.A(); B() }`,
 
-			"typealias/a.go": `package p; type A struct{ a int }`,
-			"typealias/b.go": `package p; type B = A`,
+			"cursor/a.go": `package p; func Foo() {}; func B() { Foo() }; var x, y = 1, 2; var _ = x+y`,
+
+			"cgo/a.go": "package p\n\n// int add(int a, int b) { return a + b; }\nimport \"C\"\n\nfunc Add(a, b int) int { return int(C.add(C.int(a), C.int(b))) }\n\nfunc B() int { return Add(1, 2) }\n",
+
+			"unicode/a.go": `package p; var s = "😀世界"; func A() { _ = s }; func B() { A() }`,
+			"unicode/b.go": `package p; type Größe struct{}; var g Größe; var _ = g`,
+
+			"structtag/a.go":     "package p; type T struct { A int `json:\"a,omitempty\" xml:\"a\"`; B int }",
+			"examples/a.go":      `package p; func Foo() int { return 1 }; type T struct{}; func (T) M() {}`,
+			"examples/a_test.go": `package p; import "fmt"; func ExampleFoo() { fmt.Println(Foo()) }; func ExampleT_M_value() { T{}.M() }`,
+
+			"methodset/a.go":           `package p; type Base struct{}; func (*Base) M() {}; type T struct{ Base }; func (T) A() {}; func (*T) B(x int) error { return nil }`,
+			"buildtags/foo_linux.go":   `package p; func Foo() string { return "" }`,
+			"buildtags/foo_windows.go": `package p; func Foo() int { return 0 }`,
+			"promoted/a.go":            `package p; type Inner struct{ N int }; func (*Inner) M() {}; type Mid struct{ *Inner }; type X struct{ Mid }; func F(x X) { x.M(); _ = x.N; x.Inner.M() }`,
+			"methodset/b.go":           `package p; type I interface{ N() }`,
+
+			"typealias/a.go":       `package p; type A struct{ a int }`,
+			"typealias/b.go":       `package p; type B = A`,
+			"typealias/c.go":       `package p; import "github.com/saibing/bingo/langserver/test/pkg/typealias/sub"; type C = sub.Concrete; type N = int`,
+			"typealias/sub/sub.go": `package sub; type Concrete struct{ X int }`,
 
 			"unexpected_paths/a.go": `package p; func A() { A() }`,
 
+			"unexported/a.go":      `package p; func helper() int { return 1 }; var _ = helper()`,
+			"unexported/a_test.go": `package p; var _ = helper()`,
+
 			"xreferences/a.go": `package p; import "fmt"; var _ = fmt.Println; var x int`,
 			"xreferences/b.go": `package p; import "fmt"; var _ = fmt.Println; var y int`,
 			"xreferences/c.go": `package p; import "fmt"; var _ = fmt.Println; var z int`,
 
+			"listtests/a.go":      `package p; func TestNotInTestFile() {}`,
+			"listtests/a_test.go": `package p; import "testing"; func TestA(t *testing.T) {}; func Testable() {}; func BenchmarkA(b *testing.B) {}; func Example() {}; func helper() {}`,
+			"listtests/x_test.go": `package p_test; import "testing"; func TestX(t *testing.T) {}`,
+
 			"test/a.go":      `package p; var A int`,
 			"test/a_test.go": `package p; import "testing"; import "github.com/saibing/bingo/langserver/test/pkg/test/b"; var X = b.B; func TestB(t *testing.T) {}`,
 			"test/b/b.go":    `package b; var B int; func C() int { return B };`,
@@ -82,6 +149,45 @@ func A() string {
 	return "test"
 }`,
 
+			"renamepkg/a/a.go": `package a
+
+func A() {}`,
+			"renamepkg/a/a_test.go": `package a_test
+
+import "github.com/saibing/bingo/langserver/test/pkg/renamepkg/a"
+
+var _ = a.A`,
+			"renamepkg/b/b.go": `package b
+
+import "github.com/saibing/bingo/langserver/test/pkg/renamepkg/a"
+
+func B() { a.A(); a.A() }`,
+			"renamepkg/c/c.go": `package c
+
+import x "github.com/saibing/bingo/langserver/test/pkg/renamepkg/a"
+
+var _ = x.A`,
+			"renamepkg/internal/d/d.go": `package d
+
+import "github.com/saibing/bingo/langserver/test/pkg/renamepkg/a"
+
+var _ = a.A`,
+			"renamepkgconflict/a/a.go": `package a
+
+func A() {}`,
+			"renamepkgconflict/b/b.go": `package b
+
+import (
+	"strings"
+
+	"github.com/saibing/bingo/langserver/test/pkg/renamepkgconflict/a"
+)
+
+func B() int {
+	local := 1
+	a.A()
+	return local + len(strings.ToUpper(""))
+}`,
 			"renaming/cgo/a.go": `package p
 /*
 #define _GNU_SOURCE
@@ -155,6 +261,17 @@ func C(x int, y int) int {
 			"signature/c.go": `package p; import "fmt"; func test1() { fmt.Printf("%s",)}`,
 			"signature/d.go": `package p; import "fmt"; func test2() { fmt.Printf()}`,
 			"signature/e.go": `package p; import "fmt"; func test3() { append()}`,
+			"signature/f.go": `package p
+
+// F formats v.
+//
+// width: the minimum number of characters.
+func F(width int, v *Value, n int) {}
+
+// Value is a value to format.
+type Value int
+
+func _() { F(1, nil, 2) }`,
 
 			"issue/223.go": `package main
 
@@ -235,7 +352,38 @@ type T2 struct {
 type XYZ struct {}`,
 			"different/bcd.go": `package a
 func (x XYZ) ABC() {}`,
+			"different/cde.go": `package a
+func (x *XYZ) DEF() {}`,
+
+			"alias/a/a.go": `package a
+
+type A struct{}
 
+func New() *A { return nil }`,
+			"alias/b/b.go": `package b
+
+import "github.com/saibing/bingo/langserver/test/pkg/alias/a"
+
+type B = a.A
+
+func Use(b *B) {}
+
+type Other struct{}`,
+			"alias/c/c.go": `package c
+
+import "github.com/saibing/bingo/langserver/test/pkg/alias/b"
+
+type C = b.B
+
+func Use(c *C) {}`,
+			"alias/d/d.go": `package d
+
+import "github.com/saibing/bingo/langserver/test/pkg/alias/b"
+import "github.com/saibing/bingo/langserver/test/pkg/alias/c"
+
+var x, y = b.B{}, c.C{}
+
+var _ b.Other`,
 			"completion/a.go": `package p
 
 import "strings"
@@ -249,6 +397,415 @@ const s1 = 42
 
 var s3 int
 var s4 func()`,
+			"resolve/a.go": `package p
+
+// Limit is the limit of T.
+const Limit = 10
+
+// T is a type.
+type T struct {
+	// F is a field.
+	F int
+}
+
+// M is a method.
+func (T) M() {}
+
+var _ = Lim
+var _ = T{}.F`,
+			"complit/a.go": `package p
+
+import "github.com/saibing/bingo/langserver/test/pkg/complit/q"
+
+type Config struct {
+	Timeout int
+	Title   string
+	Name    string
+}
+
+var _ = Config{}
+var _ = Config{Name: "", }
+var _ = Config{Title: ""}
+var _ = []*Config{{}}
+var _ = q.Options{}`,
+			"complit/q/q.go": `package q; type Options struct { Name string; secret int }`,
+			"apisurface/a.go": `package p
+
+import "io"
+
+const Max = 10
+
+var Reader io.Reader
+
+type T struct {
+	Name string
+	size int
+	io.Writer
+}
+
+func (T) Get() string { return "" }
+
+func (*T) set() {}
+
+type I interface {
+	Do(n int) error
+}
+
+type A = T
+
+func New() *T { return nil }
+
+func hidden() {}`,
+			"varorigin/a.go": `package p
+
+import "io"
+
+type W struct{}
+
+func (*W) Write(p []byte) (int, error) { return len(p), nil }
+
+func f() (*W, error) { return nil, nil }
+
+func g() {
+	var a io.Writer = &W{}
+	d, err := f()
+	var e io.Writer
+	_, _, _, _ = a, d, err, e
+}`,
+			"assert/a.go": `package p
+
+type I interface{ M() }
+
+type T struct{}
+
+func (T) M() {}
+
+func f(x interface{}) {
+	v, ok := x.(I)
+	w := x.(*T)
+	_, _, _ = v, ok, w
+}`,
+			"typeswitch/a.go": `package p
+
+import "fmt"
+
+func f(x interface{}) {
+	switch v := x.(type) {
+	case int:
+		_ = v
+	case string, error:
+		_ = v
+	case fmt.Stringer:
+		_ = v
+	default:
+		_ = v
+	}
+}`,
+			"unused/a.go": `package unused
+
+import "fmt"
+
+type Used struct{}
+
+func (Used) Unused() {}
+
+func (Used) String() string { return fmt.Sprint() }
+
+type Unused int
+
+func UsedFunc() Used { return Used{} }
+
+func UnusedFunc() {}
+
+func Tested() {}
+
+type Fields struct{ Shadowed int }
+
+func Shadowed() {}
+
+func Uses() int {
+	Shadowed := 0
+	return Shadowed
+}`,
+			"unused/a_test.go": `package unused
+
+import "testing"
+
+func TestTested(t *testing.T) { Tested() }`,
+			"unused/b/b.go": `package b
+
+import "github.com/saibing/bingo/langserver/test/pkg/unused"
+
+var _ = unused.UsedFunc
+
+var _ = unused.Fields{}.Shadowed + unused.Uses()`,
+			"typedef/a.go": `package p
+
+type T struct{}
+
+type U struct{}
+
+func F() *T { return nil }
+
+func G() (T, error, *U) { return T{}, nil, nil }
+
+var _ = F()
+
+var _, _, _ = G()
+
+var _ = T(struct{}{})`,
+			"malformed/a.go": `package p`,
+			"decl/a.go": `package p
+
+type T struct{ next *T }
+
+type E struct{ T }
+
+func (t *T) M() { t.next.M() }
+
+func F(e E) { e.T.M() }`,
+			"sizes/a.go":     `package p; import "unsafe"; const S = unsafe.Sizeof(uintptr(0))`,
+			"sizes/b_386.go": `package p; import "unsafe"; const P = unsafe.Sizeof(uintptr(0))`,
+			"packagedoc/a.go": `package p
+
+import "io"
+
+// Max is the maximum. It is large.
+const Max = 10
+
+const (
+	// A is a.
+	A = iota
+	b
+)
+
+// Reader reads.
+var Reader io.Reader
+
+// T is a thing.
+type T struct{ io.Writer }
+
+// Get gets. More details.
+func (*T) Get() string { return "" }
+
+func (T) set() {}
+
+// New returns a T.
+func New() *T { return nil }`,
+			"packagedoc/overview/a.go": `// Package overview is documented.
+//
+// It has a second paragraph.
+package overview`,
+			"packagedoc/a_test.go": `package p
+
+// Helper is only in the tests.
+func Helper() {}`,
+			"dotimport/a.go": `package p
+
+import (
+	. "github.com/saibing/bingo/langserver/test/pkg/dotimport/q"
+	_ "github.com/saibing/bingo/langserver/test/pkg/dotimport/r"
+)
+
+var _ = Q + Max`,
+			"dotimport/q/q.go": `package q; const Q = 1; const Max = 2`,
+			"dotimport/r/r.go": `package r`,
+			"ontype/a.go": `package p
+
+func f(x int) {
+	if x > 0 {
+	x++
+	}
+}
+
+type T struct{ A, Bcd int }
+
+var t = T{
+	A: 1,
+	Bcd: 2,
+	
+}`,
+			"noresult/a.go": `package p
+
+// F returns a function.
+func F(int) func() { return nil }
+
+func G() {
+	F(1)()
+	_ = int(2)
+
+	_ = true
+}`,
+			"bitflags/a.go": `package p
+
+type Mode int
+
+const (
+	Read Mode = 1 << iota
+	Write
+	Exec
+)
+
+const Other = 3
+
+const (
+	ReadWrite = Read | Write
+	All       = ReadWrite | Exec
+)`,
+			"convert/a.go": `package p
+
+var a = 1 + 2
+
+const b = "b"
+
+var c = make([]int, 0)
+
+const d = 4
+
+var _ [d]int
+
+var E = 5
+
+const F = 6
+
+var i interface{} = 1
+
+type counter int
+
+func (c *counter) inc() { *c++ }
+
+var n counter = 1
+
+var _ = func() { n.inc() }
+
+const g = 1.0
+
+var _ int = g
+
+const h = 2
+
+var _ = [...]string{h: "h"}
+
+func f() {
+	const local = 1
+}`,
+			"fillstruct/a.go": `package p
+
+import u "github.com/saibing/bingo/langserver/test/pkg/fillstruct/t"
+
+type S struct {
+	A int
+	B string
+	C bool
+}
+
+var _ = S{}
+
+var _ = S{A: 1}
+
+var _ = S{
+	A: 1,
+}
+
+var _ = S{1, "b", true}
+
+var _ = u.T{}`,
+			"fillstruct/t/t.go": `package t
+
+import "time"
+
+type T struct {
+	Name   string
+	hidden int
+	When   time.Time
+	Inner  Inner
+}
+
+type Inner struct{ N int }`,
+			"printf/a.go": `package p
+
+import "fmt"
+
+func logf(format string, args ...interface{}) {}
+
+func f(n int, s string) {
+	fmt.Printf("%d %-8s %*d %[1]x %v\n", n, s, 4, n)
+	_ = fmt.Sprintf("%s %d 100%%", s)
+	logf("%q", s)
+}`,
+			"receiver/a.go": `package p
+
+type T struct{ n int }
+
+func (t T) Value() int { return t.n }
+
+func (t *T) Pointer() int { return t.n }
+
+func f() T { return T{} }
+
+var (
+	m = map[int]T{}
+	v T
+	_ = T{}.Value() + f().Value() + m[0].Value() + v.Value() + (&v).Value()
+	_ = T.Value
+)`,
+			"unusedimport/a.go": `package p
+
+import (
+	f "fmt"
+	"os"
+	"strings"
+)
+
+var _ = os.Args
+var _ = strings.ToUpper`,
+			"completion/d.go": `package p
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Sprint
+var _ = strings.Tit`,
+			"completion/e.go": `package p
+
+import (
+	"fmt"
+
+	"github.com/saibing/bingo/langserver/test/pkg/unimported/lib"
+)
+
+var _ = fmt.Sprint
+var _ = lib.Unimported
+var _ = strings.Tit
+var _ = errors.Wra`,
+			"expected/a.go": `package p
+
+import "errors"
+
+type Celsius float64
+
+var (
+	ratio   float64
+	reading Celsius
+	failure = errors.New("failure")
+	zero    int
+	zone    string
+)
+
+func fahrenheit() float64 { return 0 }
+
+func describe(c Celsius) {}
+
+func title() string {
+	return 
+}
+
+func temperature() (Celsius, error) {
+	var v string = z
+	describe(r)
+	return reading, f
+}`,
 			"completion/b.go": `package p; import "fmt"; var _ = fmt.Printl`,
 			"completion/c.go": `package p;
 
@@ -260,6 +817,22 @@ func main() {
 	fmt.Println("hahah")
 	defer fmt.
 }`,
+
+			"unimported/a.go": `package unimported
+
+import "github.com/saibing/bingo/langserver/test/pkg/unimported/errors"
+
+var _ = errors.Wrap
+var _ = Unimpor
+var _ = Hidde
+var _ = Mainl
+var _ = TestOnl
+var _ = New`,
+			"unimported/errors/errors.go":            `package errors; func Wrap(err error) error { return err }`,
+			"unimported/lib/lib.go":                  `package lib; func Unimported() {}`,
+			"unimported/lib/lib_test.go":             `package lib; func TestOnly() {}`,
+			"unimported/x/internal/hidden/hidden.go": `package hidden; func Hidden() {}`,
+			"unimported/cmd/main.go":                 `package main; func Mainly() {}; func main() {}`,
 		},
 	},
 }
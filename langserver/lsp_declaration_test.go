@@ -0,0 +1,74 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var declarationContext = newTestContext(cache.None)
+
+func TestDeclaration(t *testing.T) {
+	t.Parallel()
+
+	declarationContext.setup(t)
+
+	test := func(t *testing.T, input string, output string) {
+		testDeclaration(t, &definitionTestCase{input: input, output: output})
+	}
+
+	t.Run("basic declaration", func(t *testing.T) {
+		test(t, "basic/a.go:1:23", "basic/a.go:1:17-1:18")
+		test(t, "basic/b.go:1:23", "basic/a.go:1:17-1:18")
+	})
+
+	t.Run("interface method declaration", func(t *testing.T) {
+		test(t, "declaration/a.go:1:87", "declaration/a.go:1:30-1:31")
+	})
+
+	t.Run("embedded field declaration", func(t *testing.T) {
+		test(t, "embedded/a.go:1:61", "embedded/a.go:1:44-1:45")
+	})
+}
+
+func testDeclaration(tb testing.TB, c *definitionTestCase) {
+	tbRun(tb, fmt.Sprintf("declaration-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
+		dir, err := filepath.Abs(declarationContext.root())
+		if err != nil {
+			log.Fatal("testDeclaration", err)
+		}
+		doDeclarationTest(t, declarationContext.ctx, declarationContext.conn, util.PathToURI(dir), c.input, c.output)
+	})
+}
+
+func doDeclarationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, want string) {
+	file, line, char, err := parsePos(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res locations
+	err = c.Call(ctx, "textDocument/declaration", lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(rootURI, file)},
+		Position:     lsp.Position{Line: line, Character: char},
+	}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("got %d locations, want 1", len(res))
+	}
+	loc := res[0]
+	declaration := fmt.Sprintf("%s:%d:%d-%d:%d", filepath.ToSlash(util.UriToRealPath(loc.URI)), loc.Range.Start.Line+1, loc.Range.Start.Character+1, loc.Range.End.Line+1, loc.Range.End.Character+1)
+	want = makePath(declarationContext.root(), want)
+	if declaration != want {
+		t.Errorf("\n%s\ngot %q, \nwant %q", pos, declaration, want)
+	}
+}
@@ -28,6 +28,22 @@ func TestDefinition(t *testing.T) {
 		testDefinition(t, &definitionTestCase{input: input, output: output})
 	}
 
+	t.Run("dot and blank imports", func(t *testing.T) {
+		test(t, "dotimport/a.go:8:9", "dotimport/q/q.go:1:18-1:19")
+		test(t, "dotimport/a.go:8:13", "dotimport/q/q.go:1:31-1:34")
+		test(t, "dotimport/a.go:4:2", "dotimport/q/q.go:1:9-1:10")
+		test(t, "dotimport/a.go:5:2", "")
+		test(t, "dotimport/a.go:8:5", "")
+	})
+
+	t.Run("format directive definition", func(t *testing.T) {
+		test(t, "printf/a.go:8:23", "printf/a.go:8:48-8:49")
+		test(t, "printf/a.go:8:29", "printf/a.go:8:39-8:40")
+		test(t, "printf/a.go:9:22", "")
+		// logf is not a printf-like function of this context.
+		test(t, "printf/a.go:10:8", "")
+	})
+
 	t.Run("basic definition", func(t *testing.T) {
 		test(t, "basic/a.go:1:17", "basic/a.go:1:17-1:18")
 		test(t, "basic/a.go:1:23", "basic/a.go:1:17-1:18")
@@ -35,8 +51,60 @@ func TestDefinition(t *testing.T) {
 		test(t, "basic/b.go:1:23", "basic/a.go:1:17-1:18")
 	})
 
+	t.Run("cursor position definition", func(t *testing.T) {
+		// At the start, in the middle and just past the end of Foo.
+		test(t, "cursor/a.go:1:38", "cursor/a.go:1:17-1:20")
+		test(t, "cursor/a.go:1:39", "cursor/a.go:1:17-1:20")
+		test(t, "cursor/a.go:1:41", "cursor/a.go:1:17-1:20")
+		// Just past the end of x, which is also the start of the operator.
+		test(t, "cursor/a.go:1:73", "cursor/a.go:1:51-1:52")
+		test(t, "cursor/a.go:1:74", "cursor/a.go:1:54-1:55")
+	})
+
+	t.Run("cgo definition", func(t *testing.T) {
+		test(t, "cgo/a.go:8:23", "cgo/a.go:6:6-6:9")
+		test(t, "cgo/a.go:6:6", "cgo/a.go:6:6-6:9")
+		test(t, "cgo/a.go:6:49", "cgo/a.go:6:10-6:11")
+	})
+
+	t.Run("unicode definition", func(t *testing.T) {
+		test(t, "unicode/a.go:1:59", "unicode/a.go:1:33-1:34")
+		test(t, "unicode/a.go:1:43", "unicode/a.go:1:16-1:17")
+		test(t, "unicode/b.go:1:39", "unicode/b.go:1:17-1:22")
+	})
+
+	t.Run("no definition", func(t *testing.T) {
+		test(t, "noresult/a.go:3:5", "")
+		test(t, "noresult/a.go:8:2", "")
+		test(t, "noresult/a.go:9:1", "")
+	})
+
 	t.Run("builtin definition", func(t *testing.T) {
 		test(t, "builtin/a.go:1:26", "goroot/src/builtin/builtin.go:257:6-257:13")
+		test(t, "builtindef/a.go:1:28", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:40", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:50", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:67", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:82", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:95", "goroot/src/builtin/builtin.go")
+		test(t, "builtindef/a.go:1:118", "goroot/src/builtin/builtin.go")
+	})
+
+	t.Run("builtin function definition", func(t *testing.T) {
+		for _, pos := range []string{
+			"builtinfunc/a.go:1:72",  // recover
+			"builtinfunc/a.go:1:87",  // print
+			"builtinfunc/a.go:1:96",  // println
+			"builtinfunc/a.go:1:112", // complex
+			"builtinfunc/a.go:1:134", // real
+			"builtinfunc/a.go:1:143", // imag
+			"builtinfunc/a.go:1:152", // close
+			"builtinfunc/a.go:1:162", // delete
+			"builtinfunc/a.go:1:176", // copy
+			"builtinfunc/a.go:1:188", // panic
+		} {
+			test(t, pos, "goroot/src/builtin/builtin.go")
+		}
 	})
 
 	t.Run("subdirectory definition", func(t *testing.T) {
@@ -52,10 +120,28 @@ func TestDefinition(t *testing.T) {
 		test(t, "multiple/a.go:1:23", "multiple/a.go:1:17-1:18")
 	})
 
+	t.Run("type assertion definition", func(t *testing.T) {
+		test(t, "assert/a.go:10:2", "assert/a.go:10:2-10:3")
+		test(t, "assert/a.go:10:14", "assert/a.go:3:6-3:7")
+		test(t, "assert/a.go:11:10", "assert/a.go:5:6-5:7")
+		test(t, "assert/a.go:11:11", "assert/a.go:5:6-5:7")
+	})
+
+	t.Run("embedded field", func(t *testing.T) {
+		test(t, "embedded/a.go:1:61", "embedded/a.go:1:44-1:45, embedded/a.go:1:17-1:18")
+		test(t, "embedded/b.go:1:93", "embedded/b.go:1:45-1:46")
+		test(t, "embedded/b.go:1:104", "embedded/b.go:1:69-1:73, embedded/b.go:1:17-1:21")
+	})
+
 	t.Run("go root", func(t *testing.T) {
 		test(t, "goroot/a.go:1:40", "goroot/src/fmt/print.go:274:6-274:13")
 	})
 
+	t.Run("package alias", func(t *testing.T) {
+		test(t, "alias/a.go:1:38", "goroot/src/fmt/print.go:274:6-274:13")
+		test(t, "alias/a.go:1:36", "goroot/src/fmt/doc.go")
+	})
+
 	t.Run("go project", func(t *testing.T) {
 		test(t, "goproject/a/a.go:1:17", "goproject/a/a.go:1:17-1:18")
 		test(t, "goproject/b/b.go:1:89", "goproject/a/a.go:1:17-1:18")
@@ -104,6 +190,22 @@ func doDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	if err != nil {
 		t.Fatal(err)
 	}
+	// The definition may have several locations, eg. both the embedding
+	// site and the type of an embedded field.
+	definitions, wants := strings.Split(definition, ", "), strings.Split(want, ", ")
+	if len(definitions) != len(wants) {
+		t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
+		return
+	}
+	for i := range wants {
+		definition, want := normalizeDefinition(definitions[i], wants[i], trimPrefix)
+		if definition != want {
+			t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
+		}
+	}
+}
+
+func normalizeDefinition(definition, want, trimPrefix string) (string, string) {
 	if definition != "" {
 		definition = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(definition)))
 		if trimPrefix != "" {
@@ -126,10 +228,7 @@ func doDefinitionTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	} else if want != "" {
 		want = makePath(definitionContext.root(), want)
 	}
-
-	if definition != want {
-		t.Errorf("\n%s\ngot %q, \nwant %q", pos, definition, want)
-	}
+	return definition, want
 }
 
 func callDefinition(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) (string, error) {
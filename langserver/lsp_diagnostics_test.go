@@ -0,0 +1,111 @@
+package langserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var (
+	analysesContext     = newTestContext(cache.Always)
+	ctxCheckContext     = newTestContext(cache.Always)
+	unusedImportContext = newTestContext(cache.Always)
+)
+
+// TestDiagnosticsAnalyses tests that the analysis diagnostics are published
+// for the files without a no-analysis directive.
+func TestDiagnosticsAnalyses(t *testing.T) {
+	t.Parallel()
+
+	analyses := true
+	got := testPublishedDiagnostics(t, analysesContext, &InitializationOptions{DiagnosticsAnalyses: &analyses}, "analyses/a.go", 2)
+
+	root := util.PathToURI(makePath(analysesContext.root()))
+	if diags := got[uriJoin(root, "analyses/a.go")]; len(diags) != 1 || diags[0].Source != "printf" || diags[0].Severity != lsp.Warning {
+		t.Errorf("got %+v for a.go, want a printf warning", diags)
+	}
+	if diags := got[uriJoin(root, "analyses/b.go")]; len(diags) != 0 {
+		t.Errorf("got %+v for b.go, want none", diags)
+	}
+}
+
+// TestDiagnosticsCtxCheck tests that the ctxcheck diagnostics are published
+// when the CtxCheck option is set.
+func TestDiagnosticsCtxCheck(t *testing.T) {
+	t.Parallel()
+
+	ctxCheck := true
+	got := testPublishedDiagnostics(t, ctxCheckContext, &InitializationOptions{CtxCheck: &ctxCheck}, "ctxcheck/a.go", 1)
+
+	root := util.PathToURI(makePath(ctxCheckContext.root()))
+	diags := got[uriJoin(root, "ctxcheck/a.go")]
+	if len(diags) != 1 || diags[0].Source != "ctxcheck" || diags[0].Range.Start != (lsp.Position{Line: 0, Character: 45}) {
+		t.Errorf("got %+v, want a ctxcheck warning at 0:45", diags)
+	}
+}
+
+// TestDiagnosticsUnusedImport tests that the diagnostic of an unused import
+// covers its whole spec.
+func TestDiagnosticsUnusedImport(t *testing.T) {
+	t.Parallel()
+
+	got := testPublishedDiagnostics(t, unusedImportContext, &InitializationOptions{}, "unusedimport/a.go", 1)
+
+	root := util.PathToURI(makePath(unusedImportContext.root()))
+	diags := got[uriJoin(root, "unusedimport/a.go")]
+	want := lsp.Range{Start: lsp.Position{Line: 3, Character: 1}, End: lsp.Position{Line: 3, Character: 8}}
+	if len(diags) != 1 || !isUnusedImportError(diags[0].Message) || diags[0].Range != want {
+		t.Errorf("got %+v, want an unused import error at %s", diags, want)
+	}
+}
+
+// testPublishedDiagnostics opens file in a server set up with the instant
+// diagnostics and options, and returns the diagnostics of the first n files
+// published.
+func testPublishedDiagnostics(t *testing.T, tx *TestContext, options *InitializationOptions, file string, n int) map[lsp.DocumentURI][]lsp.Diagnostic {
+	t.Helper()
+
+	published := make(chan lsp.PublishDiagnosticsParams, 10)
+	tx.notify = func(req *jsonrpc2.Request) {
+		var params lsp.PublishDiagnosticsParams
+		if req.Method != "textDocument/publishDiagnostics" || req.Params == nil || json.Unmarshal(*req.Params, &params) != nil {
+			return
+		}
+		published <- params
+	}
+	style := string(instantDiagnostics)
+	options.DiagnosticsStyle = &style
+	tx.initOptions = options
+	tx.setup(t)
+
+	text, err := ioutil.ReadFile(filepath.Join(tx.root(), filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := util.PathToURI(makePath(tx.root()))
+	err = tx.conn.Notify(tx.ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uriJoin(root, file), LanguageID: "go", Text: string(text)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[lsp.DocumentURI][]lsp.Diagnostic)
+	timeout := time.After(30 * time.Second)
+	for len(got) < n {
+		select {
+		case params := <-published:
+			got[params.URI] = params.Diagnostics
+		case <-timeout:
+			t.Fatalf("got diagnostics for %d files, want %d", len(got), n)
+		}
+	}
+	return got
+}
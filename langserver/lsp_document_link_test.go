@@ -0,0 +1,87 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var documentLinkContext = newTestContext(cache.Always)
+
+func TestDocumentLink(t *testing.T) {
+	t.Parallel()
+
+	documentLinkContext.setup(t)
+
+	dir, err := filepath.Abs(documentLinkContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.ToSlash(dir) + "/"
+
+	// The standard library packages are not loaded in every environment,
+	// only the link of the project package is checked.
+	var got []string
+	for _, link := range callDocumentLink(t, documentLinkContext.ctx, documentLinkContext.conn, uriJoin(util.PathToURI(dir), "links/a.go")) {
+		target := filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(link.Target)))
+		if strings.HasPrefix(target, root) {
+			got = append(got, formatDocumentLink(link, strings.TrimPrefix(target, root)))
+		}
+	}
+	want := []string{"1:27-1:81 links/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+	}
+}
+
+var documentLinkGodocContext = newTestContext(cache.None)
+
+func TestDocumentLinkGodoc(t *testing.T) {
+	t.Parallel()
+
+	godocURL := "https://pkg.go.dev/"
+	documentLinkGodocContext.initOptions = &InitializationOptions{GodocURL: &godocURL}
+	documentLinkGodocContext.setup(t)
+
+	dir, err := filepath.Abs(documentLinkGodocContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, link := range callDocumentLink(t, documentLinkGodocContext.ctx, documentLinkGodocContext.conn, uriJoin(util.PathToURI(dir), "links/a.go")) {
+		got = append(got, formatDocumentLink(link, link.Target))
+	}
+	want := []string{
+		"1:20-1:25 https://pkg.go.dev/fmt",
+		"1:27-1:81 https://pkg.go.dev/github.com/saibing/bingo/langserver/test/pkg/links/b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+	}
+}
+
+func callDocumentLink(t *testing.T, ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI) []DocumentLink {
+	var res []DocumentLink
+	err := c.Call(ctx, "textDocument/documentLink", DocumentLinkParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// formatDocumentLink returns link as "line:col-line:col target", with one
+// based positions.
+func formatDocumentLink(link DocumentLink, target string) string {
+	return fmt.Sprintf("%d:%d-%d:%d %s", link.Range.Start.Line+1, link.Range.Start.Character+1, link.Range.End.Line+1, link.Range.End.Character+1, target)
+}
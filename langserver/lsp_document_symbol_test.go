@@ -113,6 +113,7 @@ func TestDocumentSymbol(t *testing.T) {
 		test(t, map[string][]string{
 			"different/abc.go": {"different/abc.go:class:XYZ:2:6"},
 			"different/bcd.go": {"different/bcd.go:method:XYZ.ABC:2:14"},
+			"different/cde.go": {"different/cde.go:method:(*XYZ).DEF:2:15"},
 		})
 	})
 }
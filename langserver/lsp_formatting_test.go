@@ -3,9 +3,11 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -13,10 +15,14 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
-var formatContext = newTestContext(cache.None)
+var (
+	formatContext       = newTestContext(cache.None)
+	importsLocalContext = newTestContext(cache.None)
+)
 
 func TestFormatting(t *testing.T) {
 	t.Parallel()
@@ -32,6 +38,111 @@ func TestFormatting(t *testing.T) {
 			"0:0-1:0": "package p\n\nfunc A() { A() }\n",
 		})
 	})
+
+	t.Run("on type", func(t *testing.T) {
+		testOnTypeFormatting(t, "ontype/a.go:6:3", "}", map[string]string{
+			"4:0-5:0": "\t\tx++\n",
+		})
+		testOnTypeFormatting(t, "ontype/a.go:14:2", "\n", map[string]string{
+			"11:0-12:0": "\tA:   1,\n",
+		})
+		testOnTypeFormatting(t, "ontype/a.go:15:2", "}", map[string]string{
+			"11:0-12:0": "\tA:   1,\n",
+			"13:0-14:0": "",
+		})
+		testOnTypeFormatting(t, "ontype/a.go:5:5", "\n", map[string]string{})
+	})
+}
+
+// TestImportsLocalPrefix tests that the organized imports are regrouped into
+// the standard library, third-party and local blocks, across the blocks of
+// the file, when ImportsLocalPrefix is set.
+func TestImportsLocalPrefix(t *testing.T) {
+	t.Parallel()
+
+	localPrefix := rootImportPath
+	importsLocalContext.initOptions = &InitializationOptions{ImportsLocalPrefix: &localPrefix}
+	importsLocalContext.setup(t)
+
+	const file = "importslocal/a.go"
+	content, err := ioutil.ReadFile(filepath.Join(importsLocalContext.root(), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(makePath(importsLocalContext.root())), file)
+
+	var actions []protocol.CodeAction
+	err = importsLocalContext.conn.Call(importsLocalContext.ctx, "textDocument/codeAction", lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	}, &actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var edits []lsp.TextEdit
+	for _, action := range actions {
+		if action.Kind == protocol.SourceOrganizeImports {
+			edits = action.Edit.Changes[string(uri)]
+		}
+	}
+
+	want := "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"" + rootImportPath + "/goproject/a\"\n)\n\nvar _ = a.A\nvar _ = fmt.Println\nvar _ = os.Args\n"
+	if got := applyTextEdits(content, edits); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// applyTextEdits returns content with the edits, whose ranges are in content,
+// applied.
+func applyTextEdits(content []byte, edits []lsp.TextEdit) string {
+	type offsetEdit struct {
+		start, end int
+		text       string
+	}
+	var offsetEdits []offsetEdit
+	for _, edit := range edits {
+		start := bytesOffset(content, edit.Range.Start, positionEncodingUTF16)
+		end := bytesOffset(content, edit.Range.End, positionEncodingUTF16)
+		offsetEdits = append(offsetEdits, offsetEdit{start, end, edit.NewText})
+	}
+	sort.SliceStable(offsetEdits, func(i, j int) bool {
+		return offsetEdits[i].start < offsetEdits[j].start
+	})
+
+	var buf strings.Builder
+	last := 0
+	for _, edit := range offsetEdits {
+		buf.Write(content[last:edit.start])
+		buf.WriteString(edit.text)
+		last = edit.end
+	}
+	buf.Write(content[last:])
+	return buf.String()
+}
+
+func testOnTypeFormatting(t *testing.T, pos, ch string, want map[string]string) {
+	t.Run(fmt.Sprintf("on-type-formatting-%s", strings.Replace(pos, "/", "-", -1)), func(t *testing.T) {
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(util.PathToURI(formatContext.root()), file)
+		var edits []lsp.TextEdit
+		err = formatContext.conn.Call(formatContext.ctx, "textDocument/onTypeFormatting", lsp.DocumentOnTypeFormattingParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: line, Character: char},
+			Ch:           ch,
+		}, &edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, edit := range edits {
+			got[edit.Range.String()] = edit.NewText
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
 }
 
 type formattingTestCase struct {
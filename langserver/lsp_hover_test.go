@@ -20,6 +20,11 @@ var hoverContext = newTestContext(cache.Ondemand)
 func TestHover(t *testing.T) {
 	t.Parallel()
 
+	hoverBitFlags := true
+	hoverContext.initOptions = &InitializationOptions{
+		HoverBitFlags: &hoverBitFlags,
+		PrintfFuncs:   []string{"github.com/saibing/bingo/langserver/test/pkg/printf.logf"},
+	}
 	hoverContext.setup(t)
 
 	test := func(t *testing.T, input string, output string) {
@@ -27,6 +32,17 @@ func TestHover(t *testing.T) {
 		testHover(t, &hoverTestCase{input: input, output: output})
 	}
 
+	t.Run("format directive hover", func(t *testing.T) {
+		test(t, "printf/a.go:8:14", "n int; `%d` formats argument 1")
+		test(t, "printf/a.go:8:19", "s string; `%-8s` formats argument 2")
+		test(t, "printf/a.go:8:23", "n int; `%*d` formats argument 4")
+		test(t, "printf/a.go:8:29", "n int; `%[1]x` formats argument 1")
+		test(t, "printf/a.go:8:33", "s string; `%v` formats argument 2")
+		test(t, "printf/a.go:9:22", "`%d` has no argument 2 in the call of fmt.Sprintf")
+		test(t, "printf/a.go:9:28", "")
+		test(t, "printf/a.go:10:8", "s string; `%q` formats argument 1")
+	})
+
 	t.Run("basic hover", func(t *testing.T) {
 		test(t, "basic/a.go:1:9", "package p")
 		test(t, "basic/a.go:1:17", "func A()")
@@ -35,10 +51,21 @@ func TestHover(t *testing.T) {
 		test(t, "basic/b.go:1:23", "func A()")
 	})
 
+	t.Run("unicode hover", func(t *testing.T) {
+		test(t, "unicode/a.go:1:59", "func A()")
+		test(t, "unicode/a.go:1:33", "func A()")
+	})
+
 	t.Run("builtin hover", func(t *testing.T) {
 		test(t, "builtin/a.go:1:26", "func println(args ...Type); The println built-in function formats its arguments in an implementation-specific way and writes the result to standard error. Spaces are always added between arguments and a newline is appended. Println is useful for bootstrapping and debugging; it is not guaranteed to stay in the language. \n\n")
 	})
 
+	t.Run("builtin function hover", func(t *testing.T) {
+		test(t, "builtinfunc/a.go:1:87", "func print(args ...Type); The print built-in function formats its arguments in an implementation-specific way and writes the result to standard error. Print is useful for bootstrapping and debugging; it is not guaranteed to stay in the language. \n\n")
+		test(t, "builtinfunc/a.go:1:134", "func real(c ComplexType) FloatType; The real built-in function returns the real part of the complex number c. The return value will be floating point type corresponding to the type of c. \n\n")
+		test(t, "builtinfunc/a.go:1:143", "func imag(c ComplexType) FloatType; The imag built-in function returns the imaginary part of the complex number c. The return value will be floating point type corresponding to the type of c. \n\n")
+	})
+
 	t.Run("detailed hover", func(t *testing.T) {
 		test(t, "detailed/a.go:1:28", "struct field F string")
 		test(t, "detailed/a.go:1:17", `type T struct; struct {
@@ -47,17 +74,17 @@ func TestHover(t *testing.T) {
 	})
 
 	t.Run("xtest hover", func(t *testing.T) {
-		test(t, "xtest/a.go:1:16", "var A int")
+		test(t, "xtest/a.go:1:16", "var A int; // type declared")
 		test(t, "xtest/x_test.go:1:40", "package p")
-		test(t, "xtest/x_test.go:1:82", "var X int")
-		test(t, "xtest/x_test.go:1:88", "var A int")
-		test(t, "xtest/a_test.go:1:16", "var X int")
-		test(t, "xtest/a_test.go:1:20", "var A int")
+		test(t, "xtest/x_test.go:1:82", "var X int; // type inferred from the value")
+		test(t, "xtest/x_test.go:1:88", "var A int; // type declared")
+		test(t, "xtest/a_test.go:1:16", "var X int; // type inferred from the value")
+		test(t, "xtest/a_test.go:1:20", "var A int; // type declared")
 	})
 
 	t.Run("test hover", func(t *testing.T) {
-		test(t, "test/a_test.go:1:96", "var X int")
-		test(t, "test/a_test.go:1:102", "var B int")
+		test(t, "test/a_test.go:1:96", "var X int; // type inferred from the value")
+		test(t, "test/a_test.go:1:102", "var B int; // type declared")
 	})
 
 	t.Run("subdirectory hover", func(t *testing.T) {
@@ -74,7 +101,14 @@ func TestHover(t *testing.T) {
 	})
 
 	t.Run("go root", func(t *testing.T) {
-		test(t, "goroot/a.go:1:40", "func Println(a ...interface{}) (n int, err error); Println formats using the default formats for its operands and writes to standard output. Spaces are always added between operands and a newline is appended. It returns the number of bytes written and any write error encountered. \n\n")
+		test(t, "goroot/a.go:1:40", "func Println(a ...interface{}) (n int, err error); Println formats using the default formats for its operands and writes to standard output. Spaces are always added between operands and a newline is appended. It returns the number of bytes written and any write error encountered. \n\n; "+
+			"const name, age = \"Kim\", 22\nfmt.Println(name, \"is\", age, \"years old.\")\n\n// Output:\n// Kim is 22 years old.")
+	})
+
+	t.Run("stdlib hover in module mode", func(t *testing.T) {
+		test(t, "stdlib/a.go:1:46", "func Split(s string, sep string) []string; Split slices s into all substrings separated by sep and returns a slice of the substrings between those separators. \n\nIf s does not contain sep and sep is not empty, Split returns a slice of length 1 whose only element is s. \n\nIf sep is empty, Split splits after each UTF-8 sequence. If both s and sep are empty, Split returns an empty slice. \n\nIt is equivalent to SplitN with a count of -1. \n\n; "+
+			"fmt.Printf(\"%q\\n\", strings.Split(\"a,b,c\", \",\"))\nfmt.Printf(\"%q\\n\", strings.Split(\"a man a plan a canal panama\", \"a \"))\nfmt.Printf(\"%q\\n\", strings.Split(\" xyz \", \"\"))\nfmt.Printf(\"%q\\n\", strings.Split(\"\", \"Bernardo O'Higgins\"))\n\n"+
+			"// Output:\n// [\"a\" \"b\" \"c\"]\n// [\"\" \"man \" \"plan \" \"canal panama\"]\n// [\" \" \"x\" \"y\" \"z\" \" \"]\n// [\"\"]")
 	})
 
 	t.Run("go project", func(t *testing.T) {
@@ -92,15 +126,15 @@ func TestHover(t *testing.T) {
 	t.Run("hover docs", func(t *testing.T) {
 		test(t, "docs/a.go:7:9", "package p; Package p is a package with lots of great things. \n\n")
 		//"a.go:9:9": "", TODO: handle hovering on import statements (ast.BasicLit)
-		test(t, "docs/a.go:12:5", "var logit func(); logit is pkg2.X \n\n")
+		test(t, "docs/a.go:12:5", "var logit func(); logit is pkg2.X \n\n; // type inferred from the value")
 		test(t, "docs/a.go:12:13", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\nHow to \n\n```\nExample Code!\n\n```\n")
 		test(t, "docs/a.go:12:18", "func X(); X does the unknown. \n\n")
 		test(t, "docs/a.go:15:6", "type T struct; T is a struct. \n\n; struct {\n    F string\n    H Header\n}")
 		test(t, "docs/a.go:17:2", "struct field F string; F is a string field. \n\n")
 		test(t, "docs/a.go:20:2", "struct field H github.com/saibing/dep/pkg2.Header; H is a header. \n\n")
 		test(t, "docs/a.go:20:4", "package pkg2 (\"github.com/saibing/dep/pkg2\"); Package pkg2 shows dependencies. \n\nHow to \n\n```\nExample Code!\n\n```\n")
-		test(t, "docs/a.go:24:5", "var Foo string; Foo is the best string. \n\n")
-		test(t, "docs/a.go:31:2", "var I2 int; I2 is an int \n\n")
+		test(t, "docs/a.go:24:5", "var Foo string; Foo is the best string. \n\n; // type declared")
+		test(t, "docs/a.go:31:2", "var I2 int; I2 is an int \n\n; // type inferred from the value")
 
 		test(t, "docs/q.go:3:2", "struct field Q string; Q is a string field. \n\n")
 		test(t, "docs/q.go:5:2", "struct field X int; X is documented. \n\nX has comments. \n\n")
@@ -108,14 +142,81 @@ func TestHover(t *testing.T) {
 
 	t.Run("hover issue", func(t *testing.T) {
 		test(t, "issue/223.go:13:17", "func (*Hello).Bye() int")
-		test(t, "issue/261.go:11:15", "var t T")
+		test(t, "issue/261.go:11:15", "var t T; // type inferred by range")
 	})
 
 	t.Run("go1.9 type alias", func(t *testing.T) {
 		test(t, "typealias/a.go:1:17", "type A struct; struct {\n    a int\n}")
-		test(t, "typealias/b.go:1:17", "type B struct; struct {\n    a int\n}")
-		test(t, "typealias/b.go:1:20", "type B struct; struct {\n    a int\n}")
+		test(t, "typealias/b.go:1:17", "type B = A; struct {\n    a int\n}")
+		test(t, "typealias/b.go:1:20", "type B = A; struct {\n    a int\n}")
 		test(t, "typealias/b.go:1:21", "type A struct; struct {\n    a int\n}")
+		test(t, "typealias/c.go:1:86", "type C = sub.Concrete; struct {\n    X int\n}")
+		test(t, "typealias/c.go:1:109", "type N = int")
+	})
+
+	t.Run("variable type origin hover", func(t *testing.T) {
+		test(t, "varorigin/a.go:12:6", "var a Writer; // type declared, initialized with *W")
+		test(t, "varorigin/a.go:13:2", "var d *W; // type inferred by :=")
+		test(t, "varorigin/a.go:14:6", "var e Writer; // type declared")
+		test(t, "varorigin/a.go:15:22", "var err error; // type inferred by :=")
+		test(t, "varorigin/a.go:7:17", "var p []byte")
+	})
+
+	t.Run("type assertion hover", func(t *testing.T) {
+		test(t, "assert/a.go:10:2", "var v I; // type inferred by :=")
+		test(t, "assert/a.go:10:5", "var ok bool; // type inferred by :=")
+		test(t, "assert/a.go:11:2", "var w *T; // type inferred by :=")
+		test(t, "assert/a.go:11:10", "type T struct")
+		test(t, "assert/a.go:11:11", "type T struct")
+	})
+
+	t.Run("type switch hover", func(t *testing.T) {
+		test(t, "typeswitch/a.go:6:9", "var v int // case int\nvar v interface{} // case string, error\nvar v Stringer // case fmt.Stringer\nvar v interface{} // default")
+		test(t, "typeswitch/a.go:8:7", "var v int; // type of case int")
+		test(t, "typeswitch/a.go:12:7", "var v Stringer; // type of case fmt.Stringer")
+	})
+
+	t.Run("platform specific files hover", func(t *testing.T) {
+		test(t, "buildtags/foo_linux.go:1:17", "func Foo() string")
+		test(t, "buildtags/foo_windows.go:1:17", "func Foo() int")
+	})
+
+	t.Run("promoted selector hover", func(t *testing.T) {
+		test(t, "promoted/a.go:1:127", "func (*Inner).M() // promoted via X.Mid.Inner")
+		test(t, "promoted/a.go:1:138", "struct field N int // promoted via X.Mid.Inner")
+		test(t, "promoted/a.go:1:143", "struct field Inner *github.com/saibing/bingo/langserver/test/pkg/promoted.Inner // promoted via X.Mid")
+		test(t, "promoted/a.go:1:149", "func (*Inner).M()")
+	})
+
+	t.Run("bit flags hover", func(t *testing.T) {
+		test(t, "bitflags/a.go:7:2", "const Write Mode; const (\n\tRead = 0x1 // 0b1\n\tWrite = 0x2 // 0b10\n\tExec = 0x4 // 0b100\n)")
+		test(t, "bitflags/a.go:11:7", "const Other untyped int")
+	})
+
+	t.Run("bit flag combination hover", func(t *testing.T) {
+		test(t, "bitflags/a.go:14:2", "const ReadWrite Mode; ReadWrite = Read | Write // 0x3")
+		test(t, "bitflags/a.go:15:2", "const All Mode; All = Read | Write | Exec // 0x7")
+	})
+
+	t.Run("constant expression hover", func(t *testing.T) {
+		test(t, "constexpr/a.go:1:25", "1 << 10 = 1024 // 0x400")
+		test(t, "constexpr/a.go:1:45", "len(\"abc\") = 3")
+		test(t, "constexpr/a.go:1:66", "-(1 << 12) = -4096 // -0x1000")
+		test(t, "constexpr/a.go:1:18", "const KB untyped int")
+	})
+
+	t.Run("example hover", func(t *testing.T) {
+		test(t, "examples/a.go:1:17", "func Foo() int; fmt.Println(Foo())")
+		test(t, "examples/a.go:1:67", "func (T).M(); T{}.M()")
+	})
+
+	t.Run("cgo hover", func(t *testing.T) {
+		test(t, "cgo/a.go:8:23", "func Add(a int, b int) int")
+		test(t, "cgo/a.go:6:6", "func Add(a int, b int) int")
+	})
+
+	t.Run("struct tag hover", func(t *testing.T) {
+		test(t, "structtag/a.go:1:35", "- `json`: `a,omitempty`\n- `xml`: `a`\n")
 	})
 
 	t.Run("unexpected paths hover", func(t *testing.T) {
@@ -123,6 +224,140 @@ func TestHover(t *testing.T) {
 	})
 }
 
+var hoverSkipContext = newTestContext(cache.Ondemand)
+
+func TestHoverSkip(t *testing.T) {
+	t.Parallel()
+
+	skip := true
+	hoverSkipContext.initOptions = &InitializationOptions{HoverSkipUnexported: &skip, HoverSkipExternal: &skip}
+	hoverSkipContext.setup(t)
+
+	dir, err := filepath.Abs(hoverSkipContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		doHoverTest(t, hoverSkipContext.ctx, hoverSkipContext.conn, util.PathToURI(dir), pos, want)
+	}
+
+	test(t, "basic/a.go:1:17", "func A()")
+	test(t, "goroot/a.go:1:38", "")
+	test(t, "goroot/a.go:1:51", "")
+	test(t, "builtinfunc/a.go:1:87", "")
+}
+
+var hoverFooterContext = newTestContext(cache.Ondemand)
+
+func TestHoverSymbolFooter(t *testing.T) {
+	t.Parallel()
+
+	hoverSymbolFooter := true
+	hoverFooterContext.initOptions = &InitializationOptions{HoverSymbolFooter: &hoverSymbolFooter}
+	hoverFooterContext.setup(t)
+
+	dir, err := filepath.Abs(hoverFooterContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		doHoverTest(t, hoverFooterContext.ctx, hoverFooterContext.conn, util.PathToURI(dir), pos, want)
+	}
+
+	const pkgPath = "github.com/saibing/bingo/langserver/test/pkg"
+	test(t, "basic/b.go:1:23", "func A(); "+pkgPath+"/basic/-/A (func)")
+	test(t, "detailed/a.go:1:28", "struct field F string; "+pkgPath+"/detailed/-/T/F (field)")
+	test(t, "assert/a.go:10:2", "var v I; // type inferred by :=")
+}
+
+var hoverASTNodeContext = newTestContext(cache.Ondemand)
+
+func TestHoverASTNode(t *testing.T) {
+	t.Parallel()
+
+	hoverASTNode := true
+	hoverASTNodeContext.initOptions = &InitializationOptions{HoverASTNode: &hoverASTNode}
+	hoverASTNodeContext.setup(t)
+
+	dir, err := filepath.Abs(hoverASTNodeContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		doHoverTest(t, hoverASTNodeContext.ctx, hoverASTNodeContext.conn, util.PathToURI(dir), pos, want)
+	}
+
+	test(t, "basic/a.go:1:17", "func A()")
+	test(t, "basic/a.go:1:12", "*ast.FuncDecl; func A() { A() }")
+	test(t, "constexpr/a.go:1:12", "*ast.GenDecl; const KB = 1 << 10")
+}
+
+var (
+	hoverSizes386Context   = newTestContext(cache.None)
+	hoverSizesAMD64Context = newTestContext(cache.None)
+)
+
+func TestHoverSizes(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		context  *TestContext
+		goarch   string
+		wordSize string
+	}{
+		{hoverSizes386Context, "386", "4"},
+		{hoverSizesAMD64Context, "amd64", "8"},
+	} {
+		c := c
+		t.Run(c.goarch, func(t *testing.T) {
+			c.context.initOptions = &InitializationOptions{GOARCH: &c.goarch}
+			c.context.setup(t)
+
+			dir, err := filepath.Abs(c.context.root())
+			if err != nil {
+				t.Fatal(err)
+			}
+			test := func(t *testing.T, pos, want string) {
+				t.Helper()
+				doHoverTest(t, c.context.ctx, c.context.conn, util.PathToURI(dir), pos, want)
+			}
+
+			test(t, "sizes/a.go:1:52", "unsafe.Sizeof(uintptr(0)) = "+c.wordSize)
+			// The file of another platform is checked for it.
+			test(t, "sizes/b_386.go:1:52", "unsafe.Sizeof(uintptr(0)) = 4")
+		})
+	}
+}
+
+var hoverMethodSetContext = newTestContext(cache.Ondemand)
+
+func TestHoverMethodSet(t *testing.T) {
+	t.Parallel()
+
+	hoverMethodSet := true
+	hoverMethodSetContext.initOptions = &InitializationOptions{HoverMethodSet: &hoverMethodSet}
+	hoverMethodSetContext.setup(t)
+
+	dir, err := filepath.Abs(hoverMethodSetContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, pos, want string) {
+		t.Helper()
+		doHoverTest(t, hoverMethodSetContext.ctx, hoverMethodSetContext.conn, util.PathToURI(dir), pos, want)
+	}
+
+	const methods = "// method set of *T\nfunc (T) A()\nfunc (*T) B(x int) error\nfunc (*Base) M() // promoted from Base"
+	test(t, "methodset/a.go:1:85", "func (T).A(); "+methods)
+	test(t, "methodset/a.go:1:103", "func (*T).B(x int) error; "+methods)
+	test(t, "methodset/a.go:1:45", "func (*Base).M(); // method set of *Base\nfunc (*Base) M()")
+	test(t, "methodset/b.go:1:30", "func (I).N(); // method set of I\nfunc (I) N()")
+	test(t, "basic/a.go:1:17", "func A()")
+}
+
 type hoverTestCase struct {
 	input  string
 	output string
@@ -57,11 +57,115 @@ func TestImplementations(t *testing.T) {
 		test(t, "implementations/t1p.go:1:44", []string{"implementations/i1.go:1:32:from:method"})
 	})
 
+	t.Run("standard library implementations are opt-in", func(t *testing.T) {
+		test(t, "implementations/w.go:1:17", []string{})
+	})
+
+	t.Run("implementation direction", func(t *testing.T) {
+		testDirection := func(t *testing.T, input, direction string, output []string) {
+			testImplementations(t, &implementationsTestCase{input: input, direction: direction, output: output})
+		}
+		testDirection(t, "implementations/i1.go:1:17", "from", []string{})
+		testDirection(t, "implementations/i2.go:1:32", "to", []string{})
+		testDirection(t, "implementations/i2.go:1:32", "from", []string{"implementations/i1.go:1:32:from:method"})
+		testDirection(t, "implementations/t1.go:1:17", "to", []string{})
+		testDirection(t, "implementations/t1p.go:1:17", "both", []string{"implementations/i1.go:1:17:from:ptr"})
+	})
+
+	t.Run("implemented interfaces", func(t *testing.T) {
+		dir, err := filepath.Abs(implementationContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootURI := util.PathToURI(dir)
+		test := func(t *testing.T, file string, line, char int, want []string) {
+			t.Helper()
+			var ifaces []ImplementedInterface
+			err := implementationContext.conn.Call(implementationContext.ctx, "workspace/executeCommand", lsp.ExecuteCommandParams{
+				Command:   commandListImplementedInterfaces,
+				Arguments: []interface{}{uriJoin(rootURI, file), lsp.Position{Line: line, Character: char}},
+			}, &ifaces)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, iface := range ifaces {
+				s := iface.Name
+				if loc := iface.Location; loc != nil {
+					s += fmt.Sprintf(" %s:%d:%d", strings.TrimPrefix(string(loc.URI), string(rootURI)+"/"), loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+				}
+				if iface.Ptr {
+					s += " ptr"
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+			}
+		}
+
+		const pkgPath = "github.com/saibing/bingo/langserver/test/pkg/implementations"
+		test(t, "implementations/t1.go", 0, 16, []string{pkgPath + ".I1 implementations/i1.go:1:17"})
+		test(t, "implementations/t1p.go", 0, 16, []string{pkgPath + ".I1 implementations/i1.go:1:17 ptr"})
+		test(t, "implementations/i2.go", 0, 16, []string{pkgPath + ".I1 implementations/i1.go:1:17"})
+		test(t, "implementations/t0.go", 0, 16, nil)
+		test(t, "implementations/e.go", 0, 16, []string{"error ptr", pkgPath + ".Errorer implementations/e.go:1:74 ptr"})
+	})
+
+}
+
+var implementationMethodsContext = newTestContext(cache.Always)
+
+func TestImplementationMethods(t *testing.T) {
+	t.Parallel()
+
+	methods := true
+	implementationMethodsContext.initOptions = &InitializationOptions{ImplementationMethods: &methods}
+	implementationMethodsContext.setup(t)
+
+	dir, err := filepath.Abs(implementationMethodsContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, file string, line, char int, want []string) {
+		t.Helper()
+		got, err := callImplementation(implementationMethodsContext.ctx, implementationMethodsContext.conn, uriJoin(util.PathToURI(dir), file), line, char, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			got[i] = filepath.ToSlash(util.UriToRealPath(lsp.DocumentURI(got[i])))
+		}
+		sort.Strings(got)
+		for i := range want {
+			want[i] = makePath(implementationMethodsContext.root(), want[i])
+		}
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+		}
+	}
+
+	// The promoted method of T1E is the method of T1, found once.
+	test(t, "implementations/i1.go", 0, 16, []string{
+		"implementations/i2.go:1:32:to:method",
+		"implementations/t1.go:1:41:to:method",
+		"implementations/t1p.go:1:44:to:method",
+		"implementations/p2/p2.go:1:41:to:method",
+	})
+	test(t, "implementations/i1.go", 0, 31, []string{
+		"implementations/i2.go:1:32:to:method",
+		"implementations/t1.go:1:41:to:method",
+		"implementations/t1p.go:1:44:to:method",
+		"implementations/p2/p2.go:1:41:to:method",
+	})
+	test(t, "implementations/t1.go", 0, 16, []string{"implementations/i1.go:1:17:from"})
 }
 
 type implementationsTestCase struct {
-	input  string
-	output []string
+	input     string
+	direction string
+	output    []string
 }
 
 func testImplementations(tb testing.TB, c *implementationsTestCase) {
@@ -70,16 +174,16 @@ func testImplementations(tb testing.TB, c *implementationsTestCase) {
 		if err != nil {
 			log.Fatal("testImplementations", err)
 		}
-		doImplementationTest(t, implementationContext.ctx, implementationContext.conn, util.PathToURI(dir), c.input, c.output)
+		doImplementationTest(t, implementationContext.ctx, implementationContext.conn, util.PathToURI(dir), c.input, c.direction, c.output)
 	})
 }
 
-func doImplementationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string, want []string) {
+func doImplementationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos, direction string, want []string) {
 	file, line, char, err := parsePos(pos)
 	if err != nil {
 		t.Fatal(err)
 	}
-	impls, err := callImplementation(ctx, c, uriJoin(rootURI, file), line, char)
+	impls, err := callImplementation(ctx, c, uriJoin(rootURI, file), line, char, direction)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,11 +201,14 @@ func doImplementationTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, r
 	}
 }
 
-func callImplementation(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) ([]string, error) {
+func callImplementation(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int, direction string) ([]string, error) {
 	var res []lspext.ImplementationLocation
-	err := c.Call(ctx, "textDocument/implementation", lsp.TextDocumentPositionParams{
-		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
-		Position:     lsp.Position{Line: line, Character: char},
+	err := c.Call(ctx, "textDocument/implementation", ImplementationParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: line, Character: char},
+		},
+		Direction: direction,
 	}, &res)
 	if err != nil {
 		return nil, err
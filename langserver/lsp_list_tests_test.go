@@ -0,0 +1,62 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var listTestsContext = newTestContext(cache.Always)
+
+func TestListTests(t *testing.T) {
+	t.Parallel()
+
+	listTestsContext.setup(t)
+
+	const pkgPath = "github.com/saibing/bingo/langserver/test/pkg/listtests"
+	got, err := callListTests(listTestsContext.ctx, listTestsContext.conn, pkgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		pkgPath + ": TestA listtests/a_test.go:1:35",
+		pkgPath + ": BenchmarkA listtests/a_test.go:1:84",
+		pkgPath + ": Example listtests/a_test.go:1:118",
+		pkgPath + "_test: TestX listtests/x_test.go:1:40",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+	}
+}
+
+// callListTests returns the test functions of the packages with the prefix
+// pkgPath as "package: name file:line:col".
+func callListTests(ctx context.Context, c *jsonrpc2.Conn, pkgPath string) ([]string, error) {
+	var pkgs []TestPackage
+	if err := c.Call(ctx, "bingo/listTests", nil, &pkgs); err != nil {
+		return nil, err
+	}
+
+	root := makePath(listTestsContext.root()) + "/"
+	var res []string
+	for _, pkg := range pkgs {
+		if !strings.HasPrefix(pkg.Package, pkgPath) {
+			continue
+		}
+		for _, test := range pkg.Tests {
+			file := strings.TrimPrefix(filepath.ToSlash(util.UriToRealPath(test.Location.URI)), root)
+			start := test.Location.Range.Start
+			res = append(res, fmt.Sprintf("%s: %s %s:%d:%d", pkg.Package, test.Name, file, start.Line+1, start.Character+1))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var packageDocContext = newTestContext(cache.Always)
+
+func TestPackageDoc(t *testing.T) {
+	t.Parallel()
+
+	packageDocContext.setup(t)
+
+	dir, err := filepath.Abs(packageDocContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const importPath = "github.com/saibing/bingo/langserver/test/pkg/packagedoc"
+	test := func(t *testing.T, params PackageDocParams, want string) {
+		t.Helper()
+		got, err := callPackageDoc(packageDocContext.ctx, packageDocContext.conn, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("\ngot %q, \nwant %q", got, want)
+		}
+	}
+
+	t.Run("declarations", func(t *testing.T) {
+		want := "# package p\n\n`import \"" + importPath + "\"`\n" + `
+## Constants
+
+- ` + "`const Max untyped int`" + ` — Max is the maximum.
+- ` + "`const A untyped int`" + ` — A is a.
+
+## Variables
+
+- ` + "`var Reader io.Reader`" + ` — Reader reads.
+
+## Functions
+
+- ` + "`func New() *T`" + ` — New returns a T.
+
+## Types
+
+- ` + "`type T struct`" + ` — T is a thing.
+  - ` + "`func (*T).Get() string`" + ` — Get gets.
+`
+		test(t, PackageDocParams{ImportPath: importPath}, want)
+		test(t, PackageDocParams{TextDocument: &lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "packagedoc/a.go")}}, want)
+	})
+
+	t.Run("overview", func(t *testing.T) {
+		test(t, PackageDocParams{ImportPath: importPath + "/overview"}, "# package overview\n\n`import \""+importPath+"/overview\"`\n\nPackage overview is documented. \n\nIt has a second paragraph.\n")
+	})
+
+	t.Run("missing package", func(t *testing.T) {
+		if _, err := callPackageDoc(packageDocContext.ctx, packageDocContext.conn, PackageDocParams{ImportPath: importPath + "/missing"}); err == nil {
+			t.Error("got no error for a missing package")
+		}
+	})
+}
+
+func callPackageDoc(ctx context.Context, c *jsonrpc2.Conn, params PackageDocParams) (string, error) {
+	var doc string
+	err := c.Call(ctx, "bingo/packageDoc", params, &doc)
+	return doc, err
+}
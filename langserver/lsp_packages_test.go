@@ -0,0 +1,48 @@
+package langserver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+)
+
+var packagesContext = newTestContext(cache.Always)
+
+func TestPackages(t *testing.T) {
+	t.Parallel()
+
+	packagesContext.setup(t)
+
+	var pkgs []WorkspacePackage
+	if err := packagesContext.conn.Call(packagesContext.ctx, "bingo/packages", nil, &pkgs); err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]WorkspacePackage)
+	for _, pkg := range pkgs {
+		if _, ok := found[pkg.ImportPath]; ok {
+			t.Errorf("%s is listed twice", pkg.ImportPath)
+		}
+		found[pkg.ImportPath] = pkg
+	}
+
+	const pkgPath = "github.com/saibing/bingo/langserver/test/pkg/xtest"
+	root := makePath(packagesContext.root())
+	tests := []WorkspacePackage{
+		{ImportPath: pkgPath, Dir: root + "/xtest", Name: "p", IsMain: true},
+		{ImportPath: pkgPath + "_test", Dir: root + "/xtest", Name: "p_test", IsMain: true},
+	}
+	for _, want := range tests {
+		got := found[want.ImportPath]
+		got.Dir = filepath.ToSlash(got.Dir)
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+	if pkg, ok := found["fmt"]; !ok || pkg.IsMain || pkg.Name != "fmt" {
+		t.Errorf("got %+v for fmt, want a package which is not main", pkg)
+	}
+	if _, ok := found[pkgPath+".test"]; ok {
+		t.Errorf("the generated main package of the tests is listed")
+	}
+}
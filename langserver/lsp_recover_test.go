@@ -0,0 +1,91 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// panicConn is a jsonrpc2.JSONRPC2 which panics on a notification.
+type panicConn struct {
+	jsonrpc2.JSONRPC2
+}
+
+func (panicConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	panic("deliberate panic")
+}
+
+func TestHandlePanic(t *testing.T) {
+	// The trace of the request is sent through the conn, which panics.
+	h := &LangHandler{HandlerShared: &HandlerShared{}, init: &InitializeParams{}, config: &Config{}, trace: traceMessages}
+	_, err := h.Handle(context.Background(), panicConn{}, &jsonrpc2.Request{Method: "$/setTrace", Notif: true})
+	if e, ok := err.(*jsonrpc2.Error); !ok || e.Code != jsonrpc2.CodeInternalError || e.Message != "unexpected panic: deliberate panic" {
+		t.Errorf("got error %#v, want an internal error", err)
+	}
+}
+
+var recoverContext = newTestContext(cache.Ondemand)
+
+func TestRecoverMalformedDocument(t *testing.T) {
+	t.Parallel()
+
+	recoverContext.setup(t)
+
+	ctx, conn := recoverContext.ctx, recoverContext.conn
+	root := util.PathToURI(makePath(recoverContext.root()))
+	uri := uriJoin(root, "malformed/a.go")
+	content := "package p\n\nfunc (\ntype T interface { M( }\nvar x = []int{1, 2\nfunc F() { x. }\nfunc G() { F()() ; T{}.M(. }\n"
+	err := conn.Notify(ctx, "textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: content},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := []string{
+		"textDocument/hover",
+		"textDocument/definition",
+		"textDocument/typeDefinition",
+		"textDocument/references",
+		"textDocument/implementation",
+		"textDocument/signatureHelp",
+		"textDocument/completion",
+	}
+	for line, text := range strings.Split(content, "\n") {
+		for char := 0; char <= len(text); char++ {
+			for _, method := range methods {
+				var res json.RawMessage
+				err := conn.Call(ctx, method, lsp.TextDocumentPositionParams{
+					TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+					Position:     lsp.Position{Line: line, Character: char},
+				}, &res)
+				// The errors are expected, but not a panic.
+				if e, ok := err.(*jsonrpc2.Error); ok && strings.HasPrefix(e.Message, "unexpected panic") {
+					t.Errorf("%s at %d:%d: got %v, want no panic", method, line, char, e)
+				}
+			}
+		}
+	}
+	var symbols json.RawMessage
+	conn.Call(ctx, "textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &symbols)
+	var edits json.RawMessage
+	conn.Call(ctx, "textDocument/formatting", lsp.DocumentFormattingParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &edits)
+
+	// Let the diagnostics of the document run.
+	time.Sleep(2 * diagnosticsDelay)
+
+	hover, err := callHover(ctx, conn, uriJoin(root, "basic/a.go"), 0, 16)
+	if err != nil {
+		t.Fatalf("the server does not answer after the malformed document: %v", err)
+	}
+	if want := "func A()"; hover != want {
+		t.Errorf("got hover %q, want %q", hover, want)
+	}
+}
@@ -2,12 +2,14 @@ package langserver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
@@ -19,9 +21,28 @@ import (
 
 var referencesContext = newTestContext(cache.Always)
 
+// referencesProgress records the $/progress notifications received in the
+// referencesContext, by token.
+var referencesProgress = struct {
+	sync.Mutex
+	locs map[string][][]lsp.Location
+}{locs: make(map[string][][]lsp.Location)}
+
 func TestReferences(t *testing.T) {
 	t.Parallel()
 
+	referencesContext.notify = func(req *jsonrpc2.Request) {
+		var params struct {
+			Token string         `json:"token"`
+			Value []lsp.Location `json:"value"`
+		}
+		if req.Method != "$/progress" || req.Params == nil || json.Unmarshal(*req.Params, &params) != nil {
+			return
+		}
+		referencesProgress.Lock()
+		referencesProgress.locs[params.Token] = append(referencesProgress.locs[params.Token], params.Value)
+		referencesProgress.Unlock()
+	}
 	referencesContext.setup(t)
 
 	test := func(t *testing.T, input string, output []string) {
@@ -35,10 +56,26 @@ func TestReferences(t *testing.T) {
 		test(t, "basic/b.go:1:23", []string{"basic/a.go:1:17", "basic/a.go:1:23", "basic/b.go:1:23"})
 	})
 
+	t.Run("cursor position", func(t *testing.T) {
+		for _, pos := range []string{"cursor/a.go:1:38", "cursor/a.go:1:39", "cursor/a.go:1:41"} {
+			test(t, pos, []string{"cursor/a.go:1:17", "cursor/a.go:1:38"})
+		}
+	})
+
+	t.Run("cgo", func(t *testing.T) {
+		test(t, "cgo/a.go:6:6", []string{"cgo/a.go:6:6", "cgo/a.go:8:23"})
+	})
+
 	t.Run("builtin", func(t *testing.T) {
 		test(t, "builtin/a.go:1:26", []string{"builtin/a.go:1:23"})
 	})
 
+	t.Run("no result", func(t *testing.T) {
+		test(t, "noresult/a.go:3:5", nil)
+		test(t, "noresult/a.go:9:1", nil)
+		test(t, "noresult/a.go:10:6", nil)
+	})
+
 	t.Run("xtest", func(t *testing.T) {
 		test(t, "xtest/a.go:1:16", []string{"xtest/a.go:1:16", "xtest/a_test.go:1:20", "xtest/x_test.go:1:88"})
 		test(t, "xtest/x_test.go:1:88", []string{"xtest/a.go:1:16", "xtest/a_test.go:1:20", "xtest/x_test.go:1:88"})
@@ -51,6 +88,8 @@ func TestReferences(t *testing.T) {
 		test(t, "test/a_test.go:1:102", []string{"test/a_test.go:1:102", "test/b/b.go:1:16", "test/b/b.go:1:45", "test/c/c.go:1:84"})
 		test(t, "test/a_test.go:1:100", []string{"test/a_test.go:1:100", "test/a_test.go:1:37"})
 		test(t, "test/a_test.go:1:110", []string{"test/a_test.go:1:110"})
+		test(t, "test/b/b.go:1:16", []string{"test/a_test.go:1:102", "test/b/b.go:1:16", "test/b/b.go:1:45", "test/c/c.go:1:84"})
+		test(t, "test/c/c.go:1:84", []string{"test/a_test.go:1:102", "test/b/b.go:1:16", "test/b/b.go:1:45", "test/c/c.go:1:84"})
 	})
 
 	t.Run("go project", func(t *testing.T) {
@@ -63,32 +102,178 @@ func TestReferences(t *testing.T) {
 		test(t, "gomodule/a.go:1:57", []string{"gomodule/a.go:1:57", "gomodule/a.go:1:72", githubModule + "/d.go:1:19", githubModule + "/d.go:1:35"})
 	})
 
+	t.Run("unexported", func(t *testing.T) {
+		test(t, "unexported/a.go:1:17", []string{"unexported/a.go:1:17", "unexported/a.go:1:52", "unexported/a_test.go:1:20"})
+		test(t, "unexported/a_test.go:1:20", []string{"unexported/a.go:1:17", "unexported/a.go:1:52", "unexported/a_test.go:1:20"})
+	})
+
 	t.Run("unexpected paths", func(t *testing.T) {
 		test(t, "unexpected_paths/a.go:1:17", []string{"unexpected_paths/a.go:1:17", "unexpected_paths/a.go:1:23"})
 	})
+
+	t.Run("dot and blank imports", func(t *testing.T) {
+		test(t, "dotimport/a.go:8:9", []string{"dotimport/a.go:8:9", "dotimport/q/q.go:1:18"})
+		test(t, "dotimport/a.go:8:13", []string{"dotimport/a.go:8:13", "dotimport/q/q.go:1:31"})
+		test(t, "dotimport/a.go:5:2", nil)
+		test(t, "dotimport/a.go:8:5", nil)
+	})
+
+	t.Run("partial results", testPartialReferences)
+
+	t.Run("aliases", func(t *testing.T) {
+		// An alias and the type it denotes are distinct objects, see
+		// TestReferencesFollowAliases.
+		test(t, "alias/b/b.go:5:6", []string{"alias/b/b.go:5:6", "alias/b/b.go:7:13", "alias/c/c.go:5:12", "alias/d/d.go:6:14"})
+	})
+
+	t.Run("declaration", func(t *testing.T) {
+		withoutDecl := func(t *testing.T, input string, output []string) {
+			testReferences(t, &referencesTestCase{input: input, output: output, excludeDeclaration: true})
+		}
+		test(t, "decl/a.go:3:6", []string{"decl/a.go:3:6", "decl/a.go:3:22", "decl/a.go:5:16", "decl/a.go:7:10", "decl/a.go:9:17"})
+		withoutDecl(t, "decl/a.go:3:6", []string{"decl/a.go:3:22", "decl/a.go:5:16", "decl/a.go:7:10", "decl/a.go:9:17"})
+		withoutDecl(t, "decl/a.go:7:10", []string{"decl/a.go:3:22", "decl/a.go:5:16", "decl/a.go:7:10", "decl/a.go:9:17"})
+		test(t, "decl/a.go:7:13", []string{"decl/a.go:7:13", "decl/a.go:7:26", "decl/a.go:9:19"})
+		withoutDecl(t, "decl/a.go:7:13", []string{"decl/a.go:7:26", "decl/a.go:9:19"})
+
+		// The embedded field is a use of its type too, its declaration
+		// is only returned if it is asked for, once.
+		test(t, "decl/a.go:5:16", []string{"decl/a.go:3:22", "decl/a.go:5:16", "decl/a.go:7:10", "decl/a.go:9:17"})
+		withoutDecl(t, "decl/a.go:5:16", []string{"decl/a.go:3:22", "decl/a.go:7:10", "decl/a.go:9:17"})
+	})
+}
+
+var referencesAliasesContext = newTestContext(cache.Always)
+
+func TestReferencesFollowAliases(t *testing.T) {
+	t.Parallel()
+
+	followAliases := true
+	referencesAliasesContext.initOptions = &InitializationOptions{ReferencesFollowAliases: &followAliases}
+	referencesAliasesContext.setup(t)
+
+	test := func(t *testing.T, input string, output []string) {
+		testReferences(t, &referencesTestCase{input: input, output: output, context: referencesAliasesContext})
+	}
+
+	// The references to A, to its alias B in another package and to the
+	// alias C of B are unified, with the declaration of the object asked.
+	refs := func(decl string) []string {
+		return []string{decl, "alias/a/a.go:5:13", "alias/b/b.go:5:12", "alias/b/b.go:7:13", "alias/c/c.go:5:12", "alias/c/c.go:7:13", "alias/d/d.go:6:14", "alias/d/d.go:6:21"}
+	}
+	test(t, "alias/a/a.go:3:6", refs("alias/a/a.go:3:6"))
+	test(t, "alias/b/b.go:5:6", refs("alias/b/b.go:5:6"))
+	test(t, "alias/d/d.go:6:21", refs("alias/c/c.go:5:6"))
+	// The other types are not unified.
+	test(t, "alias/b/b.go:9:6", []string{"alias/b/b.go:9:6", "alias/d/d.go:8:9"})
+
+	// A rename does not unify them, an alias and the type it denotes are
+	// distinct names.
+	dir, err := filepath.Abs(referencesAliasesContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit, err := callRenaming(referencesAliasesContext.ctx, referencesAliasesContext.conn, uriJoin(util.PathToURI(dir), "alias/b/b.go"), 4, 5, "Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for uri, edits := range edit.Changes {
+		file := util.PathTrimPrefix(util.UriToRealPath(lsp.DocumentURI(uri)), dir)
+		for _, e := range edits {
+			got = append(got, fmt.Sprintf("%s:%d:%d", file, e.Range.Start.Line+1, e.Range.Start.Character+1))
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"alias/b/b.go:5:6", "alias/b/b.go:7:13", "alias/c/c.go:5:12", "alias/d/d.go:6:14"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the renamed %q, want %q", got, want)
+	}
+}
+
+// testPartialReferences tests that the references are streamed as $/progress
+// notifications when the request has a partial result token, then the
+// response is empty, in the referencesContext set up by TestReferences.
+func testPartialReferences(t *testing.T) {
+	dir, err := filepath.Abs(referencesContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, token string, limit int, want []string) {
+		t.Helper()
+		var res locations
+		err := referencesContext.conn.Call(referencesContext.ctx, "textDocument/references", ReferenceParams{
+			ReferenceParams: lsp.ReferenceParams{
+				Context: lsp.ReferenceContext{IncludeDeclaration: true, XLimit: limit},
+				TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+					TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "basic/a.go")},
+					Position:     lsp.Position{Line: 0, Character: 16},
+				},
+			},
+			PartialResultToken: token,
+		}, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 0 {
+			t.Errorf("got the final result %v, want it empty", res)
+		}
+
+		referencesProgress.Lock()
+		batches := referencesProgress.locs[token]
+		referencesProgress.Unlock()
+		var got []string
+		for _, locs := range batches {
+			for _, loc := range locs {
+				file := util.PathTrimPrefix(util.UriToRealPath(loc.URI), dir)
+				got = append(got, fmt.Sprintf("%s:%d:%d", file, loc.Range.Start.Line+1, loc.Range.Start.Character+1))
+			}
+		}
+		sort.Strings(got)
+		if want != nil && !reflect.DeepEqual(got, want) {
+			t.Errorf("got the streamed %q, want %q", got, want)
+		}
+		if limit > 0 && len(got) != limit {
+			t.Errorf("got %d references streamed, want %d", len(got), limit)
+		}
+	}
+
+	test(t, "all", 0, []string{"basic/a.go:1:17", "basic/a.go:1:23", "basic/b.go:1:23"})
+	test(t, "limit", 2, nil)
 }
 
 type referencesTestCase struct {
-	input  string
-	output []string
+	input              string
+	output             []string
+	excludeDeclaration bool
+
+	// context is the context of the test, referencesContext if nil.
+	context *TestContext
 }
 
 func testReferences(tb testing.TB, c *referencesTestCase) {
-	tbRun(tb, fmt.Sprintf("references-%s", strings.Replace(c.input, "/", "-", -1)), func(t testing.TB) {
-		dir, err := filepath.Abs(referencesContext.root())
+	name := fmt.Sprintf("references-%s", strings.Replace(c.input, "/", "-", -1))
+	if c.excludeDeclaration {
+		name += "-without-declaration"
+	}
+	tc := c.context
+	if tc == nil {
+		tc = referencesContext
+	}
+	tbRun(tb, name, func(t testing.TB) {
+		dir, err := filepath.Abs(tc.root())
 		if err != nil {
 			log.Fatal("testReferences", err)
 		}
-		doReferencesTest(t, referencesContext.ctx, referencesContext.conn, util.PathToURI(dir), c.input, c.output)
+		doReferencesTest(t, tc.ctx, tc.conn, tc.root(), util.PathToURI(dir), c.input, c.output, !c.excludeDeclaration)
 	})
 }
 
-func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootURI lsp.DocumentURI, pos string, want []string) {
+func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, root string, rootURI lsp.DocumentURI, pos string, want []string, includeDeclaration bool) {
 	file, line, char, err := parsePos(pos)
 	if err != nil {
 		t.Fatal(err)
 	}
-	references, err := callReferences(ctx, c, uriJoin(rootURI, file), line, char)
+	references, err := callReferences(ctx, c, uriJoin(rootURI, file), line, char, includeDeclaration)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -110,7 +295,7 @@ func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 		if strings.HasPrefix(want[i], githubModule) {
 			want[i] = makePath(gopathDir, want[i])
 		} else {
-			want[i] = makePath(referencesContext.root(), want[i])
+			want[i] = makePath(root, want[i])
 		}
 	}
 	sort.Strings(results)
@@ -120,10 +305,10 @@ func doReferencesTest(t testing.TB, ctx context.Context, c *jsonrpc2.Conn, rootU
 	}
 }
 
-func callReferences(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int) ([]string, error) {
+func callReferences(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, line, char int, includeDeclaration bool) ([]string, error) {
 	var res locations
 	err := c.Call(ctx, "textDocument/references", lsp.ReferenceParams{
-		Context: lsp.ReferenceContext{IncludeDeclaration: true},
+		Context: lsp.ReferenceContext{IncludeDeclaration: includeDeclaration},
 		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
 			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
 			Position:     lsp.Position{Line: line, Character: char},
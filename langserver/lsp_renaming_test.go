@@ -6,6 +6,7 @@ import (
 	"log"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -21,6 +22,14 @@ var renameContext = newTestContext(cache.Always)
 func TestRenaming(t *testing.T) {
 	t.Parallel()
 
+	// The references in the test files and in the internal packages are
+	// renamed anyway.
+	exclude := referencesTestsExclude
+	excludeInternal := true
+	renameContext.initOptions = &InitializationOptions{
+		ReferencesTests:         &exclude,
+		ExcludeInternalPackages: &excludeInternal,
+	}
 	renameContext.setup(t)
 
 	test := func(t *testing.T, input string, output map[string]string) {
@@ -48,6 +57,143 @@ func TestRenaming(t *testing.T) {
 			"13:5-13:6": "renaming/cgo/a.go",
 		})
 	})
+
+	t.Run("renaming package", testRenamingPackage)
+	t.Run("renaming package conflict", testRenamingPackageConflict)
+	t.Run("renaming in test files", testRenamingTestFiles)
+}
+
+// testRenamingTestFiles tests that a rename edits the references in the test
+// files whatever the references tests mode, and in the internal packages, in
+// the renameContext set up by TestRenaming, which excludes both from the
+// references.
+func testRenamingTestFiles(t *testing.T) {
+	dir, err := filepath.Abs(renameContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaceEdit, err := callRenaming(renameContext.ctx, renameContext.conn, uriJoin(util.PathToURI(dir), "renamepkg/a/a.go"), 2, 5, "Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for uri, edits := range workspaceEdit.Changes {
+		file := util.PathTrimPrefix(util.UriToRealPath(lsp.DocumentURI(uri)), dir)
+		for _, edit := range edits {
+			got = append(got, fmt.Sprintf("%s:%d:%d", file, edit.Range.Start.Line+1, edit.Range.Start.Character+1))
+		}
+	}
+	sort.Strings(got)
+	want := []string{"renamepkg/a/a.go:3:6", "renamepkg/a/a_test.go:5:11", "renamepkg/b/b.go:5:14", "renamepkg/b/b.go:5:21", "renamepkg/c/c.go:5:11", "renamepkg/internal/d/d.go:5:11"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot  %q, \nwant %q", got, want)
+	}
+
+	refs, err := callReferences(renameContext.ctx, renameContext.conn, uriJoin(util.PathToURI(dir), "renamepkg/a/a.go"), 2, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range refs {
+		if strings.Contains(ref, "/a_test.go:") || strings.Contains(ref, "/internal/") {
+			t.Errorf("got reference %s, want none in the test files and the internal packages", ref)
+		}
+	}
+}
+
+// testRenamingPackage tests the renaming of a package from its package clause
+// or from an import of it, in the renameContext set up by TestRenaming.
+func testRenamingPackage(t *testing.T) {
+	dir, err := filepath.Abs(renameContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootURI := util.PathToURI(dir)
+	test := func(t *testing.T, pos string, want []string) {
+		t.Helper()
+		file, line, char, err := parsePos(pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workspaceEdit, err := callRenaming(renameContext.ctx, renameContext.conn, uriJoin(rootURI, file), line, char, "z")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for uri, edits := range workspaceEdit.Changes {
+			file := util.PathTrimPrefix(util.UriToRealPath(lsp.DocumentURI(uri)), dir)
+			for _, edit := range edits {
+				got = append(got, fmt.Sprintf("%s:%d:%d-%d:%d %s", file, edit.Range.Start.Line+1, edit.Range.Start.Character+1, edit.Range.End.Line+1, edit.Range.End.Character+1, edit.NewText))
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("\ngot  %q, \nwant %q", got, want)
+		}
+	}
+
+	// The aliased import of c.go is not renamed.
+	want := []string{
+		"renamepkg/a/a.go:1:9-1:10 z",
+		"renamepkg/a/a_test.go:1:9-1:15 z_test",
+		"renamepkg/a/a_test.go:5:9-5:10 z",
+		"renamepkg/b/b.go:5:12-5:13 z",
+		"renamepkg/b/b.go:5:19-5:20 z",
+		"renamepkg/internal/d/d.go:5:9-5:10 z",
+	}
+	test(t, "renamepkg/a/a.go:1:9", want)
+	test(t, "renamepkg/a/a_test.go:1:10", want)
+	test(t, "renamepkg/b/b.go:3:20", want)
+}
+
+// testRenamingPackageConflict tests that a package is not renamed to a name
+// which refers to another object in a file importing it, in the renameContext
+// set up by TestRenaming.
+func testRenamingPackageConflict(t *testing.T) {
+	dir, err := filepath.Abs(renameContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := uriJoin(util.PathToURI(dir), "renamepkgconflict/a/a.go")
+
+	// A local variable, an import, a builtin used in b.go and a declaration
+	// of package b.
+	for _, newName := range []string{"local", "strings", "len", "B"} {
+		if edit, err := callRenaming(renameContext.ctx, renameContext.conn, uri, 0, 8, newName); err == nil {
+			t.Errorf("renaming a to %s: got %v, want an error", newName, edit.Changes)
+		}
+	}
+
+	edit, err := callRenaming(renameContext.ctx, renameContext.conn, uri, 0, 8, "z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(edit.Changes); n != 2 {
+		t.Errorf("got edits in %d files, want 2", n)
+	}
+}
+
+var renameTimeoutContext = newTestContext(cache.Always)
+
+// TestRenamingTimeout tests that a rename which times out fails instead of
+// renaming only the references found before the deadline.
+func TestRenamingTimeout(t *testing.T) {
+	t.Parallel()
+
+	timeout := "1ns"
+	renameTimeoutContext.initOptions = &InitializationOptions{RequestTimeout: &timeout}
+	renameTimeoutContext.setup(t)
+
+	dir, err := filepath.Abs(renameTimeoutContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaceEdit, err := callRenaming(renameTimeoutContext.ctx, renameTimeoutContext.conn, uriJoin(util.PathToURI(dir), "renaming/a.go"), 4, 1, "z")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if len(workspaceEdit.Changes) > 0 {
+		t.Errorf("got the edits %v, want none", workspaceEdit.Changes)
+	}
 }
 
 type renamingTestCase struct {
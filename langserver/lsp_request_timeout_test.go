@@ -0,0 +1,50 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/go-lsp/lspext"
+)
+
+var requestTimeoutContext = newTestContext(cache.Always)
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	timeout := "1ns"
+	requestTimeoutContext.initOptions = &InitializationOptions{RequestTimeout: &timeout}
+	requestTimeoutContext.setup(t)
+
+	ctx, conn := requestTimeoutContext.ctx, requestTimeoutContext.conn
+	uri := uriJoin(util.PathToURI(makePath(requestTimeoutContext.root())), "basic/a.go")
+
+	t.Run("partial results", func(t *testing.T) {
+		var symbols []lsp.SymbolInformation
+		if err := conn.Call(ctx, "workspace/symbol", lspext.WorkspaceSymbolParams{Query: "A"}, &symbols); err != nil {
+			t.Errorf("workspace/symbol: got error %v, want the partial results", err)
+		}
+		var refs []lsp.Location
+		err := conn.Call(ctx, "textDocument/references", lsp.ReferenceParams{TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: 0, Character: 16},
+		}}, &refs)
+		if err != nil {
+			t.Errorf("textDocument/references: got error %v, want the partial results", err)
+		}
+	})
+
+	t.Run("timeout error", func(t *testing.T) {
+		var hover lsp.Hover
+		err := conn.Call(ctx, "textDocument/hover", lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Position:     lsp.Position{Line: 0, Character: 16},
+		}, &hover)
+		want := "jsonrpc2: code -32603 message: textDocument/hover timed out after 1ns"
+		if err == nil || err.Error() != want {
+			t.Errorf("got error %v, want %q", err, want)
+		}
+	})
+}
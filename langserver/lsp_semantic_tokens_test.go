@@ -0,0 +1,77 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var semanticTokensContext = newTestContext(cache.None)
+
+func TestSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	semanticTokensContext.setup(t)
+
+	dir, err := filepath.Abs(semanticTokensContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res SemanticTokens
+	err = semanticTokensContext.conn.Call(semanticTokensContext.ctx, "textDocument/semanticTokens/full", SemanticTokensParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uriJoin(util.PathToURI(dir), "semantic/a.go")},
+	}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"1:31-32 type declaration",
+		"1:41-42 property declaration",
+		"1:43-46 type defaultLibrary",
+		"1:55-56 interface declaration",
+		"1:68-69 method declaration",
+		"1:81-82 variable declaration readonly",
+		"1:94-95 parameter declaration",
+		"1:96-97 type",
+		"1:99-100 method declaration",
+		"1:105-108 namespace defaultLibrary",
+		"1:109-116 function defaultLibrary",
+		"1:117-118 parameter",
+		"1:119-120 property",
+		"1:122-123 variable readonly",
+		"1:125-128 function defaultLibrary",
+	}
+	if got := decodeSemanticTokens(res.Data); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot\n\t%q\nwant\n\t%q", got, want)
+	}
+}
+
+// decodeSemanticTokens returns the tokens of data as
+// "line:start-end type modifiers...", with one based positions.
+func decodeSemanticTokens(data []uint32) []string {
+	var res []string
+	var line, start uint32
+	for i := 0; i+4 < len(data); i += 5 {
+		if data[i] == 0 {
+			start += data[i+1]
+		} else {
+			start = data[i+1]
+		}
+		line += data[i]
+
+		s := fmt.Sprintf("%d:%d-%d %s", line+1, start+1, start+1+data[i+2], semanticTokenTypes[data[i+3]])
+		for bit, modifier := range semanticTokenModifiers {
+			if data[i+4]&(1<<uint(bit)) != 0 {
+				s += " " + modifier
+			}
+		}
+		res = append(res, s)
+	}
+	return res
+}
@@ -43,6 +43,21 @@ func TestSignature(t *testing.T) {
 			"signature/e.go:1:48": "builtin.append(slice []builtin.Type, elems ...builtin.Type) 0",
 		})
 	})
+
+	t.Run("no signature help", func(t *testing.T) {
+		test(t, map[string]string{
+			"noresult/a.go:7:7":  " 0",
+			"noresult/a.go:8:10": " 0",
+		})
+	})
+
+	t.Run("parameter documentation", func(t *testing.T) {
+		test(t, map[string]string{
+			"signature/f.go:11:14": "F(width int, v *Value, n int) 0 the minimum number of characters.",
+			"signature/f.go:11:17": "F(width int, v *Value, n int) 1 Value is a value to format.",
+			"signature/f.go:11:22": "F(width int, v *Value, n int) 2",
+		})
+	})
 }
 
 type signatureTestCase struct {
@@ -94,5 +109,10 @@ func callSignature(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentURI, l
 		}
 	}
 	str += fmt.Sprintf(" %d", res.ActiveParameter)
+	if len(res.Signatures) > 0 && res.ActiveParameter < len(res.Signatures[0].Parameters) {
+		if doc := res.Signatures[0].Parameters[res.ActiveParameter].Documentation; doc != "" {
+			str += " " + doc
+		}
+	}
 	return str, nil
 }
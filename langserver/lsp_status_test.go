@@ -0,0 +1,35 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+)
+
+var statusContext = newTestContext(cache.Always)
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	statusContext.setup(t)
+
+	var status ServerStatus
+	if err := statusContext.conn.Call(statusContext.ctx, "bingo/status", nil, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Mode != "module" || len(status.Modules) == 0 {
+		t.Errorf("got mode %q and modules %v, want the module mode", status.Mode, status.Modules)
+	}
+	if status.CacheStyle != string(cache.Always) || !status.Cached {
+		t.Errorf("got cache style %q, cached %t, want %q, cached", status.CacheStyle, status.Cached, cache.Always)
+	}
+	if status.LastBuildTime == "" {
+		t.Errorf("got no last build time")
+	}
+	if status.Packages == 0 || status.PeakPackages < status.Packages {
+		t.Errorf("got %d packages cached, peak %d", status.Packages, status.PeakPackages)
+	}
+	if status.HeapAlloc == 0 || status.Sys < status.HeapAlloc {
+		t.Errorf("got heap alloc %d, sys %d", status.HeapAlloc, status.Sys)
+	}
+}
@@ -55,18 +55,46 @@ func TestMain(m *testing.M) {
 }
 
 func tearDown() {
+	alternateContext.tearDown()
+	apiSurfaceContext.tearDown()
+	packageDocContext.tearDown()
+	callHierarchyContext.tearDown()
+	callHierarchyParallelContext.tearDown()
+	codeActionContext.tearDown()
 	completionContext.tearDown()
+	completionImportContext.tearDown()
+	completionEagerContext.tearDown()
+	completionImportEagerContext.tearDown()
+	declarationContext.tearDown()
 	definitionContext.tearDown()
 	symbolContext.tearDown()
+	unusedContext.tearDown()
+	requestTimeoutContext.tearDown()
 	formatContext.tearDown()
+	importsLocalContext.tearDown()
 	hoverContext.tearDown()
+	hoverASTNodeContext.tearDown()
+	hoverFooterContext.tearDown()
+	hoverMethodSetContext.tearDown()
+	hoverSkipContext.tearDown()
+	listTestsContext.tearDown()
+	packagesContext.tearDown()
 	implementationContext.tearDown()
+	implementationMethodsContext.tearDown()
+	hoverSizes386Context.tearDown()
+	hoverSizesAMD64Context.tearDown()
+	recoverContext.tearDown()
 	referencesContext.tearDown()
 	renameContext.tearDown()
+	semanticTokensContext.tearDown()
+	documentLinkContext.tearDown()
+	documentLinkGodocContext.tearDown()
 	signatureContext.tearDown()
 	typeDefinitionContext.tearDown()
+	typeDefinitionFullRangeContext.tearDown()
 	workspaceReferencesContext.tearDown()
 	workspaceSymbolContext.tearDown()
+	workspaceSymbolMaxContext.tearDown()
 	xDefinitionContext.tearDown()
 }
 
@@ -76,6 +104,31 @@ type TestContext struct {
 	connServer *jsonrpc2.Conn
 	ctx        context.Context
 	exported   *packagestest.Exported
+
+	initOptions *InitializationOptions
+
+	// noResolveSupport unsets the completionItem/resolve support of the
+	// client, which resolves the detail of the completion items by default.
+	noResolveSupport bool
+
+	// notify, if set, is called with the notifications received by the
+	// client, eg. $/progress.
+	notify func(req *jsonrpc2.Request)
+}
+
+// clientHandler is the handler of the client connection of a TestContext,
+// which passes the notifications to notify.
+type clientHandler struct {
+	jsonrpc2.Handler
+	notify func(req *jsonrpc2.Request)
+}
+
+func (h clientHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Notif && h.notify != nil {
+		h.notify(req)
+		return
+	}
+	h.Handler.Handle(ctx, conn, req)
 }
 
 func newTestContext(style cache.CacheStyle) *TestContext {
@@ -130,17 +183,25 @@ func (tx *TestContext) initServer(t *testing.T) {
 	// Prepare the connection.
 	client, server := net.Pipe()
 	tx.connServer = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), tx.h)
-	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), tx.h)
+	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), clientHandler{tx.h, tx.notify})
 
-	tdCap := lsp.TextDocumentClientCapabilities{}
+	tdCap := TextDocumentClientCapabilities{}
 	tdCap.Completion.CompletionItemKind.ValueSet = []lsp.CompletionItemKind{lsp.CIKConstant}
+	if !tx.noResolveSupport {
+		tdCap.Completion.CompletionItem.ResolveSupport = &struct {
+			Properties []string `json:"properties"`
+		}{Properties: []string{"detail", "documentation"}}
+	}
 	params := InitializeParams{
 		InitializeParams: lsp.InitializeParams{
-			RootURI:      root,
-			Capabilities: lsp.ClientCapabilities{TextDocument: tdCap},
+			RootURI: root,
+		},
+		Capabilities: ClientCapabilities{
+			TextDocument: tdCap,
 		},
 
-		RootImportPath: rootImportPath,
+		InitializationOptions: tx.initOptions,
+		RootImportPath:        rootImportPath,
 	}
 	if err := tx.conn.Call(tx.ctx, "initialize", params, nil); err != nil {
 		t.Fatal("conn.Call initialize:", err)
@@ -32,6 +32,28 @@ func TestTypeDefinition(t *testing.T) {
 		test(t, "lookup/c/c.go:1:117", "lookup/a/a.go:1:17-1:18")
 		test(t, "lookup/d/d.go:1:135", "")
 	})
+
+	t.Run("unicode type definition", func(t *testing.T) {
+		test(t, "unicode/b.go:1:54", "unicode/b.go:1:17-1:22")
+	})
+
+	t.Run("type definition of a call", func(t *testing.T) {
+		test(t, "typedef/a.go:11:9", "typedef/a.go:3:6-3:7")
+		test(t, "typedef/a.go:15:9", "typedef/a.go:3:6-3:7")
+
+		dir, err := filepath.Abs(typeDefinitionContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		uri := uriJoin(util.PathToURI(dir), "typedef/a.go")
+		got, err := callTypeDefinition(typeDefinitionContext.ctx, typeDefinitionContext.conn, uri, 12, 14)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("%s:3:6-3:7, %s:5:6-5:7", uri, uri); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
 }
 
 func testTypeDefinition(tb testing.TB, c *definitionTestCase) {
@@ -97,3 +119,32 @@ func callTypeDefinition(ctx context.Context, c *jsonrpc2.Conn, uri lsp.DocumentU
 	}
 	return str, nil
 }
+
+var typeDefinitionFullRangeContext = newTestContext(cache.None)
+
+func TestTypeDefinitionFullRange(t *testing.T) {
+	t.Parallel()
+
+	fullRange := true
+	typeDefinitionFullRangeContext.initOptions = &InitializationOptions{TypeDefinitionFullRange: &fullRange}
+	typeDefinitionFullRangeContext.setup(t)
+
+	dir, err := filepath.Abs(typeDefinitionFullRangeContext.root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(t *testing.T, file string, line, char int, want string) {
+		t.Helper()
+		uri := uriJoin(util.PathToURI(dir), file)
+		got, err := callTypeDefinition(typeDefinitionFullRangeContext.ctx, typeDefinitionFullRangeContext.conn, uri, line, char)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want = string(uri) + ":" + want; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	test(t, "typedef/a.go", 10, 8, "3:1-3:16")
+	test(t, "unicode/b.go", 0, 53, "1:12-1:31")
+}
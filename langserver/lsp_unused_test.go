@@ -0,0 +1,51 @@
+package langserver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+)
+
+var unusedContext = newTestContext(cache.Always)
+
+func TestListUnusedExported(t *testing.T) {
+	t.Parallel()
+
+	unusedContext.setup(t)
+
+	rootURI := string(util.PathToURI(makePath(unusedContext.root())))
+	test := func(t *testing.T, args []interface{}, want []string) {
+		var locs []lsp.Location
+		err := unusedContext.conn.Call(unusedContext.ctx, "workspace/executeCommand", lsp.ExecuteCommandParams{
+			Command:   commandListUnusedExported,
+			Arguments: args,
+		}, &locs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, loc := range locs {
+			file := strings.TrimPrefix(string(loc.URI), rootURI+"/")
+			if strings.HasPrefix(file, "unused/") {
+				got = append(got, fmt.Sprintf("%s:%d:%d", file, loc.Range.Start.Line+1, loc.Range.Start.Character+1))
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	// The func Shadowed is unused, the field and the local of the same name
+	// are.
+	t.Run("tests included", func(t *testing.T) {
+		test(t, nil, []string{"unused/a.go:7:13", "unused/a.go:11:6", "unused/a.go:15:6", "unused/a.go:21:6"})
+	})
+
+	t.Run("tests excluded", func(t *testing.T) {
+		test(t, []interface{}{true}, []string{"unused/a.go:7:13", "unused/a.go:11:6", "unused/a.go:15:6", "unused/a.go:17:6", "unused/a.go:21:6"})
+	})
+}
@@ -0,0 +1,30 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+var backgroundInitContext = newTestContext(cache.Always)
+
+// TestBackgroundInit tests that the requests sent right after the initialize
+// response are served on demand while the packages are loaded in the
+// background.
+func TestBackgroundInit(t *testing.T) {
+	t.Parallel()
+
+	warmup := false
+	backgroundInitContext.initOptions = &InitializationOptions{WarmupOnInitialize: &warmup}
+	backgroundInitContext.setup(t)
+
+	root := util.PathToURI(makePath(backgroundInitContext.root()))
+	hover, err := callHover(backgroundInitContext.ctx, backgroundInitContext.conn, uriJoin(root, "basic/a.go"), 0, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func A()"; hover != want {
+		t.Errorf("got hover %q, want %q", hover, want)
+	}
+}
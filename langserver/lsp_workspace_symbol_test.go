@@ -26,6 +26,9 @@ var workspaceSymbolContext = newTestContext(cache.Always)
 func TestWorkspaceSymbol(t *testing.T) {
 	t.Parallel()
 
+	// The symbols of the whole workspace are requested.
+	noMax := 0
+	workspaceSymbolContext.initOptions = &InitializationOptions{SymbolMaxResults: &noMax}
 	workspaceSymbolContext.setup(t)
 
 	test := func(t *testing.T, data map[*lspext.WorkspaceSymbolParams][]string) {
@@ -135,6 +138,61 @@ func TestWorkspaceSymbol(t *testing.T) {
 			{Query: "is:exported"}: {"symbols/abc.go:variable:A:8:2", "symbols/abc.go:constant:B:12:2", "symbols/abc.go:class:C:17:2", "symbols/abc.go:class:T:22:6", "symbols/abc.go:interface:UVW:20:6", "symbols/abc.go:class:XYZ:3:6", "symbols/bcd.go:class:YZA:3:6", "symbols/abc.go:method:XYZ.ABC:5:14", "symbols/bcd.go:method:YZA.BCD:5:14"},
 		})
 	})
+
+	t.Run("main modules", func(t *testing.T) {
+		dir, err := filepath.Abs(workspaceSymbolContext.root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootDir := util.UriToRealPath(util.PathToURI(dir))
+		// outside counts the symbols found outside of the workspace.
+		outside := func(query string) int {
+			symbols, err := callWorkspaceSymbols(workspaceSymbolContext.ctx, workspaceSymbolContext.conn, lspext.WorkspaceSymbolParams{Query: query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for _, s := range symbols {
+				if !strings.HasPrefix(util.UriToRealPath(lsp.DocumentURI(s)), util.LowerDriver(rootDir)) {
+					n++
+				}
+			}
+			return n
+		}
+
+		if n := outside("is:all println"); n == 0 {
+			t.Error("got no symbol outside of the workspace with is:all")
+		}
+		if n := outside("is:main println"); n != 0 {
+			t.Errorf("got %d symbols outside of the workspace with is:main, want 0", n)
+		}
+	})
+
+	t.Run("pointer receiver", func(t *testing.T) {
+		test(t, map[*lspext.WorkspaceSymbolParams][]string{
+			{Query: "def"}:         {"different/cde.go:method:(*XYZ).DEF:2:15"},
+			{Query: "is:exported"}: {"different/abc.go:class:XYZ:2:6", "different/cde.go:method:(*XYZ).DEF:2:15", "different/bcd.go:method:XYZ.ABC:2:14"},
+			{Symbol: lspext.SymbolDescriptor{"package": "github.com/saibing/bingo/langserver/test/pkg/different", "recv": "XYZ", "name": "DEF"}}: {"different/cde.go:method:(*XYZ).DEF:2:15"},
+		})
+	})
+}
+
+var workspaceSymbolMaxContext = newTestContext(cache.Always)
+
+func TestWorkspaceSymbolMaxResults(t *testing.T) {
+	t.Parallel()
+
+	max := 1
+	workspaceSymbolMaxContext.initOptions = &InitializationOptions{SymbolMaxResults: &max}
+	workspaceSymbolMaxContext.setup(t)
+
+	symbols, err := callWorkspaceSymbols(workspaceSymbolMaxContext.ctx, workspaceSymbolMaxContext.conn, lspext.WorkspaceSymbolParams{Query: "dir:basic/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != max {
+		t.Errorf("got %d symbols %v, want %d", len(symbols), symbols, max)
+	}
 }
 
 type workspaceSymbolTestCase struct {
@@ -63,6 +63,10 @@ func TestXDefinition(t *testing.T) {
 		test(t, "gomodule/c.go:1:68", "gomodule/dep2/d2.go:1:32 id:github.com/saibing/dep/dep2/-/D2/D2 name:D2 package:github.com/saibing/dep/dep2 packageName:dep2 recv:D2 vendor:false")
 	})
 
+	t.Run("dot import", func(t *testing.T) {
+		test(t, "dotimport/a.go:8:9", "dotimport/q/q.go:1:18 id:github.com/saibing/bingo/langserver/test/pkg/dotimport/q/-/Q name:Q package:github.com/saibing/bingo/langserver/test/pkg/dotimport/q packageName:q recv: vendor:false")
+	})
+
 	t.Run("type definition lookup", func(t *testing.T) {
 		test(t, "lookup/b/b.go:1:115", "lookup/b/b.go:1:95 id:github.com/saibing/bingo/langserver/test/pkg/lookup/a/-/A name:A package:github.com/saibing/bingo/langserver/test/pkg/lookup/a packageName:a recv: vendor:false")
 	})
@@ -0,0 +1,205 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	godoc "go/doc"
+	"go/token"
+	"go/types"
+	"strings"
+
+	doc "github.com/slimsag/godocmd"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handlePackageDoc handles `bingo/packageDoc` requests. It returns the
+// documentation of a package as markdown, like go doc: its overview and the
+// exported declarations with the synopses of their doc comments.
+//
+// The package is named by its import path, it is then looked up in the
+// cache and the standard library packages are loaded on demand, or by any of
+// its documents.
+func (h *LangHandler) handlePackageDoc(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params PackageDocParams) (string, error) {
+	var pkg source.Package
+	switch {
+	case params.ImportPath != "":
+		pkg = h.project.GetStdlibPackage(params.ImportPath)
+		if pkg == nil {
+			return "", fmt.Errorf("package %s not found", params.ImportPath)
+		}
+
+	case params.TextDocument != nil:
+		if err := checkFileURI(params.TextDocument.URI); err != nil {
+			return "", err
+		}
+		var err error
+		if pkg, _, err = h.project.TypeCheck(ctx, params.TextDocument.URI); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "either importPath or textDocument is required"}
+	}
+
+	if pkg.GetTypes() == nil {
+		return "", fmt.Errorf("package %s has no type information", pkg.GetPkgPath())
+	}
+	return packageDocMarkdown(pkg), nil
+}
+
+// packageDocMarkdown renders the documentation of pkg. The declarations are
+// listed by kind in the order of their declaration, the methods under their
+// type. The test files are ignored.
+func packageDocMarkdown(pkg source.Package) string {
+	tpkg := pkg.GetTypes()
+	qf := func(p *types.Package) string {
+		if p == tpkg {
+			return ""
+		}
+		return p.Name()
+	}
+
+	var files []*ast.File
+	for _, f := range pkg.GetSyntax() {
+		if f.Name.Name == tpkg.Name() && !strings.HasSuffix(pkg.GetFileSet().Position(f.Pos()).Filename, "_test.go") {
+			files = append(files, f)
+		}
+	}
+
+	var consts, vars, funcs, typeNames []docEntry
+	methods := make(map[string][]docEntry)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				obj, ok := pkg.GetTypesInfo().Defs[decl.Name].(*types.Func)
+				if !ok || !obj.Exported() {
+					continue
+				}
+				entry := newDocEntry(obj, types.ObjectString(obj, qf), decl.Doc)
+				if decl.Recv == nil {
+					funcs = append(funcs, entry)
+				} else if recv := receiverTypeName(decl.Recv); ast.IsExported(recv) {
+					methods[recv] = append(methods[recv], entry)
+				}
+
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						obj, ok := pkg.GetTypesInfo().Defs[spec.Name].(*types.TypeName)
+						if ok && obj.Exported() {
+							typeNames = append(typeNames, newDocEntry(obj, typeSynopsis(obj, qf), specDoc(decl, spec.Doc)))
+						}
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							obj := pkg.GetTypesInfo().Defs[name]
+							if obj == nil || !obj.Exported() {
+								continue
+							}
+							entry := newDocEntry(obj, types.ObjectString(obj, qf), specDoc(decl, spec.Doc))
+							if decl.Tok == token.CONST {
+								consts = append(consts, entry)
+							} else {
+								vars = append(vars, entry)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# package %s\n\n`import \"%s\"`\n", tpkg.Name(), tpkg.Path())
+	if comments := source.PackageDoc(files, tpkg.Name()); comments != "" {
+		var overview bytes.Buffer
+		doc.ToMarkdown(&overview, comments, nil)
+		if s := strings.TrimSpace(overview.String()); s != "" {
+			fmt.Fprintf(&b, "\n%s\n", s)
+		}
+	}
+	writeDocSection(&b, "Constants", consts, nil)
+	writeDocSection(&b, "Variables", vars, nil)
+	writeDocSection(&b, "Functions", funcs, nil)
+	writeDocSection(&b, "Types", typeNames, methods)
+	return b.String()
+}
+
+// docEntry is an exported declaration and the synopsis of its doc comment.
+type docEntry struct {
+	name     string
+	decl     string
+	synopsis string
+}
+
+func newDocEntry(obj types.Object, decl string, comments *ast.CommentGroup) docEntry {
+	return docEntry{name: obj.Name(), decl: decl, synopsis: godoc.Synopsis(comments.Text())}
+}
+
+// writeDocSection writes the entries under the heading title, if any. The
+// methods of a type, by type name, are listed under its entry.
+func writeDocSection(b *bytes.Buffer, title string, entries []docEntry, methods map[string][]docEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+	for _, e := range entries {
+		writeDocEntry(b, "", e)
+		for _, m := range methods[e.name] {
+			writeDocEntry(b, "  ", m)
+		}
+	}
+}
+
+func writeDocEntry(b *bytes.Buffer, indent string, e docEntry) {
+	fmt.Fprintf(b, "%s- `%s`", indent, e.decl)
+	if e.synopsis != "" {
+		fmt.Fprintf(b, " — %s", e.synopsis)
+	}
+	b.WriteString("\n")
+}
+
+// specDoc returns the doc comment of a spec of decl, the one of decl if the
+// spec is not in a group.
+func specDoc(decl *ast.GenDecl, comments *ast.CommentGroup) *ast.CommentGroup {
+	if comments == nil && !decl.Lparen.IsValid() {
+		return decl.Doc
+	}
+	return comments
+}
+
+// typeSynopsis returns the declaration of the type name obj without the
+// fields of a struct or the methods of an interface, eg. "type T struct".
+func typeSynopsis(obj *types.TypeName, qf types.Qualifier) string {
+	if obj.IsAlias() {
+		return types.ObjectString(obj, qf)
+	}
+	switch obj.Type().Underlying().(type) {
+	case *types.Struct:
+		return "type " + obj.Name() + " struct"
+	case *types.Interface:
+		return "type " + obj.Name() + " interface"
+	}
+	return types.ObjectString(obj, qf)
+}
+
+// receiverTypeName returns the name of the type of the receiver recv, eg. T
+// for (t *T).
+func receiverTypeName(recv *ast.FieldList) string {
+	if recv == nil || len(recv.List) == 0 {
+		return ""
+	}
+	typ := recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package langserver
+
+import (
+	"context"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// WorkspacePackage is a package known to the server.
+type WorkspacePackage struct {
+	ImportPath string `json:"importPath"`
+	Dir        string `json:"dir"`
+	Name       string `json:"name"`
+
+	// IsMain is set for the packages of the main modules of the workspace.
+	IsMain bool `json:"isMain"`
+}
+
+// handlePackages handles `bingo/packages` requests. It returns the packages
+// of the cache sorted by import path, the packages which are not loaded yet
+// are not loaded by the request.
+func (h *LangHandler) handlePackages(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) ([]WorkspacePackage, error) {
+	infos := h.project.Packages()
+	pkgs := make([]WorkspacePackage, len(infos))
+	for i, info := range infos {
+		pkgs[i] = WorkspacePackage{
+			ImportPath: info.PkgPath,
+			Dir:        info.Dir,
+			Name:       info.Name,
+			IsMain:     info.IsMain,
+		}
+	}
+	return pkgs, nil
+}
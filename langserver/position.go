@@ -5,8 +5,11 @@
 package langserver
 
 import (
+	"bytes"
 	"go/token"
 	"net/url"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
@@ -22,11 +25,144 @@ func fromProtocolURI(uri lsp.DocumentURI) (span.URI, error) {
 	return span.URI(unescaped), nil
 }
 
+// The position encodings of the protocol positions, which count the
+// characters of a line in code units.
+const (
+	positionEncodingUTF8  = "utf-8"
+	positionEncodingUTF16 = "utf-16"
+	positionEncodingUTF32 = "utf-32"
+)
+
+// negotiatePositionEncoding returns the first of the encodings supported by
+// the client which is also supported by the server, UTF-16 if none is.
+func negotiatePositionEncoding(encodings []string) string {
+	for _, encoding := range encodings {
+		switch encoding {
+		case positionEncodingUTF8, positionEncodingUTF16, positionEncodingUTF32:
+			return encoding
+		}
+	}
+	return positionEncodingUTF16
+}
+
+// columnMapper converts the byte columns of the token positions to and from
+// the characters of the protocol positions, counted in the code units of the
+// negotiated position encoding. A nil columnMapper uses the byte columns.
+type columnMapper struct {
+	encoding string
+
+	// content returns the content of a file, eg. the overlay of an open
+	// document. It returns nil if the file cannot be read.
+	content func(filename string) []byte
+}
+
+// cached returns a columnMapper which reads each file once, to be used by a
+// request which converts many positions, eg. the references. The contents are
+// not invalidated, it must not outlive the request.
+func (m *columnMapper) cached() *columnMapper {
+	if m == nil || m.encoding == positionEncodingUTF8 {
+		return m
+	}
+
+	var mu sync.Mutex
+	contents := make(map[string][]byte)
+	return &columnMapper{
+		encoding: m.encoding,
+		content: func(filename string) []byte {
+			mu.Lock()
+			defer mu.Unlock()
+			content, ok := contents[filename]
+			if !ok {
+				content = m.content(filename)
+				contents[filename] = content
+			}
+			return content
+		},
+	}
+}
+
+// character returns the 0-based character of pos in its line.
+func (m *columnMapper) character(pos token.Position) int {
+	if m == nil || m.encoding == positionEncodingUTF8 || pos.Column <= 1 {
+		return pos.Column - 1
+	}
+	content := m.content(pos.Filename)
+	start := pos.Offset - (pos.Column - 1)
+	if start < 0 || pos.Offset > len(content) {
+		return pos.Column - 1
+	}
+	return codeUnits(content[start:pos.Offset], m.encoding)
+}
+
+// position converts pos to a protocol position.
+func (m *columnMapper) position(pos token.Position) lsp.Position {
+	return lsp.Position{Line: pos.Line - 1, Character: m.character(pos)}
+}
+
+// codeUnits returns the number of code units of b in encoding.
+func codeUnits(b []byte, encoding string) int {
+	switch encoding {
+	case positionEncodingUTF8:
+		return len(b)
+	case positionEncodingUTF32:
+		return utf8.RuneCount(b)
+	}
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += utf16Len(r)
+		b = b[size:]
+	}
+	return n
+}
+
+// utf16Len returns the number of UTF-16 code units of r, the runes outside of
+// the basic multilingual plane are encoded as surrogate pairs.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// codeUnitsOffset returns the byte offset in line of the character-th code
+// unit in encoding. It stops at the end of the line, a character in the
+// middle of a rune is the offset of the rune.
+func codeUnitsOffset(line []byte, character int, encoding string) int {
+	if encoding == positionEncodingUTF8 {
+		if i := bytes.IndexByte(line, '\n'); i >= 0 && i < character {
+			return i
+		}
+		if character > len(line) {
+			return len(line)
+		}
+		return character
+	}
+
+	offset := 0
+	for n := 0; n < character && offset < len(line); {
+		r, size := utf8.DecodeRune(line[offset:])
+		if r == '\n' {
+			break
+		}
+		if encoding == positionEncodingUTF32 {
+			n++
+		} else {
+			n += utf16Len(r)
+		}
+		if n > character {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
 // fromProtocolRange converts a protocol range to a source range.
 // It uses fromProtocolPosition to convert the start and end positions, which
 // requires the token file the positions belongs to.
-func fromProtocolRange(f *token.File, r lsp.Range) span.Range {
-	start := fromProtocolPosition(f, r.Start)
+func (m *columnMapper) fromProtocolRange(f *token.File, r lsp.Range) span.Range {
+	start := m.fromProtocolPosition(f, r.Start)
 	var end token.Pos
 	switch {
 	case r.End == r.Start:
@@ -34,7 +170,7 @@ func fromProtocolRange(f *token.File, r lsp.Range) span.Range {
 	case r.End.Line < 0:
 		end = token.NoPos
 	default:
-		end = fromProtocolPosition(f, r.End)
+		end = m.fromProtocolPosition(f, r.End)
 	}
 	return span.Range{
 		Start: start,
@@ -42,22 +178,70 @@ func fromProtocolRange(f *token.File, r lsp.Range) span.Range {
 	}
 }
 
-// fromProtocolPosition converts a protocol position (0-based line and column
-// number) to a token.Pos (byte offset value).
+// fromProtocolPosition converts a protocol position (0-based line and
+// character) to a token.Pos (byte offset value).
 // It requires the token file the pos belongs to in order to do this.
-func fromProtocolPosition(f *token.File, pos lsp.Position) token.Pos {
+func (m *columnMapper) fromProtocolPosition(f *token.File, pos lsp.Position) token.Pos {
 	line := lineStart(f, int(pos.Line)+1)
-	return line + token.Pos(pos.Character) // TODO: this is wrong, bytes not characters
+	if m == nil || line == token.NoPos {
+		return line + token.Pos(pos.Character)
+	}
+
+	content := m.content(f.Name())
+	offset := f.Offset(line)
+	if m.encoding == positionEncodingUTF8 || offset > len(content) {
+		return line + token.Pos(pos.Character)
+	}
+	return line + token.Pos(codeUnitsOffset(content[offset:], pos.Character, m.encoding))
 }
 
-// toProtocolPosition converts from a token pos (byte offset) to a protocol
-// position  (0-based line and column number)
-// It requires the token file the pos belongs to in order to do this.
-func toProtocolPosition(point span.Point) lsp.Position {
-	return lsp.Position{
-		Line:      point.Line() - 1,
-		Character: point.Column() - 1,
+// byteColumn returns the 1-based byte column of the character of the 1-based
+// line of the file filename.
+func (m *columnMapper) byteColumn(filename string, line, character int) int {
+	if m == nil {
+		return character + 1
+	}
+	content := m.content(filename)
+	offset := 0
+	for i := 1; i < line && offset < len(content); i++ {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return character + 1
+		}
+		offset += next + 1
+	}
+	return codeUnitsOffset(content[offset:], character, m.encoding) + 1
+}
+
+// toProtocolPosition converts from a point of the file uri to a protocol
+// position (0-based line and character).
+func (m *columnMapper) toProtocolPosition(uri span.URI, point span.Point) lsp.Position {
+	pos := token.Position{Line: point.Line(), Column: point.Column()}
+	filename, err := uri.Filename()
+	if m == nil || err != nil || pos.Column <= 1 {
+		return lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}
+	}
+
+	pos.Filename = filename
+	if point.HasOffset() {
+		pos.Offset = point.Offset()
+		return m.position(pos)
+	}
+	// Compute the offset from the start of the line.
+	content := m.content(filename)
+	offset := 0
+	for line := 1; line < pos.Line && offset >= 0; line++ {
+		if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+			offset += i + 1
+		} else {
+			offset = -1
+		}
+	}
+	if offset < 0 {
+		return lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}
 	}
+	pos.Offset = offset + pos.Column - 1
+	return m.position(pos)
 }
 
 // this functionality was borrowed from the analysisutil package
@@ -0,0 +1,82 @@
+package langserver
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+)
+
+func TestColumnMapper(t *testing.T) {
+	const src = "package p\n\nvar s = \"😀世界\"; var x = s\n"
+	fset := token.NewFileSet()
+	f := fset.AddFile("/p/a.go", -1, len(src))
+	f.SetLinesForContent([]byte(src))
+	content := func(string) []byte { return []byte(src) }
+
+	// x is at the byte offset 26 of the line 3.
+	x := f.Pos(11 + 26)
+	tests := []struct {
+		encoding string
+		want     lsp.Position
+	}{
+		{positionEncodingUTF8, lsp.Position{Line: 2, Character: 26}},
+		{positionEncodingUTF16, lsp.Position{Line: 2, Character: 20}},
+		{positionEncodingUTF32, lsp.Position{Line: 2, Character: 19}},
+	}
+	for _, test := range tests {
+		m := &columnMapper{encoding: test.encoding, content: content}
+		if got := m.position(fset.Position(x)); got != test.want {
+			t.Errorf("%s: position of x = %v, want %v", test.encoding, got, test.want)
+		}
+		if got := m.fromProtocolPosition(f, test.want); got != x {
+			t.Errorf("%s: fromProtocolPosition(%v) = %d, want %d", test.encoding, test.want, got, x)
+		}
+	}
+
+	// A character in the middle of a surrogate pair is the start of the rune.
+	m := &columnMapper{encoding: positionEncodingUTF16, content: content}
+	if got, want := m.fromProtocolPosition(f, lsp.Position{Line: 2, Character: 10}), f.Pos(11+9); got != want {
+		t.Errorf("fromProtocolPosition in a surrogate pair = %d, want %d", got, want)
+	}
+
+	// A nil mapper uses the byte columns.
+	var nilMapper *columnMapper
+	if got, want := nilMapper.position(fset.Position(x)), (lsp.Position{Line: 2, Character: 26}); got != want {
+		t.Errorf("nil mapper: position of x = %v, want %v", got, want)
+	}
+}
+
+func TestColumnMapperCached(t *testing.T) {
+	reads := 0
+	m := &columnMapper{encoding: positionEncodingUTF16, content: func(string) []byte {
+		reads++
+		return []byte("package p\n\nvar 世 = 1\n")
+	}}
+	cached := m.cached()
+	for i := 0; i < 3; i++ {
+		pos := token.Position{Filename: "/p/a.go", Offset: 19, Line: 3, Column: 9}
+		if got := cached.character(pos); got != 6 {
+			t.Fatalf("got character %d, want 6", got)
+		}
+	}
+	if reads != 1 {
+		t.Errorf("got %d reads, want 1", reads)
+	}
+}
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	tests := []struct {
+		encodings []string
+		want      string
+	}{
+		{nil, positionEncodingUTF16},
+		{[]string{"utf-8", "utf-16"}, positionEncodingUTF8},
+		{[]string{"latin-1", "utf-32"}, positionEncodingUTF32},
+	}
+	for _, test := range tests {
+		if got := negotiatePositionEncoding(test.encodings); got != test.want {
+			t.Errorf("negotiatePositionEncoding(%v) = %q, want %q", test.encodings, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// stdPrintfFuncs are the full names of the printf-like functions of the
+// standard library, see Config.PrintfFuncs for the other ones.
+var stdPrintfFuncs = map[string]bool{
+	"fmt.Appendf":              true,
+	"fmt.Errorf":               true,
+	"fmt.Fprintf":              true,
+	"fmt.Printf":               true,
+	"fmt.Sprintf":              true,
+	"log.Fatalf":               true,
+	"log.Panicf":               true,
+	"log.Printf":               true,
+	"(*log.Logger).Fatalf":     true,
+	"(*log.Logger).Panicf":     true,
+	"(*log.Logger).Printf":     true,
+	"(*testing.common).Errorf": true,
+	"(*testing.common).Fatalf": true,
+	"(*testing.common).Logf":   true,
+	"(*testing.common).Skipf":  true,
+}
+
+// formatDirective is a directive of a format string, eg. %[2]*d.
+type formatDirective struct {
+	// start and end are the byte offsets of the directive in the literal
+	// of the format, quotes included.
+	start, end int
+
+	// text is the directive, eg. %-8s.
+	text string
+
+	// arg is the index of the argument of the verb among the arguments
+	// following the format.
+	arg int
+}
+
+// parseFormat returns the directives of the format literal lit, as written
+// in the source: its escape sequences are not interpreted, eg. \x25 is not
+// a %. The arguments of the * widths and precisions are counted, but the
+// directives only bind their verb.
+func parseFormat(lit string) []formatDirective {
+	var directives []formatDirective
+	argNum := 0
+	// The closing quote is not a verb, eg. of "100%".
+	if len(lit) >= 2 {
+		lit = lit[:len(lit)-1]
+	}
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		if i < len(lit) && lit[i] == '%' {
+			continue
+		}
+		for i < len(lit) && strings.IndexByte("+-# 0", lit[i]) >= 0 {
+			i++
+		}
+		// argIndex parses an explicit argument index, eg. [2].
+		argIndex := func() {
+			if i >= len(lit) || lit[i] != '[' {
+				return
+			}
+			end := strings.IndexByte(lit[i:], ']')
+			if end < 0 {
+				return
+			}
+			if n, err := strconv.Atoi(lit[i+1 : i+end]); err == nil && n > 0 {
+				argNum = n - 1
+				i += end + 1
+			}
+		}
+		// star parses a width or a precision, a * consumes an argument.
+		star := func() {
+			argIndex()
+			if i < len(lit) && lit[i] == '*' {
+				argNum++
+				i++
+				return
+			}
+			for i < len(lit) && '0' <= lit[i] && lit[i] <= '9' {
+				i++
+			}
+		}
+		star()
+		if i < len(lit) && lit[i] == '.' {
+			i++
+			star()
+		}
+		argIndex()
+		if i >= len(lit) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(lit[i:])
+		directives = append(directives, formatDirective{start: start, end: i + size, text: lit[start : i+size], arg: argNum})
+		argNum++
+		i += size - 1
+	}
+	return directives
+}
+
+// isPrintfFunc reports whether fn is a printf-like function, of the standard
+// library or of Config.PrintfFuncs.
+func (h *LangHandler) isPrintfFunc(fn *types.Func) bool {
+	name := fn.FullName()
+	if stdPrintfFuncs[name] {
+		return true
+	}
+	for _, f := range h.config.PrintfFuncs {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDirectiveAt returns the call of a printf-like function whose format
+// literal is the innermost node of pathNodes, the directive of the format at
+// pos and its argument, nil if the call has none. It reports false if there
+// is no such directive at pos. The directives of a call passing its
+// arguments as a slice, eg. args..., are not resolved.
+func (h *LangHandler) formatDirectiveAt(pkg source.Package, pathNodes []ast.Node, pos token.Pos) (*ast.CallExpr, formatDirective, ast.Expr, bool) {
+	lit, ok := pathNodes[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING || len(pathNodes) < 2 {
+		return nil, formatDirective{}, nil, false
+	}
+	call, ok := pathNodes[1].(*ast.CallExpr)
+	if !ok || call.Ellipsis.IsValid() {
+		return nil, formatDirective{}, nil, false
+	}
+	fn := typeutil.StaticCallee(pkg.GetTypesInfo(), call)
+	if fn == nil || !h.isPrintfFunc(fn) {
+		return nil, formatDirective{}, nil, false
+	}
+	sig := fn.Type().(*types.Signature)
+	format := sig.Params().Len() - 2
+	if !sig.Variadic() || format < 0 || format >= len(call.Args) || astutil.Unparen(call.Args[format]) != lit {
+		return nil, formatDirective{}, nil, false
+	}
+
+	offset := int(pos - lit.Pos())
+	for _, d := range parseFormat(lit.Value) {
+		if d.start <= offset && offset < d.end {
+			var arg ast.Expr
+			if i := format + 1 + d.arg; i < len(call.Args) {
+				arg = call.Args[i]
+			}
+			return call, d, arg, true
+		}
+	}
+	return nil, formatDirective{}, nil, false
+}
+
+// hoverFormatDirective returns the hover of the format directive at pos, eg.
+// %d in fmt.Printf("%d", n): the argument it formats and its type. It
+// returns nil if there is no directive at pos.
+func (h *LangHandler) hoverFormatDirective(pkg source.Package, pathNodes []ast.Node, pos token.Pos) *lsp.Hover {
+	call, d, arg, ok := h.formatDirectiveAt(pkg, pathNodes, pos)
+	if !ok {
+		return nil
+	}
+	lit := pathNodes[0]
+	r := h.overlay.columns.rangeForNode(pkg.GetFileSet(), fakeNode{p: lit.Pos() + token.Pos(d.start), e: lit.Pos() + token.Pos(d.end)})
+	if arg == nil {
+		return &lsp.Hover{
+			Contents: []lsp.MarkedString{lsp.RawMarkedString(fmt.Sprintf("`%s` has no argument %d in the call of %s", d.text, d.arg+1, types.ExprString(call.Fun)))},
+			Range:    &r,
+		}
+	}
+	value := types.ExprString(arg)
+	if typ := pkg.GetTypesInfo().TypeOf(arg); typ != nil {
+		value += " " + types.TypeString(typ, types.RelativeTo(pkg.GetTypes()))
+	}
+	return &lsp.Hover{
+		Contents: []lsp.MarkedString{
+			{Language: "go", Value: value},
+			lsp.RawMarkedString(fmt.Sprintf("`%s` formats argument %d", d.text, d.arg+1)),
+		},
+		Range: &r,
+	}
+}
+
+// formatArgumentLocation returns the location of the argument of the format
+// directive at position. It reports false if there is no directive at
+// position or if it has no argument.
+func (h *LangHandler) formatArgumentLocation(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) (lsp.Location, bool) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		return lsp.Location{}, false
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil {
+		return lsp.Location{}, false
+	}
+	_, _, arg, ok := h.formatDirectiveAt(pkg, pathNodes, pos)
+	if !ok || arg == nil {
+		return lsp.Location{}, false
+	}
+	return h.overlay.columns.createLocationFromRange(pkg.GetFileSet(), arg.Pos(), arg.End()), true
+}
@@ -0,0 +1,26 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		lit  string
+		want []formatDirective
+	}{
+		{`"no directive 100%%"`, nil},
+		{`"%d %s"`, []formatDirective{{1, 3, "%d", 0}, {4, 6, "%s", 1}}},
+		{`"%-8.2f|%+q"`, []formatDirective{{1, 7, "%-8.2f", 0}, {8, 11, "%+q", 1}}},
+		{`"%*d %.*f"`, []formatDirective{{1, 4, "%*d", 1}, {5, 9, "%.*f", 3}}},
+		{`"%[2]d %[1]s %v"`, []formatDirective{{1, 6, "%[2]d", 1}, {7, 12, "%[1]s", 0}, {13, 15, "%v", 1}}},
+		{"`%é`", []formatDirective{{1, 4, "%é", 0}}},
+		{`"trailing %"`, nil},
+	}
+	for _, test := range tests {
+		if got := parseFormat(test.lit); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseFormat(%s) = %v, want %v", test.lit, got, test.want)
+		}
+	}
+}
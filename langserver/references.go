@@ -2,85 +2,273 @@ package langserver
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ReferenceParams) ([]lsp.Location, error) {
-	locs, err := h.doHandleTextDocumentReferences(ctx, conn, req, params)
+func (h *LangHandler) handleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params ReferenceParams) ([]lsp.Location, error) {
+	columns := h.overlay.columns.cached()
+	stream := partialReferences(ctx, conn, columns, params.PartialResultToken, h.config.ReferencesTests, params.Context.XLimit)
+	refs, fset, err := h.identReferences(ctx, params.TextDocument.URI, params.Position, params.Context.IncludeDeclaration, h.config.ReferencesFollowAliases, h.config.ExcludeInternalPackages, stream)
 	if err != nil {
-		// fix https://github.com/saibing/bingo/issues/32
-		params.Position.Character--
-		locs, err = h.doHandleTextDocumentReferences(ctx, conn, req, params)
+		if !deadlineExceeded(ctx) {
+			// If we are canceled, cancel loop early
+			return nil, err
+		}
+		// The references found before the deadline are returned.
+		h.notifyPartialResults(req.Method)
+	}
+	// Once partial results are streamed, the final result must be empty.
+	if fset == nil || stream != nil {
+		return []lsp.Location{}, nil
 	}
-	return locs, err
-}
 
-func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ReferenceParams) ([]lsp.Location, error) {
-	pkg, pos, err := h.typeCheck(ctx, params.TextDocument.URI, params.Position)
+	refs, err = filterTestReferences(fset, refs, h.config.ReferencesTests)
 	if err != nil {
-		// Invalid nodes means we tried to click on something which is
-		// not an ident (eg comment/string/etc). Return no information.
-		if _, ok := err.(*source.InvalidNodeError); ok {
-			return []lsp.Location{}, nil
-		}
 		return nil, err
 	}
 
-	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
-	if err != nil {
-		return nil, err
+	locs := refStreamAndCollect(fset, columns, refs, 0)
+	if h.config.SortReferencesByProximity {
+		sortLocationsByProximity(locs, params.TextDocument.URI, h.project.Contain)
+	} else {
+		sortLocationsByPosition(locs)
 	}
 
-	var ident *ast.Ident
-	firstNode := pathNodes[0]
-	switch node := firstNode.(type) {
-	case *ast.Ident:
-		ident = node
-	case *ast.FuncDecl:
-		ident = node.Name
-	default:
-		return nil, source.NewInvalidNodeError(pkg.GetFileSet(), firstNode)
+	if limit := params.Context.XLimit; limit > 0 && limit < len(locs) {
+		locs = locs[:limit]
+	}
+	return locs, nil
+}
+
+// identReferences returns the references to the object of the identifier at
+// position, with its declaration if includeDeclaration is set, and the file
+// set of their positions, nil if there is no object to search. If
+// followAliases is set, the references to a type and to its aliases are
+// unified, see findReferences. If stream is not nil, it is called with the
+// references of each package, except the declaration, as soon as they are
+// found. On error, eg. if the deadline of ctx is exceeded, the references
+// found so far are returned too.
+func (h *LangHandler) identReferences(ctx context.Context, uri lsp.DocumentURI, position lsp.Position, includeDeclaration, followAliases, excludeInternal bool, stream func(*token.FileSet, []*ast.Ident)) ([]*ast.Ident, *token.FileSet, error) {
+	// The identifier just before the cursor is found too, see
+	// https://github.com/saibing/bingo/issues/32
+	pkg, _, ident, err := h.identAt(ctx, uri, position, referencesIdent)
+	if err != nil {
+		if isNoResult(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if ident.Name == "_" {
+		// The blank identifier, eg. of a blank import, is not a reference
+		// to anything.
+		return nil, nil, nil
 	}
 
 	// NOTICE: Code adapted from golang.org/x/tools/cmd/guru
 	// referrers.go.
 	obj := source.FindIdentObject(pkg, ident)
 	if obj == nil {
-		return nil, errors.New("references object not found")
+		// eg. the package clause.
+		return nil, nil, nil
 	}
 
 	if obj.Pkg() == nil {
+		// The objects of the universe other than the builtin
+		// functions, eg. true or nil, are not searched.
 		if _, builtin := obj.(*types.Builtin); !builtin {
-			return nil, fmt.Errorf("no package found for object %s", obj)
+			return nil, nil, nil
 		}
 	}
 
-	refs, err := h.findReferences(ctx, obj)
-	if err != nil {
-		// If we are canceled, cancel loop early
-		return nil, err
+	fset := pkg.GetFileSet()
+	var report func(source.Package, []*ast.Ident)
+	if stream != nil {
+		report = func(_ source.Package, refs []*ast.Ident) {
+			stream(fset, withoutDeclaration(fset, refs, obj))
+		}
+	}
+	refs, err := h.findReferences(ctx, pkg, obj, followAliases, excludeInternal, report)
+
+	// The declaration may be among the references found, eg. an embedded
+	// field is also a use of its type, it is only returned if it is asked
+	// for, once.
+	refs = withoutDeclaration(fset, refs, obj)
+	if includeDeclaration {
+		decl := &ast.Ident{NamePos: obj.Pos(), Name: obj.Name()}
+		refs = append(refs, decl)
+		if stream != nil {
+			stream(fset, []*ast.Ident{decl})
+		}
+	}
+	return refs, fset, err
+}
+
+// referencesIdent returns the identifier to find the references of for the
+// innermost node at a position, or nil if there is none: the name of a
+// function declaration.
+func referencesIdent(node ast.Node) *ast.Ident {
+	if decl, ok := node.(*ast.FuncDecl); ok {
+		return decl.Name
+	}
+	return nil
+}
+
+// The modes of the references in test files.
+const (
+	referencesTestsInclude = "include"
+	referencesTestsExclude = "exclude"
+	referencesTestsOnly    = "only"
+)
+
+// filterTestReferences filters the refs in _test.go files according to mode,
+// they are kept if mode is "include" or empty.
+func filterTestReferences(fset *token.FileSet, refs []*ast.Ident, mode string) ([]*ast.Ident, error) {
+	var only bool
+	switch mode {
+	case "", referencesTestsInclude:
+		return refs, nil
+	case referencesTestsExclude:
+		only = false
+	case referencesTestsOnly:
+		only = true
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid references tests mode %q", mode)}
+	}
+
+	filtered := refs[:0]
+	for _, ref := range refs {
+		isTest := strings.HasSuffix(fset.Position(ref.Pos()).Filename, "_test.go")
+		if isTest == only {
+			filtered = append(filtered, ref)
+		}
+	}
+	return filtered, nil
+}
+
+// sortLocationsByPosition sorts locs by file, then by position in the file.
+func sortLocationsByPosition(locs []lsp.Location) {
+	sort.SliceStable(locs, func(i, j int) bool {
+		return lessLocation(locs[i], locs[j])
+	})
+}
+
+// sortLocationsByProximity sorts locs by their distance to the document uri:
+// the same file first, then the same package, then the same module, then the
+// rest. Locations at the same distance are sorted by position.
+func sortLocationsByProximity(locs []lsp.Location, uri lsp.DocumentURI, inModule func(lsp.DocumentURI) bool) {
+	path := util.UriToPath(uri)
+	dir := filepath.Dir(path)
+	proximity := func(loc lsp.Location) int {
+		locPath := util.UriToPath(loc.URI)
+		switch {
+		case util.PathEqual(locPath, path):
+			return 0
+		case util.PathEqual(filepath.Dir(locPath), dir):
+			return 1
+		case inModule(loc.URI):
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	sort.SliceStable(locs, func(i, j int) bool {
+		pi, pj := proximity(locs[i]), proximity(locs[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return lessLocation(locs[i], locs[j])
+	})
+}
+
+func lessLocation(a, b lsp.Location) bool {
+	if a.URI != b.URI {
+		return a.URI < b.URI
 	}
+	if a.Range.Start.Line != b.Range.Start.Line {
+		return a.Range.Start.Line < b.Range.Start.Line
+	}
+	return a.Range.Start.Character < b.Range.Start.Character
+}
 
-	if params.Context.IncludeDeclaration {
-		refs = append(refs, &ast.Ident{NamePos: obj.Pos(), Name: obj.Name()})
+// withoutDeclaration returns the refs which are not the name of the
+// declaration of obj, in any of the type-checked variants of its file.
+func withoutDeclaration(fset *token.FileSet, refs []*ast.Ident, obj types.Object) []*ast.Ident {
+	if !obj.Pos().IsValid() {
+		return refs
+	}
+	decl := fset.Position(obj.Pos())
+	filtered := make([]*ast.Ident, 0, len(refs))
+	for _, ref := range refs {
+		pos := fset.Position(ref.Pos())
+		if pos.Offset == decl.Offset && util.PathEqual(pos.Filename, decl.Filename) {
+			continue
+		}
+		filtered = append(filtered, ref)
 	}
+	return filtered
+}
 
-	return refStreamAndCollect(pkg.GetFileSet(), refs, params.Context.XLimit), nil
+// partialReferences returns the func which streams the references found in a
+// package to the client, as a $/progress notification with the given partial
+// result token. The references already sent, eg. by another test variant of
+// the package, are not sent again, and no more than limit references are sent
+// if limit > 0. It returns nil if the client did not send a token, then the
+// references are only sent in the response, else the response is empty.
+func partialReferences(ctx context.Context, conn jsonrpc2.JSONRPC2, columns *columnMapper, partialResultToken interface{}, testsMode string, limit int) func(*token.FileSet, []*ast.Ident) {
+	if partialResultToken == nil {
+		return nil
+	}
+
+	var (
+		mu sync.Mutex
+		// seen holds the ranges sent so far by file and line.
+		seen = map[string][]lsp.Range{}
+		sent int
+	)
+	return func(fset *token.FileSet, refs []*ast.Ident) {
+		refs, err := filterTestReferences(fset, refs, testsMode)
+		if err != nil {
+			return
+		}
+		var locs []lsp.Location
+		mu.Lock()
+		for _, loc := range refStreamAndCollect(fset, columns, refs, 0) {
+			if limit > 0 && sent == limit {
+				break
+			}
+			key := lineKey(loc)
+			if containsToken(seen[key], loc.Range) {
+				continue
+			}
+			seen[key] = append(seen[key], loc.Range)
+			locs = append(locs, loc)
+			sent++
+		}
+		mu.Unlock()
+		if len(locs) == 0 {
+			return
+		}
+		_ = conn.Notify(ctx, "$/progress", &ProgressParams{Token: partialResultToken, Value: locs})
+	}
 }
 
-// refStreamAndCollect returns all refs read in from chan until it is
-// closed. While it is reading, it will also occasionally stream out updates of
-// the refs received so far.
-func refStreamAndCollect(fset *token.FileSet, refs []*ast.Ident, limit int) []lsp.Location {
+// refStreamAndCollect returns the locations of the first limit refs, without
+// duplicates.
+func refStreamAndCollect(fset *token.FileSet, columns *columnMapper, refs []*ast.Ident, limit int) []lsp.Location {
 	if limit == 0 {
 		// If we don't have a limit, just set it to a value we should never exceed
 		limit = len(refs)
@@ -93,35 +281,61 @@ func refStreamAndCollect(fset *token.FileSet, refs []*ast.Ident, limit int) []ls
 
 	var locs []lsp.Location
 
-	seen := map[string]bool{}
+	// seen holds the ranges collected so far by file and line.
+	seen := map[string][]lsp.Range{}
 	for i := 0; i < l; i++ {
 		n := refs[i]
-		loc := goRangeToLSPLocation(fset, n.Pos(), n.Name)
+		loc := columns.goRangeToLSPLocation(fset, n.Pos(), n.Name)
 		if loc.URI == "" {
 			continue
 		}
 
-		// remove duplicate results because they contain uses of the xtest package
-		locStr := formatLocation(loc)
-		if seen[locStr] {
+		// remove duplicate results because they contain uses of the xtest
+		// package, and the same file may be type checked by several packages.
+		key := lineKey(loc)
+		if containsToken(seen[key], loc.Range) {
 			continue
 		}
-		seen[locStr] = true
+		seen[key] = append(seen[key], loc.Range)
 		locs = append(locs, loc)
 	}
 
 	return locs
 }
 
-func formatLocation(loc lsp.Location) string {
-	return fmt.Sprintf("%s:%s", loc.URI, loc.Range)
+// lineKey identifies the line of loc, the file path is compared like
+// util.PathEqual does.
+func lineKey(loc lsp.Location) string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(util.UriToPath(loc.URI)), loc.Range.Start.Line)
+}
+
+// containsToken reports whether one of ranges, on the same line as r, points
+// to the same identifier token. Two occurrences of an identifier are at least
+// two columns apart, so closer ranges only differ by an off-by-one column.
+func containsToken(ranges []lsp.Range, r lsp.Range) bool {
+	for _, other := range ranges {
+		if abs(other.Start.Character-r.Start.Character) <= 1 {
+			return true
+		}
+	}
+	return false
 }
 
 // findReferences will find all references to obj. It will only return
-// references from packages in pkg.Imports.
-func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object) ([]*ast.Ident, error) {
+// references from packages in pkg.Imports. An unexported object declared by
+// pkg can only be referenced by pkg, so only pkg is searched for it. If report
+// is not nil, it is called with each package and its references as soon as
+// they are found, report may be called concurrently.
+// If followAliases is set, the references to a type name are the ones to the
+// type it denotes and to all its aliases, which may be declared in any
+// package, so all the packages are searched.
+// On error, the references found so far are returned too.
+func (h *LangHandler) findReferences(ctx context.Context, pkg source.Package, queryObj types.Object, followAliases, excludeInternal bool, report func(source.Package, []*ast.Ident)) ([]*ast.Ident, error) {
 	// Bail out early if the context is canceled
-	var refs []*ast.Ident
+	var (
+		refs []*ast.Ident
+		mu   sync.Mutex
+	)
 	var defPkgPath string
 	if queryObj.Pkg() != nil {
 		defPkgPath = queryObj.Pkg().Path()
@@ -129,12 +343,25 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 		defPkgPath = cache.BuiltinPkg
 	}
 
+	if _, isTypeName := queryObj.(*types.TypeName); !isTypeName {
+		followAliases = false
+	}
+	matches := func(_ source.Package, obj types.Object) bool {
+		return sameObj(queryObj, obj)
+	}
+	if followAliases {
+		target := aliasTarget(queryObj)
+		matches = func(_ source.Package, obj types.Object) bool {
+			return sameObj(target, aliasTarget(obj))
+		}
+	}
+
 	f := func(pkg source.Package) error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
-		if defPkgPath != cache.BuiltinPkg {
+		if defPkgPath != cache.BuiltinPkg && !followAliases {
 			if p := pkg.GetImport(defPkgPath); p == nil && pkg.GetPkgPath() != defPkgPath {
 				return nil
 			}
@@ -144,21 +371,74 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 			return nil
 		}
 
+		var pkgRefs []*ast.Ident
+		checker := cancelChecker{ctx: ctx}
 		for id, obj := range pkg.GetTypesInfo().Uses {
-			if sameObj(queryObj, obj) {
-				refs = append(refs, id)
+			if err := checker.err(); err != nil {
+				return err
+			}
+			if matches(pkg, obj) {
+				pkgRefs = append(pkgRefs, id)
 			}
 		}
 
+		mu.Lock()
+		refs = append(refs, pkgRefs...)
+		mu.Unlock()
+		if report != nil && len(pkgRefs) > 0 {
+			report(pkg, pkgRefs)
+		}
 		return nil
 	}
 
-	err := h.project.Search(f)
-	if err != nil {
-		return nil, err
+	if !queryObj.Exported() && queryObj.Pkg() != nil && queryObj.Pkg() == pkg.GetTypes() && !followAliases {
+		// An unexported object is only referenced by its package, but each
+		// variant of the package, eg. with its test files, type-checks it
+		// again, so the objects of the variants are matched by declaration.
+		decl := pkg.GetFileSet().Position(queryObj.Pos())
+		matches = func(p source.Package, obj types.Object) bool {
+			if sameObj(queryObj, obj) {
+				return true
+			}
+			if obj.Pkg() == nil || obj.Pkg().Path() != defPkgPath || obj.Name() != queryObj.Name() {
+				return false
+			}
+			pos := p.GetFileSet().Position(obj.Pos())
+			return pos.Offset == decl.Offset && util.PathEqual(pos.Filename, decl.Filename)
+		}
+		err := f(pkg)
+		if err != nil {
+			return refs, err
+		}
+		err = h.project.Search(ctx, func(p source.Package) error {
+			if p == pkg || p.GetPkgPath() != pkg.GetPkgPath() {
+				return nil
+			}
+			return f(p)
+		})
+		return refs, err
 	}
 
-	return refs, nil
+	err := h.project.Search(ctx, func(p source.Package) error {
+		if excludeInternal && cache.IsInternalPackage(p.GetPkgPath()) {
+			return nil
+		}
+		return f(p)
+	})
+	return refs, err
+}
+
+// aliasTarget returns the type name of the named type denoted by obj if it
+// is an alias, eg. A for type B = A, or else obj.
+func aliasTarget(obj types.Object) types.Object {
+	tn, ok := obj.(*types.TypeName)
+	if !ok || !isAlias(tn) {
+		return obj
+	}
+	if named, ok := unalias(tn.Type()).(*types.Named); ok {
+		return named.Obj()
+	}
+	return obj
 }
 
 // same reports whether x and y are identical, or both are PkgNames
@@ -0,0 +1,162 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSortLocationsByProximity(t *testing.T) {
+	loc := func(uri string, line int) lsp.Location {
+		return lsp.Location{
+			URI:   lsp.DocumentURI(uri),
+			Range: lsp.Range{Start: lsp.Position{Line: line}},
+		}
+	}
+	locs := []lsp.Location{
+		loc("file:///other/x.go", 1),
+		loc("file:///mod/q/c.go", 2),
+		loc("file:///mod/p/b.go", 3),
+		loc("file:///mod/p/a.go", 9),
+		loc("file:///mod/p/a.go", 4),
+	}
+	inModule := func(uri lsp.DocumentURI) bool {
+		return strings.HasPrefix(string(uri), "file:///mod/")
+	}
+
+	sortLocationsByProximity(locs, "file:///mod/p/a.go", inModule)
+
+	want := []lsp.Location{
+		loc("file:///mod/p/a.go", 4),
+		loc("file:///mod/p/a.go", 9),
+		loc("file:///mod/p/b.go", 3),
+		loc("file:///mod/q/c.go", 2),
+		loc("file:///other/x.go", 1),
+	}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("got %v, want %v", locs, want)
+	}
+}
+
+func TestFilterTestReferences(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("/p/a.go", -1, 100)
+	b := fset.AddFile("/p/a_test.go", -1, 100)
+	newRefs := func() []*ast.Ident {
+		return []*ast.Ident{
+			{Name: "A", NamePos: a.Pos(10)},
+			{Name: "A", NamePos: b.Pos(20)},
+			{Name: "A", NamePos: a.Pos(30)},
+		}
+	}
+	positions := func(refs []*ast.Ident) []string {
+		var res []string
+		for _, ref := range refs {
+			res = append(res, fset.Position(ref.Pos()).String())
+		}
+		return res
+	}
+
+	tests := map[string][]string{
+		"":        {"/p/a.go:1:11", "/p/a_test.go:1:21", "/p/a.go:1:31"},
+		"include": {"/p/a.go:1:11", "/p/a_test.go:1:21", "/p/a.go:1:31"},
+		"exclude": {"/p/a.go:1:11", "/p/a.go:1:31"},
+		"only":    {"/p/a_test.go:1:21"},
+	}
+	for mode, want := range tests {
+		refs, err := filterTestReferences(fset, newRefs(), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := positions(refs); !reflect.DeepEqual(got, want) {
+			t.Errorf("mode %q: got %v, want %v", mode, got, want)
+		}
+	}
+
+	if _, err := filterTestReferences(fset, newRefs(), "none"); err == nil {
+		t.Error("expected an error for an invalid mode")
+	}
+}
+
+// notifyRecorder is a jsonrpc2.JSONRPC2 which records the notifications.
+type notifyRecorder struct {
+	jsonrpc2.JSONRPC2
+	methods []string
+	params  []interface{}
+}
+
+func (r *notifyRecorder) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	r.methods = append(r.methods, method)
+	r.params = append(r.params, params)
+	return nil
+}
+
+func TestPartialReferences(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("/p/a.go", -1, 100)
+	b := fset.AddFile("/p/a_test.go", -1, 100)
+	conn := &notifyRecorder{}
+
+	if report := partialReferences(context.Background(), conn, nil, nil, "", 0); report != nil {
+		t.Fatal("expected no partial results without a token")
+	}
+
+	report := partialReferences(context.Background(), conn, nil, "tok", "exclude", 0)
+	report(fset, []*ast.Ident{{Name: "A", NamePos: a.Pos(10)}, {Name: "A", NamePos: b.Pos(20)}})
+	report(fset, []*ast.Ident{{Name: "A", NamePos: b.Pos(30)}})
+
+	if want := []string{"$/progress"}; !reflect.DeepEqual(conn.methods, want) {
+		t.Fatalf("got notifications %v, want %v", conn.methods, want)
+	}
+	params := conn.params[0].(*ProgressParams)
+	locs := params.Value.([]lsp.Location)
+	if params.Token != "tok" || len(locs) != 1 || locs[0].Range.Start.Character != 10 {
+		t.Errorf("got %+v, want the reference of a.go with token tok", params)
+	}
+
+	// The references already sent are not sent again, and no more than
+	// the limit are sent.
+	conn = &notifyRecorder{}
+	report = partialReferences(context.Background(), conn, nil, "tok", "", 3)
+	report(fset, []*ast.Ident{{Name: "A", NamePos: a.Pos(10)}, {Name: "A", NamePos: a.Pos(40)}})
+	report(fset, []*ast.Ident{{Name: "A", NamePos: a.Pos(10)}, {Name: "A", NamePos: a.Pos(60)}, {Name: "A", NamePos: a.Pos(80)}})
+	report(fset, []*ast.Ident{{Name: "A", NamePos: a.Pos(90)}})
+	var got [][]int
+	for _, params := range conn.params {
+		var chars []int
+		for _, loc := range params.(*ProgressParams).Value.([]lsp.Location) {
+			chars = append(chars, loc.Range.Start.Character)
+		}
+		got = append(got, chars)
+	}
+	if want := [][]int{{10, 40}, {60}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the batches %v, want %v", got, want)
+	}
+}
+
+func TestRefStreamAndCollect(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("/p/a.go", -1, 100)
+	upper := fset.AddFile("/p/A.go", -1, 100)
+	refs := []*ast.Ident{
+		{Name: "X", NamePos: a.Pos(10)},
+		{Name: "X", NamePos: a.Pos(10)},
+		{Name: "X", NamePos: a.Pos(11)},
+		{Name: "X", NamePos: a.Pos(12)},
+		{Name: "X", NamePos: upper.Pos(10)},
+	}
+
+	var got []int
+	for _, loc := range refStreamAndCollect(fset, nil, refs, 0) {
+		got = append(got, loc.Range.Start.Character)
+	}
+	if want := []int{10, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got references at columns %v, want %v", got, want)
+	}
+}
@@ -2,28 +2,40 @@ package langserver
 
 import (
 	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"sync"
+	"unicode"
 
+	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (h *LangHandler) handleRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
 	req *jsonrpc2.Request, params lsp.RenameParams) (lsp.WorkspaceEdit, error) {
-	rp := lsp.ReferenceParams{
-		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
-			TextDocument: params.TextDocument,
-			Position:     params.Position,
-		},
-		Context: lsp.ReferenceContext{
-			IncludeDeclaration: true,
-			XLimit:             0,
-		},
-	}
-
-	references, err := h.handleTextDocumentReferences(ctx, conn, req, rp)
+	if pkgPath, oldName, ok := h.packageAt(ctx, params.TextDocument.URI, params.Position); ok {
+		return h.renamePackage(ctx, pkgPath, oldName, params.NewName)
+	}
+
+	// A rename must edit all the references, it fails instead of returning
+	// the references found before the deadline. The aliases of a type are
+	// distinct names, they are not renamed with it.
+	refs, fset, err := h.identReferences(ctx, params.TextDocument.URI, params.Position, true, false, false, nil)
 	if err != nil {
+		if deadlineExceeded(ctx) {
+			return lsp.WorkspaceEdit{}, fmt.Errorf("%s timed out after %s, nothing is renamed", req.Method, h.config.RequestTimeout)
+		}
 		return lsp.WorkspaceEdit{}, err
 	}
+	// All the references are renamed, whatever Config.ReferencesTests.
+	var references []lsp.Location
+	if fset != nil {
+		references = refStreamAndCollect(fset, h.overlay.columns.cached(), refs, 0)
+	}
 
 	result := lsp.WorkspaceEdit{}
 	if result.Changes == nil {
@@ -43,3 +55,183 @@ func (h *LangHandler) handleRename(ctx context.Context, conn jsonrpc2.JSONRPC2,
 	}
 	return result, nil
 }
+
+// packageAt returns the import path and the name of the package whose
+// package clause, or unaliased import path, is at position. The external
+// test package of a package, eg. foo_test, names the package it tests. It
+// reports false if there is no such package at position.
+func (h *LangHandler) packageAt(ctx context.Context, uri lsp.DocumentURI, position lsp.Position) (pkgPath, name string, ok bool) {
+	pkg, pos, err := h.typeCheck(ctx, uri, position)
+	if err != nil {
+		return "", "", false
+	}
+	pathNodes, err := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
+	if err != nil || len(pathNodes) < 2 {
+		return "", "", false
+	}
+
+	switch node := pathNodes[0].(type) {
+	case *ast.Ident:
+		if file, ok := pathNodes[1].(*ast.File); !ok || file.Name != node {
+			return "", "", false
+		}
+		pkgPath, name = pkg.GetPkgPath(), node.Name
+		if strings.HasSuffix(name, "_test") && strings.HasSuffix(pkgPath, "_test") {
+			pkgPath, name = strings.TrimSuffix(pkgPath, "_test"), strings.TrimSuffix(name, "_test")
+		}
+		return pkgPath, name, true
+
+	case *ast.BasicLit:
+		spec, ok := pathNodes[1].(*ast.ImportSpec)
+		if !ok || spec.Name != nil {
+			return "", "", false
+		}
+		pkgName, ok := pkg.GetTypesInfo().Implicits[spec].(*types.PkgName)
+		if !ok {
+			return "", "", false
+		}
+		return pkgName.Imported().Path(), pkgName.Imported().Name(), true
+	}
+	return "", "", false
+}
+
+// renamePackage renames the package pkgPath from oldName to newName: the
+// package clauses of its files and of its external tests, and the references
+// to it through its unaliased imports in the workspace. The aliased imports
+// already name the package otherwise, they are left as they are. Only the
+// packages of the main modules can be renamed.
+func (h *LangHandler) renamePackage(ctx context.Context, pkgPath, oldName, newName string) (lsp.WorkspaceEdit, error) {
+	if !isIdentifier(newName) || newName == "_" {
+		return lsp.WorkspaceEdit{}, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid package name %q", newName)}
+	}
+	if oldName == "main" {
+		return lsp.WorkspaceEdit{}, fmt.Errorf("cannot rename the main package %s", pkgPath)
+	}
+	if !h.project.IsMainPackage(pkgPath) {
+		return lsp.WorkspaceEdit{}, fmt.Errorf("cannot rename package %s outside of the main modules", pkgPath)
+	}
+
+	result := lsp.WorkspaceEdit{Changes: make(map[string][]lsp.TextEdit)}
+	columns := h.overlay.columns.cached()
+	var (
+		mu       sync.Mutex
+		conflict error
+	)
+	// The files of a package are in its test variants too.
+	seen := make(map[string]bool)
+	add := func(fset *token.FileSet, id *ast.Ident, newText string) {
+		uri := string(source.ToURI(fset.Position(id.Pos()).Filename))
+		r := columns.rangeForNode(fset, id)
+		mu.Lock()
+		defer mu.Unlock()
+		if key := fmt.Sprintf("%s:%s", uri, r); !seen[key] {
+			seen[key] = true
+			result.Changes[uri] = append(result.Changes[uri], lsp.TextEdit{Range: r, NewText: newText})
+		}
+	}
+
+	f := func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		inPkg := pkg.GetPkgPath() == pkgPath || pkg.GetPkgPath() == pkgPath+"_test"
+		if pkg.GetTypesInfo() == nil || !inPkg && pkg.GetImport(pkgPath) == nil {
+			return nil
+		}
+
+		fset := pkg.GetFileSet()
+		imports := make(map[*types.PkgName]bool)
+		for _, file := range pkg.GetSyntax() {
+			if inPkg && (file.Name.Name == oldName || file.Name.Name == oldName+"_test") {
+				add(fset, file.Name, newName+strings.TrimPrefix(file.Name.Name, oldName))
+			}
+			for _, spec := range file.Imports {
+				if spec.Name != nil {
+					continue
+				}
+				pkgName, ok := pkg.GetTypesInfo().Implicits[spec].(*types.PkgName)
+				if !ok || pkgName.Imported().Path() != pkgPath {
+					continue
+				}
+				imports[pkgName] = true
+				if other := packageRenameConflict(pkg, file, pkgName, newName); other != nil {
+					err := fmt.Errorf("cannot rename package %s to %s: it refers to %s in %s",
+						pkgPath, newName, types.ObjectString(other, types.RelativeTo(pkg.GetTypes())), fset.Position(file.Pos()).Filename)
+					mu.Lock()
+					conflict = err
+					mu.Unlock()
+					return err
+				}
+			}
+		}
+		if len(imports) == 0 {
+			return nil
+		}
+		for id, obj := range pkg.GetTypesInfo().Uses {
+			if pkgName, ok := obj.(*types.PkgName); ok && imports[pkgName] {
+				add(fset, id, newName)
+			}
+		}
+		return nil
+	}
+
+	err := h.project.Search(ctx, f)
+	if conflict != nil {
+		return lsp.WorkspaceEdit{}, conflict
+	}
+	if err != nil {
+		return lsp.WorkspaceEdit{}, err
+	}
+	return result, nil
+}
+
+// packageRenameConflict returns the object, other than pkgName, which newName
+// would refer to in file if pkgName, an unaliased import of file, was renamed
+// to newName: an import or a declaration of the package of the same name, a
+// local declaration in scope at a use of pkgName, or a builtin used in file,
+// which pkgName would shadow. It returns nil if there is none.
+func packageRenameConflict(pkg source.Package, file *ast.File, pkgName *types.PkgName, newName string) types.Object {
+	info := pkg.GetTypesInfo()
+	if scope := info.Scopes[file]; scope != nil {
+		if obj := scope.Lookup(newName); obj != nil && obj != pkgName {
+			return obj
+		}
+	}
+	if obj := pkg.GetTypes().Scope().Lookup(newName); obj != nil {
+		return obj
+	}
+
+	for id, obj := range info.Uses {
+		if id.Pos() < file.Pos() || id.Pos() > file.End() {
+			continue
+		}
+		if obj != pkgName {
+			if id.Name == newName && obj.Parent() == types.Universe {
+				return obj
+			}
+			continue
+		}
+		scope := pkg.GetTypes().Scope().Innermost(id.Pos())
+		if scope == nil {
+			continue
+		}
+		if _, other := scope.LookupParent(newName, id.Pos()); other != nil && other != pkgName && other.Parent() != types.Universe {
+			return other
+		}
+	}
+	return nil
+}
+
+// isIdentifier reports whether name is a Go identifier which is not a
+// keyword.
+func isIdentifier(name string) bool {
+	if name == "" || token.Lookup(name).IsKeyword() {
+		return false
+	}
+	for i, c := range name {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package langserver
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// SemanticTokensParams are the parameters of the
+// textDocument/semanticTokens/full request.
+type SemanticTokensParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokens are the tokens of a document, see encodeSemanticTokens for
+// the format of Data.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// SemanticTokensLegend maps the indexes of the encoded token types and the
+// bits of the encoded token modifiers to their names.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// The semantic token types, in the order of semanticTokenTypes.
+const (
+	tokenNamespace = iota
+	tokenType
+	tokenInterface
+	tokenFunction
+	tokenMethod
+	tokenVariable
+	tokenParameter
+	tokenProperty
+)
+
+// The semantic token modifiers, in the order of semanticTokenModifiers.
+const (
+	modifierDeclaration = 1 << iota
+	modifierReadonly
+	modifierDefaultLibrary
+)
+
+var semanticTokenTypes = []string{"namespace", "type", "interface", "function", "method", "variable", "parameter", "property"}
+
+var semanticTokenModifiers = []string{"declaration", "readonly", "defaultLibrary"}
+
+// semanticToken is an identifier of a document with its classification.
+type semanticToken struct {
+	line, start, length int // zero based, in code units of the position encoding
+	typ                 int
+	modifiers           int
+}
+
+// handleSemanticTokensFull classifies the identifiers of the whole document
+// with the type information, eg. a variable, a type or a function.
+func (h *LangHandler) handleSemanticTokensFull(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params SemanticTokensParams) (*SemanticTokens, error) {
+	pkg, file, err := h.loadPackageAndAst(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := h.semanticTokens(pkg, file)
+	return &SemanticTokens{Data: encodeSemanticTokens(tokens)}, nil
+}
+
+func (h *LangHandler) semanticTokens(pkg source.Package, file *ast.File) []semanticToken {
+	fset := pkg.GetFileSet()
+	info := pkg.GetTypesInfo()
+
+	// stdlib caches whether the packages of the objects are in the
+	// standard library.
+	stdlib := make(map[*types.Package]bool)
+	isStdlib := func(p *types.Package) bool {
+		if p == nil {
+			// The universe objects, eg. len or true.
+			return true
+		}
+		res, ok := stdlib[p]
+		if !ok {
+			if imp := pkg.GetImport(p.Path()); imp != nil {
+				res = h.project.IsStdlib(imp)
+			} else if p == pkg.GetTypes() {
+				res = h.project.IsStdlib(pkg)
+			}
+			stdlib[p] = res
+		}
+		return res
+	}
+
+	params := make(map[types.Object]bool)
+	addParams := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				if obj := info.Defs[name]; obj != nil {
+					params[obj] = true
+				}
+			}
+		}
+	}
+
+	var tokens []semanticToken
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addParams(n.Recv)
+			addParams(n.Type.Params)
+			addParams(n.Type.Results)
+		case *ast.FuncLit:
+			addParams(n.Type.Params)
+			addParams(n.Type.Results)
+		case *ast.Ident:
+			modifiers := 0
+			obj := info.Defs[n]
+			if obj != nil {
+				modifiers |= modifierDeclaration
+			} else {
+				obj = info.Uses[n]
+			}
+			if obj == nil || n.Name == "_" {
+				return true
+			}
+
+			var typ int
+			switch obj := obj.(type) {
+			case *types.PkgName:
+				typ = tokenNamespace
+			case *types.TypeName:
+				typ = tokenType
+				if types.IsInterface(obj.Type()) {
+					typ = tokenInterface
+				}
+			case *types.Func:
+				typ = tokenFunction
+				if obj.Type().(*types.Signature).Recv() != nil {
+					typ = tokenMethod
+				}
+			case *types.Builtin:
+				typ = tokenFunction
+			case *types.Var:
+				switch {
+				case obj.IsField():
+					typ = tokenProperty
+				case params[obj]:
+					typ = tokenParameter
+				default:
+					typ = tokenVariable
+				}
+			case *types.Const:
+				typ = tokenVariable
+				modifiers |= modifierReadonly
+			default:
+				// nil and labels are not classified.
+				return true
+			}
+			declPkg := obj.Pkg()
+			if pkgName, ok := obj.(*types.PkgName); ok {
+				declPkg = pkgName.Imported()
+			}
+			if isStdlib(declPkg) {
+				modifiers |= modifierDefaultLibrary
+			}
+
+			pos := fset.Position(n.Pos())
+			tokens = append(tokens, semanticToken{
+				line:      pos.Line - 1,
+				start:     h.overlay.columns.character(pos),
+				length:    codeUnits([]byte(n.Name), h.overlay.columns.encoding),
+				typ:       typ,
+				modifiers: modifiers,
+			})
+		}
+		return true
+	})
+	return tokens
+}
+
+// encodeSemanticTokens encodes tokens as the integer array of the LSP: five
+// integers per token, the line relative to the line of the previous token,
+// the start character relative to the start of the previous token if they are
+// on the same line, the length, the type and the modifiers.
+func encodeSemanticTokens(tokens []semanticToken) []uint32 {
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].line != tokens[j].line {
+			return tokens[i].line < tokens[j].line
+		}
+		return tokens[i].start < tokens[j].start
+	})
+
+	data := make([]uint32, 0, 5*len(tokens))
+	var line, start int
+	for _, t := range tokens {
+		deltaStart := t.start
+		if t.line == line {
+			deltaStart -= start
+		}
+		data = append(data, uint32(t.line-line), uint32(deltaStart), uint32(t.length), uint32(t.typ), uint32(t.modifiers))
+		line, start = t.line, t.start
+	}
+	return data
+}
@@ -25,8 +25,8 @@ func (h *LangHandler) handleTextDocumentSignatureHelp(ctx context.Context, conn
 		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, fmt.Sprintf("token file does not exist of %s", fileURI))
 	}
 
-	pos := fromProtocolPosition(tok, params.Position)
-	info, err := source.SignatureHelp(ctx, f, pos, h.project.GetBuiltinPackage(), h.DefaultConfig.EnhanceSignatureHelp)
+	pos := h.overlay.columns.fromProtocolPosition(tok, params.Position)
+	info, err := source.SignatureHelp(ctx, f, pos, h.project.GetBuiltinPackage(), h.config.EnhanceSignatureHelp)
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +59,8 @@ func toProtocolParameterInformation(info []source.ParameterInformation) []lsp.Pa
 	var result []lsp.ParameterInformation
 	for _, p := range info {
 		result = append(result, lsp.ParameterInformation{
-			Label: p.Label,
+			Label:         p.Label,
+			Documentation: p.Documentation,
 		})
 	}
 	return result
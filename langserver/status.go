@@ -0,0 +1,63 @@
+package langserver
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ServerStatus is the state of the server returned by `bingo/status`.
+type ServerStatus struct {
+	// Mode is "module" or "gopath", empty if the project is not built yet.
+	Mode string `json:"mode"`
+
+	// Modules are the root directories of the modules of the workspace.
+	Modules []string `json:"modules"`
+
+	CacheStyle string `json:"cacheStyle"`
+
+	// Cached is set if the packages of the workspace are in the global
+	// cache.
+	Cached bool `json:"cached"`
+
+	// LastBuildTime is the time of the last build of the global cache in
+	// RFC 3339 format, empty if it was never built.
+	LastBuildTime string `json:"lastBuildTime,omitempty"`
+
+	Packages     int `json:"packages"`
+	PeakPackages int `json:"peakPackages"`
+
+	// HeapAlloc and Sys are the bytes of the allocated heap objects and the
+	// bytes obtained from the system by the server.
+	HeapAlloc uint64 `json:"heapAlloc"`
+	Sys       uint64 `json:"sys"`
+}
+
+// handleStatus handles `bingo/status` requests. It does not wait for a build
+// of the project in progress, the state of the modules is the one of the
+// last build.
+func (h *LangHandler) handleStatus(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) (*ServerStatus, error) {
+	s := h.project.Status()
+	status := &ServerStatus{
+		Mode:         s.Mode,
+		Modules:      s.Modules,
+		CacheStyle:   string(s.CacheStyle),
+		Cached:       s.Cached,
+		Packages:     s.Packages,
+		PeakPackages: s.PeakPackages,
+	}
+	if status.Modules == nil {
+		status.Modules = []string{}
+	}
+	if !s.LastBuildTime.IsZero() {
+		status.LastBuildTime = s.LastBuildTime.Format(time.RFC3339)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	status.HeapAlloc = mem.HeapAlloc
+	status.Sys = mem.Sys
+	return status, nil
+}
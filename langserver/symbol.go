@@ -11,7 +11,9 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
@@ -26,9 +28,61 @@ type Query struct {
 	File, Dir string
 	Tokens    []string
 
+	// Scope is the scope of the search set by the query, empty if it sets
+	// none, Config.SymbolMainModulesOnly decides then.
+	Scope ScopeType
+
+	// Qualified are the fields of the query naming a symbol with its
+	// package, eg. bytes.Buffer. Their tokens are in Tokens too.
+	Qualified []QualifiedName
+
 	Symbol lspext.SymbolDescriptor
 }
 
+// QualifiedName is a symbol named with its package in a query, eg.
+// bytes.Buffer, bytes.Buffer.Write or github.com/foo/bar.T.
+type QualifiedName struct {
+	// Package is the import path of the package or a suffix of it.
+	Package string
+
+	// Container is the receiver type of a method or the type of a field,
+	// empty for the package level symbols.
+	Container string
+
+	Name string
+}
+
+// parseQualifiedName parses the query field f as a qualified name, it
+// reports false if f is not one.
+func parseQualifiedName(f string) (QualifiedName, bool) {
+	// The import path may contain periods before its last slash.
+	i := strings.LastIndex(f, "/")
+	parts := strings.Split(f[i+1:], ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return QualifiedName{}, false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return QualifiedName{}, false
+		}
+	}
+
+	qn := QualifiedName{Package: f[:i+1] + parts[0], Name: parts[len(parts)-1]}
+	if len(parts) == 3 {
+		qn.Container = parts[1]
+	}
+	return qn, true
+}
+
+// matches reports whether the symbol named name, in container and in the
+// package pkgPath, is qn. The names are lower case.
+func (qn QualifiedName) matches(pkgPath, container, name string) bool {
+	if pkgPath != qn.Package && !strings.HasSuffix(pkgPath, "/"+qn.Package) {
+		return false
+	}
+	return container == qn.Container && name == qn.Name
+}
+
 // String converts the query back into a logically equivalent, but not strictly
 // byte-wise equal, query string. It is useful for converting a modified query
 // structure back into a query string.
@@ -42,6 +96,9 @@ func (q Query) String() string {
 	default:
 		// no filter.
 	}
+	if q.Scope != "" {
+		s = queryJoin(s, "is:"+string(q.Scope))
+	}
 	if q.Kind != 0 {
 		for kwd, kind := range keywords {
 			if kind == q.Kind {
@@ -79,6 +136,14 @@ func ParseQuery(q string) (qu Query) {
 			qu.Filter = FilterExported
 			continue
 		}
+		if field == "is:main" || field == "is:all" {
+			qu.Scope = ScopeType(strings.TrimPrefix(field, "is:"))
+			continue
+		}
+
+		if qn, ok := parseQualifiedName(field); ok {
+			qu.Qualified = append(qu.Qualified, qn)
+		}
 
 		// Each field is split into tokens, delimited by periods or slashes.
 		tokens := strings.FieldsFunc(field, func(c rune) bool {
@@ -102,6 +167,19 @@ const (
 	FilterDir      FilterType = "dir"
 )
 
+// ScopeType is the scope of a workspace symbol search, set by `is:main` or
+// `is:all` in the query.
+type ScopeType string
+
+const (
+	// ScopeMain searches the packages of the main modules only, not their
+	// dependencies.
+	ScopeMain ScopeType = "main"
+
+	// ScopeAll searches every package of the cache.
+	ScopeAll ScopeType = "all"
+)
+
 // keywords are keyword tokens that will be interpreted as symbol kind
 // filters in the search query.
 var keywords = map[string]lsp.SymbolKind{
@@ -119,10 +197,66 @@ type symbolPair struct {
 	desc symbolDescriptor
 }
 
+// SymbolWeights are the weights of the criteria the results of
+// workspace/symbol are ranked with: the score of a symbol is the sum of the
+// weights of the criteria it matches. A query token matches the file of a
+// symbol only if it has at least three characters.
+type SymbolWeights struct {
+	// ContainerPrefix is added for each query token prefixing the container
+	// name, eg. the receiver type of a method.
+	ContainerPrefix int
+
+	// NamePrefix is added for each query token prefixing the name.
+	NamePrefix int
+
+	// ExactContainer is added for each query token equal to the container
+	// name.
+	ExactContainer int
+
+	// ExactName is added if the last query token is equal to the name.
+	ExactName int
+
+	// ExactNameNotLast is added for each other query token equal to the name.
+	ExactNameNotLast int
+
+	// FilePath is added for each query token contained in the file path.
+	FilePath int
+
+	// FileBasePrefix is added for each query token prefixing the file name.
+	FileBasePrefix int
+
+	// NonVendor is added to the symbols matching the query outside of a
+	// vendor directory.
+	NonVendor int
+
+	// Exported is added to the exported symbols matching the query.
+	Exported int
+
+	// QualifiedName is added for each qualified name of the query, eg.
+	// bytes.Buffer, naming the symbol: its package path ends with the
+	// package of the qualified name.
+	QualifiedName int
+}
+
+// defaultSymbolWeights are the default value of Config.SymbolWeights.
+var defaultSymbolWeights = SymbolWeights{
+	ContainerPrefix:  2,
+	NamePrefix:       3,
+	ExactContainer:   3,
+	ExactName:        50,
+	ExactNameNotLast: 5,
+	FilePath:         1,
+	FileBasePrefix:   2,
+	NonVendor:        5,
+	Exported:         1,
+	QualifiedName:    50,
+}
+
 // resultSorter is a utility struct for collecting, filtering, and
 // sorting symbol results.
 type resultSorter struct {
 	Query
+	weights   SymbolWeights
 	results   []scoredSymbol
 	resultsMu sync.Mutex
 }
@@ -143,6 +277,9 @@ func (s *resultSorter) Less(i, j int) bool {
 	if iscore == jscore {
 		if s.results[i].ContainerName == s.results[j].ContainerName {
 			if s.results[i].Name == s.results[j].Name {
+				if s.results[i].Location.URI == s.results[j].Location.URI {
+					return s.results[i].Location.Range.Start.Line < s.results[j].Location.Range.Start.Line
+				}
 				return s.results[i].Location.URI < s.results[j].Location.URI
 			}
 			return s.results[i].Name < s.results[j].Name
@@ -159,7 +296,7 @@ func (s *resultSorter) Swap(i, j int) {
 // symbol in the list of results if its score > 0.
 func (s *resultSorter) Collect(si symbolPair) {
 	s.resultsMu.Lock()
-	score := score(s.Query, si)
+	score := score(s.Query, si, s.weights)
 	if score > 0 {
 		sc := scoredSymbol{score, si}
 		s.results = append(s.results, sc)
@@ -167,6 +304,13 @@ func (s *resultSorter) Collect(si symbolPair) {
 	s.resultsMu.Unlock()
 }
 
+// count is a thread-safe method that returns the number of results.
+func (s *resultSorter) count() int {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+	return len(s.results)
+}
+
 // Results returns the ranked list of SymbolInformation values.
 func (s *resultSorter) Results() []lsp.SymbolInformation {
 	res := make([]lsp.SymbolInformation, len(s.results))
@@ -178,7 +322,7 @@ func (s *resultSorter) Results() []lsp.SymbolInformation {
 
 // score returns 0 for results that aren't matches. Results that are matches are assigned
 // a positive score, which should be used for ranking purposes.
-func score(q Query, s symbolPair) (scor int) {
+func score(q Query, s symbolPair, w SymbolWeights) (scor int) {
 	if q.Kind != 0 {
 		if q.Kind != s.Kind {
 			return 0
@@ -187,7 +331,7 @@ func score(q Query, s symbolPair) (scor int) {
 	if q.Symbol != nil && !s.desc.Contains(q.Symbol) {
 		return -1
 	}
-	name, container := strings.ToLower(s.Name), strings.ToLower(s.ContainerName)
+	name, container := strings.ToLower(s.Name), strings.ToLower(containerName(&s))
 	if !util.IsURI(s.Location.URI) {
 		log.Printf("unexpectedly saw symbol defined at a non-file URI: %q", s.Location.URI)
 		return 0
@@ -207,54 +351,64 @@ func score(q Query, s symbolPair) (scor int) {
 	for i, tok := range q.Tokens {
 		tok := strings.ToLower(tok)
 		if strings.HasPrefix(container, tok) {
-			scor += 2
+			scor += w.ContainerPrefix
 		}
 		if strings.HasPrefix(name, tok) {
-			scor += 3
+			scor += w.NamePrefix
 		}
 		if strings.Contains(filename, tok) && len(tok) >= 3 {
-			scor++
+			scor += w.FilePath
 		}
 		if strings.HasPrefix(path.Base(filename), tok) && len(tok) >= 3 {
-			scor += 2
+			scor += w.FileBasePrefix
 		}
 		if tok == name {
 			if i == len(q.Tokens)-1 {
-				scor += 50
+				scor += w.ExactName
 			} else {
-				scor += 5
+				scor += w.ExactNameNotLast
 			}
 		}
 		if tok == container {
-			scor += 3
+			scor += w.ExactContainer
+		}
+	}
+	for _, qn := range q.Qualified {
+		if qn.matches(strings.ToLower(s.desc.Package), container, name) {
+			scor += w.QualifiedName
 		}
 	}
 	if scor > 0 && !(strings.HasPrefix(filename, "vendor/") || strings.Contains(filename, "/vendor/")) {
 		// boost for non-vendor symbols
-		scor += 5
+		scor += w.NonVendor
 	}
 	if scor > 0 && ast.IsExported(s.Name) {
 		// boost for exported symbols
-		scor++
+		scor += w.Exported
 	}
 	return scor
 }
 
 // toSym returns a SymbolInformation value derived from values we get
-// from visiting the Go ast.
-func toSym(name string, pkg source.Package, container string, recv string, kind lsp.SymbolKind, fs *token.FileSet, pos token.Pos) symbolPair {
+// from visiting the Go ast. recv is the receiver type name of a method, its
+// container is formatted as in go doc, eg. "(*T)".
+func toSym(name string, pkg source.Package, container string, recv string, kind lsp.SymbolKind, fs *token.FileSet, columns *columnMapper, pos token.Pos) symbolPair {
+	parent := container
+	if recv != "" {
+		parent = recv
+	}
 	var id string
-	if container == "" {
+	if parent == "" {
 		id = fmt.Sprintf("%s/-/%s", path.Clean(pkg.GetPkgPath()), name)
 	} else {
-		id = fmt.Sprintf("%s/-/%s/%s", path.Clean(pkg.GetPkgPath()), container, name)
+		id = fmt.Sprintf("%s/-/%s/%s", path.Clean(pkg.GetPkgPath()), parent, name)
 	}
 
 	return symbolPair{
 		SymbolInformation: lsp.SymbolInformation{
 			Name:          name,
 			Kind:          kind,
-			Location:      goRangeToLSPLocation(fs, pos, name),
+			Location:      columns.goRangeToLSPLocation(fs, pos, name),
 			ContainerName: container,
 		},
 		// NOTE: fields must be kept in sync with workspace_refs.go:defSymbolDescriptor
@@ -277,7 +431,7 @@ func (h *LangHandler) handleTextDocumentSymbol(ctx context.Context, conn jsonrpc
 		return nil, err
 	}
 
-	symbols := astFileToSymbols(pkg, astFile)
+	symbols := h.overlay.symbols.fileSymbols(span.FromDocumentURI(params.TextDocument.URI), pkg, astFile)
 	res := make([]lsp.SymbolInformation, len(symbols))
 	for i, s := range symbols {
 		res[i] = s.SymbolInformation
@@ -305,11 +459,25 @@ func (h *LangHandler) handleWorkspaceSymbol(ctx context.Context, conn jsonrpc2.J
 		// refine the query.
 		params.Limit = 50
 	}
-	return h.handleSymbol(ctx, conn, req, q, params.Limit)
+	if max := h.config.SymbolMaxResults; max > 0 && params.Limit > max {
+		params.Limit = max
+	}
+	symbols, truncated, err := h.handleSymbol(ctx, conn, req, q, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		h.notifyInfo(fmt.Sprintf("workspace/symbol: only the first %d results are returned, refine your query to see the others", params.Limit))
+	}
+	return symbols, nil
 }
 
-func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, query Query, limit int) ([]lsp.SymbolInformation, error) {
-	results := resultSorter{Query: query, results: make([]scoredSymbol, 0)}
+// handleSymbol returns the limit best symbols matching query, and whether
+// there were more matching symbols.
+func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, query Query, limit int) ([]lsp.SymbolInformation, bool, error) {
+	results := resultSorter{Query: query, weights: h.config.SymbolWeights, results: make([]scoredSymbol, 0)}
+	mainOnly := query.Scope == ScopeMain || query.Scope == "" && h.config.SymbolMainModulesOnly
+	serial := h.config.MaxParallelism <= 1
 
 	f := func(pkg source.Package) error {
 		// If the context is cancelled, breaking the loop here
@@ -319,6 +487,10 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 			return ctx.Err()
 		}
 
+		if mainOnly && (pkg.GetPkgPath() == cache.BuiltinPkg || !h.project.IsMainPackage(pkg.GetPkgPath())) {
+			return nil
+		}
+
 		if results.Query.File != "" {
 			found := false
 			for _, file := range pkg.GetFilenames() {
@@ -337,43 +509,59 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 			return nil
 		}
 
-		if len(results.results) >= limit {
+		if h.config.ExcludeInternalPackages && cache.IsInternalPackage(pkg.GetPkgPath()) {
 			return nil
 		}
 
-		h.collectFromPkg(pkg, &results)
+		// One more result than the limit tells that the results are
+		// truncated. The packages are only walked in the order of their
+		// ranks by a serial search, a parallel search collects them all
+		// so that the results do not depend on the scheduling.
+		if limit > 0 && serial && results.count() > limit {
+			return nil
+		}
 
-		return nil
+		return h.collectFromPkg(ctx, pkg, &results)
 	}
 
-	err := h.project.Search(f)
+	err := h.project.Search(ctx, f)
 	if err != nil {
-		return nil, err
+		if !deadlineExceeded(ctx) {
+			return nil, false, err
+		}
+		h.notifyPartialResults(req.Method)
 	}
 
 	sort.Sort(&results)
-	if len(results.results) > limit && limit > 0 {
+	truncated := limit > 0 && len(results.results) > limit
+	if truncated {
 		results.results = results.results[:limit]
 	}
 
-	return results.Results(), nil
+	return results.Results(), truncated, nil
 }
 
 // collectFromPkg collects all the symbols from the specified package
-// into the results. It uses LangHandler's package symbol cache to
-// speed up repeated calls.
-func (h *LangHandler) collectFromPkg(pkg source.Package, results *resultSorter) {
-	symbols := astPkgToSymbols(pkg)
+// into the results. It uses the package symbol cache of the overlay to
+// speed up repeated calls, only the symbols its index finds for the query
+// are scored. It stops with the error of ctx if it is cancelled.
+func (h *LangHandler) collectFromPkg(ctx context.Context, pkg source.Package, results *resultSorter) error {
+	symbols := h.overlay.symbols.pkgCandidates(pkg, results.Query)
 	if symbols == nil {
-		return
+		return nil
 	}
 
+	checker := cancelChecker{ctx: ctx}
 	for _, sym := range symbols {
+		if err := checker.err(); err != nil {
+			return err
+		}
 		if results.Query.Filter == FilterExported && !isExported(&sym) {
 			continue
 		}
 		results.Collect(sym)
 	}
+	return nil
 }
 
 // SymbolCollector stores symbol information for an AST
@@ -381,6 +569,10 @@ type SymbolCollector struct {
 	pkgSyms []symbolPair
 	pkg     source.Package
 	fs      *token.FileSet
+	columns *columnMapper
+
+	// embeddedFields collects the embedded struct fields.
+	embeddedFields bool
 }
 
 func recvString(recv ast.Expr) string {
@@ -393,6 +585,21 @@ func recvString(recv ast.Expr) string {
 	return "BADRECV"
 }
 
+// embeddedFieldName returns the identifier naming the field which embeds the
+// type typ, eg. Reader for io.Reader or *io.Reader, or nil if typ cannot be
+// embedded.
+func embeddedFieldName(typ ast.Expr) *ast.Ident {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedFieldName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	}
+	return nil
+}
+
 func specNames(specs []ast.Spec) []string {
 	names := make([]string, 0, len(specs))
 	for _, s := range specs {
@@ -404,20 +611,24 @@ func specNames(specs []ast.Spec) []string {
 	return names
 }
 
-func (c *SymbolCollector) addSymbol(name string, recv string, container string, kind lsp.SymbolKind, pos token.Pos) {
-	c.pkgSyms = append(c.pkgSyms, toSym(name, c.pkg, recv, container, kind, c.fs, pos))
+func (c *SymbolCollector) addSymbol(name string, container string, recv string, kind lsp.SymbolKind, pos token.Pos) {
+	c.pkgSyms = append(c.pkgSyms, toSym(name, c.pkg, container, recv, kind, c.fs, c.columns, pos))
 }
 
 func (c *SymbolCollector) addFuncDecl(fun *ast.FuncDecl) {
 	if fun.Recv != nil {
 		// methods
-		recvTypeName := ""
 		var typ ast.Expr
 		if list := fun.Recv.List; len(list) == 1 {
 			typ = list[0].Type
 		}
-		recvTypeName = recvString(typ)
-		c.addSymbol(fun.Name.Name, recvTypeName, recvTypeName, lsp.SKMethod, fun.Name.NamePos)
+		container := recvString(typ)
+		recvTypeName := strings.TrimLeft(container, "*")
+		if recvTypeName != container {
+			// The pointer receivers are formatted as in go doc.
+			container = "(" + container + ")"
+		}
+		c.addSymbol(fun.Name.Name, container, recvTypeName, lsp.SKMethod, fun.Name.NamePos)
 		return
 	}
 	// ordinary function
@@ -432,6 +643,11 @@ func (c *SymbolCollector) addContainer(containerName string, fields *ast.FieldLi
 				for _, fieldName := range field.Names {
 					c.addSymbol(fieldName.Name, containerName, "", lsp.SKField, fieldName.NamePos)
 				}
+			} else if c.embeddedFields && containerKind == lsp.SKClass {
+				// The embedded interfaces of an interface are not fields.
+				if name := embeddedFieldName(field.Type); name != nil {
+					c.addSymbol(name.Name, containerName, "", lsp.SKField, name.NamePos)
+				}
 			}
 		}
 	}
@@ -473,9 +689,9 @@ func (c *SymbolCollector) Visit(n ast.Node) (w ast.Visitor) {
 	return c
 }
 
-func astPkgToSymbols(pkg source.Package) []symbolPair {
+func astPkgToSymbols(pkg source.Package, columns *columnMapper, embeddedFields bool) []symbolPair {
 	var pkgSyms []symbolPair
-	symbolCollector := &SymbolCollector{pkgSyms, pkg, pkg.GetFileSet()}
+	symbolCollector := &SymbolCollector{pkgSyms, pkg, pkg.GetFileSet(), columns, embeddedFields}
 
 	for _, src := range pkg.GetSyntax() {
 		ast.Walk(symbolCollector, src)
@@ -484,9 +700,9 @@ func astPkgToSymbols(pkg source.Package) []symbolPair {
 	return symbolCollector.pkgSyms
 }
 
-func astFileToSymbols(pkg source.Package, astFile *ast.File) []symbolPair {
+func astFileToSymbols(pkg source.Package, astFile *ast.File, columns *columnMapper, embeddedFields bool) []symbolPair {
 	var pkgSymbols []symbolPair
-	symbolCollector := &SymbolCollector{pkgSymbols, pkg, pkg.GetFileSet()}
+	symbolCollector := &SymbolCollector{pkgSymbols, pkg, pkg.GetFileSet(), columns, embeddedFields}
 	ast.Walk(symbolCollector, astFile)
 	return symbolCollector.pkgSyms
 }
@@ -513,8 +729,18 @@ func declNamePos(decl *ast.GenDecl, name string) token.Pos {
 }
 
 func isExported(sym *symbolPair) bool {
-	if sym.ContainerName == "" {
+	container := containerName(sym)
+	if container == "" {
 		return ast.IsExported(sym.Name)
 	}
-	return ast.IsExported(sym.ContainerName) && ast.IsExported(sym.Name)
+	return ast.IsExported(container) && ast.IsExported(sym.Name)
+}
+
+// containerName returns the container name of sym, the receiver type name if
+// it is a method, whose container is formatted, eg. "(*T)".
+func containerName(sym *symbolPair) string {
+	if sym.desc.Recv != "" {
+		return sym.desc.Recv
+	}
+	return sym.ContainerName
 }
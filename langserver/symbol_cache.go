@@ -0,0 +1,140 @@
+package langserver
+
+import (
+	"go/ast"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// symbolCache caches the symbols of the documents, keyed by their URI, and of
+// the packages, keyed by their path.
+//
+// The content version of an entry is the syntax it was computed from: a
+// changed file is parsed again, so an entry whose *ast.File differs from the
+// current one is stale, whether the file was changed in the editor or on the
+// disk. The overlay also invalidates the entries of a document as soon as it
+// changes, so that they do not outlive it.
+type symbolCache struct {
+	mu      sync.Mutex
+	columns *columnMapper
+	files   map[span.URI]fileSymbols
+	pkgs    map[string]pkgSymbols
+
+	// embeddedFields includes the embedded struct fields in the symbols.
+	embeddedFields bool
+}
+
+type fileSymbols struct {
+	file    *ast.File
+	symbols []symbolPair
+}
+
+type pkgSymbols struct {
+	filenames []string
+	files     []*ast.File
+	symbols   []symbolPair
+	index     *symbolIndex
+}
+
+func newSymbolCache(columns *columnMapper, embeddedFields bool) *symbolCache {
+	return &symbolCache{
+		columns:        columns,
+		files:          make(map[span.URI]fileSymbols),
+		pkgs:           make(map[string]pkgSymbols),
+		embeddedFields: embeddedFields,
+	}
+}
+
+// fileSymbols returns the symbols of file, the syntax of the document uri in
+// pkg. The returned slice must not be modified.
+func (c *symbolCache) fileSymbols(uri span.URI, pkg source.Package, file *ast.File) []symbolPair {
+	c.mu.Lock()
+	entry, ok := c.files[uri]
+	c.mu.Unlock()
+	if ok && entry.file == file {
+		return entry.symbols
+	}
+
+	symbols := astFileToSymbols(pkg, file, c.columns, c.embeddedFields)
+	c.mu.Lock()
+	c.files[uri] = fileSymbols{file: file, symbols: symbols}
+	c.mu.Unlock()
+	return symbols
+}
+
+// pkgSymbols returns the symbols of all the files of pkg. The returned slice
+// must not be modified.
+func (c *symbolCache) pkgSymbols(pkg source.Package) []symbolPair {
+	return c.pkgEntry(pkg).symbols
+}
+
+// pkgCandidates returns the symbols of pkg which may match the query q of
+// workspace/symbol, found with the index of the package. The returned slice
+// must not be modified.
+func (c *symbolCache) pkgCandidates(pkg source.Package, q Query) []symbolPair {
+	entry := c.pkgEntry(pkg)
+	indices, all := entry.index.candidates(q)
+	if all {
+		return entry.symbols
+	}
+	symbols := make([]symbolPair, len(indices))
+	for i, index := range indices {
+		symbols[i] = entry.symbols[index]
+	}
+	return symbols
+}
+
+// pkgEntry returns the entry of pkg, computing its symbols and their index
+// if the package changed since they were.
+func (c *symbolCache) pkgEntry(pkg source.Package) pkgSymbols {
+	files := pkg.GetSyntax()
+	c.mu.Lock()
+	entry, ok := c.pkgs[pkg.GetPkgPath()]
+	c.mu.Unlock()
+	if ok && sameFiles(entry.files, files) {
+		return entry
+	}
+
+	symbols := astPkgToSymbols(pkg, c.columns, c.embeddedFields)
+	entry = pkgSymbols{filenames: pkg.GetFilenames(), files: files, symbols: symbols, index: newSymbolIndex(symbols)}
+	c.mu.Lock()
+	c.pkgs[pkg.GetPkgPath()] = entry
+	c.mu.Unlock()
+	return entry
+}
+
+// invalidate drops the entries of the document uri and of the packages
+// containing it.
+func (c *symbolCache) invalidate(uri span.URI) {
+	filename, err := uri.Filename()
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, uri)
+	for pkgPath, entry := range c.pkgs {
+		for _, name := range entry.filenames {
+			if util.PathEqual(name, filename) {
+				delete(c.pkgs, pkgPath)
+				break
+			}
+		}
+	}
+}
+
+func sameFiles(a, b []*ast.File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package langserver
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// symbolTestPackage is the part of a package used to collect symbols.
+type symbolTestPackage struct {
+	source.Package
+	fset      *token.FileSet
+	files     []*ast.File
+	filenames []string
+}
+
+func (p *symbolTestPackage) GetPkgPath() string         { return "example.com/p" }
+func (p *symbolTestPackage) GetName() string            { return "p" }
+func (p *symbolTestPackage) GetFileSet() *token.FileSet { return p.fset }
+func (p *symbolTestPackage) GetSyntax() []*ast.File     { return p.files }
+func (p *symbolTestPackage) GetFilenames() []string     { return p.filenames }
+
+func parseSymbolTestPackage(t testing.TB, src string) *symbolTestPackage {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/src/p/a.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &symbolTestPackage{fset: fset, files: []*ast.File{file}, filenames: []string{"/src/p/a.go"}}
+}
+
+func TestSymbolCache(t *testing.T) {
+	uri := span.FileURI("/src/p/a.go")
+	c := newSymbolCache(nil, false)
+
+	pkg := parseSymbolTestPackage(t, "package p; func A() {}")
+	syms := c.fileSymbols(uri, pkg, pkg.files[0])
+	if len(syms) != 1 || syms[0].Name != "A" {
+		t.Fatalf("got symbols %v, want A", syms)
+	}
+	if got := c.pkgSymbols(pkg); len(got) != 1 {
+		t.Fatalf("got package symbols %v, want A", got)
+	}
+	if got := c.fileSymbols(uri, pkg, pkg.files[0]); &got[0] != &syms[0] {
+		t.Error("the symbols of an unchanged file were not cached")
+	}
+
+	// A file parsed again is a new content version.
+	changed := parseSymbolTestPackage(t, "package p; func B() {}")
+	if got := c.fileSymbols(uri, changed, changed.files[0]); len(got) != 1 || got[0].Name != "B" {
+		t.Errorf("got symbols %v of the changed file, want B", got)
+	}
+	if got := c.pkgSymbols(changed); len(got) != 1 || got[0].Name != "B" {
+		t.Errorf("got symbols %v of the changed package, want B", got)
+	}
+
+	c.invalidate(uri)
+	if len(c.files) != 0 || len(c.pkgs) != 0 {
+		t.Errorf("got %d file and %d package entries after invalidate, want none", len(c.files), len(c.pkgs))
+	}
+}
+
+func BenchmarkDocumentSymbols(b *testing.B) {
+	var src strings.Builder
+	src.WriteString("package p\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&src, "type T%d struct{ A, B int }\nfunc (T%d) M() {}\nfunc F%d() {}\nvar V%d = %d\n", i, i, i, i, i)
+	}
+	pkg := parseSymbolTestPackage(b, src.String())
+	uri := span.FileURI("/src/p/a.go")
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			astFileToSymbols(pkg, pkg.files[0], nil, false)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := newSymbolCache(nil, false)
+		for i := 0; i < b.N; i++ {
+			c.fileSymbols(uri, pkg, pkg.files[0])
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package langserver
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// symbolIndexPrefixLen is the length of the longest name and container
+// prefixes indexed, in bytes.
+const symbolIndexPrefixLen = 3
+
+// symbolIndex prunes the symbols of a package which cannot match a query of
+// workspace/symbol, so that only a few of them are scored.
+//
+// A symbol scores only if a query token prefixes its name or its container,
+// or is contained in its file path. The index maps the lower case prefixes
+// of the names and of the containers, up to symbolIndexPrefixLen bytes, to
+// the symbols, and groups the symbols by file, whose paths are few. The
+// candidates are then ranked by score as the other symbols would be.
+type symbolIndex struct {
+	prefixes map[string][]int
+	files    []indexedFile
+}
+
+// indexedFile is the path of a file and the indices of its symbols.
+type indexedFile struct {
+	path    string
+	symbols []int
+}
+
+func newSymbolIndex(symbols []symbolPair) *symbolIndex {
+	ix := &symbolIndex{prefixes: make(map[string][]int)}
+	files := make(map[string]int)
+	for i := range symbols {
+		s := &symbols[i]
+		name, container := strings.ToLower(s.Name), strings.ToLower(containerName(s))
+		for n := 1; n <= symbolIndexPrefixLen && n <= len(name); n++ {
+			ix.add(name[:n], i)
+		}
+		for n := 1; n <= symbolIndexPrefixLen && n <= len(container); n++ {
+			ix.add(container[:n], i)
+		}
+
+		path := util.UriToPath(s.Location.URI)
+		f, ok := files[path]
+		if !ok {
+			f = len(ix.files)
+			files[path] = f
+			ix.files = append(ix.files, indexedFile{path: path})
+		}
+		ix.files[f].symbols = append(ix.files[f].symbols, i)
+	}
+	return ix
+}
+
+// add adds the symbol i to the prefix, once if its name and its container
+// have the prefix.
+func (ix *symbolIndex) add(prefix string, i int) {
+	indices := ix.prefixes[prefix]
+	if n := len(indices); n > 0 && indices[n-1] == i {
+		return
+	}
+	ix.prefixes[prefix] = append(indices, i)
+}
+
+// candidates returns the indices of the symbols which may match q, in
+// increasing order, or all if every symbol may match it, eg. if q has no
+// token.
+func (ix *symbolIndex) candidates(q Query) (indices []int, all bool) {
+	if len(q.Tokens) == 0 {
+		return nil, true
+	}
+
+	// The names of the qualified names are tokens, unless they are
+	// keywords, eg. the field of pkg.field.
+	tokens := q.Tokens
+	for _, qn := range q.Qualified {
+		tokens = append(tokens[:len(tokens):len(tokens)], qn.Name)
+	}
+
+	seen := make(map[int]bool)
+	for _, tok := range tokens {
+		tok = strings.ToLower(tok)
+		key := tok
+		if len(key) > symbolIndexPrefixLen {
+			key = key[:symbolIndexPrefixLen]
+		}
+		for _, i := range ix.prefixes[key] {
+			seen[i] = true
+		}
+		if len(tok) >= 3 {
+			for _, f := range ix.files {
+				if strings.Contains(f.path, tok) {
+					for _, i := range f.symbols {
+						seen[i] = true
+					}
+				}
+			}
+		}
+	}
+
+	indices = make([]int, 0, len(seen))
+	for i := range seen {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices, false
+}
@@ -0,0 +1,112 @@
+package langserver
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+// parseSymbolIndexTestPackage returns a package of n files, each declaring a
+// struct with fields and methods, a function, a variable and a constant.
+func parseSymbolIndexTestPackage(tb testing.TB, n int) *symbolTestPackage {
+	pkg := &symbolTestPackage{fset: token.NewFileSet()}
+	for i := 0; i < n; i++ {
+		filename := fmt.Sprintf("/src/p/file%d.go", i)
+		src := fmt.Sprintf(`package p
+
+type Handler%d struct{ Name, addr string }
+
+func (h *Handler%d) ServeRequest() {}
+
+func (Handler%d) close() {}
+
+func NewHandler%d() *Handler%d { return nil }
+
+var defaultTimeout%d = 0
+
+const MaxConns%d = 10
+`, i, i, i, i, i, i, i)
+		file, err := parser.ParseFile(pkg.fset, filename, src, 0)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pkg.files = append(pkg.files, file)
+		pkg.filenames = append(pkg.filenames, filename)
+	}
+	return pkg
+}
+
+// scoreSymbols returns the names of the symbols scoring for q, in order.
+func scoreSymbols(q Query, symbols []symbolPair) []string {
+	var names []string
+	for _, s := range symbols {
+		if score(q, s, defaultSymbolWeights) > 0 {
+			names = append(names, containerName(&s)+"."+s.Name)
+		}
+	}
+	return names
+}
+
+func TestSymbolIndex(t *testing.T) {
+	pkg := parseSymbolIndexTestPackage(t, 20)
+	symbols := astPkgToSymbols(pkg, nil, false)
+	ix := newSymbolIndex(symbols)
+
+	for _, query := range []string{
+		"",
+		"h",
+		"handler1",
+		"serve",
+		"handler12 close",
+		"ADDR",
+		"file3",
+		"p.Handler2",
+		"p.Handler2.ServeRequest",
+		"method serverequest",
+		"nomatch",
+	} {
+		q := ParseQuery(query)
+		indices, all := ix.candidates(q)
+		candidates := symbols
+		if !all {
+			candidates = nil
+			for _, i := range indices {
+				candidates = append(candidates, symbols[i])
+			}
+		}
+		if got, want := scoreSymbols(q, candidates), scoreSymbols(q, symbols); !reflect.DeepEqual(got, want) {
+			t.Errorf("query %q: got %v, want %v", query, got, want)
+		}
+		if query == "serve" && len(candidates) != 20 {
+			t.Errorf("query %q: got %d candidates, want the 20 methods", query, len(candidates))
+		}
+	}
+}
+
+func BenchmarkWorkspaceSymbols(b *testing.B) {
+	pkg := parseSymbolIndexTestPackage(b, 1000)
+
+	// The first query matches a method of each file, the second one no
+	// prefix of a name.
+	for _, query := range []string{"serverequest", "timeout"} {
+		q := ParseQuery(query)
+		b.Run("scan-"+query, func(b *testing.B) {
+			c := newSymbolCache(nil, false)
+			c.pkgSymbols(pkg)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scoreSymbols(q, c.pkgSymbols(pkg))
+			}
+		})
+		b.Run("index-"+query, func(b *testing.B) {
+			c := newSymbolCache(nil, false)
+			c.pkgSymbols(pkg)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scoreSymbols(q, c.pkgCandidates(pkg, q))
+			}
+		})
+	}
+}
@@ -117,7 +117,7 @@ func Test_resultSorter(t *testing.T) {
 	}}
 
 	for _, test := range tests {
-		results := resultSorter{Query: ParseQuery(test.rawQuery)}
+		results := resultSorter{Query: ParseQuery(test.rawQuery), weights: defaultSymbolWeights}
 		for _, s := range test.allSymbols {
 			results.Collect(symbolPair{SymbolInformation: s})
 		}
@@ -141,12 +141,15 @@ func TestQueryString(t *testing.T) {
 		{input: "dir:foo bar", expect: "dir:foo bar"},
 		{input: "is:exported bar baz", expect: "is:exported bar baz"},
 		{input: "dir:foo bar baz", expect: "dir:foo bar baz"},
+		{input: "is:main bar", expect: "is:main bar"},
+		{input: "is:exported is:all bar", expect: "is:exported is:all bar"},
 
 		// Test guarantee of byte-wise ordering (hint: we only guarantee logical
 		// equivalence, not byte-wise equality).
 		{input: "bar baz is:exported", expect: "is:exported bar baz"},
 		{input: "bar baz dir:foo", expect: "dir:foo bar baz"},
 		{input: "func baz dir:foo", expect: "dir:foo func baz"},
+		{input: "bar is:main", expect: "is:main bar"},
 	}
 	for _, test := range tests {
 		test := test
@@ -160,3 +163,96 @@ func TestQueryString(t *testing.T) {
 		})
 	}
 }
+
+func Test_scoreDefaultWeights(t *testing.T) {
+	t.Parallel()
+
+	// The scores of the default weights are pinned, so that tuning them
+	// does not regress the ranking of the common queries.
+	tests := []struct {
+		rawQuery  string
+		container string
+		name      string
+		uri       lsp.DocumentURI
+		want      int
+	}{
+		{"", "foo", "Bar", "file:///foo.go", 2},
+		{"bar", "foo", "Bar", "file:///file.go", 59},
+		{"bar", "foo", "bar", "file:///file.go", 58},
+		{"ba", "foo", "bar", "file:///file.go", 8},
+		{"foo.bar", "foo", "bar", "file:///file.go", 63},
+		{"foo bar", "foo", "Bar", "file:///file.go", 64},
+		{"bar", "foo", "Bar", "file:///bar/vendor/x.go", 55},
+		{"file", "foo", "bar", "file:///file.go", 8},
+		{"zzz", "foo", "bar", "file:///file.go", 0},
+	}
+	for _, test := range tests {
+		s := symbolPair{SymbolInformation: lsp.SymbolInformation{
+			ContainerName: test.container, Name: test.name,
+			Location: lsp.Location{URI: test.uri},
+			Kind:     lsp.SKFunction,
+		}}
+		if got := score(ParseQuery(test.rawQuery), s, defaultSymbolWeights); got != test.want {
+			t.Errorf("score(%q, %s.%s in %s) = %d, want %d", test.rawQuery, test.container, test.name, test.uri, got, test.want)
+		}
+	}
+}
+
+func TestQualifiedNameQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rawQuery string
+		want     []QualifiedName
+	}{
+		{"buffer", nil},
+		{"bytes.buffer", []QualifiedName{{Package: "bytes", Name: "buffer"}}},
+		{"bytes.buffer.write", []QualifiedName{{Package: "bytes", Container: "buffer", Name: "write"}}},
+		{"github.com/foo/bar.t", []QualifiedName{{Package: "github.com/foo/bar", Name: "t"}}},
+		{"github.com/foo/bar", nil},
+		{"bytes. buffer", nil},
+	}
+	for _, test := range tests {
+		if got := ParseQuery(test.rawQuery).Qualified; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q).Qualified = %v, want %v", test.rawQuery, got, test.want)
+		}
+	}
+
+	symbol := func(pkgPath, recv, name string) symbolPair {
+		return symbolPair{
+			SymbolInformation: lsp.SymbolInformation{Name: name, Location: lsp.Location{URI: "file:///file.go"}},
+			desc:              symbolDescriptor{Package: pkgPath, Recv: recv, Name: name},
+		}
+	}
+	qualified, other := symbol("bytes", "", "Buffer"), symbol("github.com/foo/bar", "", "Buffer")
+	q := ParseQuery("bytes.Buffer")
+	if got, want := score(q, qualified, defaultSymbolWeights)-score(q, other, defaultSymbolWeights), defaultSymbolWeights.QualifiedName; got != want {
+		t.Errorf("got a boost of %d for bytes.Buffer, want %d", got, want)
+	}
+	method := symbol("github.com/foo/bytes", "Buffer", "Write")
+	if got := score(ParseQuery("bytes.Buffer.Write"), method, SymbolWeights{QualifiedName: 1}); got != 1 {
+		t.Errorf("got score %d for the method bytes.Buffer.Write, want 1", got)
+	}
+}
+
+func TestEmbeddedFieldSymbols(t *testing.T) {
+	t.Parallel()
+
+	pkg := parseSymbolTestPackage(t, `package p; import "io"; type T struct{ io.Reader; *S; F int }; type S struct{}; type I interface{ io.Closer }`)
+	symbols := func(embeddedFields bool) []string {
+		var res []string
+		for _, s := range astFileToSymbols(pkg, pkg.files[0], nil, embeddedFields) {
+			if s.Kind == lsp.SKField {
+				res = append(res, s.ContainerName+"."+s.Name)
+			}
+		}
+		return res
+	}
+
+	if got, want := symbols(false), []string{"T.F"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got fields %q, want %q", got, want)
+	}
+	if got, want := symbols(true), []string{"T.Reader", "T.S", "T.F"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got fields %q with the embedded fields, want %q", got, want)
+	}
+}
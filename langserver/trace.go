@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The values of the protocol tracing, set by the trace of the initialize
+// request and by $/setTrace.
+const (
+	traceOff      = "off"
+	traceMessages = "messages"
+	traceVerbose  = "verbose"
+)
+
+// setTrace sets the protocol tracing to value, an unknown value turns it off.
+func (h *LangHandler) setTrace(value string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch value {
+	case traceMessages, traceVerbose:
+		h.trace = value
+	default:
+		h.trace = traceOff
+	}
+}
+
+func (h *LangHandler) getTrace() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.trace
+}
+
+// logTrace sends the $/logTrace notification of the request req, handled in
+// elapsed with err. The params of the request are added if the trace is
+// verbose.
+func (h *LangHandler) logTrace(conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, trace string, elapsed time.Duration, err error) {
+	kind := "request"
+	if req.Notif {
+		kind = "notification"
+	}
+	params := LogTraceParams{Message: fmt.Sprintf("Handled %s '%s' in %s", kind, req.Method, elapsed)}
+	if !req.Notif {
+		params.Message = fmt.Sprintf("Handled %s '%s - (%s)' in %s", kind, req.Method, req.ID, elapsed)
+	}
+	if err != nil {
+		params.Message += fmt.Sprintf(", failed: %s", err)
+	}
+	if trace == traceVerbose && req.Params != nil {
+		if b, err := json.Marshal(req.Params); err == nil {
+			params.Verbose = "Params: " + string(b)
+		}
+	}
+	_ = conn.Notify(context.Background(), "$/logTrace", &params)
+}
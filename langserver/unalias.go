@@ -0,0 +1,12 @@
+//go:build !go1.22
+// +build !go1.22
+
+package langserver
+
+import "go/types"
+
+// unalias returns the type denoted by t, which is never an alias before Go
+// 1.22: the type of an alias is the type it denotes.
+func unalias(t types.Type) types.Type {
+	return t
+}
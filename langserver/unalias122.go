@@ -0,0 +1,12 @@
+//go:build go1.22
+// +build go1.22
+
+package langserver
+
+import "go/types"
+
+// unalias returns the type denoted by t, following the chain of aliases
+// represented by *types.Alias since Go 1.22.
+func unalias(t types.Type) types.Type {
+	return types.Unalias(t)
+}
@@ -0,0 +1,187 @@
+package langserver
+
+import (
+	"context"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// listUnusedExported returns the locations of the exported functions, types
+// and methods of the main modules which are used nowhere in the cached
+// packages. If excludeTests is set, the uses in the test files do not count.
+//
+// It is a batch version of the references of each symbol: the cache is
+// walked once, collecting both the declared and the used symbols. A method
+// which has the name of a method of an interface is not reported, it may be
+// called through the interface, eg. String or Error. The declarations of the
+// test files are not reported either.
+func (h *LangHandler) listUnusedExported(ctx context.Context, excludeTests bool) ([]lsp.Location, error) {
+	mainPkgs := make(map[string]bool)
+	for _, pkg := range h.project.Packages() {
+		if pkg.IsMain {
+			mainPkgs[pkg.PkgPath] = true
+		}
+	}
+
+	var (
+		mu              sync.Mutex
+		declared        = make(map[string]unusedSymbol)
+		used            = make(map[string]bool)
+		interfaceMethod = make(map[string]bool)
+	)
+	columns := h.overlay.columns.cached()
+	for _, name := range interfaceMethods(types.Universe.Lookup("error")) {
+		interfaceMethod[name] = true
+	}
+
+	f := func(pkg source.Package) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		info := pkg.GetTypesInfo()
+		if info == nil || pkg.GetTypes() == nil {
+			return nil
+		}
+		fset := pkg.GetFileSet()
+		isTestFile := func(pos token.Pos) bool {
+			tok := fset.File(pos)
+			return tok != nil && strings.HasSuffix(tok.Name(), "_test.go")
+		}
+
+		decls := make(map[string]unusedSymbol)
+		declare := func(obj types.Object, method bool) {
+			if obj.Exported() && !isTestFile(obj.Pos()) {
+				decls[symbolKey(obj)] = unusedSymbol{
+					loc:    columns.goRangeToLSPLocation(fset, obj.Pos(), obj.Name()),
+					method: method,
+					name:   obj.Name(),
+				}
+			}
+		}
+		var methods []string
+		scope := pkg.GetTypes().Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			methods = append(methods, interfaceMethods(obj)...)
+			if !mainPkgs[pkg.GetPkgPath()] {
+				continue
+			}
+			switch obj := obj.(type) {
+			case *types.Func:
+				declare(obj, false)
+			case *types.TypeName:
+				declare(obj, false)
+				if named, ok := obj.Type().(*types.Named); ok && !types.IsInterface(named) {
+					for i := 0; i < named.NumMethods(); i++ {
+						declare(named.Method(i), true)
+					}
+				}
+			}
+		}
+
+		var uses []string
+		checker := cancelChecker{ctx: ctx}
+		for id, obj := range info.Uses {
+			if err := checker.err(); err != nil {
+				return err
+			}
+			if obj.Pkg() == nil || !obj.Exported() || !mainPkgs[obj.Pkg().Path()] || !isSymbol(obj) {
+				continue
+			}
+			if excludeTests && isTestFile(id.Pos()) {
+				continue
+			}
+			uses = append(uses, symbolKey(obj))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for key, symbol := range decls {
+			declared[key] = symbol
+		}
+		for _, key := range uses {
+			used[key] = true
+		}
+		for _, name := range methods {
+			interfaceMethod[name] = true
+		}
+		return nil
+	}
+
+	if err := h.project.SearchWithProgress(ctx, "Searching unused exported symbols", f); err != nil {
+		return nil, err
+	}
+
+	locs := []lsp.Location{}
+	for key, symbol := range declared {
+		if used[key] || symbol.loc.URI == "" || symbol.method && interfaceMethod[symbol.name] {
+			continue
+		}
+		locs = append(locs, symbol.loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].URI != locs[j].URI {
+			return locs[i].URI < locs[j].URI
+		}
+		return locs[i].Range.Start.Line < locs[j].Range.Start.Line
+	})
+	return locs, nil
+}
+
+// unusedSymbol is an exported symbol declared by a main package.
+type unusedSymbol struct {
+	loc    lsp.Location
+	method bool
+	name   string
+}
+
+// symbolKey identifies the package level object or the method obj across
+// the variants of its package: "path.Name" or "(path.Type).Method".
+func symbolKey(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			typ := recv.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			if named, ok := typ.(*types.Named); ok {
+				return "(" + obj.Pkg().Path() + "." + named.Obj().Name() + ")." + obj.Name()
+			}
+		}
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// isSymbol reports whether obj is a package level object or a method, which
+// symbolKey identifies, and not eg. a struct field or a local of the same
+// name.
+func isSymbol(obj types.Object) bool {
+	if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+		return true
+	}
+	return obj.Parent() == obj.Pkg().Scope()
+}
+
+// interfaceMethods returns the names of the methods of obj if it is an
+// interface type.
+func interfaceMethods(obj types.Object) []string {
+	typeName, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	iface, ok := typeName.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for i := 0; i < iface.NumMethods(); i++ {
+		names = append(names, iface.Method(i).Name())
+	}
+	return names
+}
@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"context"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// registrationParams are the parameters of the client/registerCapability
+// request sent to the client.
+type registrationParams struct {
+	Registrations []registration `json:"registrations"`
+}
+
+type registration struct {
+	ID              string      `json:"id"`
+	Method          string      `json:"method"`
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
+
+type didChangeWatchedFilesRegistrationOptions struct {
+	Watchers []fileSystemWatcher `json:"watchers"`
+}
+
+type fileSystemWatcher struct {
+	GlobPattern string `json:"globPattern"`
+}
+
+// watchesFiles reports whether the client can watch the files of the
+// workspace for the server.
+func watchesFiles(capabilities ClientCapabilities) bool {
+	watched := capabilities.Workspace.DidChangeWatchedFiles
+	return watched != nil && watched.DynamicRegistration
+}
+
+// registerFileWatchers asks the client to notify the changes of the Go files
+// and of the go.mod files with workspace/didChangeWatchedFiles.
+func (h *LangHandler) registerFileWatchers(ctx context.Context, conn jsonrpc2.JSONRPC2) error {
+	return conn.Call(ctx, "client/registerCapability", &registrationParams{
+		Registrations: []registration{{
+			ID:     "bingo/didChangeWatchedFiles",
+			Method: "workspace/didChangeWatchedFiles",
+			RegisterOptions: &didChangeWatchedFilesRegistrationOptions{
+				Watchers: []fileSystemWatcher{{GlobPattern: "**/*.go"}, {GlobPattern: "**/go.mod"}},
+			},
+		}},
+	}, nil)
+}
+
+// handleDidChangeWatchedFiles updates the caches for the files created,
+// changed or deleted on disk. It is a notification, so nothing is returned.
+func (h *LangHandler) handleDidChangeWatchedFiles(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DidChangeWatchedFilesParams) {
+	for _, change := range params.Changes {
+		if checkFileURI(change.URI) != nil {
+			continue
+		}
+		h.project.DidChangeWatchedFile(util.UriToRealPath(change.URI))
+	}
+}
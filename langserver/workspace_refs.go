@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"math"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
@@ -66,7 +68,7 @@ func (h *LangHandler) handleWorkspaceReferences(ctx context.Context, conn jsonrp
 		return err
 	}
 
-	err := h.project.Search(f)
+	err := h.project.Search(ctx, f)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +79,12 @@ func (h *LangHandler) handleWorkspaceReferences(ctx context.Context, conn jsonrp
 		limit = math.MaxInt32
 	}
 
+	// The packages are searched concurrently, sort the references so that
+	// the results are stable.
 	r := results.results
+	sort.SliceStable(r, func(i, j int) bool {
+		return lessLocation(r[i].Reference, r[j].Reference)
+	})
 	if len(r) > limit {
 		r = r[:limit]
 	}
@@ -116,8 +123,8 @@ func (h *LangHandler) workspaceRefsFromPkg(ctx context.Context, conn jsonrpc2.JS
 			return
 		}
 
-		location := createLocationFromRange(pkg.GetFileSet(), r.Start, r.End)
-		results.results = append(results.results, referenceInformation{
+		location := h.overlay.columns.createLocationFromRange(pkg.GetFileSet(), r.Start, r.End)
+		results.add(referenceInformation{
 			Reference: location,
 			Symbol:    symDesc,
 		})
@@ -175,5 +182,13 @@ func defSymbolDescriptor(pkg source.Package, project *cache.Project, def refs.De
 
 // refResult is a utility struct for collecting workspace reference results.
 type refResult struct {
+	mu      sync.Mutex
 	results []referenceInformation
 }
+
+// add is a thread-safe method that records the reference.
+func (r *refResult) add(ref referenceInformation) {
+	r.mu.Lock()
+	r.results = append(r.results, ref)
+	r.mu.Unlock()
+}
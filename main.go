@@ -31,12 +31,44 @@ var (
 	// Default Config, can be overridden by InitializationOptions
 	maxparallelism       = flag.Int("maxparallelism", 0, "use at max N parallel goroutines to fulfill requests. Can be overridden by InitializationOptions.")
 	diagnosticsStyle     = flag.String("diagnostics-style", "instant", "diagnostics style: none, instant, onsave. Can be overridden by InitializationOptions.")
+	diagnosticsAnalyses  = flag.Bool("diagnostics-analyses", false, "publish the diagnostics of the vet analyzers along with the compiler errors. Can be overridden by InitializationOptions.")
+	ctxCheck             = flag.Bool("ctxcheck", false, "publish the diagnostics of the misuses of context.Context, along with the ones of --diagnostics-analyses, which it turns on. Can be overridden by InitializationOptions.")
+	noAnalysisDirectives = flag.String("no-analysis-directives", "", "the directives which suppress the analysis diagnostics of a file when a comment above its package clause starts with one of them, separated by commas, defaults to //lint:file-ignore,//bingo:noanalysis. Can be overridden by InitializationOptions.")
 	disableFuncSnippet   = flag.Bool("disable-func-snippet", false, "disable argument snippets on func completion. Can be overridden by InitializationOptions.")
-	globalCacheStyle     = flag.String("cache-style", "always", "set global cache style: none, on-demand, always. Can be overridden by InitializationOptions.")
+	globalCacheStyle     = flag.String("cache-style", "always", "set global cache style: none, on-demand, always, lazy. Can be overridden by InitializationOptions.")
 	formatStyle          = flag.String("format-style", "goimports", "which format style is used to format documents. Supported: gofmt and goimports. Can be overridden by InitializationOptions.")
 	goimportsPrefix      = flag.String("goimports-prefix", "", "set '--local' flag for the goimports invocation. Can be overridden by InitializationOptions.")
+	importsLocalPrefix   = flag.String("imports-local-prefix", "", "group imports into standard library, third-party and local blocks, local imports starting with one of these comma-separated prefixes. Can be overridden by InitializationOptions.")
 	enhanceSignatureHelp = flag.Bool("enhance-signature-help", false, "enhance signature help with return result. Can be overridden by InitializationOptions.")
 	buildTags            = flag.String("build-tags", "", "build tags, separated by spaces.")
+	buildFlags           = flag.String("build-flags", "", "other build flags passed to the go command when loading packages, separated by spaces, eg. -mod=vendor. Can be overridden by InitializationOptions.")
+	goos                 = flag.String("goos", "", "the target operating system the packages are loaded for, defaults to the host one. Can be overridden by InitializationOptions.")
+	goarch               = flag.String("goarch", "", "the target architecture the packages are loaded for, defaults to the host one. Can be overridden by InitializationOptions.")
+	hoverBitFlags        = flag.Bool("hover-bit-flags", false, "show the whole bit flag group with hex and binary values when hovering a `1 << iota` constant. Can be overridden by InitializationOptions.")
+	hoverSymbolFooter    = flag.Bool("hover-symbol-footer", false, "append the fully qualified symbol path and kind to every hover. Can be overridden by InitializationOptions.")
+	hoverMethodSet       = flag.Bool("hover-method-set", false, "list the method set of the receiver type when hovering a method. Can be overridden by InitializationOptions.")
+	hoverSkipUnexported  = flag.Bool("hover-skip-unexported", false, "return no hover for the unexported objects. Can be overridden by InitializationOptions.")
+	hoverSkipExternal    = flag.Bool("hover-skip-external", false, "return no hover for the objects defined in GOROOT or in the module cache. Can be overridden by InitializationOptions.")
+	hoverASTNode         = flag.Bool("hover-ast-node", false, "show the kind and the source of the AST node at the position when there is no other hover, for debugging. Can be overridden by InitializationOptions.")
+	printfFuncs          = flag.String("printf-funcs", "", "the full names of the printf-like functions, in addition to the ones of the standard library, whose format directives are resolved by hover and definition, separated by commas. Can be overridden by InitializationOptions.")
+	maxCachedPackages    = flag.Int("max-cached-packages", 0, "the maximum number of packages retained in the global cache, 0 means no limit. Can be overridden by InitializationOptions.")
+	loadBatchSize        = flag.Int("load-batch-size", 0, "load the packages of each module in batches of at most N packages, the imported packages first, to bound the peak memory, 0 means all at once. Can be overridden by InitializationOptions.")
+	implDirection        = flag.String("implementation-direction", "both", "which implementations are returned: to (the types implementing an interface), from (the interfaces satisfied by a type) or both. Can be overridden by InitializationOptions.")
+	implStdlib           = flag.Bool("implementation-stdlib", false, "include the standard library packages imported by the project when searching implementations. Can be overridden by InitializationOptions.")
+	implMethods          = flag.Bool("implementation-methods", false, "return the methods of the implementations satisfying an interface instead of their types when searching the implementations of an interface type. Can be overridden by InitializationOptions.")
+	typeDefFullRange     = flag.Bool("type-definition-full-range", false, "make the locations of textDocument/typeDefinition cover the whole type declaration instead of its name. Can be overridden by InitializationOptions.")
+	excludeInternal      = flag.Bool("exclude-internal-packages", false, "skip the packages under an internal directory when searching workspace symbols and references. Can be overridden by InitializationOptions.")
+	excludeDirs          = flag.String("exclude-dirs", "", "the names of the directories skipped when walking the workspace to find the go.mod files, separated by commas, eg. testdata. Can be overridden by InitializationOptions.")
+	maxWalkDepth         = flag.Int("max-walk-depth", 8, "the maximum depth of the directories walked to find the go.mod files of the workspace. Can be overridden by InitializationOptions.")
+	referencesTests      = flag.String("references-tests", "include", "which references in test files are returned: include, exclude or only. Can be overridden by InitializationOptions.")
+	refsFollowAliases    = flag.Bool("references-follow-aliases", false, "unify the references to a type and to its aliases, eg. type B = A. Can be overridden by InitializationOptions.")
+	sortRefsByProximity  = flag.Bool("sort-references-by-proximity", false, "sort references by proximity to the requested document instead of by position. Can be overridden by InitializationOptions.")
+	symbolEmbeddedFields = flag.Bool("symbol-embedded-fields", false, "include the embedded struct fields, named after their type, in the document and workspace symbols. Can be overridden by InitializationOptions.")
+	symbolMainOnly       = flag.Bool("symbol-main-modules-only", false, "restrict the workspace symbols to the packages of the main modules, a query can override it with is:all. Can be overridden by InitializationOptions.")
+	symbolMaxResults     = flag.Int("symbol-max-results", 1000, "the maximum number of workspace symbols returned, even if a request asks for more, 0 means no limit. Can be overridden by InitializationOptions.")
+	requestTimeout       = flag.Duration("request-timeout", 0, "the maximum duration of a request, eg. 10s, after which the references and the workspace symbols found so far are returned and the other requests fail, 0 means no timeout. Can be overridden by InitializationOptions.")
+	logLevel             = flag.String("log-level", "info", "the verbosity of the messages logged to the client: error, info, debug or trace. Can be overridden by InitializationOptions.")
+	godocURL             = flag.String("godoc-url", "", "the base URL the import paths are linked to, eg. https://pkg.go.dev, defaults to the directories of the packages. Can be overridden by InitializationOptions.")
 
 	// Compatible with sourcegraph/go-langserver, ensuring that ide-go can run, but no actual effect
 	// https://github.com/saibing/bingo/issues/163
@@ -71,15 +103,59 @@ func main() {
 	cfg := langserver.NewDefaultConfig()
 	cfg.DisableFuncSnippet = *disableFuncSnippet
 	cfg.DiagnosticsStyle = *diagnosticsStyle
+	cfg.DiagnosticsAnalyses = *diagnosticsAnalyses
+	cfg.CtxCheck = *ctxCheck
 	cfg.GlobalCacheStyle = *globalCacheStyle
 	cfg.FormatStyle = *formatStyle
 	cfg.GoimportsLocalPrefix = *goimportsPrefix
+	cfg.ImportsLocalPrefix = *importsLocalPrefix
 	cfg.EnhanceSignatureHelp = *enhanceSignatureHelp
+	cfg.GOOS = *goos
+	cfg.GOARCH = *goarch
+	cfg.SortReferencesByProximity = *sortRefsByProximity
+	cfg.ReferencesTests = *referencesTests
+	cfg.ReferencesFollowAliases = *refsFollowAliases
+	cfg.HoverBitFlags = *hoverBitFlags
+	cfg.HoverSymbolFooter = *hoverSymbolFooter
+	cfg.HoverMethodSet = *hoverMethodSet
+	cfg.HoverSkipUnexported = *hoverSkipUnexported
+	cfg.HoverSkipExternal = *hoverSkipExternal
+	cfg.HoverASTNode = *hoverASTNode
+	cfg.MaxCachedPackages = *maxCachedPackages
+	cfg.LoadBatchSize = *loadBatchSize
+	cfg.ExcludeInternalPackages = *excludeInternal
+	cfg.MaxWalkDepth = *maxWalkDepth
+	cfg.ImplementationDirection = *implDirection
+	cfg.ImplementationStdlib = *implStdlib
+	cfg.ImplementationMethods = *implMethods
+	cfg.TypeDefinitionFullRange = *typeDefFullRange
+	cfg.GodocURL = *godocURL
+	cfg.SymbolEmbeddedFields = *symbolEmbeddedFields
+	cfg.SymbolMaxResults = *symbolMaxResults
+	cfg.SymbolMainModulesOnly = *symbolMainOnly
+	cfg.RequestTimeout = *requestTimeout
+	cfg.LogLevel = *logLevel
 
 	if *buildTags != "" {
 		cfg.BuildTags = strings.Split(*buildTags, " ")
 	}
 
+	if *buildFlags != "" {
+		cfg.BuildFlags = strings.Fields(*buildFlags)
+	}
+
+	if *excludeDirs != "" {
+		cfg.ExcludeDirs = strings.Split(*excludeDirs, ",")
+	}
+
+	if *noAnalysisDirectives != "" {
+		cfg.NoAnalysisDirectives = strings.Split(*noAnalysisDirectives, ",")
+	}
+
+	if *printfFuncs != "" {
+		cfg.PrintfFuncs = strings.Split(*printfFuncs, ",")
+	}
+
 	if *maxparallelism > 0 {
 		cfg.MaxParallelism = *maxparallelism
 	}